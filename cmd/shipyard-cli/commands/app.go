@@ -153,8 +153,8 @@ func appRestartCommand(apiClient *client.Client) {
 		log.Fatalf("❌ Cannot restart: App has no active instance (active_port not set). Please deploy first.")
 	}
 
-	activePort := int(instanceInfo.Instance.ActivePort)
-	log.Printf("Current active port: %d", activePort)
+	ports := instancePorts(instanceInfo)
+	log.Printf("Current active port(s): %s", joinPorts(ports))
 
 	// Connect to remote host
 	sshClient, err := connectToHostCLI(host)
@@ -163,33 +163,36 @@ func appRestartCommand(apiClient *client.Client) {
 	}
 	defer sshClient.Close()
 
-	// Step 1: Stop the service
-	log.Printf("--- Stopping service (Port %d) ---", activePort)
-	stopCmd := fmt.Sprintf("systemctl stop %s@%d", appName, activePort)
-	if _, err := executeRemoteCommandCLI(sshClient, stopCmd); err != nil {
-		log.Fatalf("❌ Failed to stop service: %v", err)
-	}
-	log.Printf("✅ Service stopped")
+	// Restart the instances one at a time, the others keep serving traffic behind Caddy
+	for _, activePort := range ports {
+		// Step 1: Stop the service
+		log.Printf("--- Stopping service (Port %d) ---", activePort)
+		stopCmd := fmt.Sprintf("systemctl stop %s@%d", appName, activePort)
+		if _, err := executeRemoteCommandCLI(sshClient, stopCmd); err != nil {
+			log.Fatalf("❌ Failed to stop service: %v", err)
+		}
+		log.Printf("✅ Service stopped")
 
-	// Brief pause to ensure service is fully stopped
-	time.Sleep(1 * time.Second)
+		// Brief pause to ensure service is fully stopped
+		time.Sleep(1 * time.Second)
 
-	// Step 2: Start the service
-	log.Printf("--- Starting service (Port %d) ---", activePort)
-	startCmd := fmt.Sprintf("systemctl start %s@%d", appName, activePort)
-	if _, err := executeRemoteCommandCLI(sshClient, startCmd); err != nil {
-		log.Fatalf("❌ Failed to start service: %v", err)
-	}
+		// Step 2: Start the service
+		log.Printf("--- Starting service (Port %d) ---", activePort)
+		startCmd := fmt.Sprintf("systemctl start %s@%d", appName, activePort)
+		if _, err := executeRemoteCommandCLI(sshClient, startCmd); err != nil {
+			log.Fatalf("❌ Failed to start service: %v", err)
+		}
 
-	// Step 3: Health check
-	log.Println("--- Executing health check ---")
-	if err := healthCheckWithRetryCLI(sshClient, appName, activePort, cliHealthCheckMaxRetries); err != nil {
-		log.Printf("❌ Health check failed: %v", err)
-		log.Println("⚠️ Service may not have started correctly, please check logs")
-		os.Exit(1)
+		// Step 3: Health check
+		log.Println("--- Executing health check ---")
+		if err := healthCheckWithRetryCLI(sshClient, appName, activePort, cliHealthCheckMaxRetries); err != nil {
+			log.Printf("❌ Health check failed: %v", err)
+			log.Println("⚠️ Service may not have started correctly, please check logs")
+			os.Exit(1)
+		}
 	}
 
-	log.Printf("✅ App '%s' restarted successfully (Host: %s, Port: %d)", appName, hostName, activePort)
+	log.Printf("✅ App '%s' restarted successfully (Host: %s, Port: %s)", appName, hostName, joinPorts(ports))
 }
 
 // appStopCommand handles the 'app stop' command
@@ -212,8 +215,8 @@ func appStopCommand(apiClient *client.Client) {
 		return
 	}
 
-	activePort := int(instanceInfo.Instance.ActivePort)
-	log.Printf("Current active port: %d", activePort)
+	ports := instancePorts(instanceInfo)
+	log.Printf("Current active port(s): %s", joinPorts(ports))
 
 	// Connect to remote host
 	sshClient, err := connectToHostCLI(host)
@@ -222,13 +225,15 @@ func appStopCommand(apiClient *client.Client) {
 	}
 	defer sshClient.Close()
 
-	// Stop the service
-	stopCmd := fmt.Sprintf("systemctl stop %s@%d", appName, activePort)
-	if _, err := executeRemoteCommandCLI(sshClient, stopCmd); err != nil {
-		log.Fatalf("❌ Failed to stop service: %v", err)
+	// Stop the service of every instance
+	for _, activePort := range ports {
+		stopCmd := fmt.Sprintf("systemctl stop %s@%d", appName, activePort)
+		if _, err := executeRemoteCommandCLI(sshClient, stopCmd); err != nil {
+			log.Fatalf("❌ Failed to stop service: %v", err)
+		}
 	}
 
-	log.Printf("✅ App '%s' stopped successfully (Host: %s, Port: %d)", appName, hostName, activePort)
+	log.Printf("✅ App '%s' stopped successfully (Host: %s, Port: %s)", appName, hostName, joinPorts(ports))
 }
 
 // appStatusCommand handles the 'app status' command
@@ -252,8 +257,8 @@ func appStatusCommand(apiClient *client.Client) {
 		return
 	}
 
-	activePort := int(instanceInfo.Instance.ActivePort)
-	fmt.Printf("Active Port: %d\n", activePort)
+	ports := instancePorts(instanceInfo)
+	fmt.Printf("Active Port: %s\n", joinPorts(ports))
 
 	// Connect to remote host to check systemd status
 	sshClient, err := connectToHostCLI(host)
@@ -263,21 +268,27 @@ func appStatusCommand(apiClient *client.Client) {
 	}
 	defer sshClient.Close()
 
-	// Check systemd service status
-	statusCmd := fmt.Sprintf("systemctl is-active %s@%d", appName, activePort)
-	output, statusErr := executeRemoteCommandCLI(sshClient, statusCmd)
-	output = strings.TrimSpace(output)
-
-	if statusErr != nil {
-		if strings.Contains(output, "inactive") {
-			fmt.Printf("Service Status: ⏹️  Stopped (inactive)\n")
-		} else if strings.Contains(output, "failed") {
-			fmt.Printf("Service Status: ❌ Failed (failed)\n")
+	// Check systemd service status of every instance
+	for _, activePort := range ports {
+		label := "Service Status"
+		if len(ports) > 1 {
+			label = fmt.Sprintf("Service Status (Port %d)", activePort)
+		}
+		statusCmd := fmt.Sprintf("systemctl is-active %s@%d", appName, activePort)
+		output, statusErr := executeRemoteCommandCLI(sshClient, statusCmd)
+		output = strings.TrimSpace(output)
+
+		if statusErr != nil {
+			if strings.Contains(output, "inactive") {
+				fmt.Printf("%s: ⏹️  Stopped (inactive)\n", label)
+			} else if strings.Contains(output, "failed") {
+				fmt.Printf("%s: ❌ Failed (failed)\n", label)
+			} else {
+				fmt.Printf("%s: ⚠️  Unknown (%s)\n", label, output)
+			}
 		} else {
-			fmt.Printf("Service Status: ⚠️  Unknown (%s)\n", output)
+			fmt.Printf("%s: ✅ Running (active)\n", label)
 		}
-	} else {
-		fmt.Printf("Service Status: ✅ Running (active)\n")
 	}
 
 	// Show previous active port if available (for rollback info)
@@ -1,102 +1,376 @@
-package commands
-
-import (
-	"youfun/shipyard/internal/client"
-	"youfun/shipyard/internal/config"
-	"flag"
-	"fmt"
-	"log"
-	"os"
-
-	"github.com/BurntSushi/toml"
-)
-
-// BuildCommand handles the 'build' command with subcommands
-func BuildCommand(apiClient *client.Client) {
-	if len(os.Args) < 3 {
-		printBuildUsage()
-		os.Exit(1)
-	}
-
-	subCommand := os.Args[2]
-	switch subCommand {
-	case "list":
-		buildListCommand(apiClient)
-	case "help", "--help", "-h":
-		printBuildUsage()
-	default:
-		fmt.Printf("Unknown build subcommand: %s\n", subCommand)
-		printBuildUsage()
-		os.Exit(1)
-	}
-}
-
-func printBuildUsage() {
-	fmt.Print(`
-Usage: shipyard-cli build <subcommand> [options]
-
-Subcommands:
-  list        List build artifacts for an application
-
-Options:
-  --app       Application name (optional, defaults to shipyard.toml)
-
-Example:
-  shipyard-cli build list
-  shipyard-cli build list --app my-app
-`)
-}
-
-// buildListCommand handles the 'build list' command
-func buildListCommand(apiClient *client.Client) {
-	cmd := flag.NewFlagSet("build list", flag.ExitOnError)
-	appFlag := cmd.String("app", "", "Application name (optional)")
-	cmd.Parse(os.Args[3:])
-
-	// Resolve app name
-	appName := *appFlag
-	if appName == "" {
-		var projConf config.Config
-		if _, err := toml.DecodeFile(config.ConfigPath, &projConf); err == nil {
-			appName = projConf.App
-		}
-		if appName == "" {
-			log.Fatalf("❌ Could not determine application name. Please use --app or run in project directory (shipyard.toml required)")
-		}
-	}
-
-	log.Printf("--- Build Artifacts for app '%s' ---", appName)
-
-	artifacts, err := apiClient.ListBuildArtifacts(appName)
-	if err != nil {
-		log.Fatalf("❌ Failed to list build artifacts: %v", err)
-	}
-
-	if len(artifacts) == 0 {
-		fmt.Println("No build artifacts found.")
-		return
-	}
-
-	// Print header
-	fmt.Printf("\n%-16s %-12s %-40s %-20s\n", "VERSION", "MD5 (short)", "GIT COMMIT SHA", "CREATED AT")
-	fmt.Println("--------------------------------------------------------------------------------")
-
-	// Print artifacts
-	for _, artifact := range artifacts {
-		md5Short := artifact.MD5Hash
-		if len(md5Short) > 10 {
-			md5Short = md5Short[:10]
-		}
-		gitSHA := artifact.GitCommitSHA
-		if len(gitSHA) > 40 {
-			gitSHA = gitSHA[:40]
-		}
-		createdAt := ""
-		if artifact.CreatedAt != nil {
-			createdAt = artifact.CreatedAt.Format("2006-01-02 15:04:05")
-		}
-		fmt.Printf("%-16s %-12s %-40s %-20s\n", artifact.Version, md5Short, gitSHA, createdAt)
-	}
-
-	fmt.Printf("\nTotal: %d build(s)\n", len(artifacts))
-}
+package commands
+
+import (
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/deploy"
+	"youfun/shipyard/pkg/types"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// BuildCommand handles the 'build' command with subcommands
+func BuildCommand(apiClient *client.Client) {
+	if len(os.Args) < 3 {
+		printBuildUsage()
+		os.Exit(1)
+	}
+
+	subCommand := os.Args[2]
+	switch subCommand {
+	case "list":
+		buildListCommand(apiClient)
+	case "push":
+		buildPushCommand(apiClient)
+	case "pull":
+		buildPullCommand(apiClient)
+	case "prune":
+		buildPruneCommand(apiClient)
+	case "inspect":
+		buildInspectCommand(apiClient)
+	case "help", "--help", "-h":
+		printBuildUsage()
+	default:
+		fmt.Printf("Unknown build subcommand: %s\n", subCommand)
+		printBuildUsage()
+		os.Exit(1)
+	}
+}
+
+func printBuildUsage() {
+	fmt.Print(`
+Usage: shipyard-cli build <subcommand> [options]
+
+Deploys push every new build to the artifact registry of shipyard-server, so that teammates and
+CI jobs reuse it. The server signs every build it stores; only signed builds are deployed.
+Builds are identified by MD5 (or a prefix of it), Git commit SHA or version.
+
+Subcommands:
+  list        List build artifacts for an application
+  push        Push a build made on this machine to the registry, which signs it
+  pull        Pull a build from the registry into the local build cache
+  prune       Delete builds from the local build cache, with their build history
+  inspect     Show the files, size and deployments of a build
+
+Options:
+  --app       Application name (optional, defaults to shipyard.toml)
+  --all       Prune the builds of every application in the cache (prune only)
+  --keep      Newest builds kept per application (prune only, default: keep_artifacts)
+  --keep-days Keep builds deployed in the last N days (prune only)
+  --max-size  Prune the oldest builds until the cache fits, e.g. 5GB (prune only)
+  --dry-run   Show what would be deleted without deleting it (prune only)
+
+Example:
+  shipyard-cli build list
+  shipyard-cli build list --app my-app
+  shipyard-cli build push 1.4.2
+  shipyard-cli build pull a1b2c3d4
+  shipyard-cli build prune --keep 3 --keep-days 30
+  shipyard-cli build prune --all --max-size 5GB --dry-run
+  shipyard-cli build inspect a1b2c3d4
+`)
+}
+
+// buildListCommand handles the 'build list' command
+func buildListCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("build list", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	cmd.Parse(os.Args[3:])
+
+	appName := resolveBuildAppName(*appFlag)
+
+	log.Printf("--- Build Artifacts for app '%s' ---", appName)
+
+	artifacts, err := apiClient.ListBuildArtifacts(appName)
+	if err != nil {
+		log.Fatalf("❌ Failed to list build artifacts: %v", err)
+	}
+
+	if len(artifacts) == 0 {
+		fmt.Println("No build artifacts found.")
+		return
+	}
+
+	// Print header
+	fmt.Printf("\n%-16s %-12s %-40s %-20s %-9s %s\n", "VERSION", "MD5 (short)", "GIT COMMIT SHA", "CREATED AT", "REGISTRY", "SIGNED BY")
+	fmt.Println("------------------------------------------------------------------------------------------------------------")
+
+	// Print artifacts
+	for _, artifact := range artifacts {
+		md5Short := artifact.MD5Hash
+		if len(md5Short) > 10 {
+			md5Short = md5Short[:10]
+		}
+		gitSHA := artifact.GitCommitSHA
+		if len(gitSHA) > 40 {
+			gitSHA = gitSHA[:40]
+		}
+		createdAt := ""
+		if artifact.CreatedAt != nil {
+			createdAt = artifact.CreatedAt.Format("2006-01-02 15:04:05")
+		}
+		registry := "no"
+		if artifact.Stored {
+			registry = "yes"
+		}
+		signedBy := "-"
+		if artifact.Signature != "" {
+			signedBy = fmt.Sprintf("%s (%s)", artifact.SignedBy, artifact.SigningKey)
+		}
+		fmt.Printf("%-16s %-12s %-40s %-20s %-9s %s\n", artifact.Version, md5Short, gitSHA, createdAt, registry, signedBy)
+	}
+
+	fmt.Printf("\nTotal: %d build(s)\n", len(artifacts))
+}
+
+// buildPushCommand handles the 'build push' command
+func buildPushCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("build push", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	cmd.Usage = printBuildUsage
+	cmd.Parse(os.Args[3:])
+	if cmd.NArg() != 1 {
+		log.Fatalf("❌ Usage: shipyard-cli build push <md5|git-sha|version> [--app <name>]")
+	}
+
+	appName := resolveBuildAppName(*appFlag)
+	artifact := findBuild(apiClient, appName, cmd.Arg(0))
+	if artifact.Stored && artifact.Signature != "" {
+		fmt.Printf("Build %s (Version: %s) is already in the registry.\n", artifact.MD5Hash, artifact.Version)
+		return
+	}
+
+	keep := 0
+	var projConf config.Config
+	if _, err := toml.DecodeFile(config.ConfigPath, &projConf); err == nil && projConf.App == appName {
+		keep = projConf.Build.KeepArtifacts
+	}
+	pushed, err := deploy.PushBuild(apiClient, appName, artifact, keep)
+	if err != nil {
+		log.Fatalf("❌ Failed to push build: %v", err)
+	}
+	fmt.Printf("✅ Build %s (Version: %s) pushed to the registry, signed with key '%s'.\n", pushed.MD5Hash, pushed.Version, pushed.SigningKey)
+}
+
+// buildPullCommand handles the 'build pull' command
+func buildPullCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("build pull", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	cmd.Usage = printBuildUsage
+	cmd.Parse(os.Args[3:])
+	if cmd.NArg() != 1 {
+		log.Fatalf("❌ Usage: shipyard-cli build pull <md5|git-sha|version> [--app <name>]")
+	}
+
+	appName := resolveBuildAppName(*appFlag)
+	artifact := findBuild(apiClient, appName, cmd.Arg(0))
+	tarballPath, err := deploy.PullBuild(apiClient, appName, artifact)
+	if err != nil {
+		log.Fatalf("❌ Failed to pull build: %v", err)
+	}
+	fmt.Printf("✅ Build %s (Version: %s) pulled to %s\n", artifact.MD5Hash, artifact.Version, tarballPath)
+}
+
+// buildPruneCommand handles the 'build prune' command
+func buildPruneCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("build prune", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	allFlag := cmd.Bool("all", false, "Prune the builds of every application in the cache")
+	keepFlag := cmd.Int("keep", -1, "Newest builds kept per application (default: keep_artifacts)")
+	keepDaysFlag := cmd.Int("keep-days", 0, "Keep builds deployed in the last N days")
+	maxSizeFlag := cmd.String("max-size", "", "Prune the oldest builds until the cache fits, e.g. 5GB")
+	dryRunFlag := cmd.Bool("dry-run", false, "Show what would be deleted without deleting it")
+	cmd.Usage = printBuildUsage
+	cmd.Parse(os.Args[3:])
+
+	appName := ""
+	if !*allFlag {
+		appName = resolveBuildAppName(*appFlag)
+	}
+	policy := deploy.PrunePolicy{Keep: *keepFlag, KeepDays: *keepDaysFlag}
+	if policy.Keep < 0 {
+		policy.Keep = config.DefaultKeepArtifacts
+		// Use the retention of the project when running in its directory
+		if cfg, err := config.ReadConfigFile(config.ConfigPath); err == nil && (appName == "" || cfg.App == appName) && cfg.Build.KeepArtifacts > 0 {
+			policy.Keep = cfg.Build.KeepArtifacts
+		}
+	}
+	if policy.Keep < 1 {
+		log.Fatalf("❌ --keep must be at least 1, the newest build of an app is always kept")
+	}
+	if policy.KeepDays < 0 {
+		log.Fatalf("❌ --keep-days must not be negative")
+	}
+	if *maxSizeFlag != "" {
+		maxSize, err := deploy.ParseSize(*maxSizeFlag)
+		if err != nil {
+			log.Fatalf("❌ --max-size: %v", err)
+		}
+		policy.MaxSize = maxSize
+	}
+
+	result, err := deploy.PruneBuilds(apiClient, appName, policy, *dryRunFlag)
+	if err != nil {
+		log.Fatalf("❌ Failed to prune builds: %v", err)
+	}
+
+	var freed int64
+	for _, build := range result.Pruned {
+		freed += build.Size
+		version := "unknown version"
+		if build.Artifact != nil {
+			version = build.Artifact.Version
+		}
+		if result.DryRun {
+			log.Printf("   Would delete %s %s (%s, %s): %s", build.AppName, version, build.MD5Hash[:10], deploy.FormatBytes(build.Size), build.Reason)
+		} else {
+			log.Printf("🧹 Deleted %s %s (%s, %s): %s", build.AppName, version, build.MD5Hash[:10], deploy.FormatBytes(build.Size), build.Reason)
+		}
+	}
+	switch {
+	case len(result.Pruned) == 0:
+		log.Printf("✅ Nothing to prune, the build cache holds %s", deploy.FormatBytes(result.CacheSize))
+	case result.DryRun:
+		log.Printf("Dry run: %d build(s) would be deleted, freeing %s", len(result.Pruned), deploy.FormatBytes(freed))
+	default:
+		log.Printf("✅ Pruned %d build(s), freed %s; the build cache holds %s", len(result.Pruned), deploy.FormatBytes(freed), deploy.FormatBytes(result.CacheSize))
+	}
+	if policy.MaxSize > 0 && result.CacheSize > policy.MaxSize {
+		log.Printf("⚠️ The build cache stays over %s: the remaining builds are the newest of their app or were deployed in the last %d day(s)", deploy.FormatBytes(policy.MaxSize), policy.KeepDays)
+	}
+}
+
+// buildInspectCommand handles the 'build inspect' command
+func buildInspectCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("build inspect", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	cmd.Usage = printBuildUsage
+
+	// The build may come before or after the flags
+	args := os.Args[3:]
+	var query string
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		query, args = args[0], args[1:]
+	}
+	cmd.Parse(args)
+	if query == "" {
+		query = cmd.Arg(0)
+	}
+	if query == "" {
+		log.Fatalf("❌ Usage: shipyard-cli build inspect <md5|git-sha|version> [--app <name>]")
+	}
+
+	appName := resolveBuildAppName(*appFlag)
+	artifact := findBuild(apiClient, appName, query)
+	deployments, err := apiClient.ListBuildDeployments(appName, artifact.MD5Hash)
+	if err != nil {
+		log.Fatalf("❌ Failed to list deployments of build %s: %v", artifact.MD5Hash, err)
+	}
+
+	fmt.Printf("Build:        %s\n", artifact.MD5Hash)
+	fmt.Printf("Version:      %s\n", artifact.Version)
+	if artifact.GitCommitSHA != "" {
+		fmt.Printf("Git commit:   %s\n", artifact.GitCommitSHA)
+	}
+	if artifact.CreatedAt != nil {
+		fmt.Printf("Created at:   %s\n", artifact.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf("Compression:  %s\n", artifact.Compression)
+	registry := "no"
+	if artifact.Stored {
+		registry = "yes"
+	}
+	fmt.Printf("Registry:     %s\n", registry)
+	if artifact.Signature != "" {
+		fmt.Printf("Signed by:    %s (%s)\n", artifact.SignedBy, artifact.SigningKey)
+	}
+
+	tarballPath, err := deploy.LocateBuild(apiClient, appName, artifact)
+	if err != nil {
+		fmt.Printf("Files:        unavailable (%v)\n", err)
+	} else if manifest, err := deploy.ReadTarballManifest(tarballPath, artifact.Compression); err != nil {
+		fmt.Printf("Files:        unavailable (%v)\n", err)
+	} else {
+		var size, total int64
+		if info, err := os.Stat(tarballPath); err == nil {
+			size = info.Size()
+		}
+		for _, entry := range manifest {
+			total += entry.Size
+		}
+		fmt.Printf("Tarball:      %s (%s)\n", tarballPath, deploy.FormatBytes(size))
+		fmt.Printf("Files:        %d (%s uncompressed)\n", len(manifest), deploy.FormatBytes(total))
+
+		fmt.Printf("\n%-6s %-10s %s\n", "MODE", "SIZE", "PATH")
+		for _, entry := range manifest {
+			fmt.Printf("%04o   %-10s %s\n", entry.Mode, deploy.FormatBytes(entry.Size), entry.Path)
+		}
+	}
+
+	if len(deployments) == 0 {
+		fmt.Println("\nNever deployed.")
+		return
+	}
+	fmt.Printf("\n%-28s %-16s %-20s %-10s %s\n", "DEPLOYMENT", "VERSION", "HOST", "STATUS", "CREATED AT")
+	for _, deployment := range deployments {
+		createdAt := ""
+		if deployment.CreatedAt != nil {
+			createdAt = deployment.CreatedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-28s %-16s %-20s %-10s %s\n", deployment.UID, deployment.Version, deployment.HostName, deployment.Status, createdAt)
+	}
+	fmt.Printf("\nDeployed %d time(s)\n", len(deployments))
+}
+
+// resolveBuildAppName returns the app of --app, or the one of shipyard.toml.
+func resolveBuildAppName(appFlag string) string {
+	appName := appFlag
+	if appName == "" {
+		var projConf config.Config
+		if _, err := toml.DecodeFile(config.ConfigPath, &projConf); err == nil {
+			appName = projConf.App
+		}
+		if appName == "" {
+			log.Fatalf("❌ Could not determine application name. Please use --app or run in project directory (shipyard.toml required)")
+		}
+	}
+	return appName
+}
+
+// findBuild looks up a build of an app by MD5 prefix, then Git commit SHA, then version (newest first).
+func findBuild(apiClient *client.Client, appName, query string) *types.BuildArtifactDTO {
+	artifacts, err := apiClient.ListBuildArtifacts(appName)
+	if err != nil {
+		log.Fatalf("❌ Failed to list build artifacts: %v", err)
+	}
+
+	var byMD5 []types.BuildArtifactDTO
+	for _, artifact := range artifacts {
+		if strings.HasPrefix(artifact.MD5Hash, query) {
+			byMD5 = append(byMD5, artifact)
+		}
+	}
+	if len(byMD5) > 1 {
+		log.Fatalf("❌ Multiple builds match MD5 prefix '%s', please use a longer prefix", query)
+	}
+	if len(byMD5) == 1 {
+		return &byMD5[0]
+	}
+	for i := range artifacts {
+		if artifacts[i].GitCommitSHA == query {
+			return &artifacts[i]
+		}
+	}
+	for i := range artifacts {
+		if artifacts[i].Version == query {
+			return &artifacts[i]
+		}
+	}
+	log.Fatalf("❌ No build of '%s' matches '%s'", appName, query)
+	return nil
+}
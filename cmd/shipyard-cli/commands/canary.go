@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/pkg/types"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// CanaryCommand handles the 'canary' command with subcommands
+func CanaryCommand(apiClient *client.Client) {
+	if len(os.Args) < 3 {
+		printCanaryUsage()
+		os.Exit(1)
+	}
+
+	subCommand := os.Args[2]
+	switch subCommand {
+	case "promote":
+		canaryPromoteCommand(apiClient)
+	case "abort":
+		canaryAbortCommand(apiClient)
+	case "help", "--help", "-h":
+		printCanaryUsage()
+	default:
+		fmt.Printf("Unknown canary subcommand: %s\n", subCommand)
+		printCanaryUsage()
+		os.Exit(1)
+	}
+}
+
+func printCanaryUsage() {
+	fmt.Print(`
+Usage: shipyard-cli canary <subcommand> [options]
+
+A canary is started with 'shipyard-cli deploy --canary <percent>': the new version receives
+that share of the traffic while the current version keeps serving the rest.
+
+Subcommands:
+  promote     Send more traffic to the canary; at 100% it replaces the current version
+  abort       Send all traffic back to the current version and stop the canary
+
+Options:
+  --app       Application name (optional, defaults to shipyard.toml)
+  --host      Host name (optional, defaults to interactive selection)
+  --weight    Percentage of traffic for the canary (promote only, default: next step of 10, 25, 50, 100)
+
+Example:
+  shipyard-cli deploy --host prod --canary 10
+  shipyard-cli canary promote --host prod
+  shipyard-cli canary promote --host prod --weight 100
+  shipyard-cli canary abort --host prod
+`)
+}
+
+// canaryPromoteCommand handles the 'canary promote' command
+func canaryPromoteCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("canary promote", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	weightFlag := cmd.Int("weight", 0, "Percentage of traffic for the canary (default: next step)")
+	cmd.Usage = printCanaryUsage
+	cmd.Parse(os.Args[3:])
+
+	if *weightFlag < 0 || *weightFlag > 100 {
+		log.Fatalf("❌ --weight must be between 1 and 100")
+	}
+
+	appName, hostName, instanceInfo, _, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	canary, err := apiClient.PromoteCanary(instanceInfo.Instance.UID, *weightFlag)
+	if err != nil {
+		log.Fatalf("❌ Failed to promote canary of app '%s' on %s: %v", appName, hostName, err)
+	}
+
+	if canary.Status == "promoted" {
+		log.Printf("✅ Canary %s of app '%s' (Host: %s) now serves all traffic on port %d", canary.DeploymentID, appName, hostName, canary.CanaryPort)
+		log.Printf("   Previous version (:%d) stopped and kept as standby for 'shipyard-cli rollback'.", canary.StablePort)
+		return
+	}
+	log.Printf("🐤 Canary %s of app '%s' (Host: %s) promoted", canary.DeploymentID, appName, hostName)
+	printCanaryWeights(canary)
+}
+
+// canaryAbortCommand handles the 'canary abort' command
+func canaryAbortCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("canary abort", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	cmd.Usage = printCanaryUsage
+	cmd.Parse(os.Args[3:])
+
+	appName, hostName, instanceInfo, _, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	canary, err := apiClient.AbortCanary(instanceInfo.Instance.UID)
+	if err != nil {
+		log.Fatalf("❌ Failed to abort canary of app '%s' on %s: %v", appName, hostName, err)
+	}
+
+	log.Printf("⏪ Canary %s of app '%s' (Host: %s) aborted", canary.DeploymentID, appName, hostName)
+	log.Printf("   All traffic is back on port %d, canary version (:%d) stopped.", canary.StablePort, canary.CanaryPort)
+}
+
+// printCanaryWeights shows how traffic is split between the stable version and the canary
+func printCanaryWeights(canary *types.CanaryDTO) {
+	log.Printf("   Stable: port %d, %d%% of traffic", canary.StablePort, canary.StableWeight)
+	log.Printf("   Canary: port %d, %d%% of traffic", canary.CanaryPort, canary.CanaryWeight)
+}
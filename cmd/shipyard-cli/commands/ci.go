@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"os"
+	"youfun/shipyard/internal/cliutils"
+	"youfun/shipyard/internal/deploy"
+)
+
+// deployExitCodes are the exit codes of the failure classes of a deployment in CI mode
+var deployExitCodes = map[string]int{
+	deploy.FailureConfig:  cliutils.ExitConfig,
+	deploy.FailureAuth:    cliutils.ExitAuth,
+	deploy.FailureBuild:   cliutils.ExitBuild,
+	deploy.FailureUpload:  cliutils.ExitUpload,
+	deploy.FailureHealth:  cliutils.ExitHealth,
+	deploy.FailureTraffic: cliutils.ExitTraffic,
+}
+
+// deployExitCode returns the exit code of a failed deployment: the one of its failure class in CI mode, 1 otherwise.
+func deployExitCode(err error) int {
+	code, ok := deployExitCodes[deploy.FailureClass(err)]
+	if !ok || !cliutils.IsCI() {
+		return cliutils.ExitFailure
+	}
+	return code
+}
+
+// exitDeployFailed exits after a failed deployment, the deployment has already logged the error.
+func exitDeployFailed(err error) {
+	os.Exit(deployExitCode(err))
+}
@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/deploy"
+	"youfun/shipyard/internal/models"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// CronCommand handles the 'cron' command with subcommands
+func CronCommand(apiClient *client.Client) {
+	if len(os.Args) < 3 {
+		printCronUsage()
+		os.Exit(1)
+	}
+
+	subCommand := os.Args[2]
+	switch subCommand {
+	case "list":
+		cronListCommand(apiClient)
+	case "run":
+		cronRunCommand(apiClient)
+	case "history":
+		cronHistoryCommand(apiClient)
+	case "help", "--help", "-h":
+		printCronUsage()
+	default:
+		fmt.Printf("Unknown cron subcommand: %s\n", subCommand)
+		printCronUsage()
+		os.Exit(1)
+	}
+}
+
+func printCronUsage() {
+	fmt.Print(`
+Usage: shipyard-cli cron <subcommand> [options]
+
+Every deployment schedules the [[cron]] jobs of shipyard.toml as systemd timers on the host. The jobs run
+in the active release with the variables of /etc/<app>/env, their output goes to the journal.
+
+Subcommands:
+  list        List the scheduled jobs with their next and last run
+  run         Run a job now and wait for it
+  history     Show the past runs of a job and their exit status
+
+Options:
+  --app       Application name (optional, defaults to shipyard.toml)
+  --host      Host name (optional, defaults to interactive selection)
+  --limit     Number of runs to show (history only, default 20)
+
+Example:
+  shipyard-cli cron list --host prod
+  shipyard-cli cron run nightly --host prod
+  shipyard-cli cron history nightly --host prod --limit 5
+`)
+}
+
+// cronJobArg returns the job name given before or after the flags of a cron subcommand.
+func cronJobArg(cmd *flag.FlagSet) string {
+	args := os.Args[3:]
+	var job string
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		job, args = args[0], args[1:]
+	}
+	cmd.Parse(args)
+	if job == "" {
+		job = cmd.Arg(0)
+	}
+	if job == "" {
+		log.Fatalf("❌ Usage: shipyard-cli %s <job> [--app <name>] [--host <host>]", cmd.Name())
+	}
+	if !config.ValidCronName(job) {
+		log.Fatalf("❌ Invalid cron job name %q", job)
+	}
+	return job
+}
+
+// runCronCommand runs a command of the cron subcommands on the host and returns its output.
+func runCronCommand(host *models.SSHHost, command string) (string, error) {
+	sshClient, err := connectToHostCLI(host)
+	if err != nil {
+		return "", err
+	}
+	defer sshClient.Close()
+
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+	output, err := session.CombinedOutput(command)
+	return string(output), err
+}
+
+// cronListCommand handles the 'cron list' command
+func cronListCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("cron list", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	cmd.Usage = printCronUsage
+	cmd.Parse(os.Args[3:])
+
+	appName, hostName, _, host, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	output, err := runCronCommand(host, deploy.CronStatusCommand(appName))
+	if err != nil {
+		log.Fatalf("❌ Failed to list cron jobs: %v\n%s", err, output)
+	}
+	jobs := deploy.ParseCronJobs(appName, output)
+
+	log.Printf("--- Cron jobs of app '%s' (Host: %s) ---", appName, hostName)
+	if len(jobs) == 0 {
+		fmt.Println("No cron jobs scheduled. Add [[cron]] entries to shipyard.toml and deploy.")
+		return
+	}
+
+	fmt.Printf("\n%-20s %-24s %-30s %-30s %-12s\n", "NAME", "SCHEDULE", "NEXT RUN", "LAST RUN", "LAST RESULT")
+	fmt.Println("----------------------------------------------------------------------------------------------------------------------")
+	for _, job := range jobs {
+		result := job.LastResult
+		switch {
+		case job.Running:
+			result = "running"
+		case result == "":
+			result = "-"
+		case result != "success" && job.ExitStatus != "" && job.ExitStatus != "0":
+			result = fmt.Sprintf("%s (%s)", result, job.ExitStatus)
+		}
+		fmt.Printf("%-20s %-24s %-30s %-30s %-12s\n", job.Name, job.Schedule, cronTime(job.NextRun), cronTime(job.LastRun), result)
+	}
+
+	fmt.Printf("\nTotal: %d job(s)\n", len(jobs))
+}
+
+// cronTime shows an unset time of systemctl show as "-".
+func cronTime(value string) string {
+	if value == "" || value == "n/a" {
+		return "-"
+	}
+	return value
+}
+
+// cronRunCommand handles the 'cron run' command
+func cronRunCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("cron run", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	cmd.Usage = printCronUsage
+	job := cronJobArg(cmd)
+
+	appName, hostName, _, host, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	log.Printf("⏰ Running cron job '%s' of app '%s' on %s...", job, appName, hostName)
+	started := time.Now()
+	output, err := runCronCommand(host, deploy.CronRunCommand(appName, job))
+	fmt.Print(output)
+	if err != nil {
+		if _, ok := err.(*ssh.ExitError); ok {
+			log.Fatalf("❌ Cron job '%s' failed after %s, see 'shipyard-cli cron history %s'", job, time.Since(started).Round(time.Second), job)
+		}
+		log.Fatalf("❌ Failed to run cron job '%s': %v", job, err)
+	}
+	log.Printf("✅ Cron job '%s' finished in %s", job, time.Since(started).Round(time.Second))
+}
+
+// cronHistoryCommand handles the 'cron history' command
+func cronHistoryCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("cron history", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	limitFlag := cmd.Int("limit", 20, "Number of runs to show")
+	cmd.Usage = printCronUsage
+	job := cronJobArg(cmd)
+
+	appName, hostName, _, host, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	output, err := runCronCommand(host, deploy.CronHistoryCommand(appName, job))
+	if err != nil {
+		log.Fatalf("❌ Failed to read the journal of cron job '%s': %v\n%s", job, err, output)
+	}
+	runs := deploy.ParseCronHistory(output)
+	if *limitFlag > 0 && len(runs) > *limitFlag {
+		runs = runs[:*limitFlag]
+	}
+
+	log.Printf("--- Runs of cron job '%s' of app '%s' (Host: %s) ---", job, appName, hostName)
+	if len(runs) == 0 {
+		fmt.Println("No runs found in the journal.")
+		return
+	}
+
+	fmt.Printf("\n%-20s %-10s %-10s %-6s %s\n", "STARTED AT", "DURATION", "RESULT", "EXIT", "REASON")
+	fmt.Println(strings.Repeat("-", 64))
+	for _, run := range runs {
+		duration := "-"
+		if !run.Finished.IsZero() {
+			duration = run.Finished.Sub(run.Started).Round(time.Second).String()
+		}
+		fmt.Printf("%-20s %-10s %-10s %-6d %s\n", run.Started.Format("2006-01-02 15:04:05"), duration, run.Result, run.ExitStatus, run.Reason)
+	}
+}
@@ -1,8 +1,13 @@
 package commands
 
 import (
+	"context"
 	"flag"
+	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"youfun/shipyard/internal/client"
 	"youfun/shipyard/internal/cliutils"
 	"youfun/shipyard/internal/deploy"
@@ -15,17 +20,75 @@ func DeployApp(apiClient *client.Client) {
 	appNameFlag := cmd.String("app", "", "Application name (optional, defaults to shipyard.toml)")
 	hostNameFlag := cmd.String("host", "", "Host name (optional, defaults to interactive selection)")
 	useBuild := cmd.String("use-build", "", "Reuse build artifact by MD5 (short), git commit SHA, or version (see: build list)")
+	wait := cmd.Bool("wait", false, "Wait for a running deployment or lock of the instance instead of failing")
+	allHosts := cmd.Bool("all-hosts", false, "Rolling deploy to every host the app is linked to")
+	hostsFlag := cmd.String("hosts", "", "Rolling deploy to a comma-separated list of hosts, in order")
+	parallel := cmd.Int("parallel", 1, "Number of hosts deployed at the same time during a rolling deploy")
+	canary := cmd.Int("canary", 0, "Send this percentage of traffic (1-99) to the new version and keep the current one serving the rest")
+	plan := cmd.Bool("plan", false, "Show what the deployment would do without changing anything")
+	output := cmd.String("output", "text", "Output format of --plan: text or json")
+	resume := cmd.String("resume", "", "Continue a failed deployment (dpl_...) from its first incomplete step")
+	ci := cmd.Bool("ci", false, "Non-interactive mode for CI pipelines (also enabled by CI=true)")
+	jsonEvents := cmd.Bool("json", false, "Write JSON events to stdout, logs go to stderr")
 	cmd.Parse(os.Args[2:])
 
+	if *ci {
+		cliutils.SetCIMode()
+	}
+	if *canary < 0 || *canary > 99 {
+		cliutils.Fatalf(cliutils.ExitConfig, "❌ --canary must be between 1 and 99")
+	}
+	if *output != "text" && *output != "json" {
+		cliutils.Fatalf(cliutils.ExitConfig, "❌ --output must be text or json")
+	}
+	if *jsonEvents && *plan {
+		cliutils.Fatalf(cliutils.ExitConfig, "❌ --json cannot be combined with --plan, use --output json")
+	}
+
 	// Resolve app name: flag > shipyard.toml
 	appName := *appNameFlag
 	if appName == "" {
 		appName = cliutils.ResolveAppNameFromConfig()
 	}
 
+	opts := deploy.RunOptions{UseBuild: *useBuild, WaitForLock: *wait, Canary: *canary, Resume: *resume}
+	if *jsonEvents {
+		// Keep stdout for the events
+		deploy.Output = os.Stderr
+		opts.Events = deploy.NewEventWriter(os.Stdout)
+	}
+
+	if *resume != "" && (*allHosts || *hostsFlag != "" || *plan) {
+		cliutils.Fatalf(cliutils.ExitConfig, "❌ --resume cannot be combined with --all-hosts, --hosts or --plan")
+	}
+
+	if *allHosts || *hostsFlag != "" {
+		if *plan {
+			cliutils.Fatalf(cliutils.ExitConfig, "❌ --plan works on one host, use --host instead of --all-hosts or --hosts")
+		}
+		if *hostNameFlag != "" {
+			cliutils.Fatalf(cliutils.ExitConfig, "❌ --host cannot be combined with --all-hosts or --hosts")
+		}
+		hosts := rolloutHosts(apiClient, appName, *allHosts, *hostsFlag)
+
+		ctx, stop := deployContext()
+		defer stop()
+
+		rolloutOpts := deploy.RolloutOptions{RunOptions: opts, Parallel: *parallel}
+		if _, err := deploy.RunRollout(ctx, apiClient, appName, hosts, rolloutOpts, ssh.InsecureIgnoreHostKey()); err != nil {
+			log.Printf("❌ %v", err)
+			exitDeployFailed(err)
+		}
+		return
+	}
+
 	// Resolve host name: flag > interactive selection
+	// A resumed deployment continues on its own host
 	hostName := *hostNameFlag
-	if hostName == "" {
+	if hostName == "" && *resume == "" && cliutils.IsCI() {
+		cliutils.Fatalf(cliutils.ExitConfig, "❌ --host is required in CI mode")
+	}
+	if hostName == "" && *resume == "" {
 		// Fetch last deployment info to suggest default host
 		lastDeployment, _ := apiClient.GetLastDeployment(appName)
 		var lastHostName string
@@ -37,6 +100,101 @@ func DeployApp(apiClient *client.Client) {
 		hostName = hostDTO.Name
 	}
 
+	if *plan {
+		printDeployPlan(apiClient, appName, hostName, opts, *output)
+		return
+	}
+
+	ctx, stop := deployContext()
+	defer stop()
+
+	// TODO: Implement proper host key verification for CLI client using API
+	if err := deploy.RunWithAPIClient(ctx, apiClient, appName, hostName, opts, ssh.InsecureIgnoreHostKey()); err != nil {
+		exitDeployFailed(err)
+	}
+}
+
+// printDeployPlan prints what deploying to the host would do to stdout, logs go to stderr.
+func printDeployPlan(apiClient *client.Client, appName, hostName string, opts deploy.RunOptions, output string) {
 	// TODO: Implement proper host key verification for CLI client using API
-	deploy.RunWithAPIClient(apiClient, appName, hostName, *useBuild, ssh.InsecureIgnoreHostKey())
+	plan, err := deploy.BuildPlan(apiClient, appName, hostName, opts, ssh.InsecureIgnoreHostKey())
+	if err != nil {
+		cliutils.Fatalf(deployExitCode(err), "❌ Failed to plan deployment: %v", err)
+	}
+
+	if output == "json" {
+		err = plan.WriteJSON(os.Stdout)
+	} else {
+		err = plan.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("❌ Failed to print plan: %v", err)
+	}
+}
+
+// rolloutHosts resolves the hosts of a rolling deploy: every linked host, or the given list in order.
+func rolloutHosts(apiClient *client.Client, appName string, allHosts bool, hostsFlag string) []string {
+	linked, err := apiClient.ListLinkedHosts(appName)
+	if err != nil {
+		cliutils.Fatalf(deployExitCode(err), "❌ Failed to list hosts of app '%s': %v", appName, err)
+	}
+	linkedNames := make(map[string]bool, len(linked))
+	for _, host := range linked {
+		linkedNames[host.Name] = true
+	}
+
+	if allHosts {
+		if hostsFlag != "" {
+			cliutils.Fatalf(cliutils.ExitConfig, "❌ --all-hosts cannot be combined with --hosts")
+		}
+		if len(linked) == 0 {
+			cliutils.Fatalf(cliutils.ExitConfig, "❌ App '%s' is not linked to any host", appName)
+		}
+		hosts := make([]string, len(linked))
+		for i, host := range linked {
+			hosts[i] = host.Name
+		}
+		return hosts
+	}
+
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(hostsFlag, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if !linkedNames[name] {
+			cliutils.Fatalf(cliutils.ExitConfig, "❌ App '%s' is not linked to host '%s'", appName, name)
+		}
+		seen[name] = true
+		hosts = append(hosts, name)
+	}
+	if len(hosts) == 0 {
+		cliutils.Fatalf(cliutils.ExitConfig, "❌ --hosts needs at least one host name")
+	}
+	return hosts
+}
+
+// deployContext returns a context that is cancelled on the first SIGINT/SIGTERM, so a running
+// deployment can undo its steps. A second signal terminates the CLI immediately.
+func deployContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-sigs:
+			log.Printf("🛑 Received %s, cancelling deployment (press Ctrl+C again to quit without cleanup)...", sig)
+			signal.Stop(sigs)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel()
+	}
 }
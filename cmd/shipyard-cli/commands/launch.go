@@ -94,7 +94,11 @@ func LaunchCommand(apiClient *client.Client) {
 
 	// 6. Execute deployment
 	log.Println("--- 🚀 Executing first deployment ---")
-	deploy.RunWithAPIClient(apiClient, appName, hostDTO.Name, "", ssh.InsecureIgnoreHostKey())
+	ctx, stop := deployContext()
+	defer stop()
+	if err := deploy.RunWithAPIClient(ctx, apiClient, appName, hostDTO.Name, deploy.RunOptions{}, ssh.InsecureIgnoreHostKey()); err != nil {
+		os.Exit(1)
+	}
 	log.Println("✅ Application deployed successfully!")
 	log.Println("--- 🎉 shipyard launch process completed ---")
 }
@@ -122,7 +126,10 @@ func selectHost(apiClient *client.Client, hostNameFlag string, defaultHostName s
 				return &h
 			}
 		}
-		log.Fatalf("Error: Specified host '%s' not found", hostNameFlag)
+		cliutils.Fatalf(cliutils.ExitConfig, "Error: Specified host '%s' not found", hostNameFlag)
+	}
+	if cliutils.IsCI() {
+		cliutils.Fatalf(cliutils.ExitConfig, "❌ --host is required in CI mode")
 	}
 
 	// TODO: Re-enable localhost deployment after fixing database instance creation issue
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"youfun/shipyard/internal/client"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// LockCommand handles the 'lock' command
+func LockCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("lock", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	reasonFlag := cmd.String("reason", "", "Why deploys are frozen (required)")
+	cmd.Usage = printLockUsage
+	cmd.Parse(os.Args[2:])
+
+	if *reasonFlag == "" {
+		printLockUsage()
+		log.Fatalf("❌ --reason is required")
+	}
+
+	appName, hostName, instanceInfo, _, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	lock, err := apiClient.LockInstance(instanceInfo.Instance.UID, *reasonFlag)
+	if err != nil {
+		log.Fatalf("❌ Failed to lock app '%s' on %s: %v", appName, hostName, err)
+	}
+
+	log.Printf("🔒 Deploys of app '%s' (Host: %s) are locked by %s: %s", appName, hostName, lock.Owner, lock.Reason)
+	log.Printf("   Run 'shipyard-cli unlock --app %s --host %s' to allow deploys again.", appName, hostName)
+}
+
+// UnlockCommand handles the 'unlock' command
+func UnlockCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("unlock", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	forceFlag := cmd.Bool("force", false, "Also remove the lock of a running deployment, rollback, canary update or scaling")
+	cmd.Usage = printLockUsage
+	cmd.Parse(os.Args[2:])
+
+	appName, hostName, instanceInfo, _, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	lock, err := apiClient.UnlockInstance(instanceInfo.Instance.UID, *forceFlag)
+	if err != nil {
+		log.Fatalf("❌ Failed to unlock app '%s' on %s: %v", appName, hostName, err)
+	}
+
+	if lock.Kind == "" {
+		log.Printf("App '%s' (Host: %s) is not locked.", appName, hostName)
+		return
+	}
+	log.Printf("🔓 Removed %s lock of app '%s' (Host: %s) held by %s", lock.Kind, appName, hostName, lock.Owner)
+}
+
+func printLockUsage() {
+	fmt.Print(`
+Usage:
+  shipyard-cli lock --reason <text> [--app <name>] [--host <host>]
+  shipyard-cli unlock [--app <name>] [--host <host>] [--force]
+
+'lock' freezes deploys and rollbacks of an app instance until it is unlocked.
+'unlock' removes the lock; the lock of a deployment that is still running only with --force.
+Application tokens can do neither.
+
+Options:
+  --app       Application name (optional, defaults to shipyard.toml)
+  --host      Host name (optional, defaults to interactive selection)
+  --reason    Why deploys are frozen, shown to anyone trying to deploy (lock only)
+  --force     Also remove the lock of a running deployment, rollback, canary update or scaling (unlock only)
+
+Example:
+  shipyard-cli lock --host prod --reason "release freeze until Monday"
+  shipyard-cli unlock --host prod
+`)
+}
@@ -23,6 +23,7 @@ func LogsCommand(apiClient *client.Client) {
 	logsCmd := flag.NewFlagSet("logs", flag.ExitOnError)
 	hostFlag := logsCmd.String("host", "", "Target host name (optional, enters interactive mode if not provided)")
 	portFlag := logsCmd.Int("port", 0, "Specific port number (optional)")
+	processFlag := logsCmd.String("process", config.ProcessWeb, "Process type of [processes], e.g. worker (default web)")
 	linesFlag := logsCmd.Int("lines", 500, "Number of log lines to show")
 	followFlag := logsCmd.Bool("follow", false, "Follow log output")
 	fFlag := logsCmd.Bool("f", false, "Follow log output (shorthand for -f)")
@@ -31,6 +32,15 @@ func LogsCommand(apiClient *client.Client) {
 
 	logsCmd.Parse(os.Args[2:])
 
+	// The other process types run no port, their logs are those of all their instances
+	process := *processFlag
+	if process == config.ProcessWeb {
+		process = ""
+	}
+	if process != "" && !config.ValidProcessName(process) {
+		log.Fatalf("Error: invalid process type %q", process)
+	}
+
 	// Handle -f shorthand
 	if *fFlag {
 		*followFlag = true
@@ -91,7 +101,9 @@ func LogsCommand(apiClient *client.Client) {
 	var targetPort int
 	var deployInfo string
 
-	if *portFlag > 0 {
+	if process != "" {
+		deployInfo = fmt.Sprintf("Process %s (all instances)", process)
+	} else if *portFlag > 0 {
 		// Use specified port
 		targetPort = *portFlag
 		deployInfo = fmt.Sprintf("Port %d", targetPort)
@@ -132,14 +144,19 @@ func LogsCommand(apiClient *client.Client) {
 	if *followFlag {
 		// Real-time mode using WebSocket API
 		ctx := context.Background()
-		err = apiClient.StreamInstanceLogs(ctx, instanceInfo.Instance.UID, *linesFlag)
+		err = apiClient.StreamInstanceLogs(ctx, instanceInfo.Instance.UID, *linesFlag, process)
 		if err != nil {
 			fmt.Printf("\n错误: %v\n", err)
 			os.Exit(1)
 		}
 	} else {
 		// Static mode - direct SSH connection
-		logContent, err := logs.FetchJournalLogs(host, appName, targetPort, *linesFlag, false, ssh.InsecureIgnoreHostKey())
+		var logContent string
+		if process != "" {
+			logContent, err = logs.FetchProcessLogs(host, appName, process, *linesFlag, ssh.InsecureIgnoreHostKey())
+		} else {
+			logContent, err = logs.FetchJournalLogs(host, appName, targetPort, *linesFlag, false, ssh.InsecureIgnoreHostKey())
+		}
 		if err != nil {
 			fmt.Printf("错误: %v\n", err)
 			os.Exit(1)
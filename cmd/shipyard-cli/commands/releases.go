@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/pkg/types"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// ReleasesCommand handles the 'releases' command with subcommands
+func ReleasesCommand(apiClient *client.Client) {
+	if len(os.Args) < 3 {
+		printReleasesUsage()
+		os.Exit(1)
+	}
+
+	subCommand := os.Args[2]
+	switch subCommand {
+	case "list":
+		releasesListCommand(apiClient)
+	case "prune":
+		releasesPruneCommand(apiClient)
+	case "pin":
+		releasesPinCommand(apiClient, true)
+	case "unpin":
+		releasesPinCommand(apiClient, false)
+	case "help", "--help", "-h":
+		printReleasesUsage()
+	default:
+		fmt.Printf("Unknown releases subcommand: %s\n", subCommand)
+		printReleasesUsage()
+		os.Exit(1)
+	}
+}
+
+func printReleasesUsage() {
+	fmt.Print(`
+Usage: shipyard-cli releases <subcommand> [options]
+
+Every deployment unpacks a release under /var/www/<app>/releases on the host. After a successful
+deployment, releases beyond 'keep_releases' (shipyard.toml, default 3) are deleted. The active,
+standby, canary and pinned releases are always kept.
+
+Subcommands:
+  list        List the releases of an app instance
+  prune       Delete old releases now
+  pin         Protect a release from pruning
+  unpin       Let a pinned release be pruned again
+
+Options:
+  --app       Application name (optional, defaults to shipyard.toml)
+  --host      Host name (optional, defaults to interactive selection)
+  --keep      Releases to keep besides the active, standby and pinned ones (prune only, default: keep_releases)
+  --dry-run   Show what would be deleted without deleting it (prune only)
+
+Example:
+  shipyard-cli releases list --host prod
+  shipyard-cli releases prune --host prod --keep 1 --dry-run
+  shipyard-cli releases pin 1.4.2-1714550000 --host prod
+  shipyard-cli releases pin dpl_2xK9mQ --host prod
+  shipyard-cli releases unpin 1.4.2-1714550000 --host prod
+`)
+}
+
+// releasesListCommand handles the 'releases list' command
+func releasesListCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("releases list", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	cmd.Usage = printReleasesUsage
+	cmd.Parse(os.Args[3:])
+
+	appName, hostName, instanceInfo, _, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	releases, err := apiClient.ListReleases(instanceInfo.Instance.UID)
+	if err != nil {
+		log.Fatalf("❌ Failed to list releases: %v", err)
+	}
+
+	log.Printf("--- Releases of app '%s' (Host: %s) ---", appName, hostName)
+	if len(releases) == 0 {
+		fmt.Println("No releases found.")
+		return
+	}
+
+	fmt.Printf("\n%-32s %-16s %-10s %-7s %-6s %-20s\n", "RELEASE", "VERSION", "STATUS", "PINNED", "PORT", "STARTED AT")
+	fmt.Println("------------------------------------------------------------------------------------------------")
+	for _, release := range releases {
+		pinned := ""
+		if release.Pinned {
+			pinned = "yes"
+		}
+		startedAt := ""
+		if release.StartedAt != nil {
+			startedAt = release.StartedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-32s %-16s %-10s %-7s %-6d %-20s\n", release.Name, release.Version, release.Status, pinned, release.Port, startedAt)
+	}
+
+	fmt.Printf("\nTotal: %d release(s)\n", len(releases))
+}
+
+// releasesPruneCommand handles the 'releases prune' command
+func releasesPruneCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("releases prune", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	keepFlag := cmd.Int("keep", -1, "Releases to keep besides the active, standby and pinned ones (default: keep_releases)")
+	dryRunFlag := cmd.Bool("dry-run", false, "Show what would be deleted without deleting it")
+	cmd.Usage = printReleasesUsage
+	cmd.Parse(os.Args[3:])
+
+	appName, hostName, instanceInfo, _, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	keep := *keepFlag
+	if keep < 0 {
+		keep = config.DefaultKeepReleases
+		// Use the retention of the project when running in its directory
+		if cfg, err := config.ReadConfigFile(config.ConfigPath); err == nil && (*appFlag == "" || cfg.App == appName) && cfg.KeepReleases > 0 {
+			keep = cfg.KeepReleases
+		}
+	}
+
+	result, err := apiClient.PruneReleases(instanceInfo.Instance.UID, &types.PruneReleasesRequest{Keep: &keep, DryRun: *dryRunFlag})
+	if err != nil {
+		log.Fatalf("❌ Failed to prune releases of app '%s' on %s: %v", appName, hostName, err)
+	}
+
+	if len(result.Pruned) == 0 {
+		log.Printf("✅ Nothing to prune for app '%s' (Host: %s), keeping %d old release(s)", appName, hostName, keep)
+		return
+	}
+	for _, release := range result.Pruned {
+		if result.DryRun {
+			log.Printf("   Would delete %s (%s)", release.Name, release.Version)
+		} else {
+			log.Printf("🧹 Deleted %s (%s)", release.Name, release.Version)
+		}
+	}
+	if result.DryRun {
+		log.Printf("Dry run: %d release(s) of app '%s' (Host: %s) would be deleted", len(result.Pruned), appName, hostName)
+		return
+	}
+	log.Printf("✅ Pruned %d release(s) of app '%s' (Host: %s)", len(result.Pruned), appName, hostName)
+}
+
+// releasesPinCommand handles the 'releases pin' and 'releases unpin' commands
+func releasesPinCommand(apiClient *client.Client, pin bool) {
+	name := "releases pin"
+	if !pin {
+		name = "releases unpin"
+	}
+	cmd := flag.NewFlagSet(name, flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	cmd.Usage = printReleasesUsage
+
+	// The release may come before or after the flags
+	args := os.Args[3:]
+	var release string
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		release, args = args[0], args[1:]
+	}
+	cmd.Parse(args)
+	if release == "" {
+		release = cmd.Arg(0)
+	}
+	if release == "" {
+		log.Fatalf("❌ Usage: shipyard-cli %s <release|deployment-id> [--app <name>] [--host <host>]", name)
+	}
+
+	appName, hostName, instanceInfo, _, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	var result *types.ReleaseDTO
+	if pin {
+		result, err = apiClient.PinRelease(instanceInfo.Instance.UID, release)
+	} else {
+		result, err = apiClient.UnpinRelease(instanceInfo.Instance.UID, release)
+	}
+	if err != nil {
+		log.Fatalf("❌ Failed to update release %s of app '%s' on %s: %v", release, appName, hostName, err)
+	}
+
+	if pin {
+		log.Printf("📌 Release %s (%s) of app '%s' (Host: %s) pinned, it will not be pruned", result.Name, result.Version, appName, hostName)
+		return
+	}
+	log.Printf("✅ Release %s (%s) of app '%s' (Host: %s) unpinned", result.Name, result.Version, appName, hostName)
+}
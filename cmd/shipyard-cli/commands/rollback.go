@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/pkg/types"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// RollbackCommand handles the 'rollback' command
+func RollbackCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("rollback", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	toFlag := cmd.String("to", "", "Deployment ID or version to roll back to (default: standby release)")
+	cmd.Usage = printRollbackUsage
+	cmd.Parse(os.Args[2:])
+
+	appName, hostName, instanceInfo, _, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	req := &types.RollbackRequest{To: *toFlag}
+	// Reuse the readiness probe of the project when running in its directory
+	if cfg, err := config.ReadConfigFile(config.ConfigPath); err == nil && (*appFlag == "" || cfg.App == appName) {
+		req.HealthCheck = healthCheckToDTO(cfg.HealthCheck)
+	}
+
+	target := "standby release"
+	if *toFlag != "" {
+		target = *toFlag
+	}
+	log.Printf("--- Rolling back app '%s' (Host: %s) to %s ---", appName, hostName, target)
+
+	result, err := apiClient.Rollback(instanceInfo.Instance.UID, req)
+	if err != nil {
+		log.Fatalf("❌ Rollback failed: %v", err)
+	}
+
+	log.Printf("✅ App '%s' rolled back to version %s", appName, result.Version)
+	log.Printf("   Release:    %s", result.ReleasePath)
+	log.Printf("   Port:       %d -> %d", result.PreviousPort, result.Port)
+	log.Printf("   Deployment: %s", result.DeploymentID)
+}
+
+// healthCheckToDTO converts the [health_check] section for the API
+func healthCheckToDTO(hc config.HealthCheck) *types.HealthCheckConfigDTO {
+	return &types.HealthCheckConfigDTO{
+		Path:           hc.Path,
+		ExpectedStatus: hc.ExpectedStatus,
+		BodyContains:   hc.BodyContains,
+		IntervalMs:     hc.Interval.Milliseconds(),
+		TimeoutMs:      hc.Timeout.Milliseconds(),
+		GracePeriodMs:  hc.GracePeriod.Milliseconds(),
+		Retries:        hc.Retries,
+	}
+}
+
+func printRollbackUsage() {
+	fmt.Print(`
+Usage: shipyard-cli rollback [options]
+
+Restarts a retained release on a fresh port, health-checks it and switches traffic back to it.
+
+Options:
+  --app       Application name (optional, defaults to shipyard.toml)
+  --host      Host name (optional, defaults to interactive selection)
+  --to        Deployment ID (dpl_...) or version to roll back to (default: standby release)
+
+Example:
+  shipyard-cli rollback
+  shipyard-cli rollback --host prod --to v1.4.2
+  shipyard-cli rollback --app my-app --to dpl_xxxxxxxx
+`)
+}
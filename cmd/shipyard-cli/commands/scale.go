@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/pkg/types"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ScaleCommand handles the 'scale' command
+func ScaleCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("scale", flag.ExitOnError)
+	appFlag := cmd.String("app", "", "Application name (optional)")
+	hostFlag := cmd.String("host", "", "Host name (optional)")
+	countFlag := cmd.Int("count", 0, "Number of web instances to run (default: show the current count)")
+	cmd.Usage = printScaleUsage
+	cmd.Parse(os.Args[2:])
+
+	appName, hostName, instanceInfo, _, err := resolveAppAndHostFromAPI(apiClient, *appFlag, *hostFlag)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	ports := instancePorts(instanceInfo)
+	if *countFlag == 0 {
+		if len(ports) == 0 {
+			fmt.Printf("App '%s' has no instance serving traffic on %s yet, deploy first.\n", appName, hostName)
+			return
+		}
+		fmt.Printf("App '%s' runs %d instance(s) on %s, on port %s\n", appName, len(ports), hostName, joinPorts(ports))
+		return
+	}
+	if *countFlag < 1 || *countFlag > config.MaxScale {
+		log.Fatalf("❌ --count must be between 1 and %d", config.MaxScale)
+	}
+
+	req := &types.ScaleRequest{Count: *countFlag}
+	// Reuse the readiness probe of the project when running in its directory
+	if cfg, err := config.ReadConfigFile(config.ConfigPath); err == nil && (*appFlag == "" || cfg.App == appName) {
+		req.HealthCheck = healthCheckToDTO(cfg.HealthCheck)
+		if cfg.Scale > 0 && cfg.Scale != *countFlag {
+			log.Printf("⚠️  shipyard.toml sets scale = %d, the next deployment runs %d instances again", cfg.Scale, cfg.Scale)
+		}
+	}
+
+	log.Printf("--- Scaling app '%s' (Host: %s) from %d to %d instance(s) ---", appName, hostName, len(ports), *countFlag)
+	result, err := apiClient.Scale(instanceInfo.Instance.UID, req)
+	if err != nil {
+		log.Fatalf("❌ Scale failed: %v", err)
+	}
+
+	log.Printf("✅ App '%s' runs %d instance(s) of version %s", appName, len(result.Ports), result.Version)
+	log.Printf("   Ports:   %s", joinPorts(result.Ports))
+	if len(result.Started) > 0 {
+		log.Printf("   Started: %s", joinPorts(result.Started))
+	}
+	if len(result.Stopped) > 0 {
+		log.Printf("   Stopped: %s", joinPorts(result.Stopped))
+	}
+}
+
+// instancePorts returns the ports of the instances serving traffic, the active port first.
+// Servers without scaled instances only report the active port.
+func instancePorts(info *client.InstanceInfo) []int {
+	activePort := int(info.Instance.ActivePort)
+	if activePort == 0 {
+		return nil
+	}
+	ports := []int{activePort}
+	if !slices.Contains(info.Instance.ActivePorts, activePort) {
+		return ports
+	}
+	for _, port := range info.Instance.ActivePorts {
+		if port != activePort {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// joinPorts lists ports for display, e.g. "4001, 4002".
+func joinPorts(ports []int) string {
+	parts := make([]string, len(ports))
+	for i, port := range ports {
+		parts[i] = strconv.Itoa(port)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func printScaleUsage() {
+	fmt.Print(`
+Usage: shipyard-cli scale [options]
+
+Runs a number of web instances of the active release on the host, each on its own port, behind a
+load-balanced Caddy route. Added instances are health-checked before they receive traffic, removed
+ones are taken out of the route and stopped once drained. Deployments start as many instances of the
+new release, unless shipyard.toml sets scale.
+
+Options:
+  --count     Number of instances to run, 1 to 32 (default: show the current count)
+  --app       Application name (optional, defaults to shipyard.toml)
+  --host      Host name (optional, defaults to interactive selection)
+
+Example:
+  shipyard-cli scale --host prod
+  shipyard-cli scale --count 4 --host prod
+  shipyard-cli scale --app my-app --host prod --count 1
+`)
+}
@@ -3,14 +3,22 @@ package commands
 import (
 	"youfun/shipyard/internal/client"
 	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/deploy"
+	"youfun/shipyard/internal/models"
+	"flag"
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/BurntSushi/toml"
 )
 
 // statusCommand handles the 'status' or 'info' command - shows app status via API
 func StatusCommand(apiClient *client.Client) {
+	cmd := flag.NewFlagSet("status", flag.ExitOnError)
+	processFlag := cmd.String("process", "", "Only show this process type of [processes], e.g. web or worker")
+	cmd.Parse(os.Args[2:])
+
 	var projConf config.Config
 	if _, err := toml.DecodeFile(config.ConfigPath, &projConf); err != nil {
 		log.Fatalf("Failed to read or parse %s: %v. Please ensure file exists and is correctly formatted.", config.ConfigPath, err)
@@ -22,6 +30,19 @@ func StatusCommand(apiClient *client.Client) {
 
 	appName := projConf.App
 
+	// Process types besides web to show, their instances are listed over SSH
+	showWeb := *processFlag == "" || *processFlag == config.ProcessWeb
+	var processes []string
+	switch {
+	case *processFlag == "":
+		processes = projConf.OtherProcesses()
+	case *processFlag != config.ProcessWeb:
+		if !config.ValidProcessName(*processFlag) {
+			log.Fatalf("Invalid process type %q", *processFlag)
+		}
+		processes = []string{*processFlag}
+	}
+
 	// Get hosts list
 	hosts, err := apiClient.ListHosts()
 	if err != nil {
@@ -47,11 +68,22 @@ func StatusCommand(apiClient *client.Client) {
 		}
 
 		foundInstances = true
-		status := instanceInfo.Instance.Status
-		if instanceInfo.Instance.ActivePort > 0 {
-			status = fmt.Sprintf("active on port %d", instanceInfo.Instance.ActivePort)
+		if showWeb {
+			status := instanceInfo.Instance.Status
+			if instanceInfo.Instance.ActivePort > 0 {
+				status = fmt.Sprintf("active on port %s", joinPorts(instancePorts(instanceInfo)))
+			}
+			fmt.Printf("- Host: %s, Status: %s\n", host.Name, status)
+			if canary := instanceInfo.Instance.Canary; canary != nil {
+				fmt.Printf("  Canary %s: port %d at %d%%, stable port %d at %d%%\n",
+					canary.DeploymentID, canary.CanaryPort, canary.CanaryWeight, canary.StablePort, canary.StableWeight)
+			}
+		} else {
+			fmt.Printf("- Host: %s\n", host.Name)
+		}
+		if len(processes) > 0 {
+			printProcessStatus(instanceInfo, appName, processes)
 		}
-		fmt.Printf("- Host: %s, Status: %s\n", host.Name, status)
 	}
 
 	if !foundInstances {
@@ -59,3 +91,47 @@ func StatusCommand(apiClient *client.Client) {
 		fmt.Println("Use 'shipyard-cli launch' to create first deployment.")
 	}
 }
+
+// printProcessStatus lists the instances of process types besides web on the host of an instance.
+func printProcessStatus(instanceInfo *client.InstanceInfo, appName string, processes []string) {
+	host := &models.SSHHost{
+		Name:       instanceInfo.Host.Name,
+		Addr:       instanceInfo.Host.Addr,
+		Port:       instanceInfo.Host.Port,
+		User:       instanceInfo.Host.User,
+		Password:   instanceInfo.Host.Password,
+		PrivateKey: instanceInfo.Host.PrivateKey,
+	}
+	sshClient, err := connectToHostCLI(host)
+	if err != nil {
+		fmt.Printf("  ⚠️ Failed to list processes: %v\n", err)
+		return
+	}
+	defer sshClient.Close()
+
+	session, err := sshClient.NewSession()
+	if err != nil {
+		fmt.Printf("  ⚠️ Failed to list processes: %v\n", err)
+		return
+	}
+	defer session.Close()
+	output, err := session.CombinedOutput(deploy.ListProcessInstancesCommand(appName, processes))
+	if err != nil {
+		fmt.Printf("  ⚠️ Failed to list processes: %v\n", err)
+		return
+	}
+
+	instances := deploy.ParseProcessInstances(appName, string(output))
+	for _, process := range processes {
+		found := false
+		for _, instance := range instances {
+			if instance.Process == process {
+				fmt.Printf("  Process %s.%d: %s\n", process, instance.Index, instance.State)
+				found = true
+			}
+		}
+		if !found {
+			fmt.Printf("  Process %s: not running\n", process)
+		}
+	}
+}
@@ -8,16 +8,24 @@ func PrintUsage() {
 	fmt.Println("\nGlobal Flags:")
 	fmt.Println("  --config <path>   Config file path (default: shipyard.toml)")
 	fmt.Println("  --server <url>    Shipyard Server URL")
+	fmt.Println("  --ci              Never prompt, exit codes per failure class (also CI=true)")
 	fmt.Println("\nCommands:")
 	fmt.Println("  login             Login to Shipyard Server")
 	fmt.Println("  logout            Logout")
 	fmt.Println("  deploy            Deploy application")
 	fmt.Println("  launch            Initialize and deploy a new application")
-	fmt.Println("  status            Show status of current project application")
+	fmt.Println("  rollback          Roll back to the standby or a retained release")
+	fmt.Println("  scale             Run N instances of the app per host behind Caddy")
+	fmt.Println("  lock              Freeze deploys of an app instance")
+	fmt.Println("  unlock            Allow deploys of an app instance again")
+	fmt.Println("  canary            Promote or abort a canary release")
+	fmt.Println("  releases          Release retention commands (list, prune, pin, unpin)")
+	fmt.Println("  cron              Scheduled job commands (list, run, history)")
+	fmt.Println("  status            Show status of current project application [--process <name>]")
 	fmt.Println("  vars              Manage application environment variables (list, set, unset)")
 	fmt.Println("  logs              View application instance logs")
 	fmt.Println("  app               App management commands (restart, stop, status)")
-	fmt.Println("  build             Build artifact management commands (list)")
+	fmt.Println("  build             Build artifact management commands (list, push, pull)")
 	fmt.Println("  domain            Domain management commands (check)")
 	fmt.Println("  version           Show version")
 	fmt.Println("  help              Show help")
@@ -29,10 +37,11 @@ func PrintUsage() {
 	fmt.Println("  vars unset KEY [--app <name>]")
 	fmt.Println("      Delete environment variable")
 	fmt.Println("\n--- Log Viewing (logs) ---")
-	fmt.Println("  logs [app-name] [--host <host>] [--port <port>] [--lines N] [-f|--follow]")
+	fmt.Println("  logs [app-name] [--host <host>] [--port <port>] [--process <name>] [--lines N] [-f|--follow]")
 	fmt.Println("      View application instance logs")
 	fmt.Println("      Options:")
 	fmt.Println("        --port <port>      View logs for specific port")
+	fmt.Println("        --process <name>   View logs of all instances of a process type, e.g. worker (default web)")
 	fmt.Println("        --lines <N>        Show last N lines (default 500)")
 	fmt.Println("        --follow, -f       Follow log output")
 	fmt.Println("        --color            Enable color output (default enabled)")
@@ -44,9 +53,64 @@ func PrintUsage() {
 	fmt.Println("      Stop application")
 	fmt.Println("  app status [--app <name>] [--host <host>]")
 	fmt.Println("      View application status")
+	fmt.Println("\n--- Deployment Plans (deploy --plan) ---")
+	fmt.Println("  deploy --plan [--output text|json] [--app <name>] [--host <host>]")
+	fmt.Println("      Show the artifact, hooks, env keys, Caddy routes and stopped versions of a deploy without changing anything")
+	fmt.Println("\n--- Resuming Deployments (deploy --resume) ---")
+	fmt.Println("  deploy --resume <deployment-id> [--app <name>]")
+	fmt.Println("      Continue a failed deployment from its first incomplete step on the same host")
+	fmt.Println("\n--- CI Mode (--ci) ---")
+	fmt.Println("  SHIPYARD_TOKEN=<app token> shipyard-cli --ci deploy --host <host> [--json]")
+	fmt.Println("      Deploy without prompts using an application token; --json writes events to stdout")
+	fmt.Println("      Exit codes: 1 deploy, 2 config, 3 auth, 4 build, 5 upload, 6 health, 7 traffic")
+	fmt.Println("\n--- Rolling Deploys (deploy) ---")
+	fmt.Println("  deploy --all-hosts [--parallel N]")
+	fmt.Println("      Build once and deploy to every linked host, stopping at the first failure")
+	fmt.Println("  deploy --hosts a,b,c [--parallel N]")
+	fmt.Println("      Same, for the listed hosts in order")
+	fmt.Println("\n--- Canary Releases (deploy --canary, canary) ---")
+	fmt.Println("  deploy --canary <percent> [--app <name>] [--host <host>]")
+	fmt.Println("      Send a share of the traffic to the new version, the current version serves the rest")
+	fmt.Println("  canary promote [--app <name>] [--host <host>] [--weight <percent>]")
+	fmt.Println("      Send more traffic to the canary (10, 25, 50, 100); at 100 it replaces the current version")
+	fmt.Println("  canary abort [--app <name>] [--host <host>]")
+	fmt.Println("      Send all traffic back to the current version and stop the canary")
+	fmt.Println("\n--- Rollback (rollback) ---")
+	fmt.Println("  rollback [--app <name>] [--host <host>] [--to <deployment-id|version>]")
+	fmt.Println("      Restart a retained release, health-check it and switch traffic back")
+	fmt.Println("\n--- Scaling (scale) ---")
+	fmt.Println("  scale [--count N] [--app <name>] [--host <host>]")
+	fmt.Println("      Start or stop instances of the active release, load-balanced by Caddy; without --count show the current count")
+	fmt.Println("\n--- Release Retention (releases) ---")
+	fmt.Println("  releases list [--app <name>] [--host <host>]")
+	fmt.Println("      List the releases kept on the host")
+	fmt.Println("  releases prune [--app <name>] [--host <host>] [--keep N] [--dry-run]")
+	fmt.Println("      Delete releases beyond keep_releases, except active, standby and pinned ones")
+	fmt.Println("  releases pin|unpin <release|deployment-id> [--app <name>] [--host <host>]")
+	fmt.Println("      Protect a release from pruning, or lift the protection")
+	fmt.Println("\n--- Scheduled Jobs (cron) ---")
+	fmt.Println("  cron list [--app <name>] [--host <host>]")
+	fmt.Println("      List the [[cron]] jobs scheduled on the host with their next and last run")
+	fmt.Println("  cron run <job> [--app <name>] [--host <host>]")
+	fmt.Println("      Run a job now and wait for it")
+	fmt.Println("  cron history <job> [--app <name>] [--host <host>] [--limit N]")
+	fmt.Println("      Show the past runs of a job and their exit status")
+	fmt.Println("\n--- Deploy Locks (lock, unlock) ---")
+	fmt.Println("  lock --reason <text> [--app <name>] [--host <host>]")
+	fmt.Println("      Freeze deploys and rollbacks until unlocked")
+	fmt.Println("  unlock [--app <name>] [--host <host>] [--force]")
+	fmt.Println("      Remove the lock, one held by a running deployment only with --force")
 	fmt.Println("\n--- Build Management (build) ---")
 	fmt.Println("  build list [--app <name>]")
 	fmt.Println("      List build artifacts for an application")
+	fmt.Println("  build push <md5|git-sha|version> [--app <name>]")
+	fmt.Println("      Push a build made on this machine to the artifact registry, which signs it")
+	fmt.Println("  build pull <md5|git-sha|version> [--app <name>]")
+	fmt.Println("      Pull a build from the artifact registry into the local build cache")
+	fmt.Println("  build prune [--app <name> | --all] [--keep N] [--keep-days N] [--max-size SIZE] [--dry-run]")
+	fmt.Println("      Delete cached builds beyond the retention, with their build history")
+	fmt.Println("  build inspect <md5|git-sha|version> [--app <name>]")
+	fmt.Println("      Show the files, size and deployments of a build")
 	fmt.Println("\n--- Domain Management (domain) ---")
 	fmt.Println("  domain check [--app <name>] [--host <host>]")
 	fmt.Println("      Check Caddy configuration")
@@ -4,8 +4,10 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
 	"youfun/shipyard/cmd/shipyard-cli/commands"
 	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/cliutils"
 	"youfun/shipyard/internal/config"
 )
 
@@ -27,11 +29,21 @@ func main() {
 	// 2. Parse global flags
 	configPath := flag.String("config", "shipyard.toml", "Config file path (default: shipyard.toml)")
 	serverURL := flag.String("server", defaultServerURL, "Deployer Server URL")
+	ci := flag.Bool("ci", false, "Non-interactive mode for CI pipelines (also enabled by CI=true)")
 	flag.Parse()
 
 	// Set the global config path
 	config.ConfigPath = *configPath
 
+	if *ci {
+		cliutils.SetCIMode()
+	}
+
+	// An application token from the environment takes precedence over the login session
+	if token := os.Getenv("SHIPYARD_TOKEN"); token != "" {
+		savedToken = token
+	}
+
 	// Initialize API Client
 	apiClient := client.NewClient(*serverURL)
 	if savedToken != "" {
@@ -46,8 +58,15 @@ func main() {
 
 	command := args[0]
 
+	if command == "login" && cliutils.IsCI() {
+		cliutils.Fatalf(cliutils.ExitAuth, "❌ 'login' is interactive, set SHIPYARD_TOKEN to an application token in CI mode")
+	}
+
 	// Check for auth requirement
 	if savedToken == "" && command != "login" && command != "version" && command != "help" && command != "logout" {
+		if cliutils.IsCI() {
+			cliutils.Fatalf(cliutils.ExitAuth, "❌ No credentials found, set SHIPYARD_TOKEN to an application token in CI mode")
+		}
 		log.Printf("⚠️  Warning: Login session not found. If subsequent operations fail (401 Unauthorized), please run 'shipyard-cli login' first.")
 	}
 
@@ -66,6 +85,20 @@ func main() {
 		commands.LogsCommand(apiClient)
 	case "app":
 		commands.AppCommand(apiClient)
+	case "rollback":
+		commands.RollbackCommand(apiClient)
+	case "scale":
+		commands.ScaleCommand(apiClient)
+	case "lock":
+		commands.LockCommand(apiClient)
+	case "unlock":
+		commands.UnlockCommand(apiClient)
+	case "canary":
+		commands.CanaryCommand(apiClient)
+	case "releases":
+		commands.ReleasesCommand(apiClient)
+	case "cron":
+		commands.CronCommand(apiClient)
 	case "build":
 		commands.BuildCommand(apiClient)
 	case "domain":
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"youfun/shipyard/internal/api/middleware"
+	"youfun/shipyard/internal/api/response"
+	"youfun/shipyard/internal/api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AppTokenScope keeps requests authenticated with an application token to the application of the token
+func AppTokenScope(c *gin.Context) {
+	h := &Handlers{Repo: defaultAppsRepo}
+	h.AppTokenScope(c)
+}
+
+// AppTokenScopeHandler rejects CLI requests of an application token that do not work on its application (method on Handlers).
+// Requests of users pass unchanged.
+func (h *Handlers) AppTokenScope(c *gin.Context) {
+	tokenAppID, ok := middleware.GetAppTokenApplicationFromContext(c)
+	if !ok {
+		c.Next()
+		return
+	}
+
+	appID, ok := h.requestApplicationID(c)
+	if !ok || appID != tokenAppID {
+		response.Error(c, http.StatusForbidden, "Application token is not valid for this request")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// requestApplicationID resolves the application a CLI request works on from its path, query or JSON body.
+// Handlers read the application from either the query or the body, so every application both name must be
+// the same one. It returns false when the request does not name an existing application, or names several.
+func (h *Handlers) requestApplicationID(c *gin.Context) (uuid.UUID, bool) {
+	route := strings.TrimPrefix(c.FullPath(), middleware.CLIPathPrefix)
+
+	if uid := c.Param("uid"); uid != "" {
+		switch {
+		case strings.HasPrefix(route, "instances/"):
+			instanceID, err := utils.DecodeFriendlyID(utils.PrefixAppInstance, uid)
+			if err != nil {
+				return uuid.Nil, false
+			}
+			return h.instanceApplicationID(instanceID)
+		case strings.HasPrefix(route, "deployments/"):
+			deployID, err := utils.DecodeFriendlyID(utils.PrefixDeployment, uid)
+			if err != nil {
+				return uuid.Nil, false
+			}
+			history, err := h.Repo.GetDeploymentHistoryByID(deployID)
+			if err != nil {
+				return uuid.Nil, false
+			}
+			return h.instanceApplicationID(history.InstanceID)
+		case strings.HasPrefix(route, "rollouts/"):
+			rolloutID, err := utils.DecodeFriendlyID(utils.PrefixRollout, uid)
+			if err != nil {
+				return uuid.Nil, false
+			}
+			rollout, err := h.Repo.GetRolloutByID(rolloutID)
+			if err != nil {
+				return uuid.Nil, false
+			}
+			return rollout.ApplicationID, true
+		}
+		return uuid.Nil, false
+	}
+
+	if strings.HasPrefix(route, "applications/by-name/") {
+		return h.applicationIDByName(c.Param("name"))
+	}
+
+	var appIDs []uuid.UUID
+	named := func(appID uuid.UUID, ok bool) bool {
+		appIDs = append(appIDs, appID)
+		return ok
+	}
+	for _, key := range []string{"app", "app_name"} {
+		if name := c.Query(key); name != "" && !named(h.applicationIDByName(name)) {
+			return uuid.Nil, false
+		}
+	}
+	if id := c.Query("app_id"); id != "" && !named(parseApplicationID(id)) {
+		return uuid.Nil, false
+	}
+
+	fields, err := jsonBodyApplicationFields(c)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	if fields.AppName != "" && !named(h.applicationIDByName(fields.AppName)) {
+		return uuid.Nil, false
+	}
+	if fields.ApplicationID != "" && !named(parseApplicationID(fields.ApplicationID)) {
+		return uuid.Nil, false
+	}
+	if fields.InstanceID != "" {
+		instanceID, err := utils.DecodeFriendlyID(utils.PrefixAppInstance, fields.InstanceID)
+		if err != nil {
+			if instanceID, err = utils.ParseUUID(fields.InstanceID); err != nil {
+				return uuid.Nil, false
+			}
+		}
+		if !named(h.instanceApplicationID(instanceID)) {
+			return uuid.Nil, false
+		}
+	}
+
+	if len(appIDs) == 0 {
+		return uuid.Nil, false
+	}
+	for _, appID := range appIDs[1:] {
+		if appID != appIDs[0] {
+			return uuid.Nil, false
+		}
+	}
+	return appIDs[0], true
+}
+
+// applicationFields are the fields of a JSON body that name the application of a request.
+type applicationFields struct {
+	AppName       string `json:"app_name"`
+	ApplicationID string `json:"application_id"`
+	InstanceID    string `json:"instance_id"`
+}
+
+// jsonBodyApplicationFields reads the fields naming an application from the JSON body of a request and puts
+// the body back for the handler. Requests without a JSON body name none.
+func jsonBodyApplicationFields(c *gin.Context) (applicationFields, error) {
+	var fields applicationFields
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return fields, nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return fields, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		return fields, nil
+	}
+	err = json.Unmarshal(body, &fields)
+	return fields, err
+}
+
+// applicationIDByName looks up the ID of an application by name.
+func (h *Handlers) applicationIDByName(name string) (uuid.UUID, bool) {
+	app, err := h.Repo.GetApplicationByName(name)
+	if err != nil || app == nil {
+		return uuid.Nil, false
+	}
+	return app.ID, true
+}
+
+// instanceApplicationID looks up the application of an instance.
+func (h *Handlers) instanceApplicationID(instanceID uuid.UUID) (uuid.UUID, bool) {
+	instance, err := h.Repo.GetApplicationInstanceByID(instanceID)
+	if err != nil || instance == nil {
+		return uuid.Nil, false
+	}
+	return instance.ApplicationID, true
+}
+
+// parseApplicationID parses an application ID given as friendly ID (app_xxx) or raw UUID.
+func parseApplicationID(id string) (uuid.UUID, bool) {
+	appID, err := utils.DecodeFriendlyID(utils.PrefixApplication, id)
+	if err != nil {
+		if appID, err = utils.ParseUUID(id); err != nil {
+			return uuid.Nil, false
+		}
+	}
+	return appID, true
+}
@@ -4,6 +4,7 @@ import (
 	"youfun/shipyard/internal/api/response"
 	"youfun/shipyard/internal/api/utils"
 	"youfun/shipyard/internal/database"
+	"youfun/shipyard/internal/models"
 	"errors"
 	"strings"
 
@@ -117,6 +118,9 @@ func (h *Handlers) GetApplication(c *gin.Context) {
 			"status":      inst.Status,
 			"active_port": inst.ActivePort.Int64,
 		}
+		if canary, err := h.Repo.GetInstanceCanary(inst.ID); err == nil && canary != nil {
+			instanceResponses[i]["canary"] = canaryResponse(canary, models.CanaryStatusRunning)
+		}
 
 		// Get first instance's domain and port for overview display
 		if i == 0 {
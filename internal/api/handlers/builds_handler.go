@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"youfun/shipyard/internal/api/response"
+	"youfun/shipyard/internal/api/utils"
+	"youfun/shipyard/internal/compression"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/deploy"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/registry"
+	"youfun/shipyard/internal/signing"
+	"youfun/shipyard/pkg/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CLIBuildArtifact builds uploaded sources on the server or a builder host (CLI endpoint)
+func CLIBuildArtifact(c *gin.Context) {
+	h := &Handlers{Repo: defaultCLIRepo}
+	h.CLIBuildArtifact(c)
+}
+
+// CLIBuildArtifactHandler builds the sources in the request body and pushes the artifact to the registry (method on Handlers).
+// The response is an NDJSON stream of types.ServerBuildMessage: the build output, then the artifact or the error.
+func (h *Handlers) CLIBuildArtifact(c *gin.Context) {
+	appName := c.Query("app")
+	version := c.Query("version")
+	if appName == "" || version == "" {
+		response.BadRequest(c, "app and version query parameters are required")
+		return
+	}
+
+	app, err := h.Repo.GetApplicationByName(appName)
+	if err != nil {
+		response.NotFound(c, "Application not found: "+appName)
+		return
+	}
+
+	build := config.Build{
+		Location: c.DefaultQuery("location", config.BuildServer),
+		Host:     c.Query("host"),
+		Builder:  c.Query("builder"),
+		GOOS:     c.Query("goos"),
+		GOARCH:   c.Query("goarch"),
+		Main:     c.Query("main"),
+		Dir:      c.Query("dir"),
+
+		Compression: c.Query("compression"),
+		Command:     c.Query("command"),
+		OutputDir:   c.Query("output_dir"),
+	}
+	if build.Location == config.BuildLocal {
+		response.BadRequest(c, "Invalid build location: "+build.Location)
+		return
+	}
+	if err := build.Validate(); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	store, err := h.artifactStore()
+	if err != nil {
+		response.InternalServerError(c, "Artifact registry unavailable: "+err.Error())
+		return
+	}
+
+	var builderHost *models.SSHHost
+	if build.Location == config.BuildHost {
+		builderHost, err = h.Repo.GetSSHHostByName(build.Host)
+		if err != nil {
+			response.NotFound(c, "Builder host not found: "+build.Host)
+			return
+		}
+	}
+
+	// Receive the sources
+	sourceFile, err := os.CreateTemp("", "shipyard-upload-*.tar.gz")
+	if err != nil {
+		response.InternalServerError(c, "Failed to store sources: "+err.Error())
+		return
+	}
+	defer os.Remove(sourceFile.Name())
+	_, err = io.Copy(sourceFile, c.Request.Body)
+	sourceFile.Close()
+	if err != nil {
+		response.BadRequest(c, "Failed to receive sources: "+err.Error())
+		return
+	}
+
+	// From here on the build output streams back
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	stream := &buildStream{w: c.Writer, enc: json.NewEncoder(c.Writer)}
+
+	log.Printf("🔨 Building %s %s for the CLI", appName, version)
+	tarballPath, md5Hash, err := deploy.BuildOnServer(c.Request.Context(), deploy.ServerBuildOptions{
+		AppName:     appName,
+		Runtime:     c.Query("runtime"),
+		SourcePath:  sourceFile.Name(),
+		Build:       build,
+		BuilderHost: builderHost,
+		Output:      stream,
+	})
+	if err != nil {
+		log.Printf("❌ Build of %s failed: %v", appName, err)
+		stream.send(types.ServerBuildMessage{Error: "build failed: " + err.Error()})
+		return
+	}
+	defer os.Remove(tarballPath)
+
+	tarball, err := os.Open(tarballPath)
+	if err != nil {
+		stream.send(types.ServerBuildMessage{Error: "failed to open release tarball: " + err.Error()})
+		return
+	}
+	defer tarball.Close()
+
+	keep, _ := strconv.Atoi(c.Query("keep"))
+	artifact, err := h.storeArtifact(c.Request.Context(), store, &models.BuildArtifact{
+		ApplicationID: app.ID,
+		Version:       version,
+		GitCommitSHA:  c.Query("git_commit_sha"),
+		MD5Hash:       md5Hash,
+		SignedBy:      c.GetString("username"),
+		Compression:   compression.Normalize(build.Compression),
+	}, tarball, keep)
+	if err != nil {
+		stream.send(types.ServerBuildMessage{Error: err.Error()})
+		return
+	}
+	stream.send(types.ServerBuildMessage{Artifact: buildArtifactDTO(artifact)})
+}
+
+// buildStream writes the output of a build to the response as NDJSON messages, flushed as they come.
+type buildStream struct {
+	mu  sync.Mutex
+	w   gin.ResponseWriter
+	enc *json.Encoder
+}
+
+func (s *buildStream) Write(p []byte) (int, error) {
+	s.send(types.ServerBuildMessage{Output: string(p)})
+	return len(p), nil
+}
+
+func (s *buildStream) send(msg types.ServerBuildMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.enc.Encode(msg)
+	s.w.Flush()
+}
+
+// CLIPushArtifact pushes a build artifact to the artifact registry (CLI endpoint)
+func CLIPushArtifact(c *gin.Context) {
+	h := &Handlers{Repo: defaultCLIRepo}
+	h.CLIPushArtifact(c)
+}
+
+// CLIPushArtifactHandler stores the tarball in the request body in the artifact registry and registers it (method on Handlers).
+// Older builds of the application past the keep query parameter are pruned from the registry.
+func (h *Handlers) CLIPushArtifact(c *gin.Context) {
+	appID, ok := parseApplicationID(c.Query("app_id"))
+	md5Hash := c.Query("md5")
+	version := c.Query("version")
+	if !ok || version == "" || !registry.ValidMD5(md5Hash) {
+		response.BadRequest(c, "app_id, version and the full md5 query parameters are required")
+		return
+	}
+	format := c.Query("compression")
+	if err := compression.Validate(format); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	store, err := h.artifactStore()
+	if err != nil {
+		response.InternalServerError(c, "Artifact registry unavailable: "+err.Error())
+		return
+	}
+
+	keep, _ := strconv.Atoi(c.Query("keep"))
+	artifact, err := h.storeArtifact(c.Request.Context(), store, &models.BuildArtifact{
+		ApplicationID: appID,
+		Version:       version,
+		GitCommitSHA:  c.Query("git_commit_sha"),
+		MD5Hash:       md5Hash,
+		LocalPath:     c.Query("local_path"),
+		SignedBy:      c.GetString("username"),
+		Compression:   compression.Normalize(format),
+	}, c.Request.Body, keep)
+	if errors.Is(err, registry.ErrChecksumMismatch) {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+
+	response.Created(c, buildArtifactDTO(artifact))
+}
+
+// artifactStore returns the store of the artifact registry.
+func (h *Handlers) artifactStore() (registry.Store, error) {
+	if h.Artifacts != nil {
+		return h.Artifacts, nil
+	}
+	return registry.Default()
+}
+
+// storeArtifact pushes a tarball to the registry, signs it with the active signing key and registers it,
+// or marks the build already registered under its MD5 as stored. The artifact names who it is signed for
+// in SignedBy. Then older builds of the application past keep are pruned.
+func (h *Handlers) storeArtifact(ctx context.Context, store registry.Store, artifact *models.BuildArtifact, r io.Reader, keep int) (*models.BuildArtifact, error) {
+	existing, _ := h.Repo.GetBuildArtifactByMD5Prefix(artifact.ApplicationID, artifact.MD5Hash)
+	// Builds stored before artifacts were signed are pushed again to get a signature
+	if existing != nil && existing.Stored && existing.Signature != "" {
+		return existing, nil
+	}
+
+	key, privateKey, err := h.Repo.GetActiveSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing key: %w", err)
+	}
+	sum, err := registry.Push(ctx, store, artifact.MD5Hash, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to push artifact: %w", err)
+	}
+
+	if existing != nil {
+		existing.SignedBy = artifact.SignedBy
+		artifact = existing
+	}
+	artifact.Stored = true
+	artifact.SHA256 = sum
+	artifact.Signature = signing.Sign(privateKey, artifact.ApplicationID.String(), sum)
+	artifact.SigningKey = key.Name
+	if existing != nil {
+		err = h.Repo.MarkBuildArtifactStored(artifact)
+	} else {
+		err = h.Repo.AddBuildArtifact(artifact)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to register artifact: %w", err)
+	}
+	log.Printf("📦 Build artifact %s (Version: %s) pushed to the registry, signed with key '%s' for %s", artifact.MD5Hash, artifact.Version, key.Name, artifact.SignedBy)
+
+	h.pruneArtifacts(ctx, store, artifact.ApplicationID, keep)
+	return artifact, nil
+}
+
+// pruneArtifacts deletes the builds of an application kept in the registry past the newest keep ones,
+// config.DefaultKeepArtifacts if keep is not set. Builds registered without being pushed are left alone.
+func (h *Handlers) pruneArtifacts(ctx context.Context, store registry.Store, appID uuid.UUID, keep int) {
+	if keep <= 0 {
+		keep = config.DefaultKeepArtifacts
+	}
+	artifacts, err := h.Repo.GetAllBuildArtifactsForApp(appID)
+	if err != nil {
+		log.Printf("⚠️ Failed to list build artifacts for retention: %v", err)
+		return
+	}
+
+	stored := 0
+	for _, artifact := range artifacts { // newest first
+		if !artifact.Stored {
+			continue
+		}
+		if stored++; stored <= keep {
+			continue
+		}
+		if err := store.Delete(ctx, registry.Key(artifact.MD5Hash)); err != nil {
+			log.Printf("⚠️ Failed to delete build artifact %s from the registry: %v", artifact.MD5Hash, err)
+			continue
+		}
+		if err := h.Repo.DeleteBuildArtifact(artifact.ID); err != nil {
+			log.Printf("⚠️ Failed to delete build artifact %s: %v", artifact.MD5Hash, err)
+			continue
+		}
+		log.Printf("🧹 Pruned build artifact %s (Version: %s) from the registry", artifact.MD5Hash, artifact.Version)
+	}
+}
+
+func buildArtifactDTO(artifact *models.BuildArtifact) *types.BuildArtifactDTO {
+	return &types.BuildArtifactDTO{
+		ID:            utils.EncodeFriendlyID(utils.PrefixBuildArtifact, artifact.ID),
+		ApplicationID: utils.EncodeFriendlyID(utils.PrefixApplication, artifact.ApplicationID),
+		GitCommitSHA:  artifact.GitCommitSHA,
+		Version:       artifact.Version,
+		MD5Hash:       artifact.MD5Hash,
+		LocalPath:     artifact.LocalPath,
+		Stored:        artifact.Stored,
+		SHA256:        artifact.SHA256,
+		Signature:     artifact.Signature,
+		SigningKey:    artifact.SigningKey,
+		SignedBy:      artifact.SignedBy,
+		Compression:   compression.Normalize(artifact.Compression),
+		CreatedAt:     artifact.CreatedAt.Time,
+	}
+}
+
+// CLIDownloadArtifact pulls a build artifact from the artifact registry (CLI endpoint)
+func CLIDownloadArtifact(c *gin.Context) {
+	h := &Handlers{Repo: defaultCLIRepo}
+	h.CLIDownloadArtifact(c)
+}
+
+// CLIDownloadArtifactHandler sends the tarball of a build artifact from the artifact registry (method on Handlers)
+func (h *Handlers) CLIDownloadArtifact(c *gin.Context) {
+	appID, ok := parseApplicationID(c.Query("app_id"))
+	md5Hash := c.Query("md5")
+	if !ok || md5Hash == "" {
+		response.BadRequest(c, "app_id and md5 query parameters are required")
+		return
+	}
+
+	artifact, err := h.Repo.GetBuildArtifactByMD5Prefix(appID, md5Hash)
+	if err != nil || artifact == nil {
+		response.NotFound(c, "Artifact not found")
+		return
+	}
+	// Builds only registered by the CLI that made them live on its machine
+	if !artifact.Stored {
+		response.NotFound(c, "Artifact is not in the registry")
+		return
+	}
+
+	store, err := h.artifactStore()
+	if err != nil {
+		response.InternalServerError(c, "Artifact registry unavailable: "+err.Error())
+		return
+	}
+	r, err := store.Get(c.Request.Context(), registry.Key(artifact.MD5Hash))
+	if errors.Is(err, registry.ErrNotFound) {
+		response.NotFound(c, "Artifact file is missing from the registry")
+		return
+	}
+	if err != nil {
+		response.InternalServerError(c, "Failed to read artifact: "+err.Error())
+		return
+	}
+	defer r.Close()
+
+	c.DataFromReader(http.StatusOK, -1, "application/octet-stream", r, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s%s"`, artifact.MD5Hash, compression.Extension(artifact.Compression)),
+	})
+}
+
+// CLIDeleteBuildArtifact deletes a build artifact (CLI endpoint)
+func CLIDeleteBuildArtifact(c *gin.Context) {
+	h := &Handlers{Repo: defaultCLIRepo}
+	h.CLIDeleteBuildArtifact(c)
+}
+
+// CLIDeleteBuildArtifactHandler deletes a build of an application from the registry and the build history,
+// by its full MD5 (method on Handlers)
+func (h *Handlers) CLIDeleteBuildArtifact(c *gin.Context) {
+	app, artifact, ok := h.lookupBuildArtifact(c)
+	if !ok {
+		return
+	}
+
+	if artifact.Stored {
+		store, err := h.artifactStore()
+		if err != nil {
+			response.InternalServerError(c, "Artifact registry unavailable: "+err.Error())
+			return
+		}
+		if err := store.Delete(c.Request.Context(), registry.Key(artifact.MD5Hash)); err != nil {
+			response.InternalServerError(c, "Failed to delete artifact from the registry: "+err.Error())
+			return
+		}
+	}
+	if err := h.Repo.DeleteBuildArtifact(artifact.ID); err != nil {
+		response.InternalServerError(c, "Failed to delete build artifact: "+err.Error())
+		return
+	}
+	log.Printf("🧹 Deleted build artifact %s (Version: %s) of app '%s'", artifact.MD5Hash, artifact.Version, app.Name)
+	response.Message(c, "Build artifact deleted")
+}
+
+// CLIListBuildDeployments lists the deployments that shipped a build artifact (CLI endpoint)
+func CLIListBuildDeployments(c *gin.Context) {
+	h := &Handlers{Repo: defaultCLIRepo}
+	h.CLIListBuildDeployments(c)
+}
+
+// CLIListBuildDeploymentsHandler lists the deployments that shipped a build of an application, newest first
+// (method on Handlers)
+func (h *Handlers) CLIListBuildDeployments(c *gin.Context) {
+	app, artifact, ok := h.lookupBuildArtifact(c)
+	if !ok {
+		return
+	}
+
+	history, err := h.Repo.GetBuildArtifactDeployments(app.ID, artifact.MD5Hash)
+	if err != nil {
+		response.InternalServerError(c, "Failed to get deployments: "+err.Error())
+		return
+	}
+	deployments := make([]types.DeploymentHistoryDTO, len(history))
+	for i, row := range history {
+		createdAt := row.CreatedAt
+		deployments[i] = types.DeploymentHistoryDTO{
+			UID:        utils.EncodeFriendlyID(utils.PrefixDeployment, row.ID),
+			Version:    row.Version,
+			Status:     row.Status,
+			HostName:   row.HostName,
+			SigningKey: row.SigningKey,
+			SignedBy:   row.SignedBy,
+			CreatedAt:  &createdAt,
+		}
+	}
+	response.Data(c, deployments)
+}
+
+// lookupBuildArtifact finds the build of the app query parameter with the full MD5 of the md5 path parameter,
+// or responds with an error.
+func (h *Handlers) lookupBuildArtifact(c *gin.Context) (*models.Application, *models.BuildArtifact, bool) {
+	appName := c.Query("app")
+	md5Hash := c.Param("md5")
+	if appName == "" || !registry.ValidMD5(md5Hash) {
+		response.BadRequest(c, "app query parameter and full build MD5 are required")
+		return nil, nil, false
+	}
+
+	app, err := h.Repo.GetApplicationByName(appName)
+	if err != nil {
+		response.NotFound(c, "Application not found: "+appName)
+		return nil, nil, false
+	}
+	artifact, err := h.Repo.GetBuildArtifactByMD5Prefix(app.ID, md5Hash)
+	if err != nil || artifact == nil {
+		response.NotFound(c, "Build artifact not found: "+md5Hash)
+		return nil, nil, false
+	}
+	return app, artifact, true
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"youfun/shipyard/internal/api/response"
+	"youfun/shipyard/internal/api/utils"
+	"youfun/shipyard/internal/deploy"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/pkg/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// canaryResponse converts a canary for API responses.
+func canaryResponse(canary *models.InstanceCanary, status string) types.CanaryDTO {
+	return types.CanaryDTO{
+		DeploymentID: utils.EncodeFriendlyID(utils.PrefixDeployment, canary.DeploymentID),
+		Status:       status,
+		StablePort:   canary.StablePort,
+		StableWeight: 100 - canary.CanaryWeight,
+		CanaryPort:   canary.CanaryPort,
+		CanaryWeight: canary.CanaryWeight,
+		StartedAt:    canary.StartedAt.Time,
+		UpdatedAt:    canary.UpdatedAt.Time,
+	}
+}
+
+// canaryConflict rejects a request that would replace the release serving traffic while a canary is running.
+// It writes a 409 response naming the canary and returns true when the instance has one.
+func (h *Handlers) canaryConflict(c *gin.Context, instanceID uuid.UUID) bool {
+	canary, err := h.Repo.GetInstanceCanary(instanceID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to read canary")
+		return true
+	}
+	if canary == nil {
+		return false
+	}
+	response.Error(c, http.StatusConflict, fmt.Sprintf("canary %s is receiving %d%% of traffic, promote or abort it first",
+		utils.EncodeFriendlyID(utils.PrefixDeployment, canary.DeploymentID), canary.CanaryWeight))
+	return true
+}
+
+// StartDeploymentCanary records that a deployment serves a share of the traffic (from CLI)
+func StartDeploymentCanary(c *gin.Context) {
+	h := &Handlers{Repo: defaultDeploymentsRepo}
+	h.StartDeploymentCanary(c)
+}
+
+// StartDeploymentCanaryHandler records the canary of a deployment (method on Handlers)
+func (h *Handlers) StartDeploymentCanary(c *gin.Context) {
+	deployID, err := utils.DecodeFriendlyID(utils.PrefixDeployment, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid deployment ID")
+		return
+	}
+
+	var req types.StartCanaryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request")
+		return
+	}
+	if req.Weight < 1 || req.Weight > 99 {
+		response.BadRequest(c, "Canary weight must be between 1 and 99")
+		return
+	}
+
+	history, err := h.Repo.GetDeploymentHistoryByID(deployID)
+	if err != nil {
+		response.NotFound(c, "Deployment not found")
+		return
+	}
+	instance, err := h.Repo.GetApplicationInstanceByID(history.InstanceID)
+	if err != nil {
+		response.NotFound(c, "Application instance not found")
+		return
+	}
+	if !instance.ActivePort.Valid || instance.ActivePort.Int64 <= 0 {
+		response.BadRequest(c, "Instance has no active release to split traffic with")
+		return
+	}
+
+	canary := &models.InstanceCanary{
+		InstanceID:   instance.ID,
+		DeploymentID: deployID,
+		StablePort:   int(instance.ActivePort.Int64),
+		CanaryPort:   req.Port,
+		CanaryWeight: req.Weight,
+		ReleasePath:  req.ReleasePath,
+		GitCommitSHA: req.GitCommitSHA,
+	}
+	if err := h.Repo.StartInstanceCanary(canary); err != nil {
+		response.InternalServerError(c, "Failed to record canary")
+		return
+	}
+	if err := h.Repo.UpdateDeploymentHistoryStatusOnly(deployID, string(models.DeploymentStatusCanary)); err != nil {
+		log.Printf("⚠️ Failed to update deployment status: %v", err)
+	}
+
+	response.Data(c, canaryResponse(canary, models.CanaryStatusRunning))
+}
+
+// PromoteCanary sends more traffic to the canary of an application instance
+func PromoteCanary(c *gin.Context) {
+	h := &Handlers{Repo: defaultAppsRepo}
+	h.PromoteCanary(c)
+}
+
+// PromoteCanaryHandler sends more traffic to a canary (method on Handlers)
+func (h *Handlers) PromoteCanary(c *gin.Context) {
+	instanceID, err := utils.DecodeFriendlyID(utils.PrefixAppInstance, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid instance ID")
+		return
+	}
+
+	// Body is optional: without a weight the canary moves to its next step
+	var req types.PromoteCanaryRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "Invalid request")
+			return
+		}
+	}
+
+	h.updateCanary(c, instanceID, func() (*models.InstanceCanary, string, error) {
+		canary, err := deploy.PromoteCanary(instanceID, req.Weight)
+		if err != nil || canary.CanaryWeight < 100 {
+			return canary, models.CanaryStatusRunning, err
+		}
+		return canary, models.CanaryStatusPromoted, nil
+	})
+}
+
+// AbortCanary sends all traffic of an application instance back to its stable release
+func AbortCanary(c *gin.Context) {
+	h := &Handlers{Repo: defaultAppsRepo}
+	h.AbortCanary(c)
+}
+
+// AbortCanaryHandler stops the canary of an instance (method on Handlers)
+func (h *Handlers) AbortCanary(c *gin.Context) {
+	instanceID, err := utils.DecodeFriendlyID(utils.PrefixAppInstance, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid instance ID")
+		return
+	}
+
+	h.updateCanary(c, instanceID, func() (*models.InstanceCanary, string, error) {
+		canary, err := deploy.AbortCanary(instanceID)
+		return canary, models.CanaryStatusAborted, err
+	})
+}
+
+// updateCanary runs a change to the canary of an instance under the instance lock and writes the response.
+func (h *Handlers) updateCanary(c *gin.Context, instanceID uuid.UUID, update func() (*models.InstanceCanary, string, error)) {
+	canary, err := h.Repo.GetInstanceCanary(instanceID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to read canary")
+		return
+	}
+	if canary == nil {
+		response.NotFound(c, "No canary is running for this instance")
+		return
+	}
+
+	lock, ok := h.acquireLock(c, instanceID, models.LockKindCanary, "", canaryLockTTL)
+	if !ok {
+		return
+	}
+	defer h.releaseLock(lock)
+
+	canary, status, err := update()
+	if errors.Is(err, deploy.ErrNoCanary) {
+		response.NotFound(c, "No canary is running for this instance")
+		return
+	}
+	if errors.Is(err, deploy.ErrInvalidCanaryWeight) {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	if err != nil {
+		response.InternalServerError(c, "Canary update failed: "+err.Error())
+		return
+	}
+	response.Data(c, canaryResponse(canary, status))
+}
@@ -4,7 +4,12 @@ import (
 	"net/http"
 	"youfun/shipyard/internal/api/response"
 	"youfun/shipyard/internal/api/utils"
+	"youfun/shipyard/internal/compression"
+	"youfun/shipyard/internal/crypto"
+	"youfun/shipyard/internal/deploy"
 	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/signing"
+	"youfun/shipyard/pkg/types"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -237,6 +242,42 @@ func (h *Handlers) CLIListHosts(c *gin.Context) {
 	response.Data(c, resp)
 }
 
+// CLIListLinkedHosts lists the hosts an application is linked to, for multi-host rollouts
+func CLIListLinkedHosts(c *gin.Context) {
+	h := &Handlers{Repo: defaultCLIRepo}
+	h.CLIListLinkedHosts(c)
+}
+
+// CLIListLinkedHostsHandler lists the hosts an application is linked to (method on Handlers)
+func (h *Handlers) CLIListLinkedHosts(c *gin.Context) {
+	appName := c.Query("app")
+	if appName == "" {
+		response.BadRequest(c, "app parameter is required")
+		return
+	}
+
+	hosts, err := h.Repo.GetLinkedHostsForApp(appName)
+	if err != nil {
+		response.NotFound(c, "Application not found")
+		return
+	}
+
+	resp := make([]gin.H, 0, len(hosts))
+	for _, host := range hosts {
+		resp = append(resp, gin.H{
+			"uid":    utils.EncodeFriendlyID(utils.PrefixSSHHost, host.ID),
+			"name":   host.Name,
+			"addr":   host.Addr,
+			"port":   host.Port,
+			"user":   host.User,
+			"status": host.Status,
+			"arch":   host.Arch,
+		})
+	}
+
+	response.Data(c, resp)
+}
+
 // CLIGetInstance gets instance info for app+host combination
 func CLIGetInstance(c *gin.Context) {
 	h := &Handlers{Repo: defaultCLIRepo}
@@ -259,13 +300,21 @@ func (h *Handlers) CLIGetInstance(c *gin.Context) {
 		return
 	}
 
+	instanceResp := gin.H{
+		"uid":                  utils.EncodeFriendlyID(utils.PrefixAppInstance, instance.ID),
+		"status":               instance.Status,
+		"active_port":          instance.ActivePort.Int64,
+		"previous_active_port": instance.PreviousActivePort.Int64,
+	}
+	if ports, err := h.Repo.GetActiveInstancePorts(instance.ID); err == nil && len(ports) > 0 {
+		instanceResp["active_ports"] = ports
+	}
+	if canary, err := h.Repo.GetInstanceCanary(instance.ID); err == nil && canary != nil {
+		instanceResp["canary"] = canaryResponse(canary, models.CanaryStatusRunning)
+	}
+
 	response.Data(c, gin.H{
-		"instance": gin.H{
-			"uid":                  utils.EncodeFriendlyID(utils.PrefixAppInstance, instance.ID),
-			"status":               instance.Status,
-			"active_port":          instance.ActivePort.Int64,
-			"previous_active_port": instance.PreviousActivePort.Int64,
-		},
+		"instance": instanceResp,
 		"app": gin.H{
 			"uid":  utils.EncodeFriendlyID(utils.PrefixApplication, app.ID),
 			"name": app.Name,
@@ -337,7 +386,49 @@ func (h *Handlers) CLIGetDeployConfig(c *gin.Context) {
 		domainList = append(domainList, d.Hostname)
 	}
 
-	// 4. Construct Response using gin.H for consistency with other handlers
+	// 4. Get the keys artifacts must be signed with, the CLI refuses to deploy anything else
+	trusted, err := h.Repo.GetTrustedSigningKeys(app.ID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to fetch trusted keys: "+err.Error())
+		return
+	}
+	trustedKeys := make([]types.TrustedKeyDTO, len(trusted))
+	for i, key := range trusted {
+		trustedKeys[i] = types.TrustedKeyDTO{Name: key.Name, PublicKey: signing.EncodePublicKey(key.PublicKey)}
+	}
+
+	// 5. Get the Erlang nodes of the app on all hosts, BEAM releases list them in CLUSTER_NODES
+	peers, err := h.Repo.GetActivePeersForApp(app.ID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to fetch cluster nodes: "+err.Error())
+		return
+	}
+
+	// 6. Get the hosts of the app, the first one runs the jobs of [[cron]] without a host
+	linked, err := h.Repo.GetLinkedHostsForApp(app.Name)
+	if err != nil {
+		response.InternalServerError(c, "Failed to fetch linked hosts: "+err.Error())
+		return
+	}
+	linkedHosts := make([]string, len(linked))
+	for i, linkedHost := range linked {
+		linkedHosts[i] = linkedHost.Name
+	}
+
+	// 7. Construct Response using gin.H for consistency with other handlers
+	instanceResp := gin.H{
+		"id":                   instance.ID.String(), // Raw UUID for client parsing
+		"uid":                  utils.EncodeFriendlyID(utils.PrefixAppInstance, instance.ID),
+		"application_id":       instance.ApplicationID.String(), // Raw UUID for client parsing
+		"host_id":              instance.HostID.String(),        // Raw UUID for client parsing
+		"status":               instance.Status,
+		"active_port":          instance.ActivePort.Int64,
+		"previous_active_port": instance.PreviousActivePort.Int64,
+	}
+	// All instances serving traffic, the deployment starts as many of the new release
+	if ports, err := h.Repo.GetActiveInstancePorts(instance.ID); err == nil && len(ports) > 0 {
+		instanceResp["active_ports"] = ports
+	}
 
 	resp := gin.H{
 		"app": gin.H{
@@ -355,17 +446,12 @@ func (h *Handlers) CLIGetDeployConfig(c *gin.Context) {
 			"password":    host.Password,
 			"private_key": host.PrivateKey,
 		},
-		"instance": gin.H{
-			"id":                   instance.ID.String(), // Raw UUID for client parsing
-			"uid":                  utils.EncodeFriendlyID(utils.PrefixAppInstance, instance.ID),
-			"application_id":       instance.ApplicationID.String(), // Raw UUID for client parsing
-			"host_id":              instance.HostID.String(),        // Raw UUID for client parsing
-			"status":               instance.Status,
-			"active_port":          instance.ActivePort.Int64,
-			"previous_active_port": instance.PreviousActivePort.Int64,
-		},
-		"secrets": secrets,
-		"domains": domainList,
+		"instance":      instanceResp,
+		"secrets":       secrets,
+		"domains":       domainList,
+		"trusted_keys":  trustedKeys,
+		"cluster_nodes": deploy.ClusterNodes(app.Name, peers),
+		"linked_hosts":  linkedHosts,
 	}
 
 	response.Data(c, resp)
@@ -378,20 +464,23 @@ func CLICheckArtifact(c *gin.Context) {
 }
 
 // CLICheckArtifactHandler checks if a build artifact exists (CLI endpoint) (method on Handlers)
-// Supports query by: md5_prefix (short MD5 hash), git_sha (full git commit SHA), or query (auto-detect)
+// Supports query by: md5_prefix (short MD5 hash), git_sha (full git commit SHA), version, or query (auto-detect).
+// "stored" tells whether the artifact can be pulled from the registry, or only lives on the machine that built it.
+// The signature lets the CLI verify the artifact before deploying it.
 func (h *Handlers) CLICheckArtifact(c *gin.Context) {
 	appID := c.Query("app_id")
 	md5Prefix := c.Query("md5_prefix")
 	gitSHA := c.Query("git_sha")
-	query := c.Query("query") // Generic query that tries both
+	version := c.Query("version")
+	query := c.Query("query") // Generic query that tries all of them
 
 	if appID == "" {
 		response.BadRequest(c, "app_id is required")
 		return
 	}
 
-	if md5Prefix == "" && gitSHA == "" && query == "" {
-		response.BadRequest(c, "One of md5_prefix, git_sha, or query is required")
+	if md5Prefix == "" && gitSHA == "" && version == "" && query == "" {
+		response.BadRequest(c, "One of md5_prefix, git_sha, version, or query is required")
 		return
 	}
 
@@ -408,18 +497,23 @@ func (h *Handlers) CLICheckArtifact(c *gin.Context) {
 
 	var artifact *models.BuildArtifact
 
-	// Priority: md5_prefix > git_sha > query (try md5 first, then git_sha)
+	// Priority: md5_prefix > git_sha > version > query (try md5 first, then git_sha, then version)
 	if md5Prefix != "" {
 		artifact, err = h.Repo.GetBuildArtifactByMD5Prefix(appUUID, md5Prefix)
 	} else if gitSHA != "" {
 		artifact, err = h.Repo.GetBuildArtifactByGitSHA(appUUID, gitSHA)
+	} else if version != "" {
+		artifact, err = h.Repo.GetLatestBuildArtifactByVersion(appUUID, version)
 	} else if query != "" {
-		// Auto-detect: try MD5 prefix first, then git SHA
+		// Auto-detect: try MD5 prefix first, then git SHA, then version
 		artifact, err = h.Repo.GetBuildArtifactByMD5Prefix(appUUID, query)
 		if err != nil {
 			// Try git SHA
 			artifact, err = h.Repo.GetBuildArtifactByGitSHA(appUUID, query)
 		}
+		if err != nil {
+			artifact, err = h.Repo.GetLatestBuildArtifactByVersion(appUUID, query)
+		}
 	}
 
 	if err != nil || artifact == nil {
@@ -434,6 +528,12 @@ func (h *Handlers) CLICheckArtifact(c *gin.Context) {
 		"version":        artifact.Version,
 		"md5_hash":       artifact.MD5Hash,
 		"local_path":     artifact.LocalPath,
+		"stored":         artifact.Stored,
+		"sha256":         artifact.SHA256,
+		"signature":      artifact.Signature,
+		"signing_key":    artifact.SigningKey,
+		"signed_by":      artifact.SignedBy,
+		"compression":    compression.Normalize(artifact.Compression),
 		"created_at":     artifact.CreatedAt.Time.Format(time.RFC3339),
 	})
 }
@@ -445,6 +545,7 @@ type RegisterArtifactRequest struct {
 	Version       string `json:"version" binding:"required"`
 	MD5Hash       string `json:"md5_hash" binding:"required"`
 	LocalPath     string `json:"local_path" binding:"required"`
+	Compression   string `json:"compression"` // gzip (default), zstd or none
 }
 
 // CLIRegisterArtifact registers a new build artifact (CLI endpoint)
@@ -478,6 +579,11 @@ func (h *Handlers) CLIRegisterArtifact(c *gin.Context) {
 		Version:       req.Version,
 		MD5Hash:       req.MD5Hash,
 		LocalPath:     req.LocalPath,
+		Compression:   req.Compression,
+	}
+	if err := compression.Validate(artifact.Compression); err != nil {
+		response.BadRequest(c, err.Error())
+		return
 	}
 
 	if err := h.Repo.AddBuildArtifact(artifact); err != nil {
@@ -602,6 +708,45 @@ func (h *Handlers) CLIUnsetSecret(c *gin.Context) {
 	})
 }
 
+// CLIEnsureReleaseCookie returns the Erlang distribution cookie of an application (CLI endpoint)
+func CLIEnsureReleaseCookie(c *gin.Context) {
+	h := &Handlers{Repo: defaultCLIRepo}
+	h.CLIEnsureReleaseCookie(c)
+}
+
+// CLIEnsureReleaseCookieHandler returns the Erlang distribution cookie of an application, generating it on
+// first use. Hosts deployed in parallel all get the same cookie (CLI endpoint) (method on Handlers)
+func (h *Handlers) CLIEnsureReleaseCookie(c *gin.Context) {
+	appName := c.Query("app")
+	if appName == "" {
+		response.BadRequest(c, "app query parameter is required")
+		return
+	}
+
+	app, err := h.Repo.GetApplicationByName(appName)
+	if err != nil {
+		response.NotFound(c, "Application not found: "+appName)
+		return
+	}
+
+	cookie, err := crypto.GenerateReleaseCookie()
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	cookie, err = h.Repo.EnsureSecret(app.ID, deploy.ReleaseCookieKey, cookie)
+	if err != nil {
+		response.InternalServerError(c, "Failed to save release cookie: "+err.Error())
+		return
+	}
+
+	response.Data(c, gin.H{
+		"app":    appName,
+		"key":    deploy.ReleaseCookieKey,
+		"cookie": cookie,
+	})
+}
+
 // --- Domains Sync Management ---
 
 // CLISyncDomains syncs domains from config to database (CLI endpoint)
@@ -753,18 +898,35 @@ func (h *Handlers) CLIListBuildArtifacts(c *gin.Context) {
 		response.InternalServerError(c, "Failed to list build artifacts: "+err.Error())
 		return
 	}
+	// build prune keeps recently deployed builds, so the list is incomplete without their deployments
+	lastDeployed, err := h.Repo.GetBuildArtifactsLastDeployed(app.ID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to get deployments of build artifacts: "+err.Error())
+		return
+	}
 
 	var resp []gin.H
 	for _, artifact := range artifacts {
 		item := gin.H{
 			"id":             utils.EncodeFriendlyID(utils.PrefixBuildArtifact, artifact.ID),
+			"application_id": utils.EncodeFriendlyID(utils.PrefixApplication, artifact.ApplicationID),
 			"version":        artifact.Version,
 			"git_commit_sha": artifact.GitCommitSHA,
 			"md5_hash":       artifact.MD5Hash,
+			"local_path":     artifact.LocalPath,
+			"stored":         artifact.Stored,
+			"sha256":         artifact.SHA256,
+			"signature":      artifact.Signature,
+			"signing_key":    artifact.SigningKey,
+			"signed_by":      artifact.SignedBy,
+			"compression":    compression.Normalize(artifact.Compression),
 		}
 		if artifact.CreatedAt.Time != nil {
 			item["created_at"] = artifact.CreatedAt.Time.Format(time.RFC3339)
 		}
+		if deployedAt, ok := lastDeployed[artifact.MD5Hash]; ok {
+			item["last_deployed_at"] = deployedAt.Format(time.RFC3339)
+		}
 		resp = append(resp, item)
 	}
 
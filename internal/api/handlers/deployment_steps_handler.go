@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"youfun/shipyard/internal/api/response"
+	"youfun/shipyard/internal/api/utils"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/signing"
+	"youfun/shipyard/pkg/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// deploymentStepResponses converts recorded deployment steps into API response items.
+func deploymentStepResponses(steps []models.DeploymentStep) []types.DeploymentStepDTO {
+	responses := make([]types.DeploymentStepDTO, len(steps))
+	for i, s := range steps {
+		responses[i] = types.DeploymentStepDTO{
+			Name:       s.Name,
+			Position:   s.Position,
+			Status:     s.Status,
+			Detail:     s.Detail,
+			Error:      s.Error,
+			StartedAt:  s.StartedAt.Time,
+			FinishedAt: s.FinishedAt.Time,
+			DurationMs: s.DurationMs,
+		}
+	}
+	return responses
+}
+
+// UpdateDeploymentStep records the state of one step of a deployment (from CLI)
+func UpdateDeploymentStep(c *gin.Context) {
+	h := &Handlers{Repo: defaultDeploymentsRepo}
+	h.UpdateDeploymentStep(c)
+}
+
+// UpdateDeploymentStepHandler records the state of a deployment step (method on Handlers)
+func (h *Handlers) UpdateDeploymentStep(c *gin.Context) {
+	deployID, err := utils.DecodeFriendlyID(utils.PrefixDeployment, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid deployment ID")
+		return
+	}
+	name := c.Param("name")
+	position := slices.Index(models.DeploySteps, name)
+	if position < 0 {
+		response.BadRequest(c, "Unknown deployment step: "+name)
+		return
+	}
+
+	var req types.DeploymentStepDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request")
+		return
+	}
+	switch req.Status {
+	case models.StepStatusRunning, models.StepStatusSuccess, models.StepStatusFailed, models.StepStatusReverted:
+	default:
+		response.BadRequest(c, "Invalid step status: "+req.Status)
+		return
+	}
+
+	history, err := h.Repo.GetDeploymentHistoryByID(deployID)
+	if err != nil {
+		response.NotFound(c, "Deployment not found")
+		return
+	}
+
+	step := &models.DeploymentStep{
+		DeploymentID: deployID,
+		Name:         name,
+		Position:     position + 1,
+		Status:       req.Status,
+		Detail:       req.Detail,
+		Error:        req.Error,
+		StartedAt:    models.NullableTime{Time: req.StartedAt},
+		FinishedAt:   models.NullableTime{Time: req.FinishedAt},
+		DurationMs:   req.DurationMs,
+	}
+	if err := h.Repo.SaveDeploymentStep(step); err != nil {
+		response.InternalServerError(c, "Failed to save deployment step")
+		return
+	}
+	// The artifact step reports the MD5 of the artifact the deployment ships
+	if name == models.DeployStepArtifact && req.Status == models.StepStatusSuccess && req.Detail != "" {
+		h.recordDeploymentSigner(deployID, history.InstanceID, req.Detail)
+	}
+	response.Message(c, "Step updated successfully")
+}
+
+// recordDeploymentSigner records in the deployment history who signed the artifact of a deployment, once
+// its signature is verified against the keys the application trusts.
+func (h *Handlers) recordDeploymentSigner(deployID, instanceID uuid.UUID, md5Hash string) {
+	instance, err := h.Repo.GetApplicationInstanceByID(instanceID)
+	if err != nil {
+		return
+	}
+	artifact, err := h.Repo.GetBuildArtifactByMD5Prefix(instance.ApplicationID, md5Hash)
+	if err != nil || artifact == nil {
+		return
+	}
+	trusted, err := h.Repo.GetTrustedSigningKeys(instance.ApplicationID)
+	if err != nil {
+		log.Printf("⚠️ Failed to get trusted keys: %v", err)
+		return
+	}
+	keyName, err := signing.Verify(trusted, instance.ApplicationID.String(), artifact.SHA256, artifact.Signature)
+	if err != nil {
+		log.Printf("⚠️ Artifact %s of deployment %s is not signed by a trusted key: %v", md5Hash, utils.EncodeFriendlyID(utils.PrefixDeployment, deployID), err)
+		return
+	}
+	if err := h.Repo.SetDeploymentSigner(deployID, keyName, artifact.SignedBy); err != nil {
+		log.Printf("⚠️ Failed to record the signer of deployment %s: %v", utils.EncodeFriendlyID(utils.PrefixDeployment, deployID), err)
+	}
+}
+
+// ResumeDeployment takes up a failed deployment again, so the CLI can continue from its first incomplete step
+func ResumeDeployment(c *gin.Context) {
+	h := &Handlers{Repo: defaultDeploymentsRepo}
+	h.ResumeDeployment(c)
+}
+
+// ResumeDeploymentHandler reopens a failed or cancelled deployment under the instance lock (method on Handlers)
+func (h *Handlers) ResumeDeployment(c *gin.Context) {
+	uid := c.Param("uid")
+	deployID, err := utils.DecodeFriendlyID(utils.PrefixDeployment, uid)
+	if err != nil {
+		response.BadRequest(c, "Invalid deployment ID")
+		return
+	}
+
+	history, err := h.Repo.GetDeploymentHistoryByID(deployID)
+	if err != nil {
+		response.NotFound(c, "Deployment not found")
+		return
+	}
+	if history.Kind == models.DeploymentKindRollback {
+		response.BadRequest(c, "Rollbacks cannot be resumed")
+		return
+	}
+	if history.Status != string(models.DeploymentStatusFailed) && history.Status != string(models.DeploymentStatusCancelled) {
+		response.Error(c, http.StatusConflict, fmt.Sprintf("deployment %s is %s, only failed or cancelled deployments can be resumed", uid, history.Status))
+		return
+	}
+
+	// Resuming an older deployment would take the traffic back from a newer one
+	newer, err := h.Repo.HasNewerDeployment(deployID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to check for newer deployments")
+		return
+	}
+	if newer {
+		response.Error(c, http.StatusConflict, fmt.Sprintf("deployment %s is not the latest deployment of its instance, start a new deployment instead", uid))
+		return
+	}
+
+	steps, err := h.Repo.GetDeploymentSteps(deployID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to get deployment steps")
+		return
+	}
+	if len(steps) == 0 {
+		response.Error(c, http.StatusConflict, fmt.Sprintf("deployment %s has no recorded steps to resume from", uid))
+		return
+	}
+
+	instance, err := h.Repo.GetApplicationInstanceByID(history.InstanceID)
+	if err != nil {
+		response.NotFound(c, "Application instance not found")
+		return
+	}
+	app, err := h.Repo.GetApplicationByID(instance.ApplicationID)
+	if err != nil {
+		response.NotFound(c, "Application not found")
+		return
+	}
+
+	if h.canaryConflict(c, instance.ID) {
+		return
+	}
+	lock, ok := h.acquireLock(c, instance.ID, models.LockKindDeploy, "", deployLockTTL)
+	if !ok {
+		return
+	}
+	if err := h.Repo.SetInstanceLockDeployment(instance.ID, deployID); err != nil {
+		h.releaseLock(lock)
+		response.InternalServerError(c, "Failed to acquire instance lock")
+		return
+	}
+	if err := h.Repo.UpdateDeploymentHistoryStatusOnly(deployID, string(models.DeploymentStatusPending)); err != nil {
+		log.Printf("⚠️ Failed to reopen deployment %s: %v", uid, err)
+	}
+
+	response.Data(c, types.ResumeDeploymentResponse{
+		DeploymentID: uid,
+		AppName:      app.Name,
+		HostName:     history.HostName,
+		Version:      history.Version,
+		Steps:        deploymentStepResponses(steps),
+	})
+}
@@ -3,12 +3,16 @@ package handlers
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"youfun/shipyard/internal/api/response"
 	"youfun/shipyard/internal/api/utils"
 	"youfun/shipyard/internal/deploy"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/pkg/types"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // Legacy function wrappers for backward compatibility
@@ -29,9 +33,10 @@ type DeploymentResponse struct {
 
 // CreateDeploymentRequest represents a deployment creation request from CLI
 type CreateDeploymentRequest struct {
-	AppName  string `json:"app_name" binding:"required"`
-	HostName string `json:"host_name" binding:"required"`
-	Version  string `json:"version"`
+	AppName   string `json:"app_name" binding:"required"`
+	HostName  string `json:"host_name" binding:"required"`
+	Version   string `json:"version"`
+	RolloutID string `json:"rollout_id"`
 }
 
 // ListDeployments returns deployments for an application
@@ -71,8 +76,16 @@ func (h *Handlers) ListDeployments(c *gin.Context) {
 			"status":     h.Status,
 			"host_name":  h.HostName,
 			"port":       h.Port,
+			"kind":       h.Kind,
 			"created_at": h.CreatedAt.Format("2006-01-02 15:04:05"),
 		}
+		if h.SignedBy != "" || h.SigningKey != "" {
+			responses[i]["signed_by"] = h.SignedBy
+			responses[i]["signing_key"] = h.SigningKey
+		}
+		if h.RolloutID.Valid {
+			responses[i]["rollout_uid"] = utils.EncodeFriendlyID(utils.PrefixRollout, h.RolloutID.UUID)
+		}
 	}
 
 	response.Data(c, responses)
@@ -99,14 +112,23 @@ func (h *Handlers) GetDeployment(c *gin.Context) {
 		return
 	}
 
+	// Probe results explain why a release was rejected; missing results are not fatal
+	healthChecks, _ := h.Repo.GetHealthChecksForDeployment(deployID)
+	steps, _ := h.Repo.GetDeploymentSteps(deployID)
+
 	response.Data(c, gin.H{
-		"uid":        utils.EncodeFriendlyID(utils.PrefixDeployment, history.ID),
-		"version":    history.Version,
-		"status":     history.Status,
-		"host_name":  history.HostName,
-		"port":       history.Port,
-		"created_at": history.CreatedAt.Format("2006-01-02 15:04:05"),
-		"output":     history.Output,
+		"uid":           utils.EncodeFriendlyID(utils.PrefixDeployment, history.ID),
+		"version":       history.Version,
+		"status":        history.Status,
+		"host_name":     history.HostName,
+		"port":          history.Port,
+		"kind":          history.Kind,
+		"signed_by":     history.SignedBy,
+		"signing_key":   history.SigningKey,
+		"created_at":    history.CreatedAt.Format("2006-01-02 15:04:05"),
+		"output":        history.Output,
+		"health_checks": healthCheckResponses(healthChecks),
+		"steps":         deploymentStepResponses(steps),
 	})
 }
 
@@ -150,6 +172,17 @@ func (h *Handlers) CreateDeployment(c *gin.Context) {
 		return
 	}
 
+	// Deployments started by a multi-host rollout are grouped under it
+	var rolloutID uuid.NullUUID
+	if req.RolloutID != "" {
+		id, err := utils.DecodeFriendlyID(utils.PrefixRollout, req.RolloutID)
+		if err != nil {
+			response.BadRequest(c, "Invalid rollout ID")
+			return
+		}
+		rolloutID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
 	// Get application
 	app, err := h.Repo.GetApplicationByName(req.AppName)
 	if err != nil {
@@ -171,13 +204,39 @@ func (h *Handlers) CreateDeployment(c *gin.Context) {
 		return
 	}
 
+	// A new release would take over the traffic the canary shares with the stable release
+	if h.canaryConflict(c, instance.ID) {
+		return
+	}
+
+	// Only one deployment may run against an instance at a time.
+	// The lease is kept alive by CLI heartbeats and expires if the CLI disappears.
+	lock, ok := h.acquireLock(c, instance.ID, models.LockKindDeploy, "", deployLockTTL)
+	if !ok {
+		return
+	}
+
 	// Create deployment history record
 	history, err := h.Repo.CreateDeploymentHistoryWithStatus(instance.ID, req.Version, "pending", "")
 	if err != nil {
+		h.releaseLock(lock)
 		response.InternalServerError(c, "Failed to create deployment record")
 		return
 	}
 
+	if err := h.Repo.SetInstanceLockDeployment(instance.ID, history.ID); err != nil {
+		h.releaseLock(lock)
+		_ = h.Repo.UpdateDeploymentHistoryStatusOnly(history.ID, "failed")
+		response.InternalServerError(c, "Failed to acquire instance lock")
+		return
+	}
+
+	if rolloutID.Valid {
+		if err := h.Repo.SetDeploymentHistoryRollout(history.ID, rolloutID.UUID); err != nil {
+			log.Printf("⚠️ Failed to attach deployment to rollout: %v", err)
+		}
+	}
+
 	// Note: Host credentials are already decrypted by database.GetSSHHostByName
 
 	// Get secrets for the app
@@ -244,10 +303,72 @@ func (h *Handlers) UploadDeploymentLogs(c *gin.Context) {
 	response.Message(c, "Logs uploaded successfully")
 }
 
+// healthCheckResponses converts probe results into API response items.
+func healthCheckResponses(results []models.HealthCheckResult) []types.HealthCheckResultDTO {
+	responses := make([]types.HealthCheckResultDTO, len(results))
+	for i, r := range results {
+		responses[i] = types.HealthCheckResultDTO{
+			Attempt:    r.Attempt,
+			Port:       r.Port,
+			URL:        r.URL,
+			StatusCode: r.StatusCode,
+			Passed:     r.Passed,
+			Message:    r.Message,
+			DurationMs: r.DurationMs,
+			CreatedAt:  r.CreatedAt.Time,
+		}
+	}
+	return responses
+}
+
+// UploadDeploymentHealthChecks records readiness probe results for a deployment (from CLI)
+func UploadDeploymentHealthChecks(c *gin.Context) {
+	h := &Handlers{Repo: defaultDeploymentsRepo}
+	h.UploadDeploymentHealthChecks(c)
+}
+
+// UploadDeploymentHealthChecksHandler records readiness probe results (method on Handlers)
+func (h *Handlers) UploadDeploymentHealthChecks(c *gin.Context) {
+	uid := c.Param("uid")
+	deployID, err := utils.DecodeFriendlyID(utils.PrefixDeployment, uid)
+	if err != nil {
+		response.BadRequest(c, "Invalid deployment ID")
+		return
+	}
+
+	var req types.UploadHealthChecksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request")
+		return
+	}
+
+	results := make([]models.HealthCheckResult, len(req.Results))
+	for i, r := range req.Results {
+		results[i] = models.HealthCheckResult{
+			Attempt:    r.Attempt,
+			Port:       r.Port,
+			URL:        r.URL,
+			StatusCode: r.StatusCode,
+			Passed:     r.Passed,
+			Message:    r.Message,
+			DurationMs: r.DurationMs,
+			CreatedAt:  models.NullableTime{Time: r.CreatedAt},
+		}
+	}
+
+	if err := h.Repo.AddDeploymentHealthChecks(deployID, results); err != nil {
+		response.InternalServerError(c, "Failed to save health check results")
+		return
+	}
+
+	response.Message(c, "Health check results uploaded successfully")
+}
+
 // UpdateDeploymentStatusRequest represents the request to update deployment status
 type UpdateDeploymentStatusRequest struct {
 	Status       string `json:"status" binding:"required"`
 	Port         int    `json:"port"`
+	Ports        []int  `json:"ports,omitempty"` // all ports of the release when it runs several instances, Port is the first
 	ReleasePath  string `json:"release_path"`
 	GitCommitSHA string `json:"git_commit_sha"`
 }
@@ -275,7 +396,11 @@ func (h *Handlers) UpdateDeploymentStatus(c *gin.Context) {
 
 	// If status is success and we have port details, perform atomic update
 	if req.Status == "success" && req.Port > 0 && req.ReleasePath != "" {
-		if err := h.Repo.RecordSuccessfulDeployment(deployID, req.Port, req.ReleasePath, req.GitCommitSHA); err != nil {
+		ports := req.Ports
+		if len(ports) == 0 {
+			ports = []int{req.Port}
+		}
+		if err := h.Repo.RecordSuccessfulDeployment(deployID, ports, req.ReleasePath, req.GitCommitSHA); err != nil {
 			response.InternalServerError(c, "Failed to record successful deployment: "+err.Error())
 			return
 		}
@@ -394,11 +519,23 @@ func (h *Handlers) ExecuteServerDeployment(c *gin.Context) {
 	}
 
 	// Execute deployment asynchronously
+	// The CLI stops sending heartbeats once the server takes over, extend the lease to cover the run
+	if _, err := h.Repo.RenewInstanceLock(deployID, serverSideLockTTL); err != nil {
+		response.Error(c, http.StatusConflict, "Deployment no longer holds the instance lock")
+		return
+	}
+
 	go func() {
+		defer func() {
+			if err := h.Repo.ReleaseInstanceLockForDeployment(deployID); err != nil {
+				log.Printf("⚠️ Failed to release instance lock: %v", err)
+			}
+		}()
+
 		log.Printf("🚀 Starting server-side deployment for %s (deployment: %s)", app.Name, uid)
 
 		// Call the deploy package function
-		if err := deploy.ExecuteServerSideDeployment(deployID.String(), app.Name, req.Version); err != nil {
+		if err := deploy.ExecuteServerSideDeployment(deployID.String(), app.Name, req.Version, req.MD5Hash); err != nil {
 			log.Printf("❌ Server-side deployment failed: %v", err)
 			_ = h.Repo.UpdateDeploymentHistoryStatusOnly(deployID, "failed")
 			_ = h.Repo.AppendDeploymentHistoryOutput(deployID, fmt.Sprintf("Deployment failed: %v", err))
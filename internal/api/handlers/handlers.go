@@ -3,12 +3,14 @@ package handlers
 import (
 	"os"
 	"strconv"
+	"youfun/shipyard/internal/registry"
 )
 
 // Handlers holds all handler instances with their dependencies
 type Handlers struct {
 	Repo       DatabaseRepository
 	SystemPort int
+	Artifacts  registry.Store // Artifact registry, nil uses the store configured by the environment
 }
 
 // GlobalSystemPort stores the port the server is running on
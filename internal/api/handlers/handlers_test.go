@@ -1,12 +1,24 @@
 package handlers
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
+	"youfun/shipyard/internal/api/middleware"
+	"youfun/shipyard/internal/api/utils"
 	"youfun/shipyard/internal/database"
 	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/registry"
+	"youfun/shipyard/internal/signing"
+	"youfun/shipyard/pkg/types"
 	"testing"
 	"time"
 
@@ -34,15 +46,18 @@ type MockRepository struct {
 	MockGetLastSuccessfulHostForApp func(appID uuid.UUID) (string, time.Time, error)
 
 	// Application Instances
-	MockGetApplicationInstance func(appID uuid.UUID, hostID uuid.UUID) (*models.ApplicationInstance, error)
-	MockGetInstance            func(appName, hostName string) (*models.ApplicationInstance, *models.Application, *models.SSHHost, error)
-	MockLinkApplicationToHost  func(instance *models.ApplicationInstance) error
+	MockGetApplicationInstance          func(appID uuid.UUID, hostID uuid.UUID) (*models.ApplicationInstance, error)
+	MockGetApplicationInstanceByID      func(instanceID uuid.UUID) (*models.ApplicationInstance, error)
+	MockUpdateApplicationInstanceStatus func(id uuid.UUID, status string) error
+	MockGetInstance                     func(appName, hostName string) (*models.ApplicationInstance, *models.Application, *models.SSHHost, error)
+	MockLinkApplicationToHost           func(instance *models.ApplicationInstance) error
 
 	// Secrets
 	MockListSecretKeys   func(appID uuid.UUID) ([]string, error)
 	MockGetSecretsForApp func(appID uuid.UUID) (map[string]string, error)
 	MockGetAllSecrets    func(appID uuid.UUID) (map[string]string, error)
 	MockSetSecret        func(appID uuid.UUID, key, value string) error
+	MockEnsureSecret     func(appID uuid.UUID, key, value string) (string, error)
 	MockUnsetSecret      func(appID uuid.UUID, key string) error
 
 	// Deployments
@@ -53,6 +68,49 @@ type MockRepository struct {
 	MockAppendDeploymentHistoryOutput     func(id uuid.UUID, output string) error
 	MockUpdateDeploymentHistoryStatusOnly func(id uuid.UUID, status string) error
 	MockGetDeploymentsCount               func() (int, error)
+	MockRecordSuccessfulDeployment        func(deploymentID uuid.UUID, ports []int, releasePath string, gitCommitSHA string) error
+	MockGetActiveInstancePorts            func(instanceID uuid.UUID) ([]int, error)
+	MockGetActivePeersForApp              func(appID uuid.UUID) ([]database.ClusterPeer, error)
+	MockGetRecentDeploymentsGlobal        func(limit int) ([]database.RecentDeploymentRow, error)
+	MockAddDeploymentHealthChecks         func(deploymentID uuid.UUID, results []models.HealthCheckResult) error
+	MockGetHealthChecksForDeployment      func(deploymentID uuid.UUID) ([]models.HealthCheckResult, error)
+
+	// Instance locks
+	MockAcquireInstanceLock              func(lock *models.InstanceLock) (*models.InstanceLock, error)
+	MockGetInstanceLock                  func(instanceID uuid.UUID) (*models.InstanceLock, error)
+	MockSetInstanceLockDeployment        func(instanceID, deploymentID uuid.UUID) error
+	MockRenewInstanceLock                func(deploymentID uuid.UUID, ttl time.Duration) (*models.InstanceLock, error)
+	MockReleaseInstanceLockForDeployment func(deploymentID uuid.UUID) error
+	MockReleaseInstanceLockIfHeld        func(instanceID, lockID uuid.UUID) error
+	MockReleaseInstanceLock              func(instanceID uuid.UUID) error
+
+	// Rollouts
+	MockGetLinkedHostsForApp           func(appName string) ([]models.SSHHost, error)
+	MockCreateRollout                  func(rollout *models.Rollout) error
+	MockFinishRollout                  func(id uuid.UUID, status string) error
+	MockGetRolloutByID                 func(id uuid.UUID) (*models.Rollout, error)
+	MockGetRolloutsForApp              func(appID uuid.UUID, limit int) ([]models.Rollout, error)
+	MockSetDeploymentHistoryRollout    func(deploymentID, rolloutID uuid.UUID) error
+	MockGetDeploymentHistoryForRollout func(rolloutID uuid.UUID) ([]database.DeploymentHistoryRow, error)
+
+	// Canaries
+	MockStartInstanceCanary func(canary *models.InstanceCanary) error
+	MockGetInstanceCanary   func(instanceID uuid.UUID) (*models.InstanceCanary, error)
+
+	// Deployment steps
+	MockSaveDeploymentStep func(step *models.DeploymentStep) error
+	MockGetDeploymentSteps func(deploymentID uuid.UUID) ([]models.DeploymentStep, error)
+	MockHasNewerDeployment func(deploymentID uuid.UUID) (bool, error)
+
+	// Signing keys
+	MockGetSigningKeys        func() ([]models.SigningKey, error)
+	MockCreateSigningKey      func(name string) (*models.SigningKey, error)
+	MockGetActiveSigningKey   func() (*models.SigningKey, ed25519.PrivateKey, error)
+	MockGetTrustedKeysForApp  func(appID uuid.UUID) ([]models.TrustedKey, error)
+	MockAddTrustedKey         func(appID uuid.UUID, name, publicKey string) (*models.TrustedKey, error)
+	MockDeleteTrustedKey      func(id, appID uuid.UUID) error
+	MockGetTrustedSigningKeys func(appID uuid.UUID) ([]signing.TrustedKey, error)
+	MockSetDeploymentSigner   func(deploymentID uuid.UUID, signingKey, signedBy string) error
 
 	// Domains
 	MockGetDomainsForInstance func(instanceID uuid.UUID) ([]models.Domain, error)
@@ -63,9 +121,15 @@ type MockRepository struct {
 	MockSetPrimaryDomain      func(instanceID uuid.UUID, hostname string) error
 
 	// Build Artifacts
-	MockGetBuildArtifactByGitSHA   func(appID uuid.UUID, gitSHA string) (*models.BuildArtifact, error)
-	MockGetAllBuildArtifactsForApp func(appID uuid.UUID) ([]models.BuildArtifact, error)
-	MockAddBuildArtifact           func(artifact *models.BuildArtifact) error
+	MockGetBuildArtifactByGitSHA        func(appID uuid.UUID, gitSHA string) (*models.BuildArtifact, error)
+	MockGetAllBuildArtifactsForApp      func(appID uuid.UUID) ([]models.BuildArtifact, error)
+	MockAddBuildArtifact                func(artifact *models.BuildArtifact) error
+	MockGetBuildArtifactByMD5Prefix     func(appID uuid.UUID, md5Prefix string) (*models.BuildArtifact, error)
+	MockGetLatestBuildArtifactByVersion func(appID uuid.UUID, version string) (*models.BuildArtifact, error)
+	MockMarkBuildArtifactStored         func(artifact *models.BuildArtifact) error
+	MockDeleteBuildArtifact             func(id uuid.UUID) error
+	MockGetBuildArtifactDeployments     func(appID uuid.UUID, md5Hash string) ([]database.DeploymentHistoryRow, error)
+	MockGetBuildArtifactsLastDeployed   func(appID uuid.UUID) (map[string]time.Time, error)
 
 	// Users
 	MockGetUserCount       func() (int64, error)
@@ -87,6 +151,10 @@ type MockRepository struct {
 	MockGetApplicationTokensByAppID func(appID uuid.UUID) ([]database.ApplicationToken, error)
 	MockCreateApplicationToken      func(appID uuid.UUID, name string, expiresAt *time.Time) (*database.ApplicationToken, string, error)
 	MockDeleteApplicationToken      func(tokenID, appID uuid.UUID) error
+
+	// System Settings
+	MockGetSystemSetting func(key string) (string, error)
+	MockSetSystemSetting func(key, value string) error
 }
 
 // Implement the DatabaseRepository interface methods
@@ -233,6 +301,13 @@ func (m *MockRepository) SetSecret(appID uuid.UUID, key, value string) error {
 	return errors.New("not implemented")
 }
 
+func (m *MockRepository) EnsureSecret(appID uuid.UUID, key, value string) (string, error) {
+	if m.MockEnsureSecret != nil {
+		return m.MockEnsureSecret(appID, key, value)
+	}
+	return "", errors.New("not implemented")
+}
+
 func (m *MockRepository) UnsetSecret(appID uuid.UUID, key string) error {
 	if m.MockUnsetSecret != nil {
 		return m.MockUnsetSecret(appID, key)
@@ -443,6 +518,307 @@ func (m *MockRepository) DeleteApplicationToken(tokenID, appID uuid.UUID) error
 	return errors.New("not implemented")
 }
 
+func (m *MockRepository) GetApplicationInstanceByID(instanceID uuid.UUID) (*models.ApplicationInstance, error) {
+	if m.MockGetApplicationInstanceByID != nil {
+		return m.MockGetApplicationInstanceByID(instanceID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) UpdateApplicationInstanceStatus(id uuid.UUID, status string) error {
+	if m.MockUpdateApplicationInstanceStatus != nil {
+		return m.MockUpdateApplicationInstanceStatus(id, status)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) RecordSuccessfulDeployment(deploymentID uuid.UUID, ports []int, releasePath string, gitCommitSHA string) error {
+	if m.MockRecordSuccessfulDeployment != nil {
+		return m.MockRecordSuccessfulDeployment(deploymentID, ports, releasePath, gitCommitSHA)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) GetActiveInstancePorts(instanceID uuid.UUID) ([]int, error) {
+	if m.MockGetActiveInstancePorts != nil {
+		return m.MockGetActiveInstancePorts(instanceID)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) GetActivePeersForApp(appID uuid.UUID) ([]database.ClusterPeer, error) {
+	if m.MockGetActivePeersForApp != nil {
+		return m.MockGetActivePeersForApp(appID)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) GetRecentDeploymentsGlobal(limit int) ([]database.RecentDeploymentRow, error) {
+	if m.MockGetRecentDeploymentsGlobal != nil {
+		return m.MockGetRecentDeploymentsGlobal(limit)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) AddDeploymentHealthChecks(deploymentID uuid.UUID, results []models.HealthCheckResult) error {
+	if m.MockAddDeploymentHealthChecks != nil {
+		return m.MockAddDeploymentHealthChecks(deploymentID, results)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) GetHealthChecksForDeployment(deploymentID uuid.UUID) ([]models.HealthCheckResult, error) {
+	if m.MockGetHealthChecksForDeployment != nil {
+		return m.MockGetHealthChecksForDeployment(deploymentID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) AcquireInstanceLock(lock *models.InstanceLock) (*models.InstanceLock, error) {
+	if m.MockAcquireInstanceLock != nil {
+		return m.MockAcquireInstanceLock(lock)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) GetInstanceLock(instanceID uuid.UUID) (*models.InstanceLock, error) {
+	if m.MockGetInstanceLock != nil {
+		return m.MockGetInstanceLock(instanceID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) SetInstanceLockDeployment(instanceID, deploymentID uuid.UUID) error {
+	if m.MockSetInstanceLockDeployment != nil {
+		return m.MockSetInstanceLockDeployment(instanceID, deploymentID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) RenewInstanceLock(deploymentID uuid.UUID, ttl time.Duration) (*models.InstanceLock, error) {
+	if m.MockRenewInstanceLock != nil {
+		return m.MockRenewInstanceLock(deploymentID, ttl)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) ReleaseInstanceLockForDeployment(deploymentID uuid.UUID) error {
+	if m.MockReleaseInstanceLockForDeployment != nil {
+		return m.MockReleaseInstanceLockForDeployment(deploymentID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) ReleaseInstanceLockIfHeld(instanceID, lockID uuid.UUID) error {
+	if m.MockReleaseInstanceLockIfHeld != nil {
+		return m.MockReleaseInstanceLockIfHeld(instanceID, lockID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) ReleaseInstanceLock(instanceID uuid.UUID) error {
+	if m.MockReleaseInstanceLock != nil {
+		return m.MockReleaseInstanceLock(instanceID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) GetLinkedHostsForApp(appName string) ([]models.SSHHost, error) {
+	if m.MockGetLinkedHostsForApp != nil {
+		return m.MockGetLinkedHostsForApp(appName)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) CreateRollout(rollout *models.Rollout) error {
+	if m.MockCreateRollout != nil {
+		return m.MockCreateRollout(rollout)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) FinishRollout(id uuid.UUID, status string) error {
+	if m.MockFinishRollout != nil {
+		return m.MockFinishRollout(id, status)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) GetRolloutByID(id uuid.UUID) (*models.Rollout, error) {
+	if m.MockGetRolloutByID != nil {
+		return m.MockGetRolloutByID(id)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) GetRolloutsForApp(appID uuid.UUID, limit int) ([]models.Rollout, error) {
+	if m.MockGetRolloutsForApp != nil {
+		return m.MockGetRolloutsForApp(appID, limit)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) SetDeploymentHistoryRollout(deploymentID, rolloutID uuid.UUID) error {
+	if m.MockSetDeploymentHistoryRollout != nil {
+		return m.MockSetDeploymentHistoryRollout(deploymentID, rolloutID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) GetDeploymentHistoryForRollout(rolloutID uuid.UUID) ([]database.DeploymentHistoryRow, error) {
+	if m.MockGetDeploymentHistoryForRollout != nil {
+		return m.MockGetDeploymentHistoryForRollout(rolloutID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) StartInstanceCanary(canary *models.InstanceCanary) error {
+	if m.MockStartInstanceCanary != nil {
+		return m.MockStartInstanceCanary(canary)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) GetInstanceCanary(instanceID uuid.UUID) (*models.InstanceCanary, error) {
+	if m.MockGetInstanceCanary != nil {
+		return m.MockGetInstanceCanary(instanceID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) SaveDeploymentStep(step *models.DeploymentStep) error {
+	if m.MockSaveDeploymentStep != nil {
+		return m.MockSaveDeploymentStep(step)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) GetDeploymentSteps(deploymentID uuid.UUID) ([]models.DeploymentStep, error) {
+	if m.MockGetDeploymentSteps != nil {
+		return m.MockGetDeploymentSteps(deploymentID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) HasNewerDeployment(deploymentID uuid.UUID) (bool, error) {
+	if m.MockHasNewerDeployment != nil {
+		return m.MockHasNewerDeployment(deploymentID)
+	}
+	return false, errors.New("not implemented")
+}
+
+func (m *MockRepository) GetBuildArtifactByMD5Prefix(appID uuid.UUID, md5Prefix string) (*models.BuildArtifact, error) {
+	if m.MockGetBuildArtifactByMD5Prefix != nil {
+		return m.MockGetBuildArtifactByMD5Prefix(appID, md5Prefix)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) GetLatestBuildArtifactByVersion(appID uuid.UUID, version string) (*models.BuildArtifact, error) {
+	if m.MockGetLatestBuildArtifactByVersion != nil {
+		return m.MockGetLatestBuildArtifactByVersion(appID, version)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) MarkBuildArtifactStored(artifact *models.BuildArtifact) error {
+	if m.MockMarkBuildArtifactStored != nil {
+		return m.MockMarkBuildArtifactStored(artifact)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) DeleteBuildArtifact(id uuid.UUID) error {
+	if m.MockDeleteBuildArtifact != nil {
+		return m.MockDeleteBuildArtifact(id)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) GetBuildArtifactDeployments(appID uuid.UUID, md5Hash string) ([]database.DeploymentHistoryRow, error) {
+	if m.MockGetBuildArtifactDeployments != nil {
+		return m.MockGetBuildArtifactDeployments(appID, md5Hash)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) GetBuildArtifactsLastDeployed(appID uuid.UUID) (map[string]time.Time, error) {
+	if m.MockGetBuildArtifactsLastDeployed != nil {
+		return m.MockGetBuildArtifactsLastDeployed(appID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) GetSigningKeys() ([]models.SigningKey, error) {
+	if m.MockGetSigningKeys != nil {
+		return m.MockGetSigningKeys()
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) CreateSigningKey(name string) (*models.SigningKey, error) {
+	if m.MockCreateSigningKey != nil {
+		return m.MockCreateSigningKey(name)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) GetActiveSigningKey() (*models.SigningKey, ed25519.PrivateKey, error) {
+	if m.MockGetActiveSigningKey != nil {
+		return m.MockGetActiveSigningKey()
+	}
+	return nil, nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) GetTrustedKeysForApp(appID uuid.UUID) ([]models.TrustedKey, error) {
+	if m.MockGetTrustedKeysForApp != nil {
+		return m.MockGetTrustedKeysForApp(appID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) AddTrustedKey(appID uuid.UUID, name, publicKey string) (*models.TrustedKey, error) {
+	if m.MockAddTrustedKey != nil {
+		return m.MockAddTrustedKey(appID, name, publicKey)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) DeleteTrustedKey(id, appID uuid.UUID) error {
+	if m.MockDeleteTrustedKey != nil {
+		return m.MockDeleteTrustedKey(id, appID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) GetTrustedSigningKeys(appID uuid.UUID) ([]signing.TrustedKey, error) {
+	if m.MockGetTrustedSigningKeys != nil {
+		return m.MockGetTrustedSigningKeys(appID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockRepository) SetDeploymentSigner(deploymentID uuid.UUID, signingKey, signedBy string) error {
+	if m.MockSetDeploymentSigner != nil {
+		return m.MockSetDeploymentSigner(deploymentID, signingKey, signedBy)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockRepository) GetSystemSetting(key string) (string, error) {
+	if m.MockGetSystemSetting != nil {
+		return m.MockGetSystemSetting(key)
+	}
+	return "", errors.New("not implemented")
+}
+
+func (m *MockRepository) SetSystemSetting(key, value string) error {
+	if m.MockSetSystemSetting != nil {
+		return m.MockSetSystemSetting(key, value)
+	}
+	return errors.New("not implemented")
+}
+
 // Ensure MockRepository implements DatabaseRepository
 var _ DatabaseRepository = (*MockRepository)(nil)
 
@@ -629,10 +1005,13 @@ func TestDashboardStats(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
 	}
 
-	var response DashboardStatsResponse
-	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+	var envelope struct {
+		Data DashboardStatsResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
 		t.Fatalf("Failed to parse response: %v", err)
 	}
+	response := envelope.Data
 
 	if response.ApplicationsCount != 2 {
 		t.Errorf("Expected 2 applications, got %d", response.ApplicationsCount)
@@ -702,8 +1081,13 @@ func TestCLIGetInstance(t *testing.T) {
 		t.Fatalf("Failed to parse response: %v", err)
 	}
 
+	data, ok := response["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected data to be an object, got %T", response["data"])
+	}
+
 	// Check host contains credentials
-	host, ok := response["host"].(map[string]interface{})
+	host, ok := data["host"].(map[string]interface{})
 	if !ok {
 		t.Fatalf("Expected host to be an object, got %T", response["host"])
 	}
@@ -759,3 +1143,1062 @@ func TestCLIGetInstanceMissingParams(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
 	}
 }
+
+// TestCreateDeploymentInstanceLocked tests that a second deployment is rejected while the instance is locked
+func TestCreateDeploymentInstanceLocked(t *testing.T) {
+	instanceID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	acquiredAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	historyCreated := false
+
+	mockRepo := &MockRepository{
+		MockGetApplicationByName: func(name string) (*models.Application, error) {
+			return &models.Application{Name: name}, nil
+		},
+		MockGetSSHHostByName: func(name string) (*models.SSHHost, error) {
+			return &models.SSHHost{Name: name}, nil
+		},
+		MockGetInstance: func(appName, hostName string) (*models.ApplicationInstance, *models.Application, *models.SSHHost, error) {
+			return &models.ApplicationInstance{ID: instanceID}, nil, nil, nil
+		},
+		MockGetInstanceCanary: func(instanceID uuid.UUID) (*models.InstanceCanary, error) {
+			return nil, nil
+		},
+		MockAcquireInstanceLock: func(lock *models.InstanceLock) (*models.InstanceLock, error) {
+			return &models.InstanceLock{
+				InstanceID: instanceID,
+				Kind:       models.LockKindDeploy,
+				Owner:      "alice",
+				AcquiredAt: models.NullableTime{Time: &acquiredAt},
+			}, database.ErrInstanceLocked
+		},
+		MockCreateDeploymentHistoryWithStatus: func(instanceID uuid.UUID, version, status, output string) (*models.DeploymentHistory, error) {
+			historyCreated = true
+			return nil, errors.New("unexpected call")
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/cli/v1/deployments", h.CreateDeployment)
+
+	w := httptest.NewRecorder()
+	body := `{"app_name":"test-app","host_name":"test-host","version":"v2"}`
+	req, _ := http.NewRequest("POST", "/cli/v1/deployments", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if historyCreated {
+		t.Error("Expected no deployment record to be created while the instance is locked")
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	want := "deploy in progress by alice since 2024-05-01 12:30:00"
+	if response["message"] != want {
+		t.Errorf("Expected message %q, got %v", want, response["message"])
+	}
+}
+
+// TestCreateDeploymentReleasesOwnLock tests that a failed request only releases the lock it acquired
+func TestCreateDeploymentReleasesOwnLock(t *testing.T) {
+	instanceID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	lockID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	var released []uuid.UUID
+
+	mockRepo := &MockRepository{
+		MockGetApplicationByName: func(name string) (*models.Application, error) {
+			return &models.Application{Name: name}, nil
+		},
+		MockGetSSHHostByName: func(name string) (*models.SSHHost, error) {
+			return &models.SSHHost{Name: name}, nil
+		},
+		MockGetInstance: func(appName, hostName string) (*models.ApplicationInstance, *models.Application, *models.SSHHost, error) {
+			return &models.ApplicationInstance{ID: instanceID}, nil, nil, nil
+		},
+		MockGetInstanceCanary: func(instanceID uuid.UUID) (*models.InstanceCanary, error) {
+			return nil, nil
+		},
+		MockAcquireInstanceLock: func(lock *models.InstanceLock) (*models.InstanceLock, error) {
+			lock.ID = lockID
+			return lock, nil
+		},
+		MockCreateDeploymentHistoryWithStatus: func(instanceID uuid.UUID, version, status, output string) (*models.DeploymentHistory, error) {
+			return nil, errors.New("database is locked")
+		},
+		MockReleaseInstanceLockIfHeld: func(id, heldID uuid.UUID) error {
+			if id != instanceID {
+				t.Errorf("Expected the lock of instance %s to be released, got %s", instanceID, id)
+			}
+			released = append(released, heldID)
+			return nil
+		},
+		MockReleaseInstanceLock: func(id uuid.UUID) error {
+			t.Error("Expected the lock to be released only if still held")
+			return nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/cli/v1/deployments", h.CreateDeployment)
+
+	w := httptest.NewRecorder()
+	body := `{"app_name":"test-app","host_name":"test-host","version":"v2"}`
+	req, _ := http.NewRequest("POST", "/cli/v1/deployments", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	if len(released) != 1 || released[0] != lockID {
+		t.Errorf("Expected lock %s to be released once, got %v", lockID, released)
+	}
+}
+
+// TestUnlockInstance tests that the lock of a running deployment is only removed with force, and never by app tokens
+func TestUnlockInstance(t *testing.T) {
+	instanceID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	lockID := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	deployID := uuid.MustParse("00000000-0000-0000-0000-000000000004")
+	var lock *models.InstanceLock
+	var released []uuid.UUID
+	var notes []string
+
+	mockRepo := &MockRepository{
+		MockGetInstanceLock: func(id uuid.UUID) (*models.InstanceLock, error) {
+			return lock, nil
+		},
+		MockReleaseInstanceLockIfHeld: func(id, heldID uuid.UUID) error {
+			released = append(released, heldID)
+			return nil
+		},
+		MockAppendDeploymentHistoryOutput: func(id uuid.UUID, output string) error {
+			if id != deployID {
+				t.Errorf("Expected the release to be recorded on deployment %s, got %s", deployID, id)
+			}
+			notes = append(notes, output)
+			return nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("username", "bob")
+		if c.GetHeader("X-Test-App-Token") != "" {
+			middleware.SetAppTokenApplication(c, uuid.New())
+		}
+	})
+	router.POST("/api/instances/:uid/lock", h.LockInstance)
+	router.DELETE("/api/instances/:uid/lock", h.UnlockInstance)
+	path := "/api/instances/" + utils.EncodeFriendlyID(utils.PrefixAppInstance, instanceID) + "/lock"
+
+	deployLock := &models.InstanceLock{ID: lockID, InstanceID: instanceID, Kind: models.LockKindDeploy, Owner: "alice", DeploymentID: uuid.NullUUID{UUID: deployID, Valid: true}}
+	manualLock := &models.InstanceLock{ID: lockID, InstanceID: instanceID, Kind: models.LockKindManual, Owner: "alice", Reason: "incident"}
+	tests := []struct {
+		name     string
+		method   string
+		query    string
+		lock     *models.InstanceLock
+		appToken bool
+		want     int
+		released bool
+	}{
+		{"app token locks", "POST", "", nil, true, http.StatusForbidden, false},
+		{"app token unlocks", "DELETE", "?force=true", manualLock, true, http.StatusForbidden, false},
+		{"manual lock", "DELETE", "", manualLock, false, http.StatusOK, true},
+		{"running deployment", "DELETE", "", deployLock, false, http.StatusConflict, false},
+		{"running deployment with force", "DELETE", "?force=true", deployLock, false, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lock, released, notes = tt.lock, nil, nil
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, path+tt.query, strings.NewReader(`{"reason":"freeze"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.appToken {
+				req.Header.Set("X-Test-App-Token", "1")
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("Expected status code %d, got %d. Body: %s", tt.want, w.Code, w.Body.String())
+			}
+			if tt.released != (len(released) == 1 && released[0] == lockID) {
+				t.Errorf("Expected lock released = %v, got %v", tt.released, released)
+			}
+			if tt.released && tt.lock.DeploymentID.Valid && (len(notes) != 1 || !strings.Contains(notes[0], "released by bob")) {
+				t.Errorf("Expected the deployment to record who released its lock, got %q", notes)
+			}
+		})
+	}
+}
+
+// TestCreateDeploymentCanaryRunning tests that a deployment is rejected while a canary shares the traffic
+func TestCreateDeploymentCanaryRunning(t *testing.T) {
+	instanceID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	deploymentID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	lockTaken := false
+
+	mockRepo := &MockRepository{
+		MockGetApplicationByName: func(name string) (*models.Application, error) {
+			return &models.Application{Name: name}, nil
+		},
+		MockGetSSHHostByName: func(name string) (*models.SSHHost, error) {
+			return &models.SSHHost{Name: name}, nil
+		},
+		MockGetInstance: func(appName, hostName string) (*models.ApplicationInstance, *models.Application, *models.SSHHost, error) {
+			return &models.ApplicationInstance{ID: instanceID}, nil, nil, nil
+		},
+		MockGetInstanceCanary: func(id uuid.UUID) (*models.InstanceCanary, error) {
+			return &models.InstanceCanary{InstanceID: id, DeploymentID: deploymentID, StablePort: 8001, CanaryPort: 8002, CanaryWeight: 25}, nil
+		},
+		MockAcquireInstanceLock: func(lock *models.InstanceLock) (*models.InstanceLock, error) {
+			lockTaken = true
+			return lock, nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/cli/v1/deployments", h.CreateDeployment)
+
+	w := httptest.NewRecorder()
+	body := `{"app_name":"test-app","host_name":"test-host","version":"v2"}`
+	req, _ := http.NewRequest("POST", "/cli/v1/deployments", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if lockTaken {
+		t.Error("Expected the instance lock not to be taken while a canary is running")
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	message, _ := response["message"].(string)
+	if !strings.Contains(message, "25% of traffic") || !strings.Contains(message, "promote or abort") {
+		t.Errorf("Unexpected message: %q", message)
+	}
+}
+
+// TestStartDeploymentCanary tests that the canary of a deployment is recorded next to the active port
+func TestStartDeploymentCanary(t *testing.T) {
+	instanceID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	deploymentID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	var recorded *models.InstanceCanary
+	var status string
+
+	mockRepo := &MockRepository{
+		MockGetDeploymentHistoryByID: func(id uuid.UUID) (*database.DeploymentHistoryRow, error) {
+			return &database.DeploymentHistoryRow{ID: id, InstanceID: instanceID}, nil
+		},
+		MockGetApplicationInstanceByID: func(id uuid.UUID) (*models.ApplicationInstance, error) {
+			return &models.ApplicationInstance{ID: id, ActivePort: sql.NullInt64{Int64: 8001, Valid: true}}, nil
+		},
+		MockStartInstanceCanary: func(canary *models.InstanceCanary) error {
+			recorded = canary
+			return nil
+		},
+		MockUpdateDeploymentHistoryStatusOnly: func(id uuid.UUID, s string) error {
+			status = s
+			return nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/cli/v1/deployments/:uid/canary", h.StartDeploymentCanary)
+
+	w := httptest.NewRecorder()
+	uid := utils.EncodeFriendlyID(utils.PrefixDeployment, deploymentID)
+	body := `{"port":8002,"weight":10,"release_path":"/var/www/test-app/releases/v2-1"}`
+	req, _ := http.NewRequest("POST", "/cli/v1/deployments/"+uid+"/canary", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if recorded == nil || recorded.StablePort != 8001 || recorded.CanaryPort != 8002 || recorded.CanaryWeight != 10 {
+		t.Errorf("Unexpected canary record: %+v", recorded)
+	}
+	if status != string(models.DeploymentStatusCanary) {
+		t.Errorf("Expected deployment status %q, got %q", models.DeploymentStatusCanary, status)
+	}
+
+	var response struct {
+		Data types.CanaryDTO `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Data.StableWeight != 90 || response.Data.CanaryWeight != 10 || response.Data.Status != models.CanaryStatusRunning {
+		t.Errorf("Unexpected response: %+v", response.Data)
+	}
+}
+
+// TestCreateRollout tests that a rollout record is created for the requested hosts
+func TestCreateRollout(t *testing.T) {
+	appID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	var created *models.Rollout
+
+	mockRepo := &MockRepository{
+		MockGetApplicationByName: func(name string) (*models.Application, error) {
+			return &models.Application{ID: appID, Name: name}, nil
+		},
+		MockCreateRollout: func(rollout *models.Rollout) error {
+			rollout.ID = uuid.MustParse("00000000-0000-0000-0000-000000000009")
+			created = rollout
+			return nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/cli/v1/rollouts", h.CreateRollout)
+
+	w := httptest.NewRecorder()
+	body := `{"app_name":"test-app","version":"1.2.0","hosts":["web-1","web-2"]}`
+	req, _ := http.NewRequest("POST", "/cli/v1/rollouts", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if created == nil || created.ApplicationID != appID || created.Hosts != "web-1,web-2" || created.Parallel != 1 || created.Status != models.RolloutStatusRunning {
+		t.Fatalf("Unexpected rollout record: %+v", created)
+	}
+
+	var response struct {
+		Data struct {
+			ID    string   `json:"id"`
+			Hosts []string `json:"hosts"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if !strings.HasPrefix(response.Data.ID, "rol_") || len(response.Data.Hosts) != 2 {
+		t.Errorf("Unexpected rollout response: %+v", response.Data)
+	}
+}
+
+func TestPruneInstanceReleasesNegativeKeep(t *testing.T) {
+	instanceID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	mockRepo := &MockRepository{
+		MockGetApplicationInstanceByID: func(id uuid.UUID) (*models.ApplicationInstance, error) {
+			return &models.ApplicationInstance{ID: id}, nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/cli/v1/instances/:uid/releases/prune", h.PruneInstanceReleases)
+
+	w := httptest.NewRecorder()
+	uid := utils.EncodeFriendlyID(utils.PrefixAppInstance, instanceID)
+	req, _ := http.NewRequest("POST", "/cli/v1/instances/"+uid+"/releases/prune", strings.NewReader(`{"keep":-1}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
+
+func TestPinInstanceReleaseOtherInstanceDeployment(t *testing.T) {
+	instanceID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	deploymentID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	mockRepo := &MockRepository{
+		MockGetApplicationInstanceByID: func(id uuid.UUID) (*models.ApplicationInstance, error) {
+			return &models.ApplicationInstance{ID: id}, nil
+		},
+		MockGetDeploymentHistoryByID: func(id uuid.UUID) (*database.DeploymentHistoryRow, error) {
+			return &database.DeploymentHistoryRow{ID: id, InstanceID: uuid.New(), ReleasePath: "/var/www/test-app/releases/v1-1"}, nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/cli/v1/instances/:uid/releases/:release/pin", h.PinInstanceRelease)
+
+	w := httptest.NewRecorder()
+	uid := utils.EncodeFriendlyID(utils.PrefixAppInstance, instanceID)
+	release := utils.EncodeFriendlyID(utils.PrefixDeployment, deploymentID)
+	req, _ := http.NewRequest("POST", "/cli/v1/instances/"+uid+"/releases/"+release+"/pin", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusNotFound, w.Code, w.Body.String())
+	}
+}
+
+// TestResumeDeployment tests that a failed deployment is reopened under the instance lock with its recorded steps
+func TestResumeDeployment(t *testing.T) {
+	instanceID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	deploymentID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	var lockedDeployment uuid.UUID
+	var status string
+
+	mockRepo := &MockRepository{
+		MockGetDeploymentHistoryByID: func(id uuid.UUID) (*database.DeploymentHistoryRow, error) {
+			return &database.DeploymentHistoryRow{ID: id, InstanceID: instanceID, Version: "1.2.0", Status: "failed", HostName: "prod", Kind: models.DeploymentKindDeploy}, nil
+		},
+		MockHasNewerDeployment: func(id uuid.UUID) (bool, error) { return false, nil },
+		MockGetDeploymentSteps: func(id uuid.UUID) ([]models.DeploymentStep, error) {
+			return []models.DeploymentStep{
+				{DeploymentID: id, Name: models.DeployStepArtifact, Position: 1, Status: models.StepStatusSuccess, Detail: "abc123"},
+				{DeploymentID: id, Name: models.DeployStepUpload, Position: 2, Status: models.StepStatusFailed, Error: "connection reset"},
+			}, nil
+		},
+		MockGetApplicationInstanceByID: func(id uuid.UUID) (*models.ApplicationInstance, error) {
+			return &models.ApplicationInstance{ID: id}, nil
+		},
+		MockGetApplicationByID: func(id uuid.UUID) (*models.Application, error) {
+			return &models.Application{ID: id, Name: "test-app"}, nil
+		},
+		MockGetInstanceCanary: func(id uuid.UUID) (*models.InstanceCanary, error) { return nil, nil },
+		MockAcquireInstanceLock: func(lock *models.InstanceLock) (*models.InstanceLock, error) {
+			return lock, nil
+		},
+		MockSetInstanceLockDeployment: func(instance, deployment uuid.UUID) error {
+			lockedDeployment = deployment
+			return nil
+		},
+		MockUpdateDeploymentHistoryStatusOnly: func(id uuid.UUID, s string) error {
+			status = s
+			return nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/cli/v1/deployments/:uid/resume", h.ResumeDeployment)
+
+	w := httptest.NewRecorder()
+	uid := utils.EncodeFriendlyID(utils.PrefixDeployment, deploymentID)
+	req, _ := http.NewRequest("POST", "/cli/v1/deployments/"+uid+"/resume", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if lockedDeployment != deploymentID {
+		t.Errorf("Expected the instance lock to be held by the resumed deployment, got %s", lockedDeployment)
+	}
+	if status != string(models.DeploymentStatusPending) {
+		t.Errorf("Expected deployment status %q, got %q", models.DeploymentStatusPending, status)
+	}
+
+	var response struct {
+		Data types.ResumeDeploymentResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Data.AppName != "test-app" || response.Data.HostName != "prod" || len(response.Data.Steps) != 2 {
+		t.Errorf("Unexpected response: %+v", response.Data)
+	}
+}
+
+// TestResumeDeploymentRejected tests that only the latest failed or cancelled deployment can be resumed
+func TestResumeDeploymentRejected(t *testing.T) {
+	deploymentID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	tests := []struct {
+		name   string
+		status string
+		newer  bool
+		want   string
+	}{
+		{"succeeded", "success", false, "only failed or cancelled deployments can be resumed"},
+		{"superseded", "failed", true, "not the latest deployment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lockTaken := false
+			mockRepo := &MockRepository{
+				MockGetDeploymentHistoryByID: func(id uuid.UUID) (*database.DeploymentHistoryRow, error) {
+					return &database.DeploymentHistoryRow{ID: id, Status: tt.status, Kind: models.DeploymentKindDeploy}, nil
+				},
+				MockHasNewerDeployment: func(id uuid.UUID) (bool, error) { return tt.newer, nil },
+				MockAcquireInstanceLock: func(lock *models.InstanceLock) (*models.InstanceLock, error) {
+					lockTaken = true
+					return lock, nil
+				},
+			}
+
+			h := NewHandlers(mockRepo)
+			router := setupTestRouter()
+			router.POST("/cli/v1/deployments/:uid/resume", h.ResumeDeployment)
+
+			w := httptest.NewRecorder()
+			uid := utils.EncodeFriendlyID(utils.PrefixDeployment, deploymentID)
+			req, _ := http.NewRequest("POST", "/cli/v1/deployments/"+uid+"/resume", nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+			}
+			if lockTaken {
+				t.Error("Expected the instance lock not to be taken")
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("Expected message to contain %q, got %s", tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestUpdateDeploymentStepUnknownStep tests that only the known deployment steps are recorded
+func TestUpdateDeploymentStepUnknownStep(t *testing.T) {
+	mockRepo := &MockRepository{
+		MockSaveDeploymentStep: func(step *models.DeploymentStep) error {
+			t.Errorf("Unexpected step saved: %+v", step)
+			return nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.PUT("/cli/v1/deployments/:uid/steps/:name", h.UpdateDeploymentStep)
+
+	w := httptest.NewRecorder()
+	uid := utils.EncodeFriendlyID(utils.PrefixDeployment, uuid.New())
+	req, _ := http.NewRequest("PUT", "/cli/v1/deployments/"+uid+"/steps/compile", strings.NewReader(`{"status":"running"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
+
+// TestAppTokenScope tests that application tokens only reach the CLI API of their application
+func TestAppTokenScope(t *testing.T) {
+	appID := uuid.New()
+	otherAppID := uuid.New()
+	instanceID := uuid.New()
+	mockRepo := &MockRepository{
+		MockGetApplicationByName: func(name string) (*models.Application, error) {
+			switch name {
+			case "my_app":
+				return &models.Application{ID: appID, Name: name}, nil
+			case "other_app":
+				return &models.Application{ID: otherAppID, Name: name}, nil
+			}
+			return nil, errors.New("not found")
+		},
+		MockGetApplicationInstanceByID: func(id uuid.UUID) (*models.ApplicationInstance, error) {
+			return &models.ApplicationInstance{ID: id, ApplicationID: otherAppID}, nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	cli := router.Group("/api/cli/v1")
+	cli.Use(func(c *gin.Context) {
+		if c.GetHeader("X-Test-App-Token") != "" {
+			middleware.SetAppTokenApplication(c, appID)
+		}
+	}, h.AppTokenScope)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	cli.GET("/deploy/config", ok)
+	cli.GET("/hosts", ok)
+	cli.GET("/instances/:uid/releases", ok)
+	cli.POST("/deployments", func(c *gin.Context) {
+		var req types.CreateDeploymentRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.AppName == "" {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		appToken bool
+		want     int
+	}{
+		{"own app in query", "GET", "/api/cli/v1/deploy/config?app=my_app&host=prod", "", true, http.StatusOK},
+		{"other app in query", "GET", "/api/cli/v1/deploy/config?app=other_app&host=prod", "", true, http.StatusForbidden},
+		{"unknown app", "GET", "/api/cli/v1/deploy/config?app=missing", "", true, http.StatusForbidden},
+		{"no app", "GET", "/api/cli/v1/hosts", "", true, http.StatusForbidden},
+		{"instance of other app", "GET", "/api/cli/v1/instances/" + utils.EncodeFriendlyID(utils.PrefixAppInstance, instanceID) + "/releases", "", true, http.StatusForbidden},
+		{"own app in body", "POST", "/api/cli/v1/deployments", `{"app_name":"my_app","host_name":"prod"}`, true, http.StatusOK},
+		{"other app in body", "POST", "/api/cli/v1/deployments", `{"app_name":"other_app","host_name":"prod"}`, true, http.StatusForbidden},
+		{"own app in query and body", "POST", "/api/cli/v1/deployments?app=my_app", `{"app_name":"my_app","host_name":"prod"}`, true, http.StatusOK},
+		{"other app in body, own app in query", "POST", "/api/cli/v1/deployments?app=my_app", `{"app_name":"other_app","host_name":"prod"}`, true, http.StatusForbidden},
+		{"other app in query, own app in body", "POST", "/api/cli/v1/deployments?app_name=other_app", `{"app_name":"my_app","host_name":"prod"}`, true, http.StatusForbidden},
+		{"instance of other app in body", "POST", "/api/cli/v1/deployments", `{"app_name":"my_app","instance_id":"` + utils.EncodeFriendlyID(utils.PrefixAppInstance, instanceID) + `"}`, true, http.StatusForbidden},
+		{"user", "GET", "/api/cli/v1/hosts", "", false, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.appToken {
+				req.Header.Set("X-Test-App-Token", "1")
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status code %d, got %d. Body: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestCLIBuildArtifactRejectsInvalidRequests tests that builds on the server are checked before the sources are received
+func TestCLIBuildArtifactRejectsInvalidRequests(t *testing.T) {
+	mockRepo := &MockRepository{
+		MockGetApplicationByName: func(name string) (*models.Application, error) {
+			if name != "test-app" {
+				return nil, errors.New("not found")
+			}
+			return &models.Application{ID: uuid.New(), Name: name}, nil
+		},
+		MockGetSSHHostByName: func(name string) (*models.SSHHost, error) {
+			return nil, errors.New("not found")
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/cli/v1/builds", h.CLIBuildArtifact)
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{"missing version", "app=test-app", http.StatusBadRequest},
+		{"unknown app", "app=other-app&version=1.0.0", http.StatusNotFound},
+		{"unknown location", "app=test-app&version=1.0.0&location=laptop", http.StatusBadRequest},
+		{"host without builder host", "app=test-app&version=1.0.0&location=host", http.StatusBadRequest},
+		{"command builder on the server", "app=test-app&version=1.0.0&location=server&builder=command&command=make", http.StatusBadRequest},
+		{"mix builder on the server", "app=test-app&version=1.0.0&builder=mix", http.StatusBadRequest},
+		{"unknown builder host", "app=test-app&version=1.0.0&location=host&host=builder-1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/cli/v1/builds?"+tt.query, strings.NewReader("sources"))
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status code %d, got %d. Body: %s", tt.code, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestCLIDownloadArtifactNotInRegistry tests that only artifacts pushed to the registry can be downloaded
+func TestCLIDownloadArtifactNotInRegistry(t *testing.T) {
+	appID := uuid.New()
+	mockRepo := &MockRepository{
+		MockGetBuildArtifactByMD5Prefix: func(id uuid.UUID, md5Prefix string) (*models.BuildArtifact, error) {
+			return &models.BuildArtifact{ApplicationID: id, MD5Hash: md5Prefix, LocalPath: "/etc/passwd"}, nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.GET("/cli/v1/artifacts/download", h.CLIDownloadArtifact)
+
+	w := httptest.NewRecorder()
+	app := utils.EncodeFriendlyID(utils.PrefixApplication, appID)
+	req, _ := http.NewRequest("GET", "/cli/v1/artifacts/download?app_id="+app+"&md5=abc123", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusNotFound, w.Code, w.Body.String())
+	}
+}
+
+// TestCLIPushArtifact tests pushing artifacts to the registry and the retention of the builds of an app
+func TestCLIPushArtifact(t *testing.T) {
+	appID := uuid.New()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var artifacts []models.BuildArtifact // newest first, as the database returns them
+	var deleted []uuid.UUID
+	mockRepo := &MockRepository{
+		MockGetActiveSigningKey: func() (*models.SigningKey, ed25519.PrivateKey, error) {
+			return &models.SigningKey{Name: "default"}, priv, nil
+		},
+		MockGetBuildArtifactByMD5Prefix: func(id uuid.UUID, md5Prefix string) (*models.BuildArtifact, error) {
+			for _, artifact := range artifacts {
+				if artifact.MD5Hash == md5Prefix {
+					return &artifact, nil
+				}
+			}
+			return nil, errors.New("not found")
+		},
+		MockAddBuildArtifact: func(artifact *models.BuildArtifact) error {
+			artifact.ID = uuid.New()
+			artifacts = append([]models.BuildArtifact{*artifact}, artifacts...)
+			return nil
+		},
+		MockGetAllBuildArtifactsForApp: func(id uuid.UUID) ([]models.BuildArtifact, error) {
+			return artifacts, nil
+		},
+		MockDeleteBuildArtifact: func(id uuid.UUID) error {
+			deleted = append(deleted, id)
+			return nil
+		},
+	}
+
+	store, err := registry.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandlers(mockRepo)
+	h.Artifacts = store
+	router := setupTestRouter()
+	router.POST("/cli/v1/artifacts/push", h.CLIPushArtifact)
+	router.GET("/cli/v1/artifacts/download", h.CLIDownloadArtifact)
+
+	app := utils.EncodeFriendlyID(utils.PrefixApplication, appID)
+	push := func(md5Hash, version, content string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/cli/v1/artifacts/push?app_id="+app+"&md5="+md5Hash+"&version="+version+"&keep=1", strings.NewReader(content))
+		router.ServeHTTP(w, req)
+		return w
+	}
+	md5Of := func(content string) string {
+		sum := md5.Sum([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/cli/v1/artifacts/push?app_id="+app+"&md5="+md5Of("1.0.0")+"&version=1.0.0&compression=xz", strings.NewReader("1.0.0"))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d for an unknown compression, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	if w := push(md5Of("1.0.0"), "1.0.0", "tampered"); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d for a wrong MD5, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		if w := push(md5Of(version), version, version); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+	}
+
+	// keep=1 prunes 1.0.0 once 1.1.0 is pushed
+	if len(deleted) != 1 || deleted[0] != artifacts[1].ID {
+		t.Fatalf("expected the 1.0.0 build to be pruned, deleted %v", deleted)
+	}
+
+	// Pushed builds are signed for their application
+	trusted := []signing.TrustedKey{{Name: "default", PublicKey: pub}}
+	if key, err := signing.Verify(trusted, appID.String(), artifacts[0].SHA256, artifacts[0].Signature); err != nil || key != "default" {
+		t.Errorf("expected the 1.1.0 build to be signed with the default key, got %q: %v", key, err)
+	}
+	if _, err := store.Get(context.Background(), registry.Key(md5Of("1.0.0"))); err != registry.ErrNotFound {
+		t.Errorf("expected the 1.0.0 build to be deleted from the registry, got %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/cli/v1/artifacts/download?app_id="+app+"&md5="+md5Of("1.1.0"), nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "1.1.0" {
+		t.Errorf("expected to pull the 1.1.0 build, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestCLIBuildArtifactPruning tests deleting builds and listing the deployments that shipped them
+func TestCLIBuildArtifactPruning(t *testing.T) {
+	appID := uuid.New()
+	md5Hash := "0123456789abcdef0123456789abcdef"
+	artifact := &models.BuildArtifact{ID: uuid.New(), ApplicationID: appID, Version: "1.0.0", MD5Hash: md5Hash, Stored: true}
+	deployedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var deleted []uuid.UUID
+	mockRepo := &MockRepository{
+		MockGetApplicationByName: func(name string) (*models.Application, error) {
+			if name != "my_app" {
+				return nil, errors.New("not found")
+			}
+			return &models.Application{ID: appID, Name: name}, nil
+		},
+		MockGetBuildArtifactByMD5Prefix: func(id uuid.UUID, md5Prefix string) (*models.BuildArtifact, error) {
+			if md5Prefix != md5Hash {
+				return nil, errors.New("not found")
+			}
+			return artifact, nil
+		},
+		MockGetAllBuildArtifactsForApp: func(id uuid.UUID) ([]models.BuildArtifact, error) {
+			return []models.BuildArtifact{*artifact}, nil
+		},
+		MockGetBuildArtifactsLastDeployed: func(id uuid.UUID) (map[string]time.Time, error) {
+			return map[string]time.Time{md5Hash: deployedAt}, nil
+		},
+		MockGetBuildArtifactDeployments: func(id uuid.UUID, md5 string) ([]database.DeploymentHistoryRow, error) {
+			return []database.DeploymentHistoryRow{{ID: uuid.New(), Version: "1.0.0", Status: "success", HostName: "prod-1", CreatedAt: deployedAt}}, nil
+		},
+		MockDeleteBuildArtifact: func(id uuid.UUID) error {
+			deleted = append(deleted, id)
+			return nil
+		},
+	}
+
+	store, err := registry.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(context.Background(), registry.Key(md5Hash), strings.NewReader("1.0.0"), 5); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandlers(mockRepo)
+	h.Artifacts = store
+	router := setupTestRouter()
+	router.GET("/cli/v1/builds", h.CLIListBuildArtifacts)
+	router.DELETE("/cli/v1/builds/:md5", h.CLIDeleteBuildArtifact)
+	router.GET("/cli/v1/builds/:md5/deployments", h.CLIListBuildDeployments)
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// build prune keeps builds by their last deployment
+	w := serve("GET", "/cli/v1/builds?app=my_app")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"last_deployed_at":"`+deployedAt.Format(time.RFC3339)+`"`) {
+		t.Fatalf("expected the build list with the last deployment, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serve("GET", "/cli/v1/builds/"+md5Hash+"/deployments?app=my_app")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"host_name":"prod-1"`) {
+		t.Fatalf("expected the deployments of the build, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{"MD5 prefix", "/cli/v1/builds/0123456789?app=my_app", http.StatusBadRequest},
+		{"missing app", "/cli/v1/builds/" + md5Hash, http.StatusBadRequest},
+		{"unknown app", "/cli/v1/builds/" + md5Hash + "?app=other", http.StatusNotFound},
+		{"unknown build", "/cli/v1/builds/ffffffffffffffffffffffffffffffff?app=my_app", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := serve("DELETE", tt.path); w.Code != tt.code {
+			t.Errorf("%s: expected status code %d, got %d. Body: %s", tt.name, tt.code, w.Code, w.Body.String())
+		}
+	}
+	if len(deleted) != 0 {
+		t.Fatalf("expected no build to be deleted, deleted %v", deleted)
+	}
+
+	if w := serve("DELETE", "/cli/v1/builds/"+md5Hash+"?app=my_app"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if len(deleted) != 1 || deleted[0] != artifact.ID {
+		t.Errorf("expected the build to be deleted from the build history, deleted %v", deleted)
+	}
+	if _, err := store.Get(context.Background(), registry.Key(md5Hash)); err != registry.ErrNotFound {
+		t.Errorf("expected the build to be deleted from the registry, got %v", err)
+	}
+}
+
+// TestAddTrustedKey tests that only valid ed25519 public keys can be trusted for an application
+func TestAddTrustedKey(t *testing.T) {
+	appID := uuid.New()
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockRepo := &MockRepository{
+		MockGetApplicationByID: func(id uuid.UUID) (*models.Application, error) {
+			return &models.Application{ID: id, Name: "my_app"}, nil
+		},
+		MockAddTrustedKey: func(id uuid.UUID, name, publicKey string) (*models.TrustedKey, error) {
+			if _, err := signing.ParsePublicKey(publicKey); err != nil {
+				return nil, err
+			}
+			return &models.TrustedKey{ID: uuid.New(), ApplicationID: id, Name: name, PublicKey: publicKey}, nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/applications/:uid/trusted-keys", h.AddTrustedKey)
+
+	add := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		uid := utils.EncodeFriendlyID(utils.PrefixApplication, appID)
+		req, _ := http.NewRequest("POST", "/applications/"+uid+"/trusted-keys", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := add(`{"name":"ci","public_key":"rsa:AAAA"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for an invalid key, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+
+	w := add(`{"name":"ci","public_key":"` + signing.EncodePublicKey(pub) + `"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data types.TrustedKeyDTO `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.Data.Name != "ci" || !strings.HasPrefix(resp.Data.UID, utils.PrefixTrustedKey) {
+		t.Errorf("unexpected trusted key: %+v", resp.Data)
+	}
+}
+
+// TestUpdateDeploymentStatusPorts tests that all ports of a scaled release are recorded, and a single port otherwise
+func TestUpdateDeploymentStatusPorts(t *testing.T) {
+	deploymentID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	var recorded []int
+
+	mockRepo := &MockRepository{
+		MockRecordSuccessfulDeployment: func(id uuid.UUID, ports []int, releasePath string, gitCommitSHA string) error {
+			recorded = ports
+			return nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.PUT("/cli/v1/deployments/:uid/status", h.UpdateDeploymentStatus)
+
+	uid := utils.EncodeFriendlyID(utils.PrefixDeployment, deploymentID)
+	for _, tt := range []struct {
+		body string
+		want []int
+	}{
+		{`{"status":"success","port":4001,"ports":[4001,4002,4003],"release_path":"/var/www/test-app/releases/v2-1"}`, []int{4001, 4002, 4003}},
+		{`{"status":"success","port":4001,"release_path":"/var/www/test-app/releases/v2-1"}`, []int{4001}},
+	} {
+		recorded = nil
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/cli/v1/deployments/"+uid+"/status", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if !reflect.DeepEqual(recorded, tt.want) {
+			t.Errorf("Recorded ports %v, want %v", recorded, tt.want)
+		}
+	}
+}
+
+// TestScaleInstanceCanaryRunning tests that an instance is not scaled while a canary splits its traffic
+func TestScaleInstanceCanaryRunning(t *testing.T) {
+	instanceID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	deploymentID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	lockTaken := false
+
+	mockRepo := &MockRepository{
+		MockGetApplicationInstanceByID: func(id uuid.UUID) (*models.ApplicationInstance, error) {
+			return &models.ApplicationInstance{ID: id}, nil
+		},
+		MockGetInstanceCanary: func(id uuid.UUID) (*models.InstanceCanary, error) {
+			return &models.InstanceCanary{InstanceID: id, DeploymentID: deploymentID, StablePort: 8001, CanaryPort: 8002, CanaryWeight: 10}, nil
+		},
+		MockAcquireInstanceLock: func(lock *models.InstanceLock) (*models.InstanceLock, error) {
+			lockTaken = true
+			return lock, nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/cli/v1/instances/:uid/scale", h.ScaleInstance)
+
+	uid := utils.EncodeFriendlyID(utils.PrefixAppInstance, instanceID)
+	for body, want := range map[string]int{
+		`{"count":3}`:  http.StatusConflict,
+		`{"count":0}`:  http.StatusBadRequest,
+		`{"count":99}`: http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/cli/v1/instances/"+uid+"/scale", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != want {
+			t.Errorf("%s: expected status code %d, got %d. Body: %s", body, want, w.Code, w.Body.String())
+		}
+	}
+	if lockTaken {
+		t.Error("Expected the instance lock not to be taken while a canary is running")
+	}
+}
+
+func TestCLIEnsureReleaseCookie(t *testing.T) {
+	appID := uuid.New()
+	var generated string
+	mockRepo := &MockRepository{
+		MockGetApplicationByName: func(name string) (*models.Application, error) {
+			if name != "shop" {
+				return nil, errors.New("not found")
+			}
+			return &models.Application{ID: appID, Name: name}, nil
+		},
+		MockEnsureSecret: func(id uuid.UUID, key, value string) (string, error) {
+			if id != appID || key != "RELEASE_COOKIE" {
+				t.Errorf("EnsureSecret(%s, %s) called for the wrong secret", id, key)
+			}
+			generated = value
+			return "KEPT", nil
+		},
+	}
+
+	h := NewHandlers(mockRepo)
+	router := setupTestRouter()
+	router.POST("/cli/v1/release-cookie", h.CLIEnsureReleaseCookie)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/cli/v1/release-cookie?app=shop", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if len(generated) != 56 {
+		t.Errorf("Expected a generated cookie of 56 characters, got %q", generated)
+	}
+	// The cookie kept by the repository wins over the one generated for this request
+	if !strings.Contains(w.Body.String(), `"cookie":"KEPT"`) {
+		t.Errorf("Expected the kept cookie in the response, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/cli/v1/release-cookie?app=unknown", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for an unknown app, got %d", http.StatusNotFound, w.Code)
+	}
+}
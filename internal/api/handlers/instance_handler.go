@@ -4,8 +4,13 @@ import (
 	"youfun/shipyard/internal/api/response"
 	"youfun/shipyard/internal/api/utils"
 	"youfun/shipyard/internal/caddy"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/deploy"
 	"youfun/shipyard/internal/logs"
+	"youfun/shipyard/internal/models"
 	"youfun/shipyard/internal/sshutil"
+	"youfun/shipyard/pkg/types"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -270,6 +275,144 @@ func (h *Handlers) RestartInstance(c *gin.Context) {
 	response.Message(c, "Instance restarted successfully")
 }
 
+// RollbackInstance rolls a specific application instance back to a retained release
+func RollbackInstance(c *gin.Context) {
+	h := &Handlers{Repo: defaultAppsRepo}
+	h.RollbackInstance(c)
+}
+
+func (h *Handlers) RollbackInstance(c *gin.Context) {
+	uid := c.Param("uid")
+	instanceID, err := utils.DecodeFriendlyID(utils.PrefixAppInstance, uid)
+	if err != nil {
+		response.BadRequest(c, "Invalid instance ID")
+		return
+	}
+
+	// Body is optional: without "to" the standby release is restored
+	var req types.RollbackRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "Invalid request")
+			return
+		}
+	}
+
+	if _, err := h.Repo.GetApplicationInstanceByID(instanceID); err != nil {
+		response.NotFound(c, "Instance not found")
+		return
+	}
+	if h.canaryConflict(c, instanceID) {
+		return
+	}
+
+	// "to" is either a deployment ID or a version
+	opts := deploy.RollbackOptions{HealthCheck: healthCheckFromDTO(req.HealthCheck)}
+	if req.To != "" {
+		if deployID, err := utils.DecodeFriendlyID(utils.PrefixDeployment, req.To); err == nil {
+			opts.DeploymentID = deployID
+		} else if deployID, err := utils.ParseUUID(req.To); err == nil {
+			opts.DeploymentID = deployID
+		} else {
+			opts.Version = req.To
+		}
+	}
+
+	lock, ok := h.acquireLock(c, instanceID, models.LockKindRollback, "", rollbackLockTTL)
+	if !ok {
+		return
+	}
+	defer h.releaseLock(lock)
+
+	result, err := deploy.Rollback(instanceID, opts)
+	if err != nil {
+		response.InternalServerError(c, "Rollback failed: "+err.Error())
+		return
+	}
+
+	response.Data(c, types.RollbackResponse{
+		DeploymentID: utils.EncodeFriendlyID(utils.PrefixDeployment, result.DeploymentID),
+		Version:      result.Version,
+		ReleasePath:  result.ReleasePath,
+		Port:         result.Port,
+		PreviousPort: result.PreviousPort,
+	})
+}
+
+// ScaleInstance runs a number of instances of the active release of an application instance
+func ScaleInstance(c *gin.Context) {
+	h := &Handlers{Repo: defaultAppsRepo}
+	h.ScaleInstance(c)
+}
+
+func (h *Handlers) ScaleInstance(c *gin.Context) {
+	uid := c.Param("uid")
+	instanceID, err := utils.DecodeFriendlyID(utils.PrefixAppInstance, uid)
+	if err != nil {
+		response.BadRequest(c, "Invalid instance ID")
+		return
+	}
+
+	var req types.ScaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request")
+		return
+	}
+	if req.Count < 1 || req.Count > config.MaxScale {
+		response.BadRequest(c, fmt.Sprintf("count must be between 1 and %d", config.MaxScale))
+		return
+	}
+
+	if _, err := h.Repo.GetApplicationInstanceByID(instanceID); err != nil {
+		response.NotFound(c, "Instance not found")
+		return
+	}
+	// A canary runs a single instance next to the stable release
+	if h.canaryConflict(c, instanceID) {
+		return
+	}
+
+	lock, ok := h.acquireLock(c, instanceID, models.LockKindScale, "", scaleLockTTL)
+	if !ok {
+		return
+	}
+	defer h.releaseLock(lock)
+
+	result, err := deploy.Scale(instanceID, req.Count, healthCheckFromDTO(req.HealthCheck))
+	if errors.Is(err, deploy.ErrNotDeployed) {
+		response.Error(c, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		response.InternalServerError(c, "Scale failed: "+err.Error())
+		return
+	}
+
+	response.Data(c, types.ScaleResponse{
+		Version:     result.Version,
+		ReleasePath: result.ReleasePath,
+		Ports:       result.Ports,
+		Started:     result.Started,
+		Stopped:     result.Stopped,
+	})
+}
+
+// healthCheckFromDTO converts readiness probe settings sent by the CLI.
+func healthCheckFromDTO(dto *types.HealthCheckConfigDTO) *config.HealthCheck {
+	if dto == nil {
+		return nil
+	}
+	return &config.HealthCheck{
+		Path:           dto.Path,
+		ExpectedStatus: dto.ExpectedStatus,
+		BodyContains:   dto.BodyContains,
+		Interval:       time.Duration(dto.IntervalMs) * time.Millisecond,
+		Timeout:        time.Duration(dto.TimeoutMs) * time.Millisecond,
+		GracePeriod:    time.Duration(dto.GracePeriodMs) * time.Millisecond,
+		Retries:        dto.Retries,
+	}
+}
+
 // GetInstanceLogs fetches logs for a specific application instance
 func GetInstanceLogs(c *gin.Context) {
 	h := &Handlers{Repo: defaultAppsRepo}
@@ -289,6 +432,10 @@ func (h *Handlers) GetInstanceLogs(c *gin.Context) {
 	if err != nil {
 		lines = 500
 	}
+	process, ok := logsProcess(c)
+	if !ok {
+		return
+	}
 
 	// 1. Get Instance details
 	instance, err := h.Repo.GetApplicationInstanceByID(instanceID)
@@ -297,7 +444,8 @@ func (h *Handlers) GetInstanceLogs(c *gin.Context) {
 		return
 	}
 
-	if !instance.ActivePort.Valid || instance.ActivePort.Int64 == 0 {
+	// The other process types run no port, their logs are those of all their instances
+	if process == "" && (!instance.ActivePort.Valid || instance.ActivePort.Int64 == 0) {
 		response.BadRequest(c, "Instance has no active port")
 		return
 	}
@@ -318,7 +466,12 @@ func (h *Handlers) GetInstanceLogs(c *gin.Context) {
 
 	// 3. Fetch logs via SSH
 	// We use nil for hostKeyCallback to use default verification against DB stored key
-	logContent, err := logs.FetchJournalLogs(host, app.Name, int(port), lines, false, nil)
+	var logContent string
+	if process != "" {
+		logContent, err = logs.FetchProcessLogs(host, app.Name, process, lines, nil)
+	} else {
+		logContent, err = logs.FetchJournalLogs(host, app.Name, int(port), lines, false, nil)
+	}
 	if err != nil {
 		response.InternalServerError(c, "Failed to fetch logs: "+err.Error())
 		return
@@ -327,6 +480,20 @@ func (h *Handlers) GetInstanceLogs(c *gin.Context) {
 	response.Data(c, gin.H{"logs": logContent})
 }
 
+// logsProcess returns the process type of the ?process= query of the logs endpoints, "" for web.
+// It responds with a bad request for an invalid name.
+func logsProcess(c *gin.Context) (string, bool) {
+	process := c.Query("process")
+	if process == config.ProcessWeb {
+		return "", true
+	}
+	if process != "" && !config.ValidProcessName(process) {
+		response.BadRequest(c, "Invalid process type")
+		return "", false
+	}
+	return process, true
+}
+
 // WebSocket upgrader
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
@@ -362,6 +529,10 @@ func (h *Handlers) StreamInstanceLogs(c *gin.Context) {
 	if err != nil {
 		lines = 100
 	}
+	process, ok := logsProcess(c)
+	if !ok {
+		return
+	}
 
 	// For WebSocket, we need to authenticate before upgrading
 	// The token is passed via query parameter since WebSocket doesn't support custom headers easily
@@ -394,7 +565,8 @@ func (h *Handlers) StreamInstanceLogs(c *gin.Context) {
 		return
 	}
 
-	if !instance.ActivePort.Valid || instance.ActivePort.Int64 == 0 {
+	// The other process types run no port, their logs are those of all their instances
+	if process == "" && (!instance.ActivePort.Valid || instance.ActivePort.Int64 == 0) {
 		response.BadRequest(c, "Instance has no active port")
 		return
 	}
@@ -445,6 +617,9 @@ func (h *Handlers) StreamInstanceLogs(c *gin.Context) {
 
 	// 6. Build journalctl command with follow flag
 	unitName := fmt.Sprintf("%s@%d", app.Name, port)
+	if process != "" {
+		unitName = logs.ProcessUnitPattern(app.Name, process)
+	}
 	cmd := fmt.Sprintf("journalctl -u %s -n %d --no-pager -o cat -f", unitName, lines)
 
 	// 7. Set up stdout pipe
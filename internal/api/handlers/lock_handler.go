@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"youfun/shipyard/internal/api/middleware"
+	"youfun/shipyard/internal/api/response"
+	"youfun/shipyard/internal/api/utils"
+	"youfun/shipyard/internal/database"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/pkg/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// deployLockTTL is the lease of a deploy lock; the CLI renews it with heartbeats while deploying
+	deployLockTTL = 60 * time.Second
+	// rollbackLockTTL bounds a rollback run by the server, which releases the lock when done
+	rollbackLockTTL = 10 * time.Minute
+	// canaryLockTTL bounds the promotion or abort of a canary by the server, which releases the lock when done
+	canaryLockTTL = 5 * time.Minute
+	// scaleLockTTL bounds the scaling of an instance by the server, which releases the lock when done
+	scaleLockTTL = 10 * time.Minute
+	// serverSideLockTTL bounds a server-side deployment, which releases the lock when done
+	serverSideLockTTL = 30 * time.Minute
+)
+
+// lockOwner returns the name recorded as holder of locks taken by this request.
+func lockOwner(c *gin.Context) string {
+	if username := c.GetString("username"); username != "" {
+		return username
+	}
+	return "unknown"
+}
+
+// lockConflictMessage explains why an instance cannot be deployed to right now.
+func lockConflictMessage(lock *models.InstanceLock) string {
+	since := "unknown time"
+	if lock.AcquiredAt.Time != nil {
+		since = lock.AcquiredAt.Time.Format("2006-01-02 15:04:05")
+	}
+	switch lock.Kind {
+	case models.LockKindManual:
+		return fmt.Sprintf("deploys are locked by %s since %s: %s", lock.Owner, since, lock.Reason)
+	case models.LockKindRollback:
+		return fmt.Sprintf("rollback in progress by %s since %s", lock.Owner, since)
+	case models.LockKindCanary:
+		return fmt.Sprintf("canary update in progress by %s since %s", lock.Owner, since)
+	case models.LockKindScale:
+		return fmt.Sprintf("scaling in progress by %s since %s", lock.Owner, since)
+	default:
+		return fmt.Sprintf("deploy in progress by %s since %s", lock.Owner, since)
+	}
+}
+
+// instanceLockResponse converts a lock for API responses.
+func instanceLockResponse(lock *models.InstanceLock) types.InstanceLockDTO {
+	dto := types.InstanceLockDTO{
+		Kind:       lock.Kind,
+		Owner:      lock.Owner,
+		Reason:     lock.Reason,
+		AcquiredAt: lock.AcquiredAt.Time,
+		ExpiresAt:  lock.ExpiresAt.Time,
+	}
+	if lock.DeploymentID.Valid {
+		dto.DeploymentID = utils.EncodeFriendlyID(utils.PrefixDeployment, lock.DeploymentID.UUID)
+	}
+	return dto
+}
+
+// acquireLock takes the lock of an instance for this request and returns it.
+// On conflict it writes a 409 response naming the holder and returns false.
+func (h *Handlers) acquireLock(c *gin.Context, instanceID uuid.UUID, kind, reason string, ttl time.Duration) (*models.InstanceLock, bool) {
+	lock := &models.InstanceLock{
+		InstanceID: instanceID,
+		Kind:       kind,
+		Owner:      lockOwner(c),
+		Reason:     reason,
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		lock.ExpiresAt = models.NullableTime{Time: &expiresAt}
+	}
+
+	held, err := h.Repo.AcquireInstanceLock(lock)
+	if errors.Is(err, database.ErrInstanceLocked) {
+		response.Error(c, http.StatusConflict, lockConflictMessage(held))
+		return nil, false
+	}
+	if err != nil {
+		response.InternalServerError(c, "Failed to acquire instance lock: "+err.Error())
+		return nil, false
+	}
+	return lock, true
+}
+
+// releaseLock releases a lock taken by acquireLock, unless its lease expired and someone else took it over.
+func (h *Handlers) releaseLock(lock *models.InstanceLock) {
+	if err := h.Repo.ReleaseInstanceLockIfHeld(lock.InstanceID, lock.ID); err != nil {
+		log.Printf("⚠️ Failed to release instance lock: %v", err)
+	}
+}
+
+// rejectAppToken writes a 403 response to requests authenticated with an application token and returns true.
+// Locks are for users: the token of a CI pipeline must not lift the freeze that is meant to stop it.
+func rejectAppToken(c *gin.Context) bool {
+	if _, ok := middleware.GetAppTokenApplicationFromContext(c); !ok {
+		return false
+	}
+	response.Error(c, http.StatusForbidden, "Application tokens cannot lock or unlock instances")
+	return true
+}
+
+// LockInstance freezes deployments to an application instance until it is unlocked
+func LockInstance(c *gin.Context) {
+	h := &Handlers{Repo: defaultAppsRepo}
+	h.LockInstance(c)
+}
+
+// LockInstanceHandler freezes deployments to an application instance (method on Handlers)
+func (h *Handlers) LockInstance(c *gin.Context) {
+	if rejectAppToken(c) {
+		return
+	}
+	instanceID, err := utils.DecodeFriendlyID(utils.PrefixAppInstance, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid instance ID")
+		return
+	}
+
+	var req types.LockInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "A reason is required to lock an instance")
+		return
+	}
+
+	if _, err := h.Repo.GetApplicationInstanceByID(instanceID); err != nil {
+		response.NotFound(c, "Instance not found")
+		return
+	}
+
+	if _, ok := h.acquireLock(c, instanceID, models.LockKindManual, req.Reason, 0); !ok {
+		return
+	}
+
+	lock, err := h.Repo.GetInstanceLock(instanceID)
+	if err != nil || lock == nil {
+		response.InternalServerError(c, "Failed to read instance lock")
+		return
+	}
+	response.Data(c, instanceLockResponse(lock))
+}
+
+// UnlockInstance removes the lock of an application instance, whoever holds it
+func UnlockInstance(c *gin.Context) {
+	h := &Handlers{Repo: defaultAppsRepo}
+	h.UnlockInstance(c)
+}
+
+// UnlockInstanceHandler removes the lock of an application instance (method on Handlers).
+// The lock of a running deployment, rollback, canary update or scaling is only removed with force=true,
+// and a deployment records who removed its lock.
+func (h *Handlers) UnlockInstance(c *gin.Context) {
+	if rejectAppToken(c) {
+		return
+	}
+	instanceID, err := utils.DecodeFriendlyID(utils.PrefixAppInstance, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid instance ID")
+		return
+	}
+
+	lock, err := h.Repo.GetInstanceLock(instanceID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to read instance lock")
+		return
+	}
+	if lock == nil {
+		response.Message(c, "Instance is not locked")
+		return
+	}
+	if force, _ := strconv.ParseBool(c.Query("force")); !force && lock.Kind != models.LockKindManual {
+		response.Error(c, http.StatusConflict, lockConflictMessage(lock)+", unlock with force to stop waiting for it")
+		return
+	}
+
+	// Only the lock read above is released, not one taken since
+	if err := h.Repo.ReleaseInstanceLockIfHeld(instanceID, lock.ID); err != nil {
+		response.InternalServerError(c, "Failed to release instance lock")
+		return
+	}
+	releasedBy := lockOwner(c)
+	log.Printf("🔓 %s lock of instance %s held by %s released by %s", lock.Kind, c.Param("uid"), lock.Owner, releasedBy)
+	if lock.DeploymentID.Valid {
+		note := fmt.Sprintf("Instance lock released by %s while the deployment was running\n", releasedBy)
+		if err := h.Repo.AppendDeploymentHistoryOutput(lock.DeploymentID.UUID, note); err != nil {
+			log.Printf("⚠️ Failed to record the release of the lock of deployment %s: %v", lock.DeploymentID.UUID, err)
+		}
+	}
+	response.Data(c, instanceLockResponse(lock))
+}
+
+// RenewDeploymentLock extends the lease of the lock held by a running deployment (CLI heartbeat)
+func RenewDeploymentLock(c *gin.Context) {
+	h := &Handlers{Repo: defaultDeploymentsRepo}
+	h.RenewDeploymentLock(c)
+}
+
+// RenewDeploymentLockHandler extends the lease of a deployment lock (method on Handlers)
+func (h *Handlers) RenewDeploymentLock(c *gin.Context) {
+	deployID, err := utils.DecodeFriendlyID(utils.PrefixDeployment, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid deployment ID")
+		return
+	}
+
+	lock, err := h.Repo.RenewInstanceLock(deployID, deployLockTTL)
+	if errors.Is(err, database.ErrLockNotHeld) {
+		response.Error(c, http.StatusConflict, "Deployment no longer holds the instance lock")
+		return
+	}
+	if err != nil {
+		response.InternalServerError(c, "Failed to renew instance lock")
+		return
+	}
+	response.Data(c, instanceLockResponse(lock))
+}
+
+// ReleaseDeploymentLock releases the lock held by a finished deployment
+func ReleaseDeploymentLock(c *gin.Context) {
+	h := &Handlers{Repo: defaultDeploymentsRepo}
+	h.ReleaseDeploymentLock(c)
+}
+
+// ReleaseDeploymentLockHandler releases the lock held by a deployment (method on Handlers)
+func (h *Handlers) ReleaseDeploymentLock(c *gin.Context) {
+	deployID, err := utils.DecodeFriendlyID(utils.PrefixDeployment, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid deployment ID")
+		return
+	}
+
+	if err := h.Repo.ReleaseInstanceLockForDeployment(deployID); err != nil {
+		response.InternalServerError(c, "Failed to release instance lock")
+		return
+	}
+	response.Message(c, "Lock released")
+}
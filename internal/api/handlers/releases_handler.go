@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"youfun/shipyard/internal/api/response"
+	"youfun/shipyard/internal/api/utils"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/deploy"
+	"youfun/shipyard/pkg/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// releaseResponse converts a release for API responses.
+func releaseResponse(release deploy.Release) types.ReleaseDTO {
+	return types.ReleaseDTO{
+		Name:         release.Name(),
+		Version:      release.Version,
+		Path:         release.Path,
+		Status:       release.Status,
+		Pinned:       release.Pinned,
+		Port:         release.Port,
+		GitCommitSHA: release.GitCommitSHA,
+		StartedAt:    release.StartedAt.Time,
+	}
+}
+
+// releasesResponse converts releases for API responses.
+func releasesResponse(releases []deploy.Release) []types.ReleaseDTO {
+	result := make([]types.ReleaseDTO, 0, len(releases))
+	for _, release := range releases {
+		result = append(result, releaseResponse(release))
+	}
+	return result
+}
+
+// ListInstanceReleases lists the releases of an application instance
+func ListInstanceReleases(c *gin.Context) {
+	h := &Handlers{Repo: defaultAppsRepo}
+	h.ListInstanceReleases(c)
+}
+
+// ListInstanceReleasesHandler lists the releases of an instance, newest first (method on Handlers)
+func (h *Handlers) ListInstanceReleases(c *gin.Context) {
+	instanceID, ok := h.releaseInstance(c)
+	if !ok {
+		return
+	}
+
+	releases, err := deploy.ListReleases(instanceID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to list releases: "+err.Error())
+		return
+	}
+	response.Data(c, releasesResponse(releases))
+}
+
+// PruneInstanceReleases deletes the old releases of an application instance from its host
+func PruneInstanceReleases(c *gin.Context) {
+	h := &Handlers{Repo: defaultAppsRepo}
+	h.PruneInstanceReleases(c)
+}
+
+// PruneInstanceReleasesHandler applies release retention to an instance (method on Handlers)
+func (h *Handlers) PruneInstanceReleases(c *gin.Context) {
+	instanceID, ok := h.releaseInstance(c)
+	if !ok {
+		return
+	}
+
+	// Body is optional: without "keep" the default retention applies
+	var req types.PruneReleasesRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "Invalid request")
+			return
+		}
+	}
+	keep := config.DefaultKeepReleases
+	if req.Keep != nil {
+		keep = *req.Keep
+	}
+	if keep < 0 {
+		response.BadRequest(c, "keep must not be negative")
+		return
+	}
+
+	pruned, err := deploy.PruneReleases(instanceID, keep, req.DryRun)
+	if err != nil {
+		response.InternalServerError(c, "Failed to prune releases: "+err.Error())
+		return
+	}
+	response.Data(c, types.PruneReleasesResponse{Pruned: releasesResponse(pruned), DryRun: req.DryRun})
+}
+
+// PinInstanceRelease protects a release of an application instance from pruning
+func PinInstanceRelease(c *gin.Context) {
+	h := &Handlers{Repo: defaultAppsRepo}
+	h.PinInstanceRelease(c)
+}
+
+// PinInstanceReleaseHandler pins a release of an instance (method on Handlers)
+func (h *Handlers) PinInstanceRelease(c *gin.Context) {
+	h.setReleasePin(c, true)
+}
+
+// UnpinInstanceRelease lets a pinned release of an application instance be pruned again
+func UnpinInstanceRelease(c *gin.Context) {
+	h := &Handlers{Repo: defaultAppsRepo}
+	h.UnpinInstanceRelease(c)
+}
+
+// UnpinInstanceReleaseHandler unpins a release of an instance (method on Handlers)
+func (h *Handlers) UnpinInstanceRelease(c *gin.Context) {
+	h.setReleasePin(c, false)
+}
+
+// setReleasePin pins or unpins the release named in the URL and writes the response.
+// The release is named by its directory or by the ID of the deployment that created it.
+func (h *Handlers) setReleasePin(c *gin.Context, pin bool) {
+	instanceID, ok := h.releaseInstance(c)
+	if !ok {
+		return
+	}
+
+	name := c.Param("release")
+	if strings.HasPrefix(name, utils.PrefixDeployment) {
+		deployID, err := utils.DecodeFriendlyID(utils.PrefixDeployment, name)
+		if err != nil {
+			response.BadRequest(c, "Invalid deployment ID")
+			return
+		}
+		history, err := h.Repo.GetDeploymentHistoryByID(deployID)
+		if err != nil || history.InstanceID != instanceID {
+			response.NotFound(c, "Deployment not found")
+			return
+		}
+		if history.ReleasePath == "" {
+			response.NotFound(c, "Deployment has no release")
+			return
+		}
+		name = path.Base(history.ReleasePath)
+	}
+
+	release, err := deploy.PinRelease(instanceID, name, pin)
+	if errors.Is(err, deploy.ErrReleaseNotFound) {
+		response.NotFound(c, err.Error())
+		return
+	}
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Data(c, releaseResponse(*release))
+}
+
+// releaseInstance resolves the application instance of a releases request.
+// It writes an error response and returns false when the instance is unknown.
+func (h *Handlers) releaseInstance(c *gin.Context) (uuid.UUID, bool) {
+	instanceID, err := utils.DecodeFriendlyID(utils.PrefixAppInstance, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid instance ID")
+		return uuid.Nil, false
+	}
+	if _, err := h.Repo.GetApplicationInstanceByID(instanceID); err != nil {
+		response.NotFound(c, "Instance not found")
+		return uuid.Nil, false
+	}
+	return instanceID, true
+}
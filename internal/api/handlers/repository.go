@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"crypto/ed25519"
 	"youfun/shipyard/internal/database"
 	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/signing"
 	"time"
 
 	"github.com/google/uuid"
@@ -47,6 +49,7 @@ type SecretRepository interface {
 	GetSecretsForApp(appID uuid.UUID) (map[string]string, error)
 	GetAllSecrets(appID uuid.UUID) (map[string]string, error)
 	SetSecret(appID uuid.UUID, key, value string) error
+	EnsureSecret(appID uuid.UUID, key, value string) (string, error)
 	UnsetSecret(appID uuid.UUID, key string) error
 }
 
@@ -58,9 +61,13 @@ type DeploymentRepository interface {
 	CreateDeploymentHistoryWithStatus(instanceID uuid.UUID, version, status, output string) (*models.DeploymentHistory, error)
 	AppendDeploymentHistoryOutput(id uuid.UUID, output string) error
 	UpdateDeploymentHistoryStatusOnly(id uuid.UUID, status string) error
-	RecordSuccessfulDeployment(deploymentID uuid.UUID, port int, releasePath string, gitCommitSHA string) error
+	RecordSuccessfulDeployment(deploymentID uuid.UUID, ports []int, releasePath string, gitCommitSHA string) error
+	GetActiveInstancePorts(instanceID uuid.UUID) ([]int, error)
+	GetActivePeersForApp(appID uuid.UUID) ([]database.ClusterPeer, error)
 	GetDeploymentsCount() (int, error)
 	GetRecentDeploymentsGlobal(limit int) ([]database.RecentDeploymentRow, error)
+	AddDeploymentHealthChecks(deploymentID uuid.UUID, results []models.HealthCheckResult) error
+	GetHealthChecksForDeployment(deploymentID uuid.UUID) ([]models.HealthCheckResult, error)
 }
 
 // DomainRepository defines methods for domain data operations
@@ -77,8 +84,13 @@ type DomainRepository interface {
 type BuildArtifactRepository interface {
 	GetBuildArtifactByGitSHA(appID uuid.UUID, gitSHA string) (*models.BuildArtifact, error)
 	GetBuildArtifactByMD5Prefix(appID uuid.UUID, md5Prefix string) (*models.BuildArtifact, error)
+	GetLatestBuildArtifactByVersion(appID uuid.UUID, version string) (*models.BuildArtifact, error)
 	GetAllBuildArtifactsForApp(appID uuid.UUID) ([]models.BuildArtifact, error)
 	AddBuildArtifact(artifact *models.BuildArtifact) error
+	MarkBuildArtifactStored(artifact *models.BuildArtifact) error
+	DeleteBuildArtifact(id uuid.UUID) error
+	GetBuildArtifactDeployments(appID uuid.UUID, md5Hash string) ([]database.DeploymentHistoryRow, error)
+	GetBuildArtifactsLastDeployed(appID uuid.UUID) (map[string]time.Time, error)
 }
 
 // UserRepository defines methods for user data operations
@@ -116,6 +128,53 @@ type SystemSettingsRepository interface {
 	SetSystemSetting(key, value string) error
 }
 
+// InstanceLockRepository defines methods for deploy lock operations
+type InstanceLockRepository interface {
+	AcquireInstanceLock(lock *models.InstanceLock) (*models.InstanceLock, error)
+	GetInstanceLock(instanceID uuid.UUID) (*models.InstanceLock, error)
+	SetInstanceLockDeployment(instanceID, deploymentID uuid.UUID) error
+	RenewInstanceLock(deploymentID uuid.UUID, ttl time.Duration) (*models.InstanceLock, error)
+	ReleaseInstanceLockForDeployment(deploymentID uuid.UUID) error
+	ReleaseInstanceLockIfHeld(instanceID, lockID uuid.UUID) error
+	ReleaseInstanceLock(instanceID uuid.UUID) error
+}
+
+// RolloutRepository defines methods for multi-host rollout operations
+type RolloutRepository interface {
+	GetLinkedHostsForApp(appName string) ([]models.SSHHost, error)
+	CreateRollout(rollout *models.Rollout) error
+	FinishRollout(id uuid.UUID, status string) error
+	GetRolloutByID(id uuid.UUID) (*models.Rollout, error)
+	GetRolloutsForApp(appID uuid.UUID, limit int) ([]models.Rollout, error)
+	SetDeploymentHistoryRollout(deploymentID, rolloutID uuid.UUID) error
+	GetDeploymentHistoryForRollout(rolloutID uuid.UUID) ([]database.DeploymentHistoryRow, error)
+}
+
+// CanaryRepository defines methods for canary release operations
+type CanaryRepository interface {
+	StartInstanceCanary(canary *models.InstanceCanary) error
+	GetInstanceCanary(instanceID uuid.UUID) (*models.InstanceCanary, error)
+}
+
+// DeploymentStepRepository defines methods for the step state of resumable deployments
+type DeploymentStepRepository interface {
+	SaveDeploymentStep(step *models.DeploymentStep) error
+	GetDeploymentSteps(deploymentID uuid.UUID) ([]models.DeploymentStep, error)
+	HasNewerDeployment(deploymentID uuid.UUID) (bool, error)
+}
+
+// SigningKeyRepository defines methods for artifact signing keys and the keys applications trust
+type SigningKeyRepository interface {
+	GetSigningKeys() ([]models.SigningKey, error)
+	CreateSigningKey(name string) (*models.SigningKey, error)
+	GetActiveSigningKey() (*models.SigningKey, ed25519.PrivateKey, error)
+	GetTrustedKeysForApp(appID uuid.UUID) ([]models.TrustedKey, error)
+	AddTrustedKey(appID uuid.UUID, name, publicKey string) (*models.TrustedKey, error)
+	DeleteTrustedKey(id, appID uuid.UUID) error
+	GetTrustedSigningKeys(appID uuid.UUID) ([]signing.TrustedKey, error)
+	SetDeploymentSigner(deploymentID uuid.UUID, signingKey, signedBy string) error
+}
+
 // DatabaseRepository combines all repository interfaces for convenience
 type DatabaseRepository interface {
 	SSHHostRepository
@@ -130,6 +189,11 @@ type DatabaseRepository interface {
 	TwoFactorRepository
 	ApplicationTokenRepository
 	SystemSettingsRepository
+	InstanceLockRepository
+	RolloutRepository
+	CanaryRepository
+	DeploymentStepRepository
+	SigningKeyRepository
 	// DB returns the underlying database connection for transactions
 	GetDB() *sqlx.DB
 }
@@ -237,6 +301,10 @@ func (r *DefaultRepository) SetSecret(appID uuid.UUID, key, value string) error
 	return database.SetSecret(appID, key, value)
 }
 
+func (r *DefaultRepository) EnsureSecret(appID uuid.UUID, key, value string) (string, error) {
+	return database.EnsureSecret(appID, key, value)
+}
+
 func (r *DefaultRepository) UnsetSecret(appID uuid.UUID, key string) error {
 	return database.UnsetSecret(appID, key)
 }
@@ -266,8 +334,16 @@ func (r *DefaultRepository) UpdateDeploymentHistoryStatusOnly(id uuid.UUID, stat
 	return database.UpdateDeploymentHistoryStatusOnly(id, status)
 }
 
-func (r *DefaultRepository) RecordSuccessfulDeployment(deploymentID uuid.UUID, port int, releasePath string, gitCommitSHA string) error {
-	return database.RecordSuccessfulDeployment(deploymentID, port, releasePath, gitCommitSHA)
+func (r *DefaultRepository) RecordSuccessfulDeployment(deploymentID uuid.UUID, ports []int, releasePath string, gitCommitSHA string) error {
+	return database.RecordSuccessfulDeployment(deploymentID, ports, releasePath, gitCommitSHA)
+}
+
+func (r *DefaultRepository) GetActiveInstancePorts(instanceID uuid.UUID) ([]int, error) {
+	return database.GetActiveInstancePorts(instanceID)
+}
+
+func (r *DefaultRepository) GetActivePeersForApp(appID uuid.UUID) ([]database.ClusterPeer, error) {
+	return database.GetActivePeersForApp(appID)
 }
 
 func (r *DefaultRepository) GetDeploymentsCount() (int, error) {
@@ -278,6 +354,72 @@ func (r *DefaultRepository) GetRecentDeploymentsGlobal(limit int) ([]database.Re
 	return database.GetRecentDeploymentsGlobal(limit)
 }
 
+func (r *DefaultRepository) AddDeploymentHealthChecks(deploymentID uuid.UUID, results []models.HealthCheckResult) error {
+	return database.AddDeploymentHealthChecks(deploymentID, results)
+}
+
+func (r *DefaultRepository) GetHealthChecksForDeployment(deploymentID uuid.UUID) ([]models.HealthCheckResult, error) {
+	return database.GetHealthChecksForDeployment(deploymentID)
+}
+
+// InstanceLockRepository implementations
+func (r *DefaultRepository) AcquireInstanceLock(lock *models.InstanceLock) (*models.InstanceLock, error) {
+	return database.AcquireInstanceLock(lock)
+}
+
+func (r *DefaultRepository) GetInstanceLock(instanceID uuid.UUID) (*models.InstanceLock, error) {
+	return database.GetInstanceLock(instanceID)
+}
+
+func (r *DefaultRepository) SetInstanceLockDeployment(instanceID, deploymentID uuid.UUID) error {
+	return database.SetInstanceLockDeployment(instanceID, deploymentID)
+}
+
+func (r *DefaultRepository) RenewInstanceLock(deploymentID uuid.UUID, ttl time.Duration) (*models.InstanceLock, error) {
+	return database.RenewInstanceLock(deploymentID, ttl)
+}
+
+func (r *DefaultRepository) ReleaseInstanceLockForDeployment(deploymentID uuid.UUID) error {
+	return database.ReleaseInstanceLockForDeployment(deploymentID)
+}
+
+func (r *DefaultRepository) ReleaseInstanceLockIfHeld(instanceID, lockID uuid.UUID) error {
+	return database.ReleaseInstanceLockIfHeld(instanceID, lockID)
+}
+
+func (r *DefaultRepository) ReleaseInstanceLock(instanceID uuid.UUID) error {
+	return database.ReleaseInstanceLock(instanceID)
+}
+
+// RolloutRepository implementations
+func (r *DefaultRepository) GetLinkedHostsForApp(appName string) ([]models.SSHHost, error) {
+	return database.GetLinkedHostsForApp(appName)
+}
+
+func (r *DefaultRepository) CreateRollout(rollout *models.Rollout) error {
+	return database.CreateRollout(rollout)
+}
+
+func (r *DefaultRepository) FinishRollout(id uuid.UUID, status string) error {
+	return database.FinishRollout(id, status)
+}
+
+func (r *DefaultRepository) GetRolloutByID(id uuid.UUID) (*models.Rollout, error) {
+	return database.GetRolloutByID(id)
+}
+
+func (r *DefaultRepository) GetRolloutsForApp(appID uuid.UUID, limit int) ([]models.Rollout, error) {
+	return database.GetRolloutsForApp(appID, limit)
+}
+
+func (r *DefaultRepository) SetDeploymentHistoryRollout(deploymentID, rolloutID uuid.UUID) error {
+	return database.SetDeploymentHistoryRollout(deploymentID, rolloutID)
+}
+
+func (r *DefaultRepository) GetDeploymentHistoryForRollout(rolloutID uuid.UUID) ([]database.DeploymentHistoryRow, error) {
+	return database.GetDeploymentHistoryForRollout(rolloutID)
+}
+
 // DomainRepository implementations
 func (r *DefaultRepository) GetDomainsForInstance(instanceID uuid.UUID) ([]models.Domain, error) {
 	return database.GetDomainsForInstance(instanceID)
@@ -316,10 +458,30 @@ func (r *DefaultRepository) GetAllBuildArtifactsForApp(appID uuid.UUID) ([]model
 	return database.GetAllBuildArtifactsForApp(appID)
 }
 
+func (r *DefaultRepository) GetLatestBuildArtifactByVersion(appID uuid.UUID, version string) (*models.BuildArtifact, error) {
+	return database.GetLatestBuildArtifactByVersion(appID, version)
+}
+
 func (r *DefaultRepository) AddBuildArtifact(artifact *models.BuildArtifact) error {
 	return database.AddBuildArtifact(artifact)
 }
 
+func (r *DefaultRepository) MarkBuildArtifactStored(artifact *models.BuildArtifact) error {
+	return database.MarkBuildArtifactStored(artifact)
+}
+
+func (r *DefaultRepository) DeleteBuildArtifact(id uuid.UUID) error {
+	return database.DeleteBuildArtifact(id)
+}
+
+func (r *DefaultRepository) GetBuildArtifactDeployments(appID uuid.UUID, md5Hash string) ([]database.DeploymentHistoryRow, error) {
+	return database.GetBuildArtifactDeployments(appID, md5Hash)
+}
+
+func (r *DefaultRepository) GetBuildArtifactsLastDeployed(appID uuid.UUID) (map[string]time.Time, error) {
+	return database.GetBuildArtifactsLastDeployed(appID)
+}
+
 // UserRepository implementations
 func (r *DefaultRepository) GetUserCount() (int64, error) {
 	return database.GetUserCount()
@@ -400,3 +562,58 @@ func (r *DefaultRepository) GetSystemSetting(key string) (string, error) {
 func (r *DefaultRepository) SetSystemSetting(key, value string) error {
 	return database.SetSystemSetting(key, value)
 }
+
+// CanaryRepository implementations
+func (r *DefaultRepository) StartInstanceCanary(canary *models.InstanceCanary) error {
+	return database.StartInstanceCanary(canary)
+}
+
+func (r *DefaultRepository) GetInstanceCanary(instanceID uuid.UUID) (*models.InstanceCanary, error) {
+	return database.GetInstanceCanary(instanceID)
+}
+
+// DeploymentStepRepository implementations
+func (r *DefaultRepository) SaveDeploymentStep(step *models.DeploymentStep) error {
+	return database.SaveDeploymentStep(step)
+}
+
+func (r *DefaultRepository) GetDeploymentSteps(deploymentID uuid.UUID) ([]models.DeploymentStep, error) {
+	return database.GetDeploymentSteps(deploymentID)
+}
+
+func (r *DefaultRepository) HasNewerDeployment(deploymentID uuid.UUID) (bool, error) {
+	return database.HasNewerDeployment(deploymentID)
+}
+
+// SigningKeyRepository implementations
+func (r *DefaultRepository) GetSigningKeys() ([]models.SigningKey, error) {
+	return database.GetSigningKeys()
+}
+
+func (r *DefaultRepository) CreateSigningKey(name string) (*models.SigningKey, error) {
+	return database.CreateSigningKey(name)
+}
+
+func (r *DefaultRepository) GetActiveSigningKey() (*models.SigningKey, ed25519.PrivateKey, error) {
+	return database.GetActiveSigningKey()
+}
+
+func (r *DefaultRepository) GetTrustedKeysForApp(appID uuid.UUID) ([]models.TrustedKey, error) {
+	return database.GetTrustedKeysForApp(appID)
+}
+
+func (r *DefaultRepository) AddTrustedKey(appID uuid.UUID, name, publicKey string) (*models.TrustedKey, error) {
+	return database.AddTrustedKey(appID, name, publicKey)
+}
+
+func (r *DefaultRepository) DeleteTrustedKey(id, appID uuid.UUID) error {
+	return database.DeleteTrustedKey(id, appID)
+}
+
+func (r *DefaultRepository) GetTrustedSigningKeys(appID uuid.UUID) ([]signing.TrustedKey, error) {
+	return database.GetTrustedSigningKeys(appID)
+}
+
+func (r *DefaultRepository) SetDeploymentSigner(deploymentID uuid.UUID, signingKey, signedBy string) error {
+	return database.SetDeploymentSigner(deploymentID, signingKey, signedBy)
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"strings"
+	"youfun/shipyard/internal/api/response"
+	"youfun/shipyard/internal/api/utils"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rolloutResponse converts a rollout and its deployments for API responses.
+func rolloutResponse(rollout *models.Rollout, deployments []types.DeploymentHistoryDTO) types.RolloutDTO {
+	return types.RolloutDTO{
+		ID:          utils.EncodeFriendlyID(utils.PrefixRollout, rollout.ID),
+		Version:     rollout.Version,
+		Hosts:       strings.Split(rollout.Hosts, ","),
+		Parallel:    rollout.Parallel,
+		Status:      rollout.Status,
+		CreatedBy:   rollout.CreatedBy,
+		CreatedAt:   rollout.CreatedAt.Time,
+		FinishedAt:  rollout.FinishedAt.Time,
+		Deployments: deployments,
+	}
+}
+
+// CreateRollout starts a rollout of an application to several hosts (from CLI)
+func CreateRollout(c *gin.Context) {
+	h := &Handlers{Repo: defaultDeploymentsRepo}
+	h.CreateRollout(c)
+}
+
+// CreateRolloutHandler starts a rollout (method on Handlers)
+func (h *Handlers) CreateRollout(c *gin.Context) {
+	var req types.CreateRolloutRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Hosts) == 0 {
+		response.BadRequest(c, "Invalid request")
+		return
+	}
+	if req.Parallel < 1 {
+		req.Parallel = 1
+	}
+
+	app, err := h.Repo.GetApplicationByName(req.AppName)
+	if err != nil {
+		response.NotFound(c, "Application not found")
+		return
+	}
+
+	rollout := &models.Rollout{
+		ApplicationID: app.ID,
+		Version:       req.Version,
+		Hosts:         strings.Join(req.Hosts, ","),
+		Parallel:      req.Parallel,
+		Status:        models.RolloutStatusRunning,
+		CreatedBy:     lockOwner(c),
+	}
+	if err := h.Repo.CreateRollout(rollout); err != nil {
+		response.InternalServerError(c, "Failed to create rollout record")
+		return
+	}
+
+	response.Created(c, rolloutResponse(rollout, nil))
+}
+
+// UpdateRolloutStatus records the final status of a rollout (from CLI)
+func UpdateRolloutStatus(c *gin.Context) {
+	h := &Handlers{Repo: defaultDeploymentsRepo}
+	h.UpdateRolloutStatus(c)
+}
+
+// UpdateRolloutStatusHandler records the final status of a rollout (method on Handlers)
+func (h *Handlers) UpdateRolloutStatus(c *gin.Context) {
+	rolloutID, err := utils.DecodeFriendlyID(utils.PrefixRollout, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid rollout ID")
+		return
+	}
+
+	var req types.UpdateRolloutStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request")
+		return
+	}
+	switch req.Status {
+	case models.RolloutStatusSuccess, models.RolloutStatusFailed, models.RolloutStatusCancelled:
+	default:
+		response.BadRequest(c, "Invalid rollout status: "+req.Status)
+		return
+	}
+
+	if err := h.Repo.FinishRollout(rolloutID, req.Status); err != nil {
+		response.InternalServerError(c, "Failed to update rollout status")
+		return
+	}
+	response.Message(c, "Rollout status updated")
+}
+
+// GetRollout returns a rollout with the deployment of each host
+func GetRollout(c *gin.Context) {
+	h := &Handlers{Repo: defaultDeploymentsRepo}
+	h.GetRollout(c)
+}
+
+// GetRolloutHandler returns a rollout with the deployment of each host (method on Handlers)
+func (h *Handlers) GetRollout(c *gin.Context) {
+	rolloutID, err := utils.DecodeFriendlyID(utils.PrefixRollout, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid rollout ID")
+		return
+	}
+
+	rollout, err := h.Repo.GetRolloutByID(rolloutID)
+	if err != nil {
+		response.NotFound(c, "Rollout not found")
+		return
+	}
+
+	history, err := h.Repo.GetDeploymentHistoryForRollout(rolloutID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to get rollout deployments")
+		return
+	}
+
+	deployments := make([]types.DeploymentHistoryDTO, len(history))
+	for i, d := range history {
+		createdAt := d.CreatedAt
+		deployments[i] = types.DeploymentHistoryDTO{
+			UID:         utils.EncodeFriendlyID(utils.PrefixDeployment, d.ID),
+			Version:     d.Version,
+			ReleasePath: d.ReleasePath,
+			Status:      d.Status,
+			HostName:    d.HostName,
+			SigningKey:  d.SigningKey,
+			SignedBy:    d.SignedBy,
+			CreatedAt:   &createdAt,
+		}
+	}
+
+	response.Data(c, rolloutResponse(rollout, deployments))
+}
+
+// ListRollouts returns the recent rollouts of an application
+func ListRollouts(c *gin.Context) {
+	h := &Handlers{Repo: defaultDeploymentsRepo}
+	h.ListRollouts(c)
+}
+
+// ListRolloutsHandler returns the recent rollouts of an application (method on Handlers)
+func (h *Handlers) ListRollouts(c *gin.Context) {
+	appID, err := utils.DecodeFriendlyID(utils.PrefixApplication, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid application ID")
+		return
+	}
+
+	rollouts, err := h.Repo.GetRolloutsForApp(appID, 50)
+	if err != nil {
+		response.InternalServerError(c, "Failed to get rollouts")
+		return
+	}
+
+	responses := make([]types.RolloutDTO, len(rollouts))
+	for i := range rollouts {
+		responses[i] = rolloutResponse(&rollouts[i], nil)
+	}
+	response.Data(c, responses)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"youfun/shipyard/internal/api/response"
+	"youfun/shipyard/internal/api/utils"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/pkg/types"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Legacy function wrappers for backward compatibility
+var defaultSigningKeysRepo = &DefaultRepository{}
+
+// SigningKeyResponse represents a signing key of the server in API responses, the private key never leaves it
+type SigningKeyResponse struct {
+	UID       string `json:"uid"`
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"`
+	Active    bool   `json:"active"` // Signs new artifacts
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// CreateSigningKeyRequest represents the request to create a signing key
+type CreateSigningKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// AddTrustedKeyRequest represents the request to trust a public key for an application
+type AddTrustedKeyRequest struct {
+	Name      string `json:"name" binding:"required"`
+	PublicKey string `json:"public_key" binding:"required"` // ed25519:<base64>
+}
+
+// ListSigningKeys returns the artifact signing keys of the server
+func ListSigningKeys(c *gin.Context) {
+	h := &Handlers{Repo: defaultSigningKeysRepo}
+	h.ListSigningKeys(c)
+}
+
+// ListSigningKeysHandler returns the artifact signing keys of the server, newest first (method on Handlers)
+func (h *Handlers) ListSigningKeys(c *gin.Context) {
+	keys, err := h.Repo.GetSigningKeys()
+	if err != nil {
+		response.InternalServerError(c, "Failed to list signing keys: "+err.Error())
+		return
+	}
+
+	responses := make([]SigningKeyResponse, len(keys))
+	for i, key := range keys {
+		responses[i] = signingKeyResponse(&key)
+		responses[i].Active = i == 0
+	}
+	response.Data(c, responses)
+}
+
+// CreateSigningKey generates a new artifact signing key, which signs every artifact from then on
+func CreateSigningKey(c *gin.Context) {
+	h := &Handlers{Repo: defaultSigningKeysRepo}
+	h.CreateSigningKey(c)
+}
+
+// CreateSigningKeyHandler generates a new artifact signing key (method on Handlers)
+func (h *Handlers) CreateSigningKey(c *gin.Context) {
+	var req CreateSigningKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+	if len(req.Name) > 100 {
+		response.BadRequest(c, "Key name must be 100 characters or less")
+		return
+	}
+
+	key, err := h.Repo.CreateSigningKey(req.Name)
+	if err != nil {
+		response.InternalServerError(c, "Failed to create signing key: "+err.Error())
+		return
+	}
+
+	resp := signingKeyResponse(key)
+	resp.Active = true
+	response.Created(c, resp)
+}
+
+func signingKeyResponse(key *models.SigningKey) SigningKeyResponse {
+	resp := SigningKeyResponse{
+		UID:       utils.EncodeFriendlyID(utils.PrefixSigningKey, key.ID),
+		Name:      key.Name,
+		PublicKey: key.PublicKey,
+	}
+	if key.CreatedAt.Time != nil {
+		resp.CreatedAt = key.CreatedAt.Time.Format(time.RFC3339)
+	}
+	return resp
+}
+
+// ListTrustedKeys returns the public keys an application accepts artifact signatures from
+func ListTrustedKeys(c *gin.Context) {
+	h := &Handlers{Repo: defaultSigningKeysRepo}
+	h.ListTrustedKeys(c)
+}
+
+// ListTrustedKeysHandler returns the public keys configured for an application (method on Handlers).
+// Without any, artifacts signed by any signing key of the server are accepted.
+func (h *Handlers) ListTrustedKeys(c *gin.Context) {
+	appID, err := utils.DecodeFriendlyID(utils.PrefixApplication, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid application ID")
+		return
+	}
+
+	keys, err := h.Repo.GetTrustedKeysForApp(appID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to list trusted keys: "+err.Error())
+		return
+	}
+	response.Data(c, trustedKeyDTOs(keys))
+}
+
+// AddTrustedKey trusts a public key for the artifacts of an application
+func AddTrustedKey(c *gin.Context) {
+	h := &Handlers{Repo: defaultSigningKeysRepo}
+	h.AddTrustedKey(c)
+}
+
+// AddTrustedKeyHandler trusts a public key for the artifacts of an application (method on Handlers)
+func (h *Handlers) AddTrustedKey(c *gin.Context) {
+	appID, err := utils.DecodeFriendlyID(utils.PrefixApplication, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid application ID")
+		return
+	}
+	if _, err := h.Repo.GetApplicationByID(appID); err != nil {
+		response.NotFound(c, "Application not found")
+		return
+	}
+
+	var req AddTrustedKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	key, err := h.Repo.AddTrustedKey(appID, req.Name, req.PublicKey)
+	if err != nil {
+		response.BadRequest(c, "Failed to add trusted key: "+err.Error())
+		return
+	}
+	response.Created(c, trustedKeyDTOs([]models.TrustedKey{*key})[0])
+}
+
+// DeleteTrustedKey stops trusting a public key for an application
+func DeleteTrustedKey(c *gin.Context) {
+	h := &Handlers{Repo: defaultSigningKeysRepo}
+	h.DeleteTrustedKey(c)
+}
+
+// DeleteTrustedKeyHandler stops trusting a public key for an application (method on Handlers)
+func (h *Handlers) DeleteTrustedKey(c *gin.Context) {
+	appID, err := utils.DecodeFriendlyID(utils.PrefixApplication, c.Param("uid"))
+	if err != nil {
+		response.BadRequest(c, "Invalid application ID")
+		return
+	}
+	keyID, err := utils.DecodeFriendlyID(utils.PrefixTrustedKey, c.Param("keyId"))
+	if err != nil {
+		response.BadRequest(c, "Invalid key ID")
+		return
+	}
+
+	if err := h.Repo.DeleteTrustedKey(keyID, appID); err != nil {
+		response.NotFound(c, "Trusted key not found")
+		return
+	}
+	response.Message(c, "Trusted key deleted successfully")
+}
+
+func trustedKeyDTOs(keys []models.TrustedKey) []types.TrustedKeyDTO {
+	dtos := make([]types.TrustedKeyDTO, len(keys))
+	for i, key := range keys {
+		dtos[i] = types.TrustedKeyDTO{
+			UID:       utils.EncodeFriendlyID(utils.PrefixTrustedKey, key.ID),
+			Name:      key.Name,
+			PublicKey: key.PublicKey,
+		}
+		if key.CreatedAt.Time != nil {
+			dtos[i].CreatedAt = key.CreatedAt.Time.Format(time.RFC3339)
+		}
+	}
+	return dtos
+}
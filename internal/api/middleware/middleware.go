@@ -14,6 +14,12 @@ import (
 	"github.com/google/uuid"
 )
 
+// CLIPathPrefix is the path of the CLI API, the only API application tokens may use
+const CLIPathPrefix = "/api/cli/v1/"
+
+// appTokenApplicationKey holds the application of the token a request was authenticated with
+const appTokenApplicationKey = "app_token_application_id"
+
 // JWTSecret is the secret key used to sign JWT tokens
 // MUST be set via JWT_SECRET environment variable to persist across restarts
 var JWTSecret []byte
@@ -128,6 +134,20 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		claims, err := ValidateToken(tokenString)
 		if err != nil {
+			// Not a JWT: try an application token, as used by CI pipelines
+			if !strings.Contains(tokenString, ".") {
+				if app, token, appErr := database.ValidateApplicationToken(tokenString); appErr == nil {
+					if !strings.HasPrefix(c.FullPath(), CLIPathPrefix) {
+						c.JSON(http.StatusForbidden, gin.H{"error": "Application tokens can only be used with the CLI API"})
+						c.Abort()
+						return
+					}
+					c.Set("username", "token:"+token.Name)
+					SetAppTokenApplication(c, app.ID)
+					c.Next()
+					return
+				}
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
@@ -150,6 +170,21 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// SetAppTokenApplication marks a request as authenticated with a token of the application
+func SetAppTokenApplication(c *gin.Context, applicationID uuid.UUID) {
+	c.Set(appTokenApplicationKey, applicationID.String())
+}
+
+// GetAppTokenApplicationFromContext returns the application of the token a request was authenticated with.
+// It returns false for requests of users.
+func GetAppTokenApplicationFromContext(c *gin.Context) (uuid.UUID, bool) {
+	applicationID, err := uuid.Parse(c.GetString(appTokenApplicationKey))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return applicationID, true
+}
+
 // GetUserIDFromContext extracts the user ID from the Gin context
 func GetUserIDFromContext(c *gin.Context) (uuid.UUID, error) {
 	userIDStr, exists := c.Get("user_id")
@@ -111,10 +111,27 @@ func (s *Server) setupRoutes() {
 			protected.POST("/applications/:uid/tokens", handlers.CreateApplicationToken)
 			protected.DELETE("/applications/:uid/tokens/:tokenId", handlers.DeleteApplicationToken)
 
+			// Artifact signing keys, and the keys each application trusts
+			protected.GET("/signing-keys", handlers.ListSigningKeys)
+			protected.POST("/signing-keys", handlers.CreateSigningKey)
+			protected.GET("/applications/:uid/trusted-keys", handlers.ListTrustedKeys)
+			protected.POST("/applications/:uid/trusted-keys", handlers.AddTrustedKey)
+			protected.DELETE("/applications/:uid/trusted-keys/:keyId", handlers.DeleteTrustedKey)
+
 			// Application Instances Management
 			protected.POST("/instances/:uid/stop", handlers.StopInstance)
 			protected.POST("/instances/:uid/start", handlers.StartInstance)
 			protected.POST("/instances/:uid/restart", handlers.RestartInstance)
+			protected.POST("/instances/:uid/rollback", handlers.RollbackInstance)
+			protected.POST("/instances/:uid/scale", handlers.ScaleInstance)
+			protected.POST("/instances/:uid/lock", handlers.LockInstance)
+			protected.DELETE("/instances/:uid/lock", handlers.UnlockInstance)
+			protected.POST("/instances/:uid/canary/promote", handlers.PromoteCanary)
+			protected.POST("/instances/:uid/canary/abort", handlers.AbortCanary)
+			protected.GET("/instances/:uid/releases", handlers.ListInstanceReleases)
+			protected.POST("/instances/:uid/releases/prune", handlers.PruneInstanceReleases)
+			protected.POST("/instances/:uid/releases/:release/pin", handlers.PinInstanceRelease)
+			protected.DELETE("/instances/:uid/releases/:release/pin", handlers.UnpinInstanceRelease)
 			protected.GET("/instances/:uid/logs", handlers.GetInstanceLogs)
 			protected.GET("/instances/:uid/logs/stream", handlers.StreamInstanceLogs)
 
@@ -138,25 +155,52 @@ func (s *Server) setupRoutes() {
 			protected.POST("/deployments/:uid/logs", handlers.UploadDeploymentLogs)
 			protected.PATCH("/deployments/:uid/status", handlers.UpdateDeploymentStatus)
 
+			// Rollouts (multi-host deployments)
+			protected.GET("/applications/:uid/rollouts", handlers.ListRollouts)
+			protected.GET("/rollouts/:uid", handlers.GetRollout)
+
 			// CLI-specific routes
 			cli := protected.Group("/cli/v1")
+			cli.Use(handlers.AppTokenScope)
 			{
 				cli.POST("/apps", handlers.CLICreateApplication)
 				cli.POST("/applications", handlers.CLICreateApplication) // Alias
 				cli.GET("/hosts", handlers.CLIListHosts)
+				cli.GET("/hosts/linked", handlers.CLIListLinkedHosts)
 				cli.GET("/applications/by-name/:name", handlers.CLIGetApplicationByName)
 				cli.GET("/ssh-hosts/by-name/:name", handlers.CLIGetSSHHostByName)
 				cli.POST("/apps/link", handlers.CLILinkAppToHost)
 				cli.POST("/link", handlers.CLILinkAppToHost) // Alias
 				cli.GET("/instance", handlers.CLIGetInstance)
 				cli.GET("/deployments/latest", handlers.CLIGetLastDeployment) // Add this route
+				cli.POST("/instances/:uid/rollback", handlers.RollbackInstance)
+				cli.POST("/instances/:uid/scale", handlers.ScaleInstance)
+				cli.POST("/instances/:uid/lock", handlers.LockInstance)
+				cli.DELETE("/instances/:uid/lock", handlers.UnlockInstance)
+				cli.POST("/instances/:uid/canary/promote", handlers.PromoteCanary)
+				cli.POST("/instances/:uid/canary/abort", handlers.AbortCanary)
+				cli.GET("/instances/:uid/releases", handlers.ListInstanceReleases)
+				cli.POST("/instances/:uid/releases/prune", handlers.PruneInstanceReleases)
+				cli.POST("/instances/:uid/releases/:release/pin", handlers.PinInstanceRelease)
+				cli.DELETE("/instances/:uid/releases/:release/pin", handlers.UnpinInstanceRelease)
 
 				// Deployments
 				cli.POST("/deployments", handlers.CreateDeployment)
 				cli.PUT("/deployments/:uid/status", handlers.UpdateDeploymentStatus)   // NOTE: Client uses PUT /status
 				cli.PATCH("/deployments/:uid/status", handlers.UpdateDeploymentStatus) // Alias just in case
 				cli.POST("/deployments/:uid/logs", handlers.UploadDeploymentLogs)
+				cli.POST("/deployments/:uid/health-checks", handlers.UploadDeploymentHealthChecks)
+				cli.POST("/deployments/:uid/lock/heartbeat", handlers.RenewDeploymentLock)
+				cli.DELETE("/deployments/:uid/lock", handlers.ReleaseDeploymentLock)
+				cli.POST("/deployments/:uid/canary", handlers.StartDeploymentCanary)
+				cli.PUT("/deployments/:uid/steps/:name", handlers.UpdateDeploymentStep)
+				cli.POST("/deployments/:uid/resume", handlers.ResumeDeployment)
 				
+				// Rollouts
+				cli.POST("/rollouts", handlers.CreateRollout)
+				cli.GET("/rollouts/:uid", handlers.GetRollout)
+				cli.PUT("/rollouts/:uid/status", handlers.UpdateRolloutStatus)
+
 				// Server-side deployment (localhost deployment)
 				cli.POST("/deployments/:uid/upload", handlers.UploadDeploymentArtifact)
 				cli.POST("/deployments/:uid/execute", handlers.ExecuteServerDeployment)
@@ -167,17 +211,23 @@ func (s *Server) setupRoutes() {
 				// Artifacts
 				cli.GET("/artifacts/check", handlers.CLICheckArtifact)
 				cli.POST("/artifacts", handlers.CLIRegisterArtifact)
+				cli.GET("/artifacts/download", handlers.CLIDownloadArtifact)
+				cli.POST("/artifacts/push", handlers.CLIPushArtifact)
 
 				// Secrets (Environment Variables) management
 				cli.GET("/secrets", handlers.CLIListSecrets)
 				cli.POST("/secrets", handlers.CLISetSecret)
 				cli.DELETE("/secrets", handlers.CLIUnsetSecret)
+				cli.POST("/release-cookie", handlers.CLIEnsureReleaseCookie)
 
 				// Domains management
 				cli.POST("/domains/sync", handlers.CLISyncDomains)
 
 				// Build artifacts management
 				cli.GET("/builds", handlers.CLIListBuildArtifacts)
+				cli.POST("/builds", handlers.CLIBuildArtifact)
+				cli.DELETE("/builds/:md5", handlers.CLIDeleteBuildArtifact)
+				cli.GET("/builds/:md5/deployments", handlers.CLIListBuildDeployments)
 			}
 
 			// System settings (Domain configuration)
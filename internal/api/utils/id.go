@@ -115,6 +115,9 @@ const (
 	PrefixSSHHost       = "ssh_"
 	PrefixAppInstance   = "inst_"
 	PrefixDatabase      = "db_"
+	PrefixRollout       = "rol_"
+	PrefixSigningKey    = "sgk_"
+	PrefixTrustedKey    = "tkey_"
 )
 
 // EncodeFriendlyID returns prefix+base58(uuid_bytes)
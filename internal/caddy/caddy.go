@@ -1,13 +1,19 @@
 package caddy
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 
 	"github.com/OrbitDeploy/fastcaddy"
 	"golang.org/x/crypto/ssh"
 )
 
+// routesPath is where fastcaddy keeps the routes of the default server
+const routesPath = "/apps/http/servers/srv0/routes"
+
 // Service provides methods for interacting with the Caddy API.
 // It uses the fastcaddy library to communicate over an existing SSH client.
 type Service struct {
@@ -26,6 +32,16 @@ func NewService(sshClient *ssh.Client) *Service {
 	}
 }
 
+// NewServiceAt creates a Caddy service wrapper for the admin API at adminURL, e.g. http://localhost:2019.
+func NewServiceAt(adminURL string) *Service {
+	fc := fastcaddy.New()
+	fc.API.BaseURL = adminURL
+	return &Service{
+		client:      fc,
+		isLocalhost: true,
+	}
+}
+
 // NewLocalService creates a new Caddy service wrapper for localhost deployment.
 // It connects directly to the local Caddy instance without SSH.
 func NewLocalService() *Service {
@@ -139,3 +155,280 @@ func (s *Service) UpdateSystemRoute(domain string, targetPort int) error {
 	log.Printf("Updating System Route (Web UI): %s -> %s", domain, proxyTo)
 	return s.client.AddReverseProxy(domain, proxyTo)
 }
+
+// Upstream is a local port served by a weighted reverse proxy, with its share of the traffic.
+type Upstream struct {
+	Port   int
+	Weight int
+}
+
+func (u Upstream) String() string {
+	return fmt.Sprintf("localhost:%d (%d%%)", u.Port, u.Weight)
+}
+
+// weightedRoute builds the Caddy route of a domain that splits traffic across upstreams by weight.
+// The route ID is the domain, like the routes created by AddReverseProxy, so either replaces the other.
+func weightedRoute(domain string, upstreams []Upstream) map[string]interface{} {
+	dials := make([]map[string]interface{}, len(upstreams))
+	weights := make([]int, len(upstreams))
+	for i, u := range upstreams {
+		dials[i] = map[string]interface{}{"dial": fmt.Sprintf("localhost:%d", u.Port)}
+		weights[i] = u.Weight
+	}
+
+	return map[string]interface{}{
+		"@id":   domain,
+		"match": []map[string]interface{}{{"host": []string{domain}}},
+		"handle": []map[string]interface{}{{
+			"handler":   "reverse_proxy",
+			"upstreams": dials,
+			"load_balancing": map[string]interface{}{
+				"selection_policy": map[string]interface{}{
+					"policy":  "weighted_round_robin",
+					"weights": weights,
+				},
+			},
+		}},
+		"terminal": true,
+	}
+}
+
+// UpdateWeightedReverseProxy configures reverse proxies for multiple domains that split traffic
+// across several local ports with weighted round robin selection.
+func (s *Service) UpdateWeightedReverseProxy(domains []string, upstreams []Upstream) error {
+	if len(domains) == 0 {
+		return fmt.Errorf("domain list cannot be empty")
+	}
+	if len(upstreams) == 0 {
+		return fmt.Errorf("upstream list cannot be empty")
+	}
+
+	log.Printf("Configuring weighted reverse proxy for %d domains, upstreams: %v", len(domains), upstreams)
+
+	for _, domain := range domains {
+		if s.client.HasID(domain) {
+			if err := s.client.DeleteRoute(domain); err != nil {
+				return fmt.Errorf("failed to remove existing route for domain '%s': %w", domain, err)
+			}
+		}
+		if err := s.client.PutConfig(weightedRoute(domain, upstreams), routesPath, "POST"); err != nil {
+			return fmt.Errorf("failed to configure weighted reverse proxy for domain '%s': %w", domain, err)
+		}
+	}
+
+	log.Printf("✅ Successfully configured weighted reverse proxy for %d domains", len(domains))
+	return nil
+}
+
+// loadBalancedRoute builds the Caddy route of a domain that spreads traffic across the instances of a release.
+// Requests go to the instance with the fewest requests in flight; an instance that refuses a connection is
+// marked down for a while by the passive health checks and the request is retried on another one.
+// Active checks are left out, Caddy would take instances answering the health path with a redirect for down.
+func loadBalancedRoute(domain string, ports []int) map[string]interface{} {
+	dials := make([]map[string]interface{}, len(ports))
+	for i, port := range ports {
+		dials[i] = map[string]interface{}{"dial": fmt.Sprintf("localhost:%d", port)}
+	}
+
+	return map[string]interface{}{
+		"@id":   domain,
+		"match": []map[string]interface{}{{"host": []string{domain}}},
+		"handle": []map[string]interface{}{{
+			"handler":   "reverse_proxy",
+			"upstreams": dials,
+			"load_balancing": map[string]interface{}{
+				"selection_policy": map[string]interface{}{"policy": "least_conn"},
+				"try_duration":     "5s",
+				"try_interval":     "250ms",
+			},
+			"health_checks": map[string]interface{}{
+				"passive": map[string]interface{}{
+					"fail_duration": "30s",
+					"max_fails":     1,
+				},
+			},
+		}},
+		"terminal": true,
+	}
+}
+
+// UpdateLoadBalancedReverseProxy configures reverse proxies for multiple domains that spread traffic across
+// several local ports running the same release. A single port gets the plain route of UpdateReverseProxyMultiDomain.
+func (s *Service) UpdateLoadBalancedReverseProxy(domains []string, ports []int) error {
+	if len(ports) == 0 {
+		return fmt.Errorf("port list cannot be empty")
+	}
+	if len(ports) == 1 {
+		return s.UpdateReverseProxyMultiDomain(domains, ports[0])
+	}
+	if len(domains) == 0 {
+		return fmt.Errorf("domain list cannot be empty")
+	}
+
+	log.Printf("Configuring load-balanced reverse proxy for %d domains, ports: %v", len(domains), ports)
+
+	for _, domain := range domains {
+		if s.client.HasID(domain) {
+			if err := s.client.DeleteRoute(domain); err != nil {
+				return fmt.Errorf("failed to remove existing route for domain '%s': %w", domain, err)
+			}
+		}
+		if err := s.client.PutConfig(loadBalancedRoute(domain, ports), routesPath, "POST"); err != nil {
+			return fmt.Errorf("failed to configure load-balanced reverse proxy for domain '%s': %w", domain, err)
+		}
+	}
+
+	log.Printf("✅ Successfully configured load-balanced reverse proxy for %d domains", len(domains))
+	return nil
+}
+
+// routeUpstreams maps every host matched by the routes of a server config to the upstreams it is proxied to.
+// Upstreams of a weighted route carry their weight, like Upstream.String, e.g. "localhost:8001 (90%)".
+func routeUpstreams(server map[string]interface{}) map[string][]string {
+	result := make(map[string][]string)
+	routes, _ := server["routes"].([]interface{})
+	for _, r := range routes {
+		route, _ := r.(map[string]interface{})
+		var upstreams []string
+		handlers, _ := route["handle"].([]interface{})
+		for _, h := range handlers {
+			handler, _ := h.(map[string]interface{})
+			if handler["handler"] != "reverse_proxy" {
+				continue
+			}
+			var weights []interface{}
+			if lb, ok := handler["load_balancing"].(map[string]interface{}); ok {
+				if policy, ok := lb["selection_policy"].(map[string]interface{}); ok && policy["policy"] == "weighted_round_robin" {
+					weights, _ = policy["weights"].([]interface{})
+				}
+			}
+			dials, _ := handler["upstreams"].([]interface{})
+			for i, u := range dials {
+				upstream, _ := u.(map[string]interface{})
+				dial, _ := upstream["dial"].(string)
+				if i < len(weights) {
+					dial = fmt.Sprintf("%s (%v%%)", dial, weights[i])
+				}
+				upstreams = append(upstreams, dial)
+			}
+		}
+		if len(upstreams) == 0 {
+			continue
+		}
+		matches, _ := route["match"].([]interface{})
+		for _, m := range matches {
+			match, _ := m.(map[string]interface{})
+			hosts, _ := match["host"].([]interface{})
+			for _, host := range hosts {
+				if name, ok := host.(string); ok {
+					result[name] = upstreams
+				}
+			}
+		}
+	}
+	return result
+}
+
+// RouteUpstreams returns the upstreams each domain of the default server is proxied to.
+func (s *Service) RouteUpstreams() (map[string][]string, error) {
+	server, err := s.client.GetConfig("/apps/http/servers/srv0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Caddy routes: %w", err)
+	}
+	return routeUpstreams(server), nil
+}
+
+// UpstreamStatus is an entry of Caddy's /reverse_proxy/upstreams admin endpoint.
+type UpstreamStatus struct {
+	Address     string `json:"address"`
+	NumRequests int    `json:"num_requests"`
+	Fails       int    `json:"fails"`
+}
+
+// ErrUpstreamNotFound is returned when Caddy has no stats for an upstream. Caddy forgets an upstream once no
+// route uses it, even with requests still in flight, so its requests cannot be counted.
+var ErrUpstreamNotFound = errors.New("upstream not found in Caddy's stats")
+
+// activeRequests returns the number of requests in flight to a local port, and whether Caddy knows the port.
+func activeRequests(statuses []UpstreamStatus, port int) (int, bool) {
+	address := fmt.Sprintf("localhost:%d", port)
+	total, found := 0, false
+	for _, status := range statuses {
+		if status.Address == address {
+			total += status.NumRequests
+			found = true
+		}
+	}
+	return total, found
+}
+
+// UpstreamRequests returns the number of requests Caddy is still proxying to a local port.
+// The port must be held with HoldUpstream once the routes no longer use it, ErrUpstreamNotFound otherwise.
+func (s *Service) UpstreamRequests(port int) (int, error) {
+	resp, err := s.client.API.HTTPClient.Get(s.client.API.BaseURL + "/reverse_proxy/upstreams")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get Caddy upstream stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to get Caddy upstream stats, status code: %d", resp.StatusCode)
+	}
+
+	var statuses []UpstreamStatus
+	if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+		return 0, fmt.Errorf("failed to parse Caddy upstream stats: %w", err)
+	}
+	active, found := activeRequests(statuses, port)
+	if !found {
+		return 0, fmt.Errorf("%w: localhost:%d", ErrUpstreamNotFound, port)
+	}
+	return active, nil
+}
+
+// drainRouteID returns the ID of the route holding a local port while it drains.
+func drainRouteID(port int) string {
+	return fmt.Sprintf("shipyard-drain-%d", port)
+}
+
+// drainRoute builds a route that no request matches, proxying to a local port: it keeps the port among
+// Caddy's upstreams, out of rotation, so its stats keep counting the requests in flight to it.
+// The route has no host matcher, Caddy would try to get a certificate for it.
+func drainRoute(port int) map[string]interface{} {
+	return map[string]interface{}{
+		"@id":   drainRouteID(port),
+		"match": []map[string]interface{}{{"remote_ip": map[string]interface{}{"ranges": []string{"0.0.0.0/32"}}}},
+		"handle": []map[string]interface{}{{
+			"handler":   "reverse_proxy",
+			"upstreams": []map[string]interface{}{{"dial": fmt.Sprintf("localhost:%d", port)}},
+		}},
+		"terminal": true,
+	}
+}
+
+// HoldUpstream keeps a local port among Caddy's upstreams, out of rotation, until ReleaseUpstream.
+// Hold the ports leaving the routes before the routes are updated, so their requests in flight can be counted.
+func (s *Service) HoldUpstream(port int) error {
+	id := drainRouteID(port)
+	if s.client.HasID(id) {
+		if err := s.client.DeleteRoute(id); err != nil {
+			return fmt.Errorf("failed to remove existing drain route of port %d: %w", port, err)
+		}
+	}
+	if err := s.client.PutConfig(drainRoute(port), routesPath, "POST"); err != nil {
+		return fmt.Errorf("failed to hold upstream localhost:%d: %w", port, err)
+	}
+	return nil
+}
+
+// ReleaseUpstream removes the hold of HoldUpstream on a local port.
+func (s *Service) ReleaseUpstream(port int) error {
+	id := drainRouteID(port)
+	if !s.client.HasID(id) {
+		return nil
+	}
+	if err := s.client.DeleteRoute(id); err != nil {
+		return fmt.Errorf("failed to release upstream localhost:%d: %w", port, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package caddy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWeightedRoute(t *testing.T) {
+	route := weightedRoute("example.com", []Upstream{{Port: 8001, Weight: 75}, {Port: 8002, Weight: 25}})
+
+	data, err := json.Marshal(route)
+	if err != nil {
+		t.Fatalf("failed to marshal route: %v", err)
+	}
+	want := `{"@id":"example.com",` +
+		`"handle":[{"handler":"reverse_proxy",` +
+		`"load_balancing":{"selection_policy":{"policy":"weighted_round_robin","weights":[75,25]}},` +
+		`"upstreams":[{"dial":"localhost:8001"},{"dial":"localhost:8002"}]}],` +
+		`"match":[{"host":["example.com"]}],"terminal":true}`
+	if string(data) != want {
+		t.Errorf("unexpected route:\n got %s\nwant %s", data, want)
+	}
+}
+
+func TestLoadBalancedRoute(t *testing.T) {
+	route := loadBalancedRoute("example.com", []int{8001, 8002, 8003})
+
+	data, err := json.Marshal(route)
+	if err != nil {
+		t.Fatalf("failed to marshal route: %v", err)
+	}
+	want := `{"@id":"example.com",` +
+		`"handle":[{"handler":"reverse_proxy",` +
+		`"health_checks":{"passive":{"fail_duration":"30s","max_fails":1}},` +
+		`"load_balancing":{"selection_policy":{"policy":"least_conn"},"try_duration":"5s","try_interval":"250ms"},` +
+		`"upstreams":[{"dial":"localhost:8001"},{"dial":"localhost:8002"},{"dial":"localhost:8003"}]}],` +
+		`"match":[{"host":["example.com"]}],"terminal":true}`
+	if string(data) != want {
+		t.Errorf("unexpected route:\n got %s\nwant %s", data, want)
+	}
+}
+
+func TestActiveRequests(t *testing.T) {
+	var statuses []UpstreamStatus
+	data := `[{"address":"localhost:8001","num_requests":3,"fails":0},{"address":"localhost:8002","num_requests":1,"fails":2}]`
+	if err := json.Unmarshal([]byte(data), &statuses); err != nil {
+		t.Fatalf("failed to parse upstream stats: %v", err)
+	}
+
+	if got, found := activeRequests(statuses, 8001); got != 3 || !found {
+		t.Errorf("expected 3 requests to :8001, got %d (found: %v)", got, found)
+	}
+	if got, found := activeRequests(statuses, 8002); got != 1 || !found {
+		t.Errorf("expected 1 request to :8002, got %d (found: %v)", got, found)
+	}
+	// Caddy forgets upstreams no route uses, their requests cannot be counted
+	if _, found := activeRequests(statuses, 8003); found {
+		t.Error("expected an unknown upstream not to be found")
+	}
+}
+
+func TestDrainRoute(t *testing.T) {
+	data, err := json.Marshal(drainRoute(8001))
+	if err != nil {
+		t.Fatalf("failed to marshal route: %v", err)
+	}
+	want := `{"@id":"shipyard-drain-8001",` +
+		`"handle":[{"handler":"reverse_proxy","upstreams":[{"dial":"localhost:8001"}]}],` +
+		`"match":[{"remote_ip":{"ranges":["0.0.0.0/32"]}}],"terminal":true}`
+	if string(data) != want {
+		t.Errorf("unexpected route:\n got %s\nwant %s", data, want)
+	}
+}
+
+func TestRouteUpstreams(t *testing.T) {
+	data := `{"routes":[
+		{"@id":"example.com","match":[{"host":["example.com"]}],"handle":[{"handler":"reverse_proxy","upstreams":[{"dial":"localhost:8001"}]}]},
+		{"@id":"shop.example.com","match":[{"host":["shop.example.com"]}],"handle":[{"handler":"reverse_proxy",
+			"load_balancing":{"selection_policy":{"policy":"weighted_round_robin","weights":[90,10]}},
+			"upstreams":[{"dial":"localhost:8002"},{"dial":"localhost:8003"}]}]},
+		{"match":[{"host":["static.example.com"]}],"handle":[{"handler":"file_server"}]}
+	]}`
+	var server map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &server); err != nil {
+		t.Fatalf("failed to parse server config: %v", err)
+	}
+
+	got := routeUpstreams(server)
+	want := map[string][]string{
+		"example.com":      {"localhost:8001"},
+		"shop.example.com": {"localhost:8002 (90%)", "localhost:8003 (10%)"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("routeUpstreams() = %v, want %v", got, want)
+	}
+}
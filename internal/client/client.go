@@ -13,6 +13,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"youfun/shipyard/pkg/types"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -166,18 +167,37 @@ func (c *Client) CreateDeployment(req *types.CreateDeploymentRequest) (*types.De
 }
 
 // UpdateDeploymentStatus updates the status of a deployment.
-func (c *Client) UpdateDeploymentStatus(deploymentID string, status string, port int, releasePath, gitCommitSHA string) error {
+// ports lists the instances the release runs on, the first is recorded as the active port.
+func (c *Client) UpdateDeploymentStatus(deploymentID string, status string, ports []int, releasePath, gitCommitSHA string) error {
 	reqBody := types.UpdateDeploymentStatusRequest{
 		Status:       status,
-		Port:         port,
+		Ports:        ports,
 		ReleasePath:  releasePath,
 		GitCommitSHA: gitCommitSHA,
 	}
+	if len(ports) > 0 {
+		reqBody.Port = ports[0]
+	}
 	path := fmt.Sprintf("deployments/%s/status", deploymentID)
 	// PUT request, no response body expected
 	return c.put(path, reqBody, nil)
 }
 
+// CreateRollout creates the record that groups the deployments of a multi-host rollout.
+func (c *Client) CreateRollout(req *types.CreateRolloutRequest) (*types.RolloutDTO, error) {
+	var result types.RolloutDTO
+	if err := c.post("rollouts", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateRolloutStatus records the final status of a rollout.
+func (c *Client) UpdateRolloutStatus(rolloutID, status string) error {
+	reqBody := types.UpdateRolloutStatusRequest{Status: status}
+	return c.put(fmt.Sprintf("rollouts/%s/status", rolloutID), reqBody, nil)
+}
+
 // UploadDeploymentLogs uploads logs for a deployment.
 func (c *Client) UploadDeploymentLogs(deploymentID string, logs string) error {
 	reqBody := types.UploadDeploymentLogsRequest{Logs: logs}
@@ -186,6 +206,46 @@ func (c *Client) UploadDeploymentLogs(deploymentID string, logs string) error {
 	return c.post(path, reqBody, nil)
 }
 
+// UploadHealthChecks uploads the readiness probe results of a deployment.
+func (c *Client) UploadHealthChecks(deploymentID string, results []types.HealthCheckResultDTO) error {
+	reqBody := types.UploadHealthChecksRequest{Results: results}
+	path := fmt.Sprintf("deployments/%s/health-checks", deploymentID)
+	// POST request, no response body expected
+	return c.post(path, reqBody, nil)
+}
+
+// RenewDeploymentLock extends the lease of the instance lock held by a running deployment.
+func (c *Client) RenewDeploymentLock(deploymentID string) error {
+	path := fmt.Sprintf("deployments/%s/lock/heartbeat", deploymentID)
+	return c.post(path, nil, nil)
+}
+
+// ReleaseDeploymentLock releases the instance lock held by a deployment.
+func (c *Client) ReleaseDeploymentLock(deploymentID string) error {
+	return c.delete(fmt.Sprintf("deployments/%s/lock", deploymentID), nil)
+}
+
+// StartCanary records that a deployment serves a share of the traffic next to the stable release.
+func (c *Client) StartCanary(deploymentID string, req *types.StartCanaryRequest) error {
+	return c.post(fmt.Sprintf("deployments/%s/canary", deploymentID), req, nil)
+}
+
+// UpdateDeploymentStep records the state of one step of a deployment.
+func (c *Client) UpdateDeploymentStep(deploymentID string, step *types.DeploymentStepDTO) error {
+	path := fmt.Sprintf("deployments/%s/steps/%s", deploymentID, step.Name)
+	return c.put(path, step, nil)
+}
+
+// ResumeDeployment takes up a failed deployment again and returns its recorded steps.
+// It takes the instance lock for the deployment, like CreateDeployment.
+func (c *Client) ResumeDeployment(deploymentID string) (*types.ResumeDeploymentResponse, error) {
+	var result types.ResumeDeploymentResponse
+	if err := c.post(fmt.Sprintf("deployments/%s/resume", deploymentID), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 // UploadDeploymentArtifact uploads a build artifact tarball for server-side deployment.
 func (c *Client) UploadDeploymentArtifact(deploymentID string, artifactPath string) error {
 	fullURL := fmt.Sprintf("%s/api/cli/v1/deployments/%s/upload", c.BaseURL, deploymentID)
@@ -248,7 +308,7 @@ func (c *Client) ExecuteServerDeployment(deploymentID string, version, gitCommit
 }
 
 // CheckArtifact checks if a build artifact exists.
-// The query parameter can be an MD5 prefix (short hash), full MD5 hash, or git commit SHA.
+// The query parameter can be an MD5 prefix (short hash), full MD5 hash, git commit SHA or version.
 func (c *Client) CheckArtifact(appID, query string) (*types.BuildArtifactDTO, error) {
 	q := url.Values{}
 	q.Add("app_id", appID)
@@ -274,6 +334,155 @@ func (c *Client) RegisterArtifact(artifact *types.BuildArtifactDTO) error {
 	return c.post("artifacts", artifact, nil)
 }
 
+// PushArtifact uploads a build artifact tarball to the artifact registry of the server, which signs and
+// registers it. The server keeps the newest keep builds of the app, its default if keep is 0.
+// The registered artifact is returned with its signature.
+func (c *Client) PushArtifact(artifact *types.BuildArtifactDTO, tarballPath string, keep int) (*types.BuildArtifactDTO, error) {
+	q := url.Values{}
+	q.Add("app_id", artifact.ApplicationID)
+	q.Add("md5", artifact.MD5Hash)
+	q.Add("version", artifact.Version)
+	q.Add("git_commit_sha", artifact.GitCommitSHA)
+	q.Add("local_path", artifact.LocalPath)
+	if artifact.Compression != "" {
+		q.Add("compression", artifact.Compression)
+	}
+	if keep > 0 {
+		q.Add("keep", strconv.Itoa(keep))
+	}
+	fullURL := fmt.Sprintf("%s/api/cli/v1/artifacts/push?%s", c.BaseURL, q.Encode())
+
+	file, err := os.Open(tarballPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open artifact file: %w", err)
+	}
+	defer file.Close()
+
+	req, err := http.NewRequest("POST", fullURL, file)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, c.handleError(resp)
+	}
+
+	var apiResp APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	var result types.BuildArtifactDTO
+	if err := json.Unmarshal(apiResp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response data: %w", err)
+	}
+	return &result, nil
+}
+
+// BuildOnServer uploads the sources of a project for a build on the server, or on a builder host through it.
+// The build output is copied to output as it streams in; the artifact pushed to the registry is returned.
+func (c *Client) BuildOnServer(req *types.ServerBuildRequest, sourcePath string, output io.Writer) (*types.BuildArtifactDTO, error) {
+	q := url.Values{}
+	q.Add("app", req.AppName)
+	q.Add("runtime", req.Runtime)
+	q.Add("version", req.Version)
+	q.Add("git_commit_sha", req.GitCommitSHA)
+	q.Add("location", req.Location)
+	q.Add("host", req.Host)
+	q.Add("builder", req.Builder)
+	q.Add("goos", req.GOOS)
+	q.Add("goarch", req.GOARCH)
+	q.Add("main", req.Main)
+	q.Add("dir", req.Dir)
+	q.Add("compression", req.Compression)
+	q.Add("command", req.Command)
+	q.Add("output_dir", req.OutputDir)
+	if req.KeepArtifacts > 0 {
+		q.Add("keep", strconv.Itoa(req.KeepArtifacts))
+	}
+	fullURL := fmt.Sprintf("%s/api/cli/v1/builds?%s", c.BaseURL, q.Encode())
+
+	file, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source archive: %w", err)
+	}
+	defer file.Close()
+
+	httpReq, err := http.NewRequest("POST", fullURL, file)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, c.handleError(resp)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg types.ServerBuildMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("build stream ended without a result")
+			}
+			return nil, fmt.Errorf("failed to read build output: %w", err)
+		}
+		switch {
+		case msg.Error != "":
+			return nil, fmt.Errorf("%s", msg.Error)
+		case msg.Artifact != nil:
+			return msg.Artifact, nil
+		default:
+			_, _ = io.WriteString(output, msg.Output)
+		}
+	}
+}
+
+// DownloadArtifact pulls a build artifact from the artifact registry of the server to destPath.
+func (c *Client) DownloadArtifact(appID, md5Hash, destPath string) error {
+	q := url.Values{}
+	q.Add("app_id", appID)
+	q.Add("md5", md5Hash)
+	fullURL := fmt.Sprintf("%s/api/cli/v1/artifacts/download?%s", c.BaseURL, q.Encode())
+
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return c.handleError(resp)
+	}
+
+	file, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create artifact file: %w", err)
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(destPath)
+		return fmt.Errorf("failed to download artifact: %w", err)
+	}
+	return file.Close()
+}
+
 func (c *Client) LinkApp(appName, hostName string) error {
 	reqBody := types.LinkAppRequest{
 		AppName:  appName,
@@ -300,6 +509,18 @@ func (c *Client) ListHosts() ([]types.SSHHostDTO, error) {
 	return hosts, nil
 }
 
+// ListLinkedHosts lists the hosts an application is linked to.
+func (c *Client) ListLinkedHosts(appName string) ([]types.SSHHostDTO, error) {
+	q := url.Values{}
+	q.Add("app", appName)
+
+	var hosts []types.SSHHostDTO
+	if err := c.get("hosts/linked", q, &hosts); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
 // SyncDomains syncs domains from config to the database via API
 func (c *Client) SyncDomains(instanceID string, domains []string, primaryDomain string) error {
 	reqBody := types.SyncDomainsRequest{
@@ -401,15 +622,32 @@ func (c *Client) UnsetSecret(appName, key string) error {
 	return c.delete("secrets", q)
 }
 
+// EnsureReleaseCookie returns the Erlang distribution cookie of an application, which the server
+// generates on first use
+func (c *Client) EnsureReleaseCookie(appName string) (string, error) {
+	q := url.Values{}
+	q.Add("app", appName)
+
+	var result struct {
+		Cookie string `json:"cookie"`
+	}
+	if err := c.post(fmt.Sprintf("release-cookie?%s", q.Encode()), nil, &result); err != nil {
+		return "", err
+	}
+	return result.Cookie, nil
+}
+
 // --- Instance Management ---
 
 // InstanceInfo struct remains unchanged
 type InstanceInfo struct {
 	Instance struct {
-		UID                string `json:"uid"`
-		Status             string `json:"status"`
-		ActivePort         int64  `json:"active_port"`
-		PreviousActivePort int64  `json:"previous_active_port"`
+		UID                string           `json:"uid"`
+		Status             string           `json:"status"`
+		ActivePort         int64            `json:"active_port"`
+		PreviousActivePort int64            `json:"previous_active_port"`
+		ActivePorts        []int            `json:"active_ports,omitempty"`
+		Canary             *types.CanaryDTO `json:"canary,omitempty"`
 	} `json:"instance"`
 	App struct {
 		UID  string `json:"uid"`
@@ -470,6 +708,108 @@ func (c *Client) GetLastDeployment(appName string) (*types.DeploymentHistoryDTO,
 	return &result, nil
 }
 
+// Rollback rolls an application instance back to its standby or another retained release.
+func (c *Client) Rollback(instanceUID string, req *types.RollbackRequest) (*types.RollbackResponse, error) {
+	var result types.RollbackResponse
+	path := fmt.Sprintf("instances/%s/rollback", instanceUID)
+	if err := c.post(path, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Scale runs a number of instances of the active release of an application instance behind Caddy.
+func (c *Client) Scale(instanceUID string, req *types.ScaleRequest) (*types.ScaleResponse, error) {
+	var result types.ScaleResponse
+	path := fmt.Sprintf("instances/%s/scale", instanceUID)
+	if err := c.post(path, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// LockInstance freezes deployments to an application instance until it is unlocked.
+func (c *Client) LockInstance(instanceUID, reason string) (*types.InstanceLockDTO, error) {
+	var result types.InstanceLockDTO
+	req := types.LockInstanceRequest{Reason: reason}
+	if err := c.post(fmt.Sprintf("instances/%s/lock", instanceUID), req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UnlockInstance removes the lock of an application instance.
+// The lock of a running operation such as a deployment is only removed with force.
+// The returned lock is empty if the instance was not locked.
+func (c *Client) UnlockInstance(instanceUID string, force bool) (*types.InstanceLockDTO, error) {
+	var result types.InstanceLockDTO
+	path := fmt.Sprintf("instances/%s/lock", instanceUID)
+	if force {
+		path += "?force=true"
+	}
+	if err := c.request("DELETE", path, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PromoteCanary sends more traffic to the canary of an application instance.
+// With weight 0 the canary moves to its next step; at 100 it becomes the active release.
+func (c *Client) PromoteCanary(instanceUID string, weight int) (*types.CanaryDTO, error) {
+	var result types.CanaryDTO
+	req := types.PromoteCanaryRequest{Weight: weight}
+	if err := c.post(fmt.Sprintf("instances/%s/canary/promote", instanceUID), req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AbortCanary sends all traffic of an application instance back to its stable release and stops the canary.
+func (c *Client) AbortCanary(instanceUID string) (*types.CanaryDTO, error) {
+	var result types.CanaryDTO
+	if err := c.post(fmt.Sprintf("instances/%s/canary/abort", instanceUID), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListReleases lists the releases of an application instance, newest first.
+func (c *Client) ListReleases(instanceUID string) ([]types.ReleaseDTO, error) {
+	var result []types.ReleaseDTO
+	if err := c.get(fmt.Sprintf("instances/%s/releases", instanceUID), nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PruneReleases deletes the old releases of an application instance from its host.
+func (c *Client) PruneReleases(instanceUID string, req *types.PruneReleasesRequest) (*types.PruneReleasesResponse, error) {
+	var result types.PruneReleasesResponse
+	if err := c.post(fmt.Sprintf("instances/%s/releases/prune", instanceUID), req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PinRelease protects a release of an application instance from pruning.
+// The release is identified by its directory name or by the ID of the deployment that created it.
+func (c *Client) PinRelease(instanceUID, release string) (*types.ReleaseDTO, error) {
+	var result types.ReleaseDTO
+	if err := c.post(fmt.Sprintf("instances/%s/releases/%s/pin", instanceUID, url.PathEscape(release)), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UnpinRelease lets a pinned release of an application instance be pruned again.
+func (c *Client) UnpinRelease(instanceUID, release string) (*types.ReleaseDTO, error) {
+	var result types.ReleaseDTO
+	if err := c.request("DELETE", fmt.Sprintf("instances/%s/releases/%s/pin", instanceUID, url.PathEscape(release)), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 // ListBuildArtifacts lists all build artifacts for an application
 func (c *Client) ListBuildArtifacts(appName string) ([]types.BuildArtifactDTO, error) {
 	q := url.Values{}
@@ -486,17 +826,39 @@ func (c *Client) ListBuildArtifacts(appName string) ([]types.BuildArtifactDTO, e
 	return result.Artifacts, nil
 }
 
+// DeleteBuildArtifact deletes a build of an application, by its full MD5, from the artifact registry and the build history
+func (c *Client) DeleteBuildArtifact(appName, md5Hash string) error {
+	q := url.Values{}
+	q.Add("app", appName)
+	return c.delete("builds/"+url.PathEscape(md5Hash), q)
+}
+
+// ListBuildDeployments lists the deployments that shipped a build of an application, by its full MD5, newest first
+func (c *Client) ListBuildDeployments(appName, md5Hash string) ([]types.DeploymentHistoryDTO, error) {
+	q := url.Values{}
+	q.Add("app", appName)
+
+	var result []types.DeploymentHistoryDTO
+	if err := c.get("builds/"+url.PathEscape(md5Hash)+"/deployments", q, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // StreamInstanceLogs connects to the WebSocket endpoint and streams logs in real-time
 // instanceUID: The unique identifier of the instance (e.g., inst_xxx)
 // lines: Number of initial log lines to show
 // Returns: error if connection or streaming fails
-func (c *Client) StreamInstanceLogs(ctx context.Context, instanceUID string, lines int) error {
+func (c *Client) StreamInstanceLogs(ctx context.Context, instanceUID string, lines int, process string) error {
 	// Build WebSocket URL
 	// Convert http/https to ws/wss
 	wsURL := strings.Replace(c.BaseURL, "http://", "ws://", 1)
 	wsURL = strings.Replace(wsURL, "https://", "wss://", 1)
 	wsURL = fmt.Sprintf("%s/api/instances/%s/logs/stream?lines=%d&token=%s",
 		wsURL, instanceUID, lines, c.Token)
+	if process != "" {
+		wsURL += "&process=" + url.QueryEscape(process)
+	}
 
 	// Create WebSocket connection
 	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
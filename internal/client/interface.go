@@ -1,6 +1,7 @@
 package client
 
 import (
+	"io"
 	"youfun/shipyard/pkg/types"
 )
 
@@ -11,17 +12,44 @@ type APIClient interface {
 
 	// Deployment History
 	CreateDeployment(req *types.CreateDeploymentRequest) (*types.DeploymentHistoryDTO, error)
-	UpdateDeploymentStatus(deploymentID, status string, port int, releasePath, gitCommitSHA string) error
+	UpdateDeploymentStatus(deploymentID, status string, ports []int, releasePath, gitCommitSHA string) error
 	UploadDeploymentLogs(deploymentID string, logs string) error
+	UploadHealthChecks(deploymentID string, results []types.HealthCheckResultDTO) error
+	UpdateDeploymentStep(deploymentID string, step *types.DeploymentStepDTO) error
+	ResumeDeployment(deploymentID string) (*types.ResumeDeploymentResponse, error)
+
+	// Rollouts
+	CreateRollout(req *types.CreateRolloutRequest) (*types.RolloutDTO, error)
+	UpdateRolloutStatus(rolloutID, status string) error
+
+	// Deploy Locks
+	RenewDeploymentLock(deploymentID string) error
+	ReleaseDeploymentLock(deploymentID string) error
+
+	// Canary Releases
+	StartCanary(deploymentID string, req *types.StartCanaryRequest) error
+
+	// Releases
+	ListReleases(instanceUID string) ([]types.ReleaseDTO, error)
+	PruneReleases(instanceUID string, req *types.PruneReleasesRequest) (*types.PruneReleasesResponse, error)
 	
 	// Server-side Deployment
 	UploadDeploymentArtifact(deploymentID string, artifactPath string) error
 	ExecuteServerDeployment(deploymentID string, version, gitCommitSHA, md5Hash string) error
 
 	// Artifacts
-	// CheckArtifact checks if a build artifact exists by query (MD5 prefix, full MD5, or git SHA)
+	// CheckArtifact checks if a build artifact exists by query (MD5 prefix, full MD5, git SHA or version)
 	CheckArtifact(appID, query string) (*types.BuildArtifactDTO, error)
 	RegisterArtifact(artifact *types.BuildArtifactDTO) error
+	// PushArtifact uploads a build artifact to the artifact registry of the server, which signs it
+	PushArtifact(artifact *types.BuildArtifactDTO, tarballPath string, keep int) (*types.BuildArtifactDTO, error)
+	// BuildOnServer builds uploaded sources on the server or a builder host, streaming the build output
+	BuildOnServer(req *types.ServerBuildRequest, sourcePath string, output io.Writer) (*types.BuildArtifactDTO, error)
+	DownloadArtifact(appID, md5Hash, destPath string) error
+	ListBuildArtifacts(appName string) ([]types.BuildArtifactDTO, error)
+	// DeleteBuildArtifact deletes a build from the artifact registry and the build history
+	DeleteBuildArtifact(appName, md5Hash string) error
+	ListBuildDeployments(appName, md5Hash string) ([]types.DeploymentHistoryDTO, error)
 
 	// Host Init
 	LinkApp(appName, hostName string) error
@@ -35,9 +63,12 @@ type APIClient interface {
 	ListSecrets(appName string) ([]string, error)
 	SetSecret(appName, key, value string) error
 	UnsetSecret(appName, key string) error
+	EnsureReleaseCookie(appName string) (string, error)
 
 	// Instance Management
 	GetInstance(appName, hostName string) (*InstanceInfo, error)
 	GetHostByName(hostName string) (*types.SSHHostDTO, error)
 	GetLastDeployment(appName string) (*types.DeploymentHistoryDTO, error)
+	Rollback(instanceUID string, req *types.RollbackRequest) (*types.RollbackResponse, error)
+	Scale(instanceUID string, req *types.ScaleRequest) (*types.ScaleResponse, error)
 }
@@ -0,0 +1,47 @@
+package cliutils
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+)
+
+// Exit codes of the CLI in CI mode, one per failure class. Outside CI mode every failure exits with 1.
+const (
+	ExitFailure = 1 // Any other failure
+	ExitConfig  = 2 // Unknown app or host, invalid flags or configuration
+	ExitAuth    = 3 // Missing, invalid or insufficient credentials
+	ExitBuild   = 4 // The artifact could not be built or found
+	ExitUpload  = 5 // The release could not be uploaded to the host
+	ExitHealth  = 6 // The new version did not start or failed its health check
+	ExitTraffic = 7 // Traffic could not be switched to the new version
+)
+
+// ErrNonInteractive is returned by prompts in CI mode, where the input has to come from flags.
+var ErrNonInteractive = errors.New("input required, but prompts are disabled in CI mode")
+
+var ciMode bool
+
+// SetCIMode enables CI mode: the CLI never prompts and fails fast.
+func SetCIMode() {
+	ciMode = true
+}
+
+// IsCI reports whether the CLI runs in CI mode, enabled with --ci or CI=true.
+func IsCI() bool {
+	if ciMode {
+		return true
+	}
+	ci, _ := strconv.ParseBool(os.Getenv("CI"))
+	return ci
+}
+
+// Fatalf logs an error and exits with code in CI mode, or with 1 otherwise.
+func Fatalf(code int, format string, v ...interface{}) {
+	log.Printf(format, v...)
+	if !IsCI() {
+		code = ExitFailure
+	}
+	os.Exit(code)
+}
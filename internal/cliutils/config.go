@@ -40,16 +40,16 @@ func ResolveAppNameFromConfig() string {
 
 	// Check if config file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		log.Fatalf("Error: configuration file '%s' not found. Please run in the project directory or specify the application name with --app. or use launch command", configPath)
+		Fatalf(ExitConfig, "Error: configuration file '%s' not found. Please run in the project directory or specify the application name with --app. or use launch command", configPath)
 	}
 
 	projConf, err := config.ReadConfigFile(configPath)
 	if err != nil {
-		log.Fatalf("Failed to read configuration file: %v", err)
+		Fatalf(ExitConfig, "Failed to read configuration file: %v", err)
 	}
 
 	if projConf.App == "" {
-		log.Fatalf("Error: could not determine application name. Please set 'app' in %s or use the --app flag.", configPath)
+		Fatalf(ExitConfig, "Error: could not determine application name. Please set 'app' in %s or use the --app flag.", configPath)
 	}
 	log.Printf("Using application name: '%s'", projConf.App)
 	return projConf.App
@@ -147,6 +147,18 @@ keep_releases = 3
     type = "shell"
     command = "bin/migrate"
 
+
+# [health_check] HTTP readiness probe run against the new version before traffic is switched.
+# Defaults: path "/", any 2xx/3xx status, 2s interval, 5s timeout, 10 retries.
+# [health_check]
+#   path = "/health"
+#   expected_status = [200]
+#   body_contains = "OK"
+#   interval = "2s"
+#   timeout = "5s"
+#   grace_period = "0s"
+#   retries = 10
+
 `, appName)
 
 	return os.WriteFile(config.ConfigPath, []byte(content), 0644)
@@ -10,8 +10,11 @@ import (
 
 // PromptForSelection displays a list of items to the user and prompts them to select one.
 // It supports a default selection, which is chosen if the user just presses Enter.
-// It returns the zero-based index of the selected item.
+// It returns the zero-based index of the selected item, or ErrNonInteractive in CI mode.
 func PromptForSelection(promptTitle string, items []string, defaultIndex int) (int, error) {
+	if IsCI() {
+		return -1, ErrNonInteractive
+	}
 	fmt.Println(promptTitle)
 	for i, item := range items {
 		fmt.Printf("%d. %s\n", i+1, item)
@@ -43,7 +46,11 @@ func PromptForSelection(promptTitle string, items []string, defaultIndex int) (i
 }
 
 // PromptForInput prompts the user for text input with an optional default value.
+// In CI mode it returns the default value without prompting.
 func PromptForInput(prompt string, defaultValue string) string {
+	if IsCI() {
+		return defaultValue
+	}
 	reader := bufio.NewReader(os.Stdin)
 	if defaultValue != "" {
 		fmt.Printf("%s (default: %s): ", prompt, defaultValue)
@@ -61,7 +68,11 @@ func PromptForInput(prompt string, defaultValue string) string {
 }
 
 // PromptForConfirmation asks the user a yes/no question and returns true if they confirm.
+// In CI mode it returns the default answer without prompting.
 func PromptForConfirmation(prompt string, defaultYes bool) bool {
+	if IsCI() {
+		return defaultYes
+	}
 	reader := bufio.NewReader(os.Stdin)
 	suffix := " [y/N]: "
 	if defaultYes {
@@ -27,6 +27,14 @@ func DetectRuntime() string {
 		log.Println("Detected Go project (go.mod).")
 		return "golang"
 	}
+	if _, err := os.Stat("pyproject.toml"); err == nil {
+		log.Println("Detected Python project (pyproject.toml).")
+		return "python"
+	}
+	if _, err := os.Stat("requirements.txt"); err == nil {
+		log.Println("Detected Python project (requirements.txt).")
+		return "python"
+	}
 	if _, err := os.Stat("Dockerfile"); err == nil {
 		log.Println("Detected Dockerfile.")
 		return "docker"
@@ -0,0 +1,148 @@
+// Package compression reads and writes release tarballs in the compression formats Shipyard supports,
+// compressing large releases on all cores, and builds the matching extraction commands for hosts.
+package compression
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+)
+
+// Compression formats of release tarballs
+const (
+	Gzip = "gzip" // tar.gz, the default, which every host can extract
+	Zstd = "zstd" // tar.zst, faster to compress and extract, needs zstd on the host
+	None = "none" // plain tar
+)
+
+// pgzipBlockSize is the size of the blocks compressed in parallel by the gzip writer.
+const pgzipBlockSize = 1 << 20
+
+// Normalize returns the format of a tarball recorded without one, which predates compression options: gzip.
+func Normalize(format string) string {
+	if format == "" {
+		return Gzip
+	}
+	return format
+}
+
+// Validate checks that format is a supported compression format. An empty format means gzip.
+func Validate(format string) error {
+	switch format {
+	case "", Gzip, Zstd, None:
+		return nil
+	}
+	return fmt.Errorf("unknown compression %q, expected gzip, zstd or none", format)
+}
+
+// Extension returns the file extension of a tarball in the given format, e.g. ".tar.zst".
+func Extension(format string) string {
+	switch Normalize(format) {
+	case Zstd:
+		return ".tar.zst"
+	case None:
+		return ".tar"
+	}
+	return ".tar.gz"
+}
+
+// NewWriter returns a writer compressing to w in the given format on all available cores.
+// Closing it flushes the compressed stream but does not close w.
+func NewWriter(w io.Writer, format string) (io.WriteCloser, error) {
+	switch Normalize(format) {
+	case Gzip:
+		gw := pgzip.NewWriter(w)
+		if err := gw.SetConcurrency(pgzipBlockSize, runtime.GOMAXPROCS(0)); err != nil {
+			return nil, err
+		}
+		return gw, nil
+	case Zstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(runtime.GOMAXPROCS(0)))
+	case None:
+		return nopWriteCloser{w}, nil
+	}
+	return nil, Validate(format)
+}
+
+// NewReader returns a reader decompressing r in the given format.
+func NewReader(r io.Reader, format string) (io.ReadCloser, error) {
+	switch Normalize(format) {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case None:
+		return io.NopCloser(r), nil
+	}
+	return nil, Validate(format)
+}
+
+// Decompressor returns the program a host needs to extract tarballs in the given format, or "" when
+// tar handles it alone.
+func Decompressor(format string) string {
+	if Normalize(format) == Zstd {
+		return "zstd"
+	}
+	return ""
+}
+
+// ExtractCommand returns the shell command extracting a tarball in the given format from stdin into dir.
+// dir must already be quoted for the shell when needed.
+func ExtractCommand(format, dir string) string {
+	switch Normalize(format) {
+	case Zstd:
+		return fmt.Sprintf("zstd -dcq | tar -xf - -C %s", dir)
+	case None:
+		return fmt.Sprintf("tar -xf - -C %s", dir)
+	}
+	return fmt.Sprintf("tar -xzf - -C %s", dir)
+}
+
+// Recompress writes the tarball at srcPath, compressed in srcFormat, to a temporary file compressed in
+// dstFormat and returns its path. The caller removes it.
+func Recompress(srcPath, srcFormat, dstFormat string) (string, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open tarball (%s): %w", srcPath, err)
+	}
+	defer src.Close()
+	r, err := NewReader(src, srcFormat)
+	if err != nil {
+		return "", fmt.Errorf("failed to read tarball (%s): %w", srcPath, err)
+	}
+	defer r.Close()
+
+	dst, err := os.CreateTemp("", "shipyard-release-*"+Extension(dstFormat))
+	if err != nil {
+		return "", fmt.Errorf("failed to create tarball: %w", err)
+	}
+	defer dst.Close()
+
+	w, err := NewWriter(dst, dstFormat)
+	if err == nil {
+		_, err = io.Copy(w, r)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("failed to recompress tarball (%s) as %s: %w", srcPath, dstFormat, err)
+	}
+	return dst.Name(), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
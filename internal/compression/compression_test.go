@@ -0,0 +1,87 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	content := bytes.Repeat([]byte("release tarball content "), 200000) // several blocks of the gzip writer
+	for _, format := range []string{"", Gzip, Zstd, None} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, format)
+		if err != nil {
+			t.Fatalf("NewWriter(%q) failed: %v", format, err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewReader(&buf, format)
+		if err != nil {
+			t.Fatalf("NewReader(%q) failed: %v", format, err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("%q: failed to read: %v", format, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("%q: content does not round-trip", format)
+		}
+	}
+
+	if _, err := NewWriter(io.Discard, "brotli"); err == nil {
+		t.Error("expected an unknown format to be refused")
+	}
+}
+
+func TestRecompress(t *testing.T) {
+	src, err := os.CreateTemp(t.TempDir(), "release-*.tar.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, _ := NewWriter(src, Zstd)
+	io.WriteString(w, "release")
+	w.Close()
+	src.Close()
+
+	path, err := Recompress(src.Name(), Zstd, Gzip)
+	if err != nil {
+		t.Fatalf("Recompress() failed: %v", err)
+	}
+	defer os.Remove(path)
+	if !strings.HasSuffix(path, ".tar.gz") {
+		t.Errorf("expected a .tar.gz, got %s", path)
+	}
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	r, err := NewReader(f, Gzip)
+	if err != nil {
+		t.Fatalf("recompressed tarball is not gzip: %v", err)
+	}
+	if got, _ := io.ReadAll(r); string(got) != "release" {
+		t.Errorf("unexpected content %q", got)
+	}
+}
+
+func TestExtractCommand(t *testing.T) {
+	tests := map[string]string{
+		"":   "tar -xzf - -C /rel",
+		Gzip: "tar -xzf - -C /rel",
+		Zstd: "zstd -dcq | tar -xf - -C /rel",
+		None: "tar -xf - -C /rel",
+	}
+	for format, want := range tests {
+		if got := ExtractCommand(format, "/rel"); got != want {
+			t.Errorf("ExtractCommand(%q) = %q, want %q", format, got, want)
+		}
+	}
+}
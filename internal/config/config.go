@@ -3,6 +3,12 @@ package config
 import (
 	"fmt"
 	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"youfun/shipyard/internal/compression"
 
 	"github.com/BurntSushi/toml"
 )
@@ -22,17 +28,261 @@ type Hooks struct {
 	// Future hooks like pre_start, post_start can be added here.
 }
 
+// HealthCheck defines the HTTP readiness probe run against a new release before traffic is switched.
+type HealthCheck struct {
+	Path           string        `toml:"path"`            // request path, default "/"
+	ExpectedStatus []int         `toml:"expected_status"` // accepted status codes, default any 2xx/3xx
+	BodyContains   string        `toml:"body_contains"`   // optional substring the response body must contain
+	Interval       time.Duration `toml:"interval"`        // wait between probes, default 2s
+	Timeout        time.Duration `toml:"timeout"`         // per-probe timeout, default 5s
+	GracePeriod    time.Duration `toml:"grace_period"`    // wait before the first probe, default 0
+	Retries        int           `toml:"retries"`         // number of probes before giving up, default 10
+}
+
+// Build defines where and how the release is built.
+type Build struct {
+	Location string `toml:"location"` // local (default), server or host
+	Host     string `toml:"host"`     // builder host for location = "host"
+	Builder  string `toml:"builder"`  // docker (default), mix, go or tarball
+	GOOS     string `toml:"goos"`     // target OS of the go builder, default linux
+	GOARCH   string `toml:"goarch"`   // target architecture of the go builder, default amd64
+	Main     string `toml:"main"`     // package built by the go builder, default "."
+	Dir      string `toml:"dir"`      // directory packed by the tarball builder, default "."
+
+	Command   string `toml:"command"`    // build command of the command builder, run with sh -c
+	OutputDir string `toml:"output_dir"` // directory the command builder packs after the build, default "."
+
+	Compression string `toml:"compression"` // compression of the release tarball: gzip (default), zstd or none
+
+	KeepArtifacts int `toml:"keep_artifacts"` // builds of the app kept in the artifact registry, default 10
+}
+
+// Build locations
+const (
+	BuildLocal  = "local"  // The machine running the CLI
+	BuildServer = "server" // shipyard-server itself
+	BuildHost   = "host"   // Docker on a builder host, over SSH from shipyard-server
+)
+
+// Builders
+const (
+	BuilderDocker  = "docker"  // docker build with the preset or the project's Dockerfile
+	BuilderMix     = "mix"     // native mix release, the build machine must match the target OS and glibc
+	BuilderGo      = "go"      // native go build for GOOS/GOARCH
+	BuilderTarball = "tarball" // packs a directory as it is
+	BuilderCommand = "command" // runs [build] command, then packs [build] output_dir
+)
+
+// Run defines how the instances of the app are started.
+type Run struct {
+	Command string `toml:"command"` // start command of the systemd unit, rendered again on every deployment
+}
+
+// Process is a process type of the app, started from the same release as the others. In shipyard.toml it is
+// either the start command, or a table with the command and the number of instances.
+type Process struct {
+	Command string `toml:"command"`
+	Count   int    `toml:"count"` // instances per host, default 1, 0 stops the process type
+}
+
+// ProcessWeb is the process type that serves HTTP: it runs on a port, is health-checked, takes part in the
+// blue/green switch and is routed by Caddy. The other process types run next to it.
+const ProcessWeb = "web"
+
+var processNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ValidProcessName reports whether name can be a process type of [processes].
+func ValidProcessName(name string) bool {
+	return processNamePattern.MatchString(name)
+}
+
+// UnmarshalTOML reads a process type given as a command string or as a table.
+func (p *Process) UnmarshalTOML(data interface{}) error {
+	p.Count = 1
+	switch v := data.(type) {
+	case string:
+		p.Command = v
+		return nil
+	case map[string]interface{}:
+		for key, value := range v {
+			switch key {
+			case "command":
+				command, ok := value.(string)
+				if !ok {
+					return fmt.Errorf("command must be a string")
+				}
+				p.Command = command
+			case "count":
+				count, ok := value.(int64)
+				if !ok {
+					return fmt.Errorf("count must be an integer")
+				}
+				p.Count = int(count)
+			default:
+				return fmt.Errorf("unknown key %q, expected command or count", key)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("expected a command or a table with command and count")
+}
+
+// ProcessUnit returns the name of the systemd template unit of a process type up to its instance,
+// "<app>@" for web and "<app>-<process>@" for the others.
+func ProcessUnit(app, process string) string {
+	if process == ProcessWeb || process == "" {
+		return app + "@"
+	}
+	return app + "-" + process + "@"
+}
+
+// Cron is a scheduled job of [[cron]], run by a systemd timer on one host of the app against the active release.
+type Cron struct {
+	Name     string        `toml:"name"`
+	Schedule string        `toml:"schedule"` // crontab expression ("0 3 * * *") or systemd calendar event ("daily")
+	Command  string        `toml:"command"`
+	Type     string        `toml:"type"`    // eval or shell, as for hooks
+	Timeout  time.Duration `toml:"timeout"` // the job is stopped after it, no limit when 0
+	Host     string        `toml:"host"`    // host running the job, the first host of the app by name when empty
+}
+
+var cronNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidCronName reports whether name can be the name of a job of [[cron]].
+func ValidCronName(name string) bool {
+	return cronNamePattern.MatchString(name)
+}
+
+// cronFieldPattern matches a field of a crontab expression: *, numbers, lists, ranges and steps.
+var cronFieldPattern = regexp.MustCompile(`^(\*|[0-9]+(-[0-9]+)?)(/[0-9]+)?(,(\*|[0-9]+(-[0-9]+)?)(/[0-9]+)?)*$`)
+
+var cronWeekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// OnCalendar returns the schedule as a systemd calendar event. Crontab expressions with five fields
+// (minute, hour, day of month, month, day of week) are converted, anything else is taken as a calendar event.
+func (c Cron) OnCalendar() (string, error) {
+	fields := strings.Fields(c.Schedule)
+	if len(fields) != 5 {
+		return strings.TrimSpace(c.Schedule), nil
+	}
+	for _, field := range fields {
+		if !cronFieldPattern.MatchString(field) {
+			return strings.TrimSpace(c.Schedule), nil
+		}
+	}
+
+	// Steps of * start at the first value of the field
+	convert := func(field string, first int) string {
+		parts := strings.Split(field, ",")
+		for i, part := range parts {
+			if rest, ok := strings.CutPrefix(part, "*/"); ok {
+				part = fmt.Sprintf("%02d/%s", first, rest)
+			}
+			parts[i] = strings.Replace(part, "-", "..", 1)
+		}
+		return strings.Join(parts, ",")
+	}
+	minute, hour, day, month, weekday := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+	event := fmt.Sprintf("*-%s-%s %s:%s:00", convert(month, 1), convert(day, 1), convert(hour, 0), convert(minute, 0))
+	if weekday == "*" {
+		return event, nil
+	}
+	var days []string
+	for _, part := range strings.Split(weekday, ",") {
+		if strings.Contains(part, "/") {
+			return "", fmt.Errorf("[[cron]] %s: steps of the day of week are not supported, list the days", c.Name)
+		}
+		var names []string
+		for _, n := range strings.SplitN(part, "-", 2) {
+			if n == "*" {
+				names = append(names, "Mon..Sun")
+				continue
+			}
+			i, err := strconv.Atoi(n)
+			if err != nil || i > 7 {
+				return "", fmt.Errorf("[[cron]] %s: invalid day of week %q", c.Name, n)
+			}
+			names = append(names, cronWeekdays[i])
+		}
+		days = append(days, strings.Join(names, ".."))
+	}
+	return strings.Join(days, ",") + " " + event, nil
+}
+
+// ValidateCron checks the scheduled jobs of [[cron]].
+func (c Config) ValidateCron() error {
+	seen := make(map[string]bool)
+	for _, job := range c.Cron {
+		if !ValidCronName(job.Name) {
+			return fmt.Errorf("invalid [[cron]] name %q, expected lowercase letters, digits, - and _", job.Name)
+		}
+		if seen[job.Name] {
+			return fmt.Errorf("[[cron]] %s is defined twice", job.Name)
+		}
+		seen[job.Name] = true
+		if job.Command == "" || strings.ContainsAny(job.Command, "\n\r") {
+			return fmt.Errorf("[[cron]] %s needs a command on a single line", job.Name)
+		}
+		if job.Type != "eval" && job.Type != "shell" {
+			return fmt.Errorf("[[cron]] %s has unknown type %q, expected eval or shell", job.Name, job.Type)
+		}
+		if job.Timeout < 0 {
+			return fmt.Errorf("[[cron]] %s: timeout must not be negative", job.Name)
+		}
+		schedule, err := job.OnCalendar()
+		if err != nil {
+			return err
+		}
+		if schedule == "" || strings.ContainsAny(schedule, "\n\r") {
+			return fmt.Errorf("[[cron]] %s needs a schedule", job.Name)
+		}
+	}
+	return nil
+}
+
+// RuntimeCustom is the runtime of apps started by [run] command, built by [build] command or their own
+// Dockerfile.shipyard.
+const RuntimeCustom = "custom"
+
 // Config stores the full configuration loaded from shipyard.toml
 type Config struct {
 	App           string                 `toml:"app"`
 	Domains       []string               `toml:"domains"`        // support multiple domains
 	PrimaryDomain string                 `toml:"primary_domain"` // primary domain (optional)
-	Runtime       string                 `toml:"runtime"`        // phoenix|node|golang, can be empty for auto-detection
+	Runtime       string                 `toml:"runtime"`        // phoenix|elixir|node|golang|python|static|custom, can be empty for auto-detection
+	Version       string                 `toml:"version"`        // version of the builds, read from the project files of the runtime when empty
 	Env           map[string]interface{} `toml:"env"`
 	Hooks         Hooks                  `toml:"hooks"`
 	KeepReleases  int                    `toml:"keep_releases"` // number of old releases to keep, default 3
+	HealthCheck   HealthCheck            `toml:"health_check"`
+	DrainTimeout  time.Duration          `toml:"drain_timeout"` // max wait for in-flight requests before stopping the old version, default 30s
+	Build         Build                  `toml:"build"`
+	Run           Run                    `toml:"run"`
+	Scale         int                    `toml:"scale"`     // web instances per host behind Caddy, 0 keeps the number serving traffic
+	Processes     map[string]Process     `toml:"processes"` // process types started from the release, web is the routed one
+	Cron          []Cron                 `toml:"cron"`      // scheduled jobs, run by systemd timers on the hosts
+	Upload        string                 `toml:"upload"`    // full (default) or delta
 }
 
+// Release upload modes
+const (
+	UploadDelta = "delta" // Only the files that changed since the active release
+	UploadFull  = "full"  // The whole release tarball
+)
+
+// DefaultKeepReleases is the number of old releases kept on the host besides the active, standby and pinned ones.
+const DefaultKeepReleases = 3
+
+// DefaultKeepArtifacts is the number of builds of an app kept in the artifact registry of shipyard-server.
+const DefaultKeepArtifacts = 10
+
+// DefaultDrainTimeout is how long the old version may keep serving in-flight requests after traffic is switched.
+const DefaultDrainTimeout = 30 * time.Second
+
+// MaxScale is the most web instances of an app on one host.
+const MaxScale = 32
+
 var AppConfig Config
 
 // ConfigPath stores the path to the config file in use; can be overridden by --config
@@ -84,20 +334,165 @@ func LoadConfig(appName string, configPath string) {
 	}
 
 	if AppConfig.Runtime == "" {
-		log.Println("Runtime not explicitly configured; will auto-detect during deployment (phoenix|node|golang|static)")
+		log.Println("Runtime not explicitly configured; will auto-detect during deployment (phoenix|elixir|node|golang|python|static)")
 	} else {
 		log.Printf("runtime=%s (from %s)", AppConfig.Runtime, configPath)
 	}
 
 	// set default KeepReleases if not configured
 	if AppConfig.KeepReleases == 0 {
-		AppConfig.KeepReleases = 3 // default keep 3 old releases
+		AppConfig.KeepReleases = DefaultKeepReleases
 		log.Printf("keep_releases not configured, using default value %d.", AppConfig.KeepReleases)
 	}
 
+	AppConfig.HealthCheck.ApplyDefaults()
+
+	if AppConfig.DrainTimeout <= 0 {
+		AppConfig.DrainTimeout = DefaultDrainTimeout
+	}
+
+	if AppConfig.Build.Location == "" {
+		AppConfig.Build.Location = BuildLocal
+	}
+	if AppConfig.Build.Builder == "" {
+		AppConfig.Build.Builder = BuilderDocker
+		if AppConfig.Build.Command != "" {
+			AppConfig.Build.Builder = BuilderCommand
+		}
+	}
+	if AppConfig.Build.Compression == "" {
+		AppConfig.Build.Compression = compression.Gzip
+	}
+
+	if AppConfig.Upload == "" {
+		AppConfig.Upload = UploadFull
+	}
+
 	log.Printf("Configuration loaded (from %s).", configPath)
 }
 
+// ApplyDefaults fills unset health check fields with their default values.
+func (hc *HealthCheck) ApplyDefaults() {
+	if hc.Path == "" {
+		hc.Path = "/"
+	}
+	if hc.Interval <= 0 {
+		hc.Interval = 2 * time.Second
+	}
+	if hc.Timeout <= 0 {
+		hc.Timeout = 5 * time.Second
+	}
+	if hc.Retries <= 0 {
+		hc.Retries = 10
+	}
+}
+
+// AcceptsStatus reports whether the given HTTP status code counts as healthy.
+func (hc HealthCheck) AcceptsStatus(code int) bool {
+	if len(hc.ExpectedStatus) == 0 {
+		return code >= 200 && code < 400
+	}
+	for _, expected := range hc.ExpectedStatus {
+		if code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the build settings.
+func (b Build) Validate() error {
+	switch b.Builder {
+	case "", BuilderDocker, BuilderMix, BuilderGo, BuilderTarball:
+	case BuilderCommand:
+		if b.Command == "" {
+			return fmt.Errorf("[build] builder = \"command\" needs the build command in [build] command")
+		}
+	default:
+		return fmt.Errorf("unknown [build] builder %q, expected docker, mix, go, tarball or command", b.Builder)
+	}
+	if err := compression.Validate(b.Compression); err != nil {
+		return fmt.Errorf("[build] %w", err)
+	}
+
+	switch b.Location {
+	case BuildLocal:
+		return nil
+	case BuildServer, BuildHost:
+		if b.Location == BuildHost && b.Host == "" {
+			return fmt.Errorf("[build] location = \"host\" needs the builder host in [build] host")
+		}
+		// The native builders would run the code of the project in shipyard-server or on the builder host itself
+		if b.Builder != "" && b.Builder != BuilderDocker {
+			return fmt.Errorf("[build] location = %q only supports the docker builder", b.Location)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown [build] location %q, expected local, server or host", b.Location)
+}
+
+// ValidateUpload checks how releases are uploaded.
+func (c Config) ValidateUpload() error {
+	switch c.Upload {
+	case "", UploadFull, UploadDelta:
+		return nil
+	}
+	return fmt.Errorf("unknown upload %q, expected full or delta", c.Upload)
+}
+
+// ValidateRun checks scale and the start commands of [run] and [processes]: the custom runtime needs the one
+// of web, and every other process type needs its own.
+func (c Config) ValidateRun() error {
+	if c.Runtime == RuntimeCustom && c.StartCommand() == "" {
+		return fmt.Errorf("runtime = \"custom\" needs the start command in [run] command or [processes] web")
+	}
+	if c.Scale < 0 || c.Scale > MaxScale {
+		return fmt.Errorf("scale must be between 1 and %d, got %d", MaxScale, c.Scale)
+	}
+	for name, process := range c.Processes {
+		if !ValidProcessName(name) {
+			return fmt.Errorf("invalid process type %q in [processes], expected lowercase letters, digits and _", name)
+		}
+		if process.Count < 0 {
+			return fmt.Errorf("[processes] %s: count must not be negative", name)
+		}
+		if name == ProcessWeb {
+			if process.Count != 1 {
+				return fmt.Errorf("[processes] web does not take a count, set scale = %d to run several web instances per host", process.Count)
+			}
+			if c.Run.Command != "" && process.Command != "" && c.Run.Command != process.Command {
+				return fmt.Errorf("[run] command and [processes] web set different start commands")
+			}
+			continue
+		}
+		if process.Command == "" {
+			return fmt.Errorf("[processes] %s needs a command", name)
+		}
+	}
+	return nil
+}
+
+// StartCommand returns the start command of the web instances: [processes] web, else [run] command, else "" for
+// the start command of the runtime.
+func (c Config) StartCommand() string {
+	if web := c.Processes[ProcessWeb]; web.Command != "" {
+		return web.Command
+	}
+	return c.Run.Command
+}
+
+// OtherProcesses returns the process types of [processes] besides web, sorted by name.
+func (c Config) OtherProcesses() []string {
+	var names []string
+	for name := range c.Processes {
+		if name != ProcessWeb {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GetRemoteReleasesDir helper function to get the remote releases directory
 func GetRemoteReleasesDir() string {
 
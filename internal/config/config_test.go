@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig_Default(t *testing.T) {
@@ -96,3 +97,342 @@ domains = ["test.com"]
 		t.Errorf("expected Domains to be ['test.com'], got %v", cfg.Domains)
 	}
 }
+
+func TestLoadConfig_HealthCheck(t *testing.T) {
+	content := `
+app = "hcapp"
+
+[health_check]
+  path = "/healthz"
+  expected_status = [200, 204]
+  body_contains = "ok"
+  interval = "500ms"
+  timeout = "3s"
+  grace_period = "1s"
+`
+	if err := os.WriteFile("shipyard.toml", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("shipyard.toml")
+
+	AppConfig = Config{}
+	LoadConfig("", "shipyard.toml")
+	hc := AppConfig.HealthCheck
+
+	if hc.Path != "/healthz" {
+		t.Errorf("expected Path to be '/healthz', got '%s'", hc.Path)
+	}
+	if hc.Interval != 500*time.Millisecond {
+		t.Errorf("expected Interval to be 500ms, got %s", hc.Interval)
+	}
+	if hc.Timeout != 3*time.Second {
+		t.Errorf("expected Timeout to be 3s, got %s", hc.Timeout)
+	}
+	if hc.GracePeriod != time.Second {
+		t.Errorf("expected GracePeriod to be 1s, got %s", hc.GracePeriod)
+	}
+	// retries is not set, default applies
+	if hc.Retries != 10 {
+		t.Errorf("expected Retries to be 10, got %d", hc.Retries)
+	}
+	if !hc.AcceptsStatus(204) || hc.AcceptsStatus(302) {
+		t.Errorf("expected only configured status codes to be accepted, got %v", hc.ExpectedStatus)
+	}
+	// drain_timeout is not set, default applies
+	if AppConfig.DrainTimeout != DefaultDrainTimeout {
+		t.Errorf("expected DrainTimeout to be %s, got %s", DefaultDrainTimeout, AppConfig.DrainTimeout)
+	}
+}
+
+func TestLoadConfig_DrainTimeout(t *testing.T) {
+	content := `
+app = "drainapp"
+drain_timeout = "2m"
+`
+	if err := os.WriteFile("shipyard.toml", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("shipyard.toml")
+
+	AppConfig = Config{}
+	LoadConfig("", "shipyard.toml")
+
+	if AppConfig.DrainTimeout != 2*time.Minute {
+		t.Errorf("expected DrainTimeout to be 2m, got %s", AppConfig.DrainTimeout)
+	}
+}
+
+func TestLoadConfig_Upload(t *testing.T) {
+	for content, want := range map[string]string{
+		`app = "uploadapp"`:                       UploadFull,
+		"app = \"uploadapp\"\nupload = \"delta\"": UploadDelta,
+		"app = \"uploadapp\"\nupload = \"rsync\"": "rsync",
+	} {
+		if err := os.WriteFile("shipyard.toml", []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		AppConfig = Config{}
+		LoadConfig("", "shipyard.toml")
+		if AppConfig.Upload != want {
+			t.Errorf("%q: expected Upload to be %q, got %q", content, want, AppConfig.Upload)
+		}
+		// An unknown upload is a configuration error, not silently one of the modes
+		if err := AppConfig.ValidateUpload(); (err != nil) != (want == "rsync") {
+			t.Errorf("%q: ValidateUpload() error = %v", content, err)
+		}
+	}
+	os.Remove("shipyard.toml")
+}
+
+func TestLoadConfig_Build(t *testing.T) {
+	content := `
+app = "buildapp"
+
+[build]
+location = "host"
+host = "builder-1"
+`
+	if err := os.WriteFile("shipyard.toml", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("shipyard.toml")
+
+	AppConfig = Config{}
+	LoadConfig("", "shipyard.toml")
+
+	if AppConfig.Build.Location != BuildHost || AppConfig.Build.Host != "builder-1" || AppConfig.Build.Builder != BuilderDocker {
+		t.Errorf("unexpected build config: %+v", AppConfig.Build)
+	}
+	if err := AppConfig.Build.Validate(); err != nil {
+		t.Errorf("expected valid build config, got %v", err)
+	}
+}
+
+func TestLoadConfig_CustomRuntime(t *testing.T) {
+	content := `
+app = "customapp"
+runtime = "custom"
+
+[build]
+command = "make release"
+output_dir = "_build/out"
+
+[run]
+command = "./bin/app serve --port $PORT"
+`
+	if err := os.WriteFile("shipyard.toml", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("shipyard.toml")
+
+	AppConfig = Config{}
+	LoadConfig("", "shipyard.toml")
+
+	if AppConfig.Build.Builder != BuilderCommand || AppConfig.Build.OutputDir != "_build/out" {
+		t.Errorf("expected [build] command to select the command builder, got %+v", AppConfig.Build)
+	}
+	if err := AppConfig.ValidateRun(); err != nil {
+		t.Errorf("expected valid run config, got %v", err)
+	}
+
+	AppConfig.Run.Command = ""
+	if err := AppConfig.ValidateRun(); err == nil {
+		t.Error("expected the custom runtime without [run] command to be refused")
+	}
+}
+
+func TestLoadConfig_Processes(t *testing.T) {
+	content := `
+app = "shop"
+
+[processes]
+web = "bin/shop start"
+worker = { command = "bin/shop eval Shop.Worker.run", count = 3 }
+scheduler = "bin/shop eval Shop.Scheduler.run"
+`
+	if err := os.WriteFile("shipyard.toml", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("shipyard.toml")
+
+	AppConfig = Config{}
+	LoadConfig("", "shipyard.toml")
+
+	if got := AppConfig.StartCommand(); got != "bin/shop start" {
+		t.Errorf("StartCommand() = %q", got)
+	}
+	if worker := AppConfig.Processes["worker"]; worker.Command != "bin/shop eval Shop.Worker.run" || worker.Count != 3 {
+		t.Errorf("unexpected worker %+v", worker)
+	}
+	if scheduler := AppConfig.Processes["scheduler"]; scheduler.Count != 1 {
+		t.Errorf("expected a default count of 1, got %+v", scheduler)
+	}
+	if got := AppConfig.OtherProcesses(); len(got) != 2 || got[0] != "scheduler" || got[1] != "worker" {
+		t.Errorf("OtherProcesses() = %v", got)
+	}
+	if err := AppConfig.ValidateRun(); err != nil {
+		t.Errorf("expected valid processes, got %v", err)
+	}
+	if got := ProcessUnit("shop", "worker"); got != "shop-worker@" {
+		t.Errorf("ProcessUnit() = %q", got)
+	}
+	if got := ProcessUnit("shop", ProcessWeb); got != "shop@" {
+		t.Errorf("ProcessUnit(web) = %q", got)
+	}
+}
+
+func TestConfig_ValidateRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"no start command", Config{}, false},
+		{"custom with [run]", Config{Runtime: RuntimeCustom, Run: Run{Command: "./app"}}, false},
+		{"custom with web", Config{Runtime: RuntimeCustom, Processes: map[string]Process{"web": {Command: "./app", Count: 1}}}, false},
+		{"custom without start command", Config{Runtime: RuntimeCustom}, true},
+		{"worker stopped", Config{Processes: map[string]Process{"worker": {Command: "./worker", Count: 0}}}, false},
+		{"worker without command", Config{Processes: map[string]Process{"worker": {Count: 1}}}, true},
+		{"negative count", Config{Processes: map[string]Process{"worker": {Command: "./worker", Count: -1}}}, true},
+		{"invalid name", Config{Processes: map[string]Process{"Worker-1": {Command: "./worker", Count: 1}}}, true},
+		{"two web instances", Config{Processes: map[string]Process{"web": {Command: "./app", Count: 2}}}, true},
+		{"conflicting web command", Config{Run: Run{Command: "./a"}, Processes: map[string]Process{"web": {Command: "./b", Count: 1}}}, true},
+		{"scaled", Config{Scale: 4}, false},
+		{"negative scale", Config{Scale: -1}, true},
+		{"scale above limit", Config{Scale: MaxScale + 1}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.ValidateRun(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: ValidateRun() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadConfig_Cron(t *testing.T) {
+	content := `
+app = "shop"
+
+[[cron]]
+name = "nightly"
+schedule = "0 3 * * *"
+type = "eval"
+command = "Shop.Tasks.nightly()"
+timeout = "30m"
+
+[[cron]]
+name = "cleanup"
+schedule = "hourly"
+type = "shell"
+command = "bin/cleanup --older-than 7d"
+`
+	if err := os.WriteFile("shipyard.toml", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("shipyard.toml")
+
+	AppConfig = Config{}
+	LoadConfig("", "shipyard.toml")
+
+	if len(AppConfig.Cron) != 2 {
+		t.Fatalf("expected 2 cron jobs, got %+v", AppConfig.Cron)
+	}
+	if job := AppConfig.Cron[0]; job.Name != "nightly" || job.Type != "eval" || job.Timeout != 30*time.Minute {
+		t.Errorf("unexpected job %+v", job)
+	}
+	if err := AppConfig.ValidateCron(); err != nil {
+		t.Errorf("expected valid cron jobs, got %v", err)
+	}
+
+	AppConfig.Cron[1].Name = "nightly"
+	if err := AppConfig.ValidateCron(); err == nil {
+		t.Error("expected a duplicate job name to be refused")
+	}
+}
+
+func TestCron_OnCalendar(t *testing.T) {
+	tests := map[string]string{
+		"0 3 * * *":      "*-*-* 3:0:00",
+		"*/15 * * * *":   "*-*-* *:00/15:00",
+		"30 2 1 * *":     "*-*-1 2:30:00",
+		"0 9 * * 1-5":    "Mon..Fri *-*-* 9:0:00",
+		"0 0 * * 0,6":    "Sun,Sat *-*-* 0:0:00",
+		"0 */2 * * 7":    "Sun *-*-* 00/2:0:00",
+		"daily":          "daily",
+		"Mon *-*-* 4:00": "Mon *-*-* 4:00",
+	}
+	for schedule, want := range tests {
+		got, err := Cron{Name: "job", Schedule: schedule}.OnCalendar()
+		if err != nil || got != want {
+			t.Errorf("OnCalendar(%q) = %q, %v, want %q", schedule, got, err, want)
+		}
+	}
+	if _, err := (Cron{Name: "job", Schedule: "0 0 * * */2"}).OnCalendar(); err == nil {
+		t.Error("expected steps of the day of week to be refused")
+	}
+}
+
+func TestConfig_ValidateCron(t *testing.T) {
+	valid := Cron{Name: "nightly", Schedule: "daily", Type: "shell", Command: "bin/task"}
+	tests := []struct {
+		name    string
+		change  func(*Cron)
+		wantErr bool
+	}{
+		{"valid", func(*Cron) {}, false},
+		{"invalid name", func(c *Cron) { c.Name = "Nightly Job" }, true},
+		{"no command", func(c *Cron) { c.Command = "" }, true},
+		{"multi-line command", func(c *Cron) { c.Command = "a\nb" }, true},
+		{"no type", func(c *Cron) { c.Type = "" }, true},
+		{"no schedule", func(c *Cron) { c.Schedule = " " }, true},
+		{"negative timeout", func(c *Cron) { c.Timeout = -time.Second }, true},
+	}
+	for _, tt := range tests {
+		job := valid
+		tt.change(&job)
+		if err := (Config{Cron: []Cron{job}}).ValidateCron(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: ValidateCron() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuild_Validate(t *testing.T) {
+	tests := []struct {
+		build   Build
+		wantErr bool
+	}{
+		{Build{Location: BuildLocal}, false},
+		{Build{Location: BuildServer}, false},
+		{Build{Location: BuildHost, Host: "builder-1"}, false},
+		{Build{Location: BuildHost}, true},
+		{Build{Location: "laptop"}, true},
+		{Build{Location: BuildLocal, Builder: BuilderGo}, false},
+		{Build{Location: BuildServer, Builder: BuilderDocker}, false},
+		{Build{Location: BuildServer, Builder: BuilderMix}, true},
+		{Build{Location: BuildServer, Builder: BuilderCommand, Command: "make release"}, true},
+		{Build{Location: BuildLocal, Builder: "bazel"}, true},
+		{Build{Location: BuildHost, Host: "builder-1", Builder: BuilderTarball}, true},
+		{Build{Location: BuildLocal, Compression: "zstd"}, false},
+		{Build{Location: BuildLocal, Compression: "xz"}, true},
+		{Build{Location: BuildLocal, Builder: BuilderCommand, Command: "make release"}, false},
+		{Build{Location: BuildLocal, Builder: BuilderCommand}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.build.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.build, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHealthCheck_AcceptsStatusDefault(t *testing.T) {
+	var hc HealthCheck
+	for _, code := range []int{200, 204, 301, 399} {
+		if !hc.AcceptsStatus(code) {
+			t.Errorf("expected status %d to be accepted by default", code)
+		}
+	}
+	for _, code := range []int{0, 404, 500, 503} {
+		if hc.AcceptsStatus(code) {
+			t.Errorf("expected status %d to be rejected by default", code)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"encoding/base32"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -94,3 +95,13 @@ func GeneratePhoenixSecret() (string, error) {
 	}
 	return base64.StdEncoding.EncodeToString(randomBytes), nil
 }
+
+// GenerateReleaseCookie generates a 56-character Erlang distribution cookie for RELEASE_COOKIE.
+// Uppercase letters and digits only, like the cookies Erlang generates, so it needs no quoting.
+func GenerateReleaseCookie() (string, error) {
+	randomBytes := make([]byte, 35) // 35 bytes = 280 bits, which results in a 56-char Base32 string
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", fmt.Errorf("failed to generate RELEASE_COOKIE: %w", err)
+	}
+	return base32.StdEncoding.EncodeToString(randomBytes), nil
+}
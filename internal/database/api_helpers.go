@@ -139,15 +139,19 @@ func GetAllSecrets(appID uuid.UUID) (map[string]string, error) {
 
 // DeploymentHistoryRow represents a deployment history entry with host name and port
 type DeploymentHistoryRow struct {
-	ID          uuid.UUID `db:"id"`
-	InstanceID  uuid.UUID `db:"instance_id"`
-	Version     string    `db:"version"`
-	ReleasePath string    `db:"release_path"`
-	Status      string    `db:"status"`
-	Output      string    `db:"log_output"`
-	HostName    string    `db:"host_name"`
-	Port        int       `db:"port"`
-	CreatedAt   time.Time `db:"created_at"`
+	ID          uuid.UUID     `db:"id"`
+	InstanceID  uuid.UUID     `db:"instance_id"`
+	Version     string        `db:"version"`
+	ReleasePath string        `db:"release_path"`
+	Status      string        `db:"status"`
+	Output      string        `db:"log_output"`
+	HostName    string        `db:"host_name"`
+	Port        int           `db:"port"`
+	Kind        string        `db:"kind"`
+	RolloutID   uuid.NullUUID `db:"rollout_id"`
+	SigningKey  string        `db:"signing_key"`
+	SignedBy    string        `db:"signed_by"`
+	CreatedAt   time.Time     `db:"created_at"`
 }
 
 // GetDeploymentHistoryForApp retrieves deployment history for an application
@@ -156,7 +160,8 @@ func GetDeploymentHistoryForApp(appName string) ([]DeploymentHistoryRow, error)
 	query := Rebind(`
 		SELECT dh.id, dh.instance_id, dh.version, dh.release_path, dh.status, 
 		       COALESCE(dh.log_output, '') as log_output, h.name as host_name, 
-		       COALESCE(dh.port, 0) as port, dh.created_at
+		       COALESCE(dh.port, 0) as port, COALESCE(dh.kind, 'deploy') as kind, dh.rollout_id,
+		       COALESCE(dh.signing_key, '') as signing_key, COALESCE(dh.signed_by, '') as signed_by, dh.created_at
 		FROM deployment_history dh
 		JOIN application_instances ai ON dh.instance_id = ai.id
 		JOIN applications a ON ai.application_id = a.id
@@ -175,7 +180,8 @@ func GetDeploymentHistoryByID(id uuid.UUID) (*DeploymentHistoryRow, error) {
 	query := Rebind(`
 		SELECT dh.id, dh.instance_id, dh.version, dh.release_path, dh.status, 
 		       COALESCE(dh.log_output, '') as log_output, h.name as host_name, 
-		       COALESCE(dh.port, 0) as port, dh.created_at
+		       COALESCE(dh.port, 0) as port, COALESCE(dh.kind, 'deploy') as kind, dh.rollout_id,
+		       COALESCE(dh.signing_key, '') as signing_key, COALESCE(dh.signed_by, '') as signed_by, dh.created_at
 		FROM deployment_history dh
 		JOIN application_instances ai ON dh.instance_id = ai.id
 		JOIN ssh_hosts h ON ai.host_id = h.id
@@ -212,6 +218,23 @@ func CreateDeploymentHistoryWithStatus(instanceID uuid.UUID, version, status, ou
 	return history, err
 }
 
+// CreateRollbackHistory creates a pending deployment history entry marked as a rollback
+func CreateRollbackHistory(instanceID uuid.UUID, version, releasePath string) (*models.DeploymentHistory, error) {
+	now := time.Now()
+	history := &models.DeploymentHistory{
+		ID:          uuid.New(),
+		InstanceID:  instanceID,
+		Version:     version,
+		ReleasePath: releasePath,
+		Status:      models.DeploymentStatusPending,
+		Kind:        models.DeploymentKindRollback,
+		CreatedAt:   models.NullableTime{Time: &now},
+	}
+	query := `INSERT INTO deployment_history (id, instance_id, version, release_path, status, kind, created_at) VALUES (:id, :instance_id, :version, :release_path, :status, :kind, :created_at)`
+	_, err := DB.NamedExec(query, history)
+	return history, err
+}
+
 // AppendDeploymentHistoryOutput appends to the log output of a deployment
 func AppendDeploymentHistoryOutput(id uuid.UUID, output string) error {
 	query := Rebind("UPDATE deployment_history SET log_output = COALESCE(log_output, '') || ? WHERE id = ?")
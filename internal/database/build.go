@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"youfun/shipyard/internal/compression"
 	"youfun/shipyard/internal/models"
 	"time"
 
@@ -20,11 +21,26 @@ func AddBuildArtifact(artifact *models.BuildArtifact) error {
 	artifact.ID = uuid.New()
 	now := time.Now()
 	artifact.CreatedAt = models.NullableTime{Time: &now}
-	query := `INSERT INTO build_artifacts (id, application_id, version, git_commit_sha, md5_hash, local_path, created_at) VALUES (:id, :application_id, :version, :git_commit_sha, :md5_hash, :local_path, :created_at)`
+	artifact.Compression = compression.Normalize(artifact.Compression)
+	query := `INSERT INTO build_artifacts (id, application_id, version, git_commit_sha, md5_hash, local_path, stored, sha256, signature, signing_key, signed_by, compression, created_at) VALUES (:id, :application_id, :version, :git_commit_sha, :md5_hash, :local_path, :stored, :sha256, :signature, :signing_key, :signed_by, :compression, :created_at)`
 	_, err := DB.NamedExec(query, artifact)
 	return err
 }
 
+// MarkBuildArtifactStored records that a build artifact has been pushed to the artifact registry,
+// along with the signature it got there.
+func MarkBuildArtifactStored(artifact *models.BuildArtifact) error {
+	query := Rebind(`UPDATE build_artifacts SET stored = ?, sha256 = ?, signature = ?, signing_key = ?, signed_by = ? WHERE id = ?`)
+	_, err := DB.Exec(query, true, artifact.SHA256, artifact.Signature, artifact.SigningKey, artifact.SignedBy, artifact.ID)
+	return err
+}
+
+// DeleteBuildArtifact deletes a build artifact record.
+func DeleteBuildArtifact(id uuid.UUID) error {
+	_, err := DB.Exec(Rebind(`DELETE FROM build_artifacts WHERE id = ?`), id)
+	return err
+}
+
 // GetBuildArtifactByMD5 retrieves a build artifact by MD5 checksum.
 func GetBuildArtifactByMD5(appID uuid.UUID, md5Hash string) (*models.BuildArtifact, error) {
 	var artifact models.BuildArtifact
@@ -87,6 +103,66 @@ func GetAllBuildArtifactsForApp(appID uuid.UUID) ([]models.BuildArtifact, error)
 	return artifacts, nil
 }
 
+// GetBuildArtifactDeployments retrieves the deployments of an application that shipped the build artifact
+// with the given MD5, newest first. The artifact step of a deployment records the MD5 of its artifact.
+func GetBuildArtifactDeployments(appID uuid.UUID, md5Hash string) ([]DeploymentHistoryRow, error) {
+	var history []DeploymentHistoryRow
+	query := Rebind(`
+		SELECT dh.id, dh.instance_id, dh.version, dh.release_path, dh.status,
+		       '' as log_output, h.name as host_name,
+		       COALESCE(dh.port, 0) as port, COALESCE(dh.kind, 'deploy') as kind, dh.rollout_id,
+		       COALESCE(dh.signing_key, '') as signing_key, COALESCE(dh.signed_by, '') as signed_by, dh.created_at
+		FROM deployment_steps ds
+		JOIN deployment_history dh ON ds.deployment_id = dh.id
+		JOIN application_instances ai ON dh.instance_id = ai.id
+		JOIN ssh_hosts h ON ai.host_id = h.id
+		WHERE ai.application_id = ? AND ds.name = ? AND ds.status = ? AND ds.detail = ?
+		ORDER BY dh.created_at DESC
+	`)
+	err := DB.Select(&history, query, appID, models.DeployStepArtifact, models.StepStatusSuccess, md5Hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deployments of build artifact '%s': %w", md5Hash, err)
+	}
+	return history, nil
+}
+
+// GetBuildArtifactsLastDeployed returns when each build artifact of an application was last deployed
+// successfully, keyed by MD5. Builds never deployed are missing from the map.
+func GetBuildArtifactsLastDeployed(appID uuid.UUID) (map[string]time.Time, error) {
+	var rows []struct {
+		MD5Hash   string    `db:"md5_hash"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+	query := Rebind(`
+		SELECT ds.detail as md5_hash, dh.created_at
+		FROM deployment_steps ds
+		JOIN deployment_history dh ON ds.deployment_id = dh.id
+		JOIN application_instances ai ON dh.instance_id = ai.id
+		WHERE ai.application_id = ? AND ds.name = ? AND ds.status = ? AND dh.status = ?
+	`)
+	err := DB.Select(&rows, query, appID, models.DeployStepArtifact, models.StepStatusSuccess, models.DeploymentStatusSuccess)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deployments of application '%s': %w", appID, err)
+	}
+
+	lastDeployed := make(map[string]time.Time)
+	for _, row := range rows {
+		if row.CreatedAt.After(lastDeployed[row.MD5Hash]) {
+			lastDeployed[row.MD5Hash] = row.CreatedAt
+		}
+	}
+	return lastDeployed, nil
+}
+
+// GetArtifactDir returns the directory of the filesystem artifact registry of shipyard-server.
+func GetArtifactDir() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "artifacts"), nil
+}
+
 func GetBuildCacheDir() (string, error) {
 	configDir, err := getConfigDir()
 	if err != nil {
@@ -1,9 +1,14 @@
 package database
 
 import (
+	"errors"
+	"youfun/shipyard/internal/crypto"
 	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/signing"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -95,3 +100,538 @@ func TestDBType(t *testing.T) {
 		t.Errorf("Expected DBTypeSQLite, got %s", CurrentDBType)
 	}
 }
+
+func TestInstanceLock(t *testing.T) {
+	instanceID := uuid.New()
+	deploymentID := uuid.New()
+	expiresAt := time.Now().Add(time.Minute)
+
+	lock := &models.InstanceLock{
+		InstanceID: instanceID,
+		Kind:       models.LockKindDeploy,
+		Owner:      "alice",
+		ExpiresAt:  models.NullableTime{Time: &expiresAt},
+	}
+	if _, err := AcquireInstanceLock(lock); err != nil {
+		t.Fatalf("AcquireInstanceLock() failed: %v", err)
+	}
+	if err := SetInstanceLockDeployment(instanceID, deploymentID); err != nil {
+		t.Fatalf("SetInstanceLockDeployment() failed: %v", err)
+	}
+
+	// A second deploy is rejected and told who holds the lock
+	held, err := AcquireInstanceLock(&models.InstanceLock{InstanceID: instanceID, Kind: models.LockKindDeploy, Owner: "bob", ExpiresAt: models.NullableTime{Time: &expiresAt}})
+	if !errors.Is(err, ErrInstanceLocked) {
+		t.Fatalf("expected ErrInstanceLocked, got %v", err)
+	}
+	if held.Owner != "alice" || held.DeploymentID.UUID != deploymentID {
+		t.Errorf("unexpected lock holder: %+v", held)
+	}
+
+	if _, err := RenewInstanceLock(deploymentID, time.Minute); err != nil {
+		t.Fatalf("RenewInstanceLock() failed: %v", err)
+	}
+	if err := ReleaseInstanceLockForDeployment(deploymentID); err != nil {
+		t.Fatalf("ReleaseInstanceLockForDeployment() failed: %v", err)
+	}
+	if _, err := RenewInstanceLock(deploymentID, time.Minute); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("expected ErrLockNotHeld after release, got %v", err)
+	}
+	if current, err := GetInstanceLock(instanceID); err != nil || current != nil {
+		t.Errorf("expected instance to be unlocked, got %+v (err: %v)", current, err)
+	}
+}
+
+func TestInstanceLockExpiredLeaseIsTakenOver(t *testing.T) {
+	instanceID := uuid.New()
+	expired := time.Now().Add(-time.Second)
+	if _, err := AcquireInstanceLock(&models.InstanceLock{InstanceID: instanceID, Kind: models.LockKindDeploy, Owner: "alice", ExpiresAt: models.NullableTime{Time: &expired}}); err != nil {
+		t.Fatalf("AcquireInstanceLock() failed: %v", err)
+	}
+
+	// Manual locks have no lease and are held until released
+	lock, err := AcquireInstanceLock(&models.InstanceLock{InstanceID: instanceID, Kind: models.LockKindManual, Owner: "bob", Reason: "incident"})
+	if err != nil {
+		t.Fatalf("expected expired lease to be taken over, got %v", err)
+	}
+	if lock.Owner != "bob" {
+		t.Errorf("expected bob to hold the lock, got %s", lock.Owner)
+	}
+
+	current, err := GetInstanceLock(instanceID)
+	if err != nil || current == nil || current.Reason != "incident" || current.ExpiresAt.Time != nil {
+		t.Errorf("unexpected manual lock: %+v (err: %v)", current, err)
+	}
+}
+
+func TestReleaseInstanceLockIfHeld(t *testing.T) {
+	instanceID := uuid.New()
+	expired := time.Now().Add(-time.Second)
+	stale, err := AcquireInstanceLock(&models.InstanceLock{InstanceID: instanceID, Kind: models.LockKindRollback, Owner: "alice", ExpiresAt: models.NullableTime{Time: &expired}})
+	if err != nil {
+		t.Fatalf("AcquireInstanceLock() failed: %v", err)
+	}
+	expiresAt := time.Now().Add(time.Minute)
+	lock, err := AcquireInstanceLock(&models.InstanceLock{InstanceID: instanceID, Kind: models.LockKindDeploy, Owner: "bob", ExpiresAt: models.NullableTime{Time: &expiresAt}})
+	if err != nil {
+		t.Fatalf("expected expired lease to be taken over, got %v", err)
+	}
+	if lock.ID == uuid.Nil || lock.ID == stale.ID {
+		t.Fatalf("expected the lock taken over to get a new ID, got %s", lock.ID)
+	}
+
+	// The holder whose lease expired does not release the lock taken over
+	if err := ReleaseInstanceLockIfHeld(instanceID, stale.ID); err != nil {
+		t.Fatalf("ReleaseInstanceLockIfHeld() failed: %v", err)
+	}
+	if current, err := GetInstanceLock(instanceID); err != nil || current == nil || current.ID != lock.ID {
+		t.Fatalf("expected bob to keep the lock, got %+v (err: %v)", current, err)
+	}
+
+	if err := ReleaseInstanceLockIfHeld(instanceID, lock.ID); err != nil {
+		t.Fatalf("ReleaseInstanceLockIfHeld() failed: %v", err)
+	}
+	if current, err := GetInstanceLock(instanceID); err != nil || current != nil {
+		t.Errorf("expected instance to be unlocked, got %+v (err: %v)", current, err)
+	}
+}
+
+func TestRollout(t *testing.T) {
+	appID := uuid.New()
+	rollout := &models.Rollout{
+		ApplicationID: appID,
+		Version:       "1.2.0",
+		Hosts:         "web-1,web-2",
+		Parallel:      1,
+		Status:        models.RolloutStatusRunning,
+		CreatedBy:     "alice",
+	}
+	if err := CreateRollout(rollout); err != nil {
+		t.Fatalf("CreateRollout() failed: %v", err)
+	}
+	if err := FinishRollout(rollout.ID, models.RolloutStatusFailed); err != nil {
+		t.Fatalf("FinishRollout() failed: %v", err)
+	}
+
+	got, err := GetRolloutByID(rollout.ID)
+	if err != nil {
+		t.Fatalf("GetRolloutByID() failed: %v", err)
+	}
+	if got.Status != models.RolloutStatusFailed || got.Hosts != "web-1,web-2" || got.FinishedAt.Time == nil {
+		t.Errorf("unexpected rollout: %+v", got)
+	}
+
+	rollouts, err := GetRolloutsForApp(appID, 10)
+	if err != nil {
+		t.Fatalf("GetRolloutsForApp() failed: %v", err)
+	}
+	if len(rollouts) != 1 || rollouts[0].ID != rollout.ID {
+		t.Errorf("expected the rollout to be listed for its app, got %+v", rollouts)
+	}
+}
+
+func TestInstanceCanary(t *testing.T) {
+	instanceID := uuid.New()
+	if canary, err := GetInstanceCanary(instanceID); err != nil || canary != nil {
+		t.Fatalf("expected no canary, got %+v, %v", canary, err)
+	}
+
+	canary := &models.InstanceCanary{
+		InstanceID:   instanceID,
+		DeploymentID: uuid.New(),
+		StablePort:   8001,
+		CanaryPort:   8002,
+		CanaryWeight: 10,
+		ReleasePath:  "/var/www/my_app/releases/1.2.0-1",
+	}
+	if err := StartInstanceCanary(canary); err != nil {
+		t.Fatalf("StartInstanceCanary() failed: %v", err)
+	}
+	if err := UpdateInstanceCanaryWeight(instanceID, 50); err != nil {
+		t.Fatalf("UpdateInstanceCanaryWeight() failed: %v", err)
+	}
+
+	got, err := GetInstanceCanary(instanceID)
+	if err != nil || got == nil {
+		t.Fatalf("GetInstanceCanary() failed: %v", err)
+	}
+	if got.CanaryWeight != 50 || got.StablePort != 8001 || got.CanaryPort != 8002 || got.DeploymentID != canary.DeploymentID {
+		t.Errorf("unexpected canary: %+v", got)
+	}
+
+	if err := DeleteInstanceCanary(instanceID); err != nil {
+		t.Fatalf("DeleteInstanceCanary() failed: %v", err)
+	}
+	if got, _ := GetInstanceCanary(instanceID); got != nil {
+		t.Errorf("expected canary to be removed, got %+v", got)
+	}
+}
+
+func TestReleasePins(t *testing.T) {
+	instanceID := uuid.New()
+	path := "/var/www/my_app/releases/1.2.0-1"
+
+	if err := PinRelease(instanceID, path); err != nil {
+		t.Fatalf("PinRelease() failed: %v", err)
+	}
+	// Pinning twice keeps a single pin
+	if err := PinRelease(instanceID, path); err != nil {
+		t.Fatalf("PinRelease() failed: %v", err)
+	}
+	pinned, err := GetPinnedReleases(instanceID)
+	if err != nil {
+		t.Fatalf("GetPinnedReleases() failed: %v", err)
+	}
+	if len(pinned) != 1 || !pinned[path] {
+		t.Errorf("expected %s to be pinned, got %v", path, pinned)
+	}
+
+	if err := UnpinRelease(instanceID, path); err != nil {
+		t.Fatalf("UnpinRelease() failed: %v", err)
+	}
+	if pinned, _ := GetPinnedReleases(instanceID); len(pinned) != 0 {
+		t.Errorf("expected no pinned releases, got %v", pinned)
+	}
+}
+
+func TestMarkReleasePruned(t *testing.T) {
+	instanceID := uuid.New()
+	path := "/var/www/my_app/releases/1.2.0-1"
+	for _, port := range []int{8001, 8002} {
+		run := &models.DeploymentInstance{ApplicationInstanceID: instanceID, Version: "1.2.0", ReleasePath: path, Port: port, Status: "standby"}
+		if err := AddDeploymentInstance(run); err != nil {
+			t.Fatalf("AddDeploymentInstance() failed: %v", err)
+		}
+	}
+	other := &models.DeploymentInstance{ApplicationInstanceID: instanceID, Version: "1.3.0", ReleasePath: "/var/www/my_app/releases/1.3.0-2", Port: 8003, Status: "running"}
+	if err := AddDeploymentInstance(other); err != nil {
+		t.Fatalf("AddDeploymentInstance() failed: %v", err)
+	}
+
+	if err := MarkReleasePruned(instanceID, path); err != nil {
+		t.Fatalf("MarkReleasePruned() failed: %v", err)
+	}
+
+	runs, err := GetDeploymentHistoryForInstance(instanceID, 10)
+	if err != nil {
+		t.Fatalf("GetDeploymentHistoryForInstance() failed: %v", err)
+	}
+	for _, run := range runs {
+		want := "pruned"
+		if run.ReleasePath != path {
+			want = "running"
+		}
+		if run.Status != want {
+			t.Errorf("expected run on port %d to be %s, got %s", run.Port, want, run.Status)
+		}
+	}
+}
+
+func TestRecordSuccessfulDeploymentPorts(t *testing.T) {
+	instance := &models.ApplicationInstance{ApplicationID: uuid.New(), HostID: uuid.New(), Status: "running"}
+	if err := LinkApplicationToHost(instance); err != nil {
+		t.Fatalf("LinkApplicationToHost() failed: %v", err)
+	}
+
+	first, err := CreateDeploymentHistoryWithStatus(instance.ID, "1.0.0", "running", "")
+	if err != nil {
+		t.Fatalf("CreateDeploymentHistoryWithStatus() failed: %v", err)
+	}
+	if err := RecordSuccessfulDeployment(first.ID, []int{8001, 8002}, "/var/www/my_app/releases/1.0.0-1", ""); err != nil {
+		t.Fatalf("RecordSuccessfulDeployment() failed: %v", err)
+	}
+	if ports, err := GetActiveInstancePorts(instance.ID); err != nil || len(ports) != 2 || ports[0] != 8001 || ports[1] != 8002 {
+		t.Fatalf("expected ports 8001 and 8002 to be active, got %v, %v", ports, err)
+	}
+
+	second, err := CreateDeploymentHistoryWithStatus(instance.ID, "1.1.0", "running", "")
+	if err != nil {
+		t.Fatalf("CreateDeploymentHistoryWithStatus() failed: %v", err)
+	}
+	if err := RecordSuccessfulDeployment(second.ID, []int{8005, 8003, 8004}, "/var/www/my_app/releases/1.1.0-2", ""); err != nil {
+		t.Fatalf("RecordSuccessfulDeployment() failed: %v", err)
+	}
+	if ports, _ := GetActiveInstancePorts(instance.ID); len(ports) != 3 || ports[0] != 8003 {
+		t.Errorf("expected ports 8003 to 8005 to be active, got %v", ports)
+	}
+
+	got, err := GetApplicationInstanceByID(instance.ID)
+	if err != nil {
+		t.Fatalf("GetApplicationInstanceByID() failed: %v", err)
+	}
+	if got.ActivePort.Int64 != 8005 || got.PreviousActivePort.Int64 != 8001 {
+		t.Errorf("expected active port 8005 and previous port 8001, got %d and %d", got.ActivePort.Int64, got.PreviousActivePort.Int64)
+	}
+	runs, _ := GetDeploymentHistoryForInstance(instance.ID, 10)
+	for _, run := range runs {
+		if run.Port <= 8002 && run.Status != "standby" {
+			t.Errorf("expected run on port %d to be standby, got %s", run.Port, run.Status)
+		}
+	}
+}
+
+func TestRecordSuccessfulDeploymentTwice(t *testing.T) {
+	instance := &models.ApplicationInstance{ApplicationID: uuid.New(), HostID: uuid.New(), Status: "running"}
+	if err := LinkApplicationToHost(instance); err != nil {
+		t.Fatalf("LinkApplicationToHost() failed: %v", err)
+	}
+
+	first, _ := CreateDeploymentHistoryWithStatus(instance.ID, "1.0.0", "running", "")
+	if err := RecordSuccessfulDeployment(first.ID, []int{8001}, "/var/www/my_app/releases/1.0.0-1", ""); err != nil {
+		t.Fatalf("RecordSuccessfulDeployment() failed: %v", err)
+	}
+	second, _ := CreateDeploymentHistoryWithStatus(instance.ID, "1.1.0", "running", "")
+	for i := 0; i < 2; i++ {
+		if err := RecordSuccessfulDeployment(second.ID, []int{8002, 8003}, "/var/www/my_app/releases/1.1.0-2", ""); err != nil {
+			t.Fatalf("RecordSuccessfulDeployment() failed: %v", err)
+		}
+	}
+
+	if ports, _ := GetActiveInstancePorts(instance.ID); len(ports) != 2 || ports[0] != 8002 || ports[1] != 8003 {
+		t.Errorf("expected ports 8002 and 8003 to stay active, got %v", ports)
+	}
+	got, err := GetApplicationInstanceByID(instance.ID)
+	if err != nil {
+		t.Fatalf("GetApplicationInstanceByID() failed: %v", err)
+	}
+	if got.ActivePort.Int64 != 8002 || got.PreviousActivePort.Int64 != 8001 {
+		t.Errorf("expected active port 8002 and previous port 8001, got %d and %d", got.ActivePort.Int64, got.PreviousActivePort.Int64)
+	}
+	runs, _ := GetDeploymentHistoryForInstance(instance.ID, 10)
+	if len(runs) != 3 {
+		t.Errorf("expected 3 runs, got %d", len(runs))
+	}
+}
+
+func TestGetActivePeersForApp(t *testing.T) {
+	appID := uuid.New()
+	var instances []*models.ApplicationInstance
+	for _, addr := range []string{"10.0.0.2", "10.0.0.1"} {
+		host := &models.SSHHost{ID: uuid.New(), Name: "peer-" + addr, Addr: addr, Port: 22, User: "root"}
+		if err := AddSSHHost(host); err != nil {
+			t.Fatalf("AddSSHHost() failed: %v", err)
+		}
+		instance := &models.ApplicationInstance{ApplicationID: appID, HostID: host.ID, Status: "running"}
+		if err := LinkApplicationToHost(instance); err != nil {
+			t.Fatalf("LinkApplicationToHost() failed: %v", err)
+		}
+		instances = append(instances, instance)
+	}
+
+	for i, ports := range [][]int{{4001, 4002}, {4005}} {
+		history, err := CreateDeploymentHistoryWithStatus(instances[i].ID, "1.0.0", "running", "")
+		if err != nil {
+			t.Fatalf("CreateDeploymentHistoryWithStatus() failed: %v", err)
+		}
+		if err := RecordSuccessfulDeployment(history.ID, ports, "/var/www/my_app/releases/1.0.0-1", ""); err != nil {
+			t.Fatalf("RecordSuccessfulDeployment() failed: %v", err)
+		}
+	}
+	// The second deployment on the first host leaves its first instances on standby
+	history, _ := CreateDeploymentHistoryWithStatus(instances[0].ID, "1.1.0", "running", "")
+	if err := RecordSuccessfulDeployment(history.ID, []int{4003}, "/var/www/my_app/releases/1.1.0-2", ""); err != nil {
+		t.Fatalf("RecordSuccessfulDeployment() failed: %v", err)
+	}
+
+	peers, err := GetActivePeersForApp(appID)
+	if err != nil {
+		t.Fatalf("GetActivePeersForApp() failed: %v", err)
+	}
+	want := []ClusterPeer{{HostAddr: "10.0.0.1", Port: 4005}, {HostAddr: "10.0.0.2", Port: 4003}}
+	if len(peers) != len(want) || peers[0] != want[0] || peers[1] != want[1] {
+		t.Errorf("GetActivePeersForApp() = %v, want %v", peers, want)
+	}
+}
+
+func TestEnsureSecret(t *testing.T) {
+	if err := crypto.Init(strings.Repeat("ab", 32)); err != nil {
+		t.Fatalf("crypto.Init() failed: %v", err)
+	}
+	appID := uuid.New()
+
+	value, err := EnsureSecret(appID, "RELEASE_COOKIE", "first")
+	if err != nil || value != "first" {
+		t.Fatalf("EnsureSecret() = %q, %v, want the new value", value, err)
+	}
+	if value, err := EnsureSecret(appID, "RELEASE_COOKIE", "second"); err != nil || value != "first" {
+		t.Errorf("EnsureSecret() = %q, %v, want the kept value", value, err)
+	}
+	if err := SetSecret(appID, "RELEASE_COOKIE", "rotated"); err != nil {
+		t.Fatalf("SetSecret() failed: %v", err)
+	}
+	if value, _ := EnsureSecret(appID, "RELEASE_COOKIE", "third"); value != "rotated" {
+		t.Errorf("EnsureSecret() = %q, want the value set by the user", value)
+	}
+}
+
+func TestDeploymentSteps(t *testing.T) {
+	instanceID := uuid.New()
+	history, err := CreateDeploymentHistoryWithStatus(instanceID, "1.2.0", "pending", "")
+	if err != nil {
+		t.Fatalf("CreateDeploymentHistoryWithStatus() failed: %v", err)
+	}
+
+	now := time.Now()
+	steps := []*models.DeploymentStep{
+		{DeploymentID: history.ID, Name: models.DeployStepUpload, Position: 2, Status: models.StepStatusRunning, StartedAt: models.NullableTime{Time: &now}},
+		{DeploymentID: history.ID, Name: models.DeployStepArtifact, Position: 1, Status: models.StepStatusSuccess, Detail: "abc123"},
+		// Replaces the running state of the upload step
+		{DeploymentID: history.ID, Name: models.DeployStepUpload, Position: 2, Status: models.StepStatusFailed, Error: "connection reset", DurationMs: 1500},
+	}
+	for _, step := range steps {
+		if err := SaveDeploymentStep(step); err != nil {
+			t.Fatalf("SaveDeploymentStep() failed: %v", err)
+		}
+	}
+
+	got, err := GetDeploymentSteps(history.ID)
+	if err != nil {
+		t.Fatalf("GetDeploymentSteps() failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 steps, got %+v", got)
+	}
+	if got[0].Name != models.DeployStepArtifact || got[0].Detail != "abc123" {
+		t.Errorf("expected the artifact step first, got %+v", got[0])
+	}
+	if got[1].Status != models.StepStatusFailed || got[1].Error != "connection reset" || got[1].DurationMs != 1500 {
+		t.Errorf("expected the failed upload step, got %+v", got[1])
+	}
+
+	if newer, err := HasNewerDeployment(history.ID); err != nil || newer {
+		t.Errorf("expected no newer deployment, got %v (err: %v)", newer, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := CreateDeploymentHistoryWithStatus(instanceID, "1.3.0", "pending", ""); err != nil {
+		t.Fatalf("CreateDeploymentHistoryWithStatus() failed: %v", err)
+	}
+	if newer, err := HasNewerDeployment(history.ID); err != nil || !newer {
+		t.Errorf("expected a newer deployment, got %v (err: %v)", newer, err)
+	}
+}
+
+func TestBuildArtifactDeployments(t *testing.T) {
+	host := &models.SSHHost{ID: uuid.New(), Name: "artifact-host", Addr: "localhost", Port: 22, User: "tester"}
+	if err := AddSSHHost(host); err != nil {
+		t.Fatalf("AddSSHHost() failed: %v", err)
+	}
+	app := &models.Application{Name: "artifact-app"}
+	if err := AddApplication(app); err != nil {
+		t.Fatalf("AddApplication() failed: %v", err)
+	}
+	instance := &models.ApplicationInstance{ApplicationID: app.ID, HostID: host.ID, Status: "active"}
+	if err := LinkApplicationToHost(instance); err != nil {
+		t.Fatalf("LinkApplicationToHost() failed: %v", err)
+	}
+
+	deploy := func(version, md5Hash string, status models.DeploymentStatus) {
+		t.Helper()
+		history, err := CreateDeploymentHistoryWithStatus(instance.ID, version, string(status), "")
+		if err != nil {
+			t.Fatalf("CreateDeploymentHistoryWithStatus() failed: %v", err)
+		}
+		step := &models.DeploymentStep{DeploymentID: history.ID, Name: models.DeployStepArtifact, Position: 1, Status: models.StepStatusSuccess, Detail: md5Hash}
+		if err := SaveDeploymentStep(step); err != nil {
+			t.Fatalf("SaveDeploymentStep() failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	deploy("1.0.0", "md5-1.0.0", models.DeploymentStatusSuccess)
+	deploy("1.1.0", "md5-1.1.0", models.DeploymentStatusFailed)
+	deploy("1.0.0", "md5-1.0.0", models.DeploymentStatusSuccess)
+
+	deployments, err := GetBuildArtifactDeployments(app.ID, "md5-1.0.0")
+	if err != nil {
+		t.Fatalf("GetBuildArtifactDeployments() failed: %v", err)
+	}
+	if len(deployments) != 2 || deployments[0].HostName != "artifact-host" || !deployments[0].CreatedAt.After(deployments[1].CreatedAt) {
+		t.Fatalf("expected the two deployments of 1.0.0, newest first, got %+v", deployments)
+	}
+
+	lastDeployed, err := GetBuildArtifactsLastDeployed(app.ID)
+	if err != nil {
+		t.Fatalf("GetBuildArtifactsLastDeployed() failed: %v", err)
+	}
+	// The failed deployment of 1.1.0 does not count
+	if len(lastDeployed) != 1 || !lastDeployed["md5-1.0.0"].Equal(deployments[0].CreatedAt) {
+		t.Errorf("expected 1.0.0 last deployed at %v, got %v", deployments[0].CreatedAt, lastDeployed)
+	}
+}
+
+func TestBuildArtifactStored(t *testing.T) {
+	appID := uuid.New()
+	artifact := &models.BuildArtifact{ApplicationID: appID, Version: "1.2.0", GitCommitSHA: "abc123", MD5Hash: "0123456789abcdef0123456789abcdef", LocalPath: "/home/dev/.shipyard/build_cache/my_app.tar.gz"}
+	if err := AddBuildArtifact(artifact); err != nil {
+		t.Fatalf("AddBuildArtifact() failed: %v", err)
+	}
+	if got, err := GetBuildArtifactByMD5(appID, artifact.MD5Hash); err != nil || got.Stored {
+		t.Fatalf("expected a registered artifact not in the registry, got %+v (err: %v)", got, err)
+	}
+
+	artifact.SHA256, artifact.Signature, artifact.SigningKey, artifact.SignedBy = "e3b0c442", "c2lnbmF0dXJl", "default", "alice"
+	if err := MarkBuildArtifactStored(artifact); err != nil {
+		t.Fatalf("MarkBuildArtifactStored() failed: %v", err)
+	}
+	if got, err := GetBuildArtifactByMD5(appID, artifact.MD5Hash); err != nil || !got.Stored || got.Signature != artifact.Signature || got.SignedBy != "alice" {
+		t.Fatalf("expected the artifact to be stored with its signature, got %+v (err: %v)", got, err)
+	}
+
+	if err := DeleteBuildArtifact(artifact.ID); err != nil {
+		t.Fatalf("DeleteBuildArtifact() failed: %v", err)
+	}
+	if _, err := GetBuildArtifactByMD5(appID, artifact.MD5Hash); err == nil {
+		t.Error("expected the artifact to be deleted")
+	}
+}
+
+func TestSigningKeys(t *testing.T) {
+	key, priv, err := GetActiveSigningKey()
+	if err != nil {
+		t.Fatalf("GetActiveSigningKey() failed: %v", err)
+	}
+	if key.Name != DefaultSigningKeyName {
+		t.Errorf("expected the default key to be created on first use, got %q", key.Name)
+	}
+	if again, _, err := GetActiveSigningKey(); err != nil || again.ID != key.ID {
+		t.Fatalf("expected the same key to be returned, got %+v (err: %v)", again, err)
+	}
+
+	appID := uuid.New()
+	sum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	sig := signing.Sign(priv, appID.String(), sum)
+
+	// Without keys of its own, an application trusts the keys of the server
+	trusted, err := GetTrustedSigningKeys(appID)
+	if err != nil {
+		t.Fatalf("GetTrustedSigningKeys() failed: %v", err)
+	}
+	if name, err := signing.Verify(trusted, appID.String(), sum, sig); err != nil || name != DefaultSigningKeyName {
+		t.Fatalf("expected the server key to be trusted, got %q (err: %v)", name, err)
+	}
+
+	if _, err := AddTrustedKey(appID, "bad", "ed25519:AAAA"); err == nil {
+		t.Error("expected an invalid public key to be refused")
+	}
+	other, err := CreateSigningKey("release")
+	if err != nil {
+		t.Fatalf("CreateSigningKey() failed: %v", err)
+	}
+	trustedKey, err := AddTrustedKey(appID, "release", other.PublicKey)
+	if err != nil {
+		t.Fatalf("AddTrustedKey() failed: %v", err)
+	}
+	trusted, err = GetTrustedSigningKeys(appID)
+	if err != nil {
+		t.Fatalf("GetTrustedSigningKeys() failed: %v", err)
+	}
+	if _, err := signing.Verify(trusted, appID.String(), sum, sig); !errors.Is(err, signing.ErrUntrusted) {
+		t.Errorf("expected only the keys of the application to be trusted, got %v", err)
+	}
+
+	if err := DeleteTrustedKey(trustedKey.ID, appID); err != nil {
+		t.Fatalf("DeleteTrustedKey() failed: %v", err)
+	}
+	if err := DeleteTrustedKey(trustedKey.ID, appID); err == nil {
+		t.Error("expected deleting a missing key to fail")
+	}
+}
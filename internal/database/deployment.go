@@ -92,6 +92,36 @@ func GetLatestDeploymentInstanceByPort(appInstanceID uuid.UUID, port int) (*mode
 	return &run, err
 }
 
+// GetActiveInstancePorts returns the ports of the runs of an application instance that serve traffic, in
+// ascending order.
+func GetActiveInstancePorts(instanceID uuid.UUID) ([]int, error) {
+	var ports []int
+	query := Rebind(`SELECT DISTINCT port FROM deployment_instances WHERE application_instance_id = ? AND status = 'active' ORDER BY port`)
+	err := DB.Select(&ports, query, instanceID)
+	return ports, err
+}
+
+// ClusterPeer is an instance serving traffic on one of the hosts of an application.
+type ClusterPeer struct {
+	HostAddr string `db:"addr"`
+	Port     int    `db:"port"`
+}
+
+// GetActivePeersForApp returns the instances of an application that serve traffic, on all its hosts.
+func GetActivePeersForApp(appID uuid.UUID) ([]ClusterPeer, error) {
+	var peers []ClusterPeer
+	query := Rebind(`
+		SELECT DISTINCT h.addr, di.port
+		FROM deployment_instances di
+		JOIN application_instances ai ON di.application_instance_id = ai.id
+		JOIN ssh_hosts h ON ai.host_id = h.id
+		WHERE ai.application_id = ? AND di.status = 'active'
+		ORDER BY h.addr, di.port
+	`)
+	err := DB.Select(&peers, query, appID)
+	return peers, err
+}
+
 // GetStaleDeploymentInstances finds instances that are not active or standby, so they can be cleaned up.
 func GetStaleDeploymentInstances(instanceID uuid.UUID, activePort int, standbyPort int) ([]models.DeploymentInstance, error) {
 	var instances []models.DeploymentInstance
@@ -9,14 +9,26 @@ import (
 )
 
 // RecordSuccessfulDeployment handles the complex logic of recording a successful deployment.
-// It updates deployment_history, creates a deployment_instance, and updates application_instance ports.
-func RecordSuccessfulDeployment(deploymentID uuid.UUID, port int, releasePath string, gitCommitSHA string) error {
+// It updates deployment_history, creates a deployment_instance per port, and updates application_instance ports.
+// The first port is the active port of the instance, the others serve traffic next to it.
+// Recording a deployment that already succeeded with the same release is a no-op.
+func RecordSuccessfulDeployment(deploymentID uuid.UUID, ports []int, releasePath string, gitCommitSHA string) error {
+	if len(ports) == 0 {
+		return fmt.Errorf("no port to record for deployment %s", deploymentID)
+	}
+	port := ports[0]
+
 	// 1. Get the deployment history record to know instance_id and version
 	var history models.DeploymentHistory
 	err := DB.Get(&history, "SELECT * FROM deployment_history WHERE id = ?", deploymentID)
 	if err != nil {
 		return fmt.Errorf("failed to find deployment history %s: %w", deploymentID, err)
 	}
+	// Already recorded, e.g. reported again by a resumed deployment: recording it twice would
+	// add its runs again and make its own active port the previous one
+	if history.Status == models.DeploymentStatusSuccess && history.ReleasePath == releasePath {
+		return nil
+	}
 
 	// 2. Start a transaction
 	tx, err := DB.Beginx()
@@ -33,25 +45,28 @@ func RecordSuccessfulDeployment(deploymentID uuid.UUID, port int, releasePath st
 		return fmt.Errorf("failed to update deployment history: %w", err)
 	}
 
-	// 4. Create Deployment Instance
+	// 4. Create Deployment Instances, one per port
 	// Use the version from history, but allow release_path and git_commit_sha from CLI
-	runID := uuid.New()
+	runIDs := make([]uuid.UUID, len(ports))
 	queryInstance := Rebind(`INSERT INTO deployment_instances 
 		(id, application_instance_id, version, git_commit_sha, release_path, port, status, started_at, created_at)
 		VALUES (?, ?, ?, ?, ?, ?, 'running', ?, ?)`)
 
-	_, err = tx.Exec(queryInstance,
-		runID,
-		history.InstanceID,
-		history.Version,
-		gitCommitSHA,
-		releasePath,
-		port,
-		now,
-		now,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create deployment instance: %w", err)
+	for i, p := range ports {
+		runIDs[i] = uuid.New()
+		_, err = tx.Exec(queryInstance,
+			runIDs[i],
+			history.InstanceID,
+			history.Version,
+			gitCommitSHA,
+			releasePath,
+			p,
+			now,
+			now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create deployment instance: %w", err)
+		}
 	}
 
 	// 5. Get current active port (to be previous active port)
@@ -73,7 +88,7 @@ func RecordSuccessfulDeployment(deploymentID uuid.UUID, port int, releasePath st
 		return fmt.Errorf("failed to update application instance ports: %w", err)
 	}
 
-	// 7. Update status of the NEW deployment instance to 'active'
+	// 7. Update status of the NEW deployment instances to 'active'
 	// Wait, deployment_instances status logic:
 	// 'running' -> initially created
 	// 'active' -> when it's taking traffic (which is now)
@@ -81,9 +96,16 @@ func RecordSuccessfulDeployment(deploymentID uuid.UUID, port int, releasePath st
 	// Let's update it to match legacy logic (AddDeploymentInstance sets 'running', then later Update to 'active').
 	// But here we are doing it all at once when success is reported. So 'active' is appropriate.
 	// Actually, the INSERT above set it to 'running'. Let's update it to 'active'.
+	// The runs of the replaced release that still count as serving traffic become its standby.
+	queryReplaced := Rebind("UPDATE deployment_instances SET status = 'standby' WHERE application_instance_id = ? AND status = 'active'")
+	if _, err := tx.Exec(queryReplaced, history.InstanceID); err != nil {
+		return fmt.Errorf("failed to mark replaced instances as standby: %w", err)
+	}
 	queryUpdateStatus := Rebind("UPDATE deployment_instances SET status = 'active' WHERE id = ?")
-	if _, err := tx.Exec(queryUpdateStatus, runID); err != nil {
-		return fmt.Errorf("failed to mark new instance as active: %w", err)
+	for _, runID := range runIDs {
+		if _, err := tx.Exec(queryUpdateStatus, runID); err != nil {
+			return fmt.Errorf("failed to mark new instance as active: %w", err)
+		}
 	}
 
 	// 8. Mark old instance as standby (if any)
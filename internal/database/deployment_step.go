@@ -0,0 +1,55 @@
+package database
+
+import (
+	"youfun/shipyard/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// --- deployment_steps Table Operations ---
+
+// SaveDeploymentStep records the state of a deployment step, replacing the previous state of the step.
+func SaveDeploymentStep(step *models.DeploymentStep) error {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(Rebind("DELETE FROM deployment_steps WHERE deployment_id = ? AND name = ?"), step.DeploymentID, step.Name); err != nil {
+		return err
+	}
+	query := `INSERT INTO deployment_steps (deployment_id, name, position, status, detail, error, started_at, finished_at, duration_ms)
+	          VALUES (:deployment_id, :name, :position, :status, :detail, :error, :started_at, :finished_at, :duration_ms)`
+	if _, err := tx.NamedExec(query, step); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetDeploymentSteps retrieves the recorded steps of a deployment in the order they run.
+func GetDeploymentSteps(deploymentID uuid.UUID) ([]models.DeploymentStep, error) {
+	var steps []models.DeploymentStep
+	query := Rebind(`
+		SELECT deployment_id, name, position, status, COALESCE(detail, '') as detail, COALESCE(error, '') as error,
+		       started_at, finished_at, duration_ms
+		FROM deployment_steps
+		WHERE deployment_id = ?
+		ORDER BY position ASC
+	`)
+	err := DB.Select(&steps, query, deploymentID)
+	return steps, err
+}
+
+// HasNewerDeployment reports whether a deployment or rollback of the same instance was created after the given deployment.
+func HasNewerDeployment(deploymentID uuid.UUID) (bool, error) {
+	var count int
+	query := Rebind(`
+		SELECT COUNT(*)
+		FROM deployment_history newer
+		JOIN deployment_history d ON d.instance_id = newer.instance_id
+		WHERE d.id = ? AND newer.id != d.id AND newer.created_at > d.created_at
+	`)
+	err := DB.Get(&count, query, deploymentID)
+	return count > 0, err
+}
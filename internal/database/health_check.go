@@ -0,0 +1,54 @@
+package database
+
+import (
+	"youfun/shipyard/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- deployment_health_checks Table Operations ---
+
+// AddDeploymentHealthChecks records the readiness probe results of a deployment.
+func AddDeploymentHealthChecks(deploymentID uuid.UUID, results []models.HealthCheckResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	tx, err := DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO deployment_health_checks (id, deployment_id, attempt, port, url, status_code, passed, message, duration_ms, created_at)
+	          VALUES (:id, :deployment_id, :attempt, :port, :url, :status_code, :passed, :message, :duration_ms, :created_at)`
+	for i := range results {
+		r := &results[i]
+		r.ID = uuid.New()
+		r.DeploymentID = deploymentID
+		if r.CreatedAt.Time == nil {
+			now := time.Now()
+			r.CreatedAt = models.NullableTime{Time: &now}
+		}
+		if _, err := tx.NamedExec(query, r); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetHealthChecksForDeployment retrieves the readiness probe results of a deployment, oldest first.
+func GetHealthChecksForDeployment(deploymentID uuid.UUID) ([]models.HealthCheckResult, error) {
+	var results []models.HealthCheckResult
+	query := Rebind(`
+		SELECT id, deployment_id, attempt, port, url, status_code, passed,
+		       COALESCE(message, '') as message, duration_ms, created_at
+		FROM deployment_health_checks
+		WHERE deployment_id = ?
+		ORDER BY attempt ASC
+	`)
+	err := DB.Select(&results, query, deploymentID)
+	return results, err
+}
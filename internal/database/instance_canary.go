@@ -0,0 +1,66 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"youfun/shipyard/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- instance_canaries Table Operations ---
+
+const instanceCanarySelect = `SELECT instance_id, deployment_id, stable_port, canary_port, canary_weight, release_path,
+	COALESCE(git_commit_sha, '') as git_commit_sha, started_at, updated_at FROM instance_canaries`
+
+// StartInstanceCanary records the canary of an instance, replacing any previous one.
+func StartInstanceCanary(canary *models.InstanceCanary) error {
+	now := time.Now()
+	canary.StartedAt = models.NullableTime{Time: &now}
+	canary.UpdatedAt = models.NullableTime{Time: &now}
+
+	tx, err := DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(Rebind("DELETE FROM instance_canaries WHERE instance_id = ?"), canary.InstanceID); err != nil {
+		return err
+	}
+	query := `INSERT INTO instance_canaries (instance_id, deployment_id, stable_port, canary_port, canary_weight, release_path, git_commit_sha, started_at, updated_at)
+	          VALUES (:instance_id, :deployment_id, :stable_port, :canary_port, :canary_weight, :release_path, :git_commit_sha, :started_at, :updated_at)`
+	if _, err := tx.NamedExec(query, canary); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetInstanceCanary returns the canary of an instance, or nil if no canary is running.
+func GetInstanceCanary(instanceID uuid.UUID) (*models.InstanceCanary, error) {
+	var canary models.InstanceCanary
+	query := Rebind(instanceCanarySelect + " WHERE instance_id = ?")
+	if err := DB.Get(&canary, query, instanceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &canary, nil
+}
+
+// UpdateInstanceCanaryWeight records the share of the traffic sent to the canary of an instance.
+func UpdateInstanceCanaryWeight(instanceID uuid.UUID, weight int) error {
+	now := time.Now()
+	query := Rebind("UPDATE instance_canaries SET canary_weight = ?, updated_at = ? WHERE instance_id = ?")
+	_, err := DB.Exec(query, weight, models.NullableTime{Time: &now}, instanceID)
+	return err
+}
+
+// DeleteInstanceCanary removes the canary of an instance once it is promoted or aborted.
+func DeleteInstanceCanary(instanceID uuid.UUID) error {
+	query := Rebind("DELETE FROM instance_canaries WHERE instance_id = ?")
+	_, err := DB.Exec(query, instanceID)
+	return err
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"youfun/shipyard/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInstanceLocked is returned when a lock is requested for an instance that is already locked.
+var ErrInstanceLocked = errors.New("application instance is locked")
+
+// ErrLockNotHeld is returned when a lock is renewed or released by someone who no longer holds it.
+var ErrLockNotHeld = errors.New("lock is not held")
+
+// --- instance_locks Table Operations ---
+
+const instanceLockSelect = `SELECT id, instance_id, kind, owner, COALESCE(reason, '') as reason, deployment_id, acquired_at, expires_at FROM instance_locks`
+
+// AcquireInstanceLock takes the lock of lock.InstanceID. An expired lease is replaced.
+// If the instance is locked, the current lock is returned together with ErrInstanceLocked.
+func AcquireInstanceLock(lock *models.InstanceLock) (*models.InstanceLock, error) {
+	now := time.Now()
+	if lock.ID == uuid.Nil {
+		lock.ID = uuid.New()
+	}
+	if lock.AcquiredAt.Time == nil {
+		lock.AcquiredAt = models.NullableTime{Time: &now}
+	}
+
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current models.InstanceLock
+	err = tx.Get(&current, Rebind(instanceLockSelect+" WHERE instance_id = ?"), lock.InstanceID)
+	switch {
+	case err == nil && !current.Expired(now):
+		return &current, ErrInstanceLocked
+	case err == nil:
+		// The holder stopped renewing its lease (e.g. the CLI was killed), take over
+		if _, err := tx.Exec(Rebind("DELETE FROM instance_locks WHERE instance_id = ?"), lock.InstanceID); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	query := `INSERT INTO instance_locks (id, instance_id, kind, owner, reason, deployment_id, acquired_at, expires_at)
+	          VALUES (:id, :instance_id, :kind, :owner, :reason, :deployment_id, :acquired_at, :expires_at)`
+	if _, err := tx.NamedExec(query, lock); err != nil {
+		// Lost the race against a concurrent acquire
+		tx.Rollback()
+		if current, getErr := GetInstanceLock(lock.InstanceID); getErr == nil && current != nil {
+			return current, ErrInstanceLocked
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// GetInstanceLock returns the lock of an instance, or nil if it is not locked or the lease has expired.
+func GetInstanceLock(instanceID uuid.UUID) (*models.InstanceLock, error) {
+	var lock models.InstanceLock
+	query := Rebind(instanceLockSelect + " WHERE instance_id = ?")
+	if err := DB.Get(&lock, query, instanceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if lock.Expired(time.Now()) {
+		return nil, nil
+	}
+	return &lock, nil
+}
+
+// SetInstanceLockDeployment associates a held lock with the deployment it protects.
+func SetInstanceLockDeployment(instanceID, deploymentID uuid.UUID) error {
+	query := Rebind("UPDATE instance_locks SET deployment_id = ? WHERE instance_id = ?")
+	_, err := DB.Exec(query, deploymentID, instanceID)
+	return err
+}
+
+// RenewInstanceLock extends the lease of the lock held by a deployment.
+// ErrLockNotHeld is returned if the lock was released or taken over after its lease expired.
+func RenewInstanceLock(deploymentID uuid.UUID, ttl time.Duration) (*models.InstanceLock, error) {
+	expiresAt := time.Now().Add(ttl)
+	result, err := DB.Exec(Rebind("UPDATE instance_locks SET expires_at = ? WHERE deployment_id = ?"),
+		models.NullableTime{Time: &expiresAt}, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return nil, ErrLockNotHeld
+	}
+
+	var lock models.InstanceLock
+	query := Rebind(instanceLockSelect + " WHERE deployment_id = ?")
+	if err := DB.Get(&lock, query, deploymentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrLockNotHeld
+		}
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// ReleaseInstanceLockForDeployment releases the lock held by a deployment, if it still holds it.
+func ReleaseInstanceLockForDeployment(deploymentID uuid.UUID) error {
+	query := Rebind("DELETE FROM instance_locks WHERE deployment_id = ?")
+	_, err := DB.Exec(query, deploymentID)
+	return err
+}
+
+// ReleaseInstanceLockIfHeld releases the lock of an instance acquired as lockID, if it still holds it.
+// A lock taken over after its lease expired, or released and acquired again, is kept.
+func ReleaseInstanceLockIfHeld(instanceID, lockID uuid.UUID) error {
+	query := Rebind("DELETE FROM instance_locks WHERE instance_id = ? AND id = ?")
+	_, err := DB.Exec(query, instanceID, lockID)
+	return err
+}
+
+// ReleaseInstanceLock removes the lock of an instance regardless of who holds it.
+func ReleaseInstanceLock(instanceID uuid.UUID) error {
+	query := Rebind("DELETE FROM instance_locks WHERE instance_id = ?")
+	_, err := DB.Exec(query, instanceID)
+	return err
+}
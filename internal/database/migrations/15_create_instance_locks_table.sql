@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS instance_locks (
+    instance_id TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    owner TEXT NOT NULL,
+    reason TEXT,
+    deployment_id TEXT,
+    acquired_at DATETIME NOT NULL,
+    expires_at DATETIME,
+    FOREIGN KEY (instance_id) REFERENCES application_instances(id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS instance_locks;
@@ -0,0 +1,19 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS rollouts (
+    id TEXT PRIMARY KEY,
+    application_id TEXT NOT NULL,
+    version TEXT,
+    hosts TEXT NOT NULL,
+    parallel INTEGER NOT NULL DEFAULT 1,
+    status TEXT NOT NULL,
+    created_by TEXT,
+    created_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    FOREIGN KEY (application_id) REFERENCES applications(id)
+);
+CREATE INDEX IF NOT EXISTS idx_rollouts_application ON rollouts(application_id);
+ALTER TABLE deployment_history ADD COLUMN rollout_id TEXT;
+
+-- +migrate Down
+ALTER TABLE deployment_history DROP COLUMN rollout_id;
+DROP TABLE IF EXISTS rollouts;
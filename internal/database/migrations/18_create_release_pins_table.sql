@@ -0,0 +1,11 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS release_pins (
+    instance_id TEXT NOT NULL,
+    release_path TEXT NOT NULL,
+    pinned_at DATETIME NOT NULL,
+    PRIMARY KEY (instance_id, release_path),
+    FOREIGN KEY (instance_id) REFERENCES application_instances(id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS release_pins;
@@ -0,0 +1,18 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS deployment_health_checks (
+    id TEXT PRIMARY KEY,
+    deployment_id TEXT NOT NULL,
+    attempt INTEGER NOT NULL,
+    port INTEGER NOT NULL DEFAULT 0,
+    url TEXT NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    passed BOOLEAN NOT NULL DEFAULT FALSE,
+    message TEXT,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (deployment_id) REFERENCES deployment_history(id)
+);
+CREATE INDEX IF NOT EXISTS idx_deployment_health_checks_deployment ON deployment_health_checks(deployment_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS deployment_health_checks;
@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE deployment_history ADD COLUMN kind TEXT NOT NULL DEFAULT 'deploy';
+
+-- +migrate Down
+ALTER TABLE deployment_history DROP COLUMN kind;
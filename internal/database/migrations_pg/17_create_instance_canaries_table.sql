@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS instance_canaries (
+    instance_id TEXT PRIMARY KEY,
+    deployment_id TEXT NOT NULL,
+    stable_port INTEGER NOT NULL,
+    canary_port INTEGER NOT NULL,
+    canary_weight INTEGER NOT NULL,
+    release_path TEXT NOT NULL,
+    git_commit_sha TEXT,
+    started_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (instance_id) REFERENCES application_instances(id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS instance_canaries;
@@ -0,0 +1,17 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS deployment_steps (
+    deployment_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    detail TEXT,
+    error TEXT,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (deployment_id, name),
+    FOREIGN KEY (deployment_id) REFERENCES deployment_history(id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS deployment_steps;
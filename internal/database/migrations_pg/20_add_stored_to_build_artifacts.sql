@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE build_artifacts ADD COLUMN stored BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +migrate Down
+ALTER TABLE build_artifacts DROP COLUMN stored;
@@ -0,0 +1,35 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    public_key TEXT NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS app_trusted_keys (
+    id TEXT PRIMARY KEY,
+    application_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMP,
+    UNIQUE (application_id, name),
+    FOREIGN KEY (application_id) REFERENCES applications(id) ON DELETE CASCADE
+);
+
+ALTER TABLE build_artifacts ADD COLUMN sha256 TEXT NOT NULL DEFAULT '';
+ALTER TABLE build_artifacts ADD COLUMN signature TEXT NOT NULL DEFAULT '';
+ALTER TABLE build_artifacts ADD COLUMN signing_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE build_artifacts ADD COLUMN signed_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE deployment_history ADD COLUMN signing_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE deployment_history ADD COLUMN signed_by TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE deployment_history DROP COLUMN signed_by;
+ALTER TABLE deployment_history DROP COLUMN signing_key;
+ALTER TABLE build_artifacts DROP COLUMN signed_by;
+ALTER TABLE build_artifacts DROP COLUMN signing_key;
+ALTER TABLE build_artifacts DROP COLUMN signature;
+ALTER TABLE build_artifacts DROP COLUMN sha256;
+DROP TABLE IF EXISTS app_trusted_keys;
+DROP TABLE IF EXISTS signing_keys;
@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE build_artifacts ADD COLUMN compression TEXT NOT NULL DEFAULT 'gzip';
+
+-- +migrate Down
+ALTER TABLE build_artifacts DROP COLUMN compression;
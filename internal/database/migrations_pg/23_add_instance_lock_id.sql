@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE instance_locks ADD COLUMN id TEXT;
+
+-- +migrate Down
+ALTER TABLE instance_locks DROP COLUMN id;
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"youfun/shipyard/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- release_pins Table Operations ---
+
+// PinRelease protects a release directory of an instance from release retention.
+func PinRelease(instanceID uuid.UUID, releasePath string) error {
+	now := time.Now()
+	if _, err := DB.Exec(Rebind("DELETE FROM release_pins WHERE instance_id = ? AND release_path = ?"), instanceID, releasePath); err != nil {
+		return err
+	}
+	query := Rebind("INSERT INTO release_pins (instance_id, release_path, pinned_at) VALUES (?, ?, ?)")
+	_, err := DB.Exec(query, instanceID, releasePath, models.NullableTime{Time: &now})
+	return err
+}
+
+// UnpinRelease lets release retention prune a release directory of an instance again.
+func UnpinRelease(instanceID uuid.UUID, releasePath string) error {
+	query := Rebind("DELETE FROM release_pins WHERE instance_id = ? AND release_path = ?")
+	_, err := DB.Exec(query, instanceID, releasePath)
+	return err
+}
+
+// GetPinnedReleases returns the pinned release directories of an instance.
+func GetPinnedReleases(instanceID uuid.UUID) (map[string]bool, error) {
+	var paths []string
+	query := Rebind("SELECT release_path FROM release_pins WHERE instance_id = ?")
+	if err := DB.Select(&paths, query, instanceID); err != nil {
+		return nil, err
+	}
+	pinned := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		pinned[path] = true
+	}
+	return pinned, nil
+}
+
+// MarkReleasePruned marks every run of a release directory of an instance as pruned once it is deleted from the host.
+func MarkReleasePruned(instanceID uuid.UUID, releasePath string) error {
+	now := time.Now()
+	query := Rebind("UPDATE deployment_instances SET status = ?, stopped_at = COALESCE(stopped_at, ?) WHERE application_instance_id = ? AND release_path = ?")
+	_, err := DB.Exec(query, models.ReleaseStatusPruned, now, instanceID, releasePath)
+	return err
+}
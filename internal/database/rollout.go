@@ -0,0 +1,88 @@
+package database
+
+import (
+	"youfun/shipyard/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- rollouts Table Operations ---
+
+// CreateRollout records the start of a rollout of an application to several hosts.
+func CreateRollout(rollout *models.Rollout) error {
+	if rollout.ID == uuid.Nil {
+		rollout.ID = uuid.New()
+	}
+	if rollout.CreatedAt.Time == nil {
+		now := time.Now()
+		rollout.CreatedAt = models.NullableTime{Time: &now}
+	}
+	query := `INSERT INTO rollouts (id, application_id, version, hosts, parallel, status, created_by, created_at)
+	          VALUES (:id, :application_id, :version, :hosts, :parallel, :status, :created_by, :created_at)`
+	_, err := DB.NamedExec(query, rollout)
+	return err
+}
+
+// FinishRollout records the final status of a rollout.
+func FinishRollout(id uuid.UUID, status string) error {
+	now := time.Now()
+	query := Rebind("UPDATE rollouts SET status = ?, finished_at = ? WHERE id = ?")
+	_, err := DB.Exec(query, status, models.NullableTime{Time: &now}, id)
+	return err
+}
+
+// GetRolloutByID retrieves a rollout.
+func GetRolloutByID(id uuid.UUID) (*models.Rollout, error) {
+	var rollout models.Rollout
+	query := Rebind(`
+		SELECT id, application_id, COALESCE(version, '') as version, hosts, parallel, status,
+		       COALESCE(created_by, '') as created_by, created_at, finished_at
+		FROM rollouts
+		WHERE id = ?
+	`)
+	if err := DB.Get(&rollout, query, id); err != nil {
+		return nil, err
+	}
+	return &rollout, nil
+}
+
+// GetRolloutsForApp retrieves the most recent rollouts of an application, newest first.
+func GetRolloutsForApp(appID uuid.UUID, limit int) ([]models.Rollout, error) {
+	var rollouts []models.Rollout
+	query := Rebind(`
+		SELECT id, application_id, COALESCE(version, '') as version, hosts, parallel, status,
+		       COALESCE(created_by, '') as created_by, created_at, finished_at
+		FROM rollouts
+		WHERE application_id = ?
+		ORDER BY created_at DESC
+		LIMIT ?
+	`)
+	err := DB.Select(&rollouts, query, appID, limit)
+	return rollouts, err
+}
+
+// SetDeploymentHistoryRollout attaches a deployment to the rollout it is part of.
+func SetDeploymentHistoryRollout(deploymentID, rolloutID uuid.UUID) error {
+	query := Rebind("UPDATE deployment_history SET rollout_id = ? WHERE id = ?")
+	_, err := DB.Exec(query, rolloutID, deploymentID)
+	return err
+}
+
+// GetDeploymentHistoryForRollout retrieves the deployments of a rollout in the order they started.
+func GetDeploymentHistoryForRollout(rolloutID uuid.UUID) ([]DeploymentHistoryRow, error) {
+	var history []DeploymentHistoryRow
+	query := Rebind(`
+		SELECT dh.id, dh.instance_id, dh.version, dh.release_path, dh.status,
+		       COALESCE(dh.log_output, '') as log_output, h.name as host_name,
+		       COALESCE(dh.port, 0) as port, COALESCE(dh.kind, 'deploy') as kind, dh.rollout_id,
+		       COALESCE(dh.signing_key, '') as signing_key, COALESCE(dh.signed_by, '') as signed_by, dh.created_at
+		FROM deployment_history dh
+		JOIN application_instances ai ON dh.instance_id = ai.id
+		JOIN ssh_hosts h ON ai.host_id = h.id
+		WHERE dh.rollout_id = ?
+		ORDER BY dh.created_at ASC
+	`)
+	err := DB.Select(&history, query, rolloutID)
+	return history, err
+}
@@ -36,6 +36,31 @@ func SetSecret(appID uuid.UUID, key, value string) error {
 	return err
 }
 
+// EnsureSecret sets a secret unless the application has it already, and returns the value it keeps.
+// Concurrent callers all get the value of the first one.
+func EnsureSecret(appID uuid.UUID, key, value string) (string, error) {
+	encryptedValue, err := crypto.Encrypt(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	now := time.Now()
+	query := Rebind(`
+	INSERT INTO secrets (id, application_id, key, value, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT(application_id, key) DO NOTHING;
+	`)
+	if _, err := DB.Exec(query, uuid.New(), appID, key, encryptedValue, now, now); err != nil {
+		return "", err
+	}
+
+	var stored string
+	if err := DB.Get(&stored, Rebind("SELECT value FROM secrets WHERE application_id = ? AND key = ?"), appID, key); err != nil {
+		return "", err
+	}
+	return crypto.Decrypt(stored)
+}
+
 // UnsetSecret deletes a secret.
 func UnsetSecret(appID uuid.UUID, key string) error {
 	query := Rebind("DELETE FROM secrets WHERE application_id = ? AND key = ?")
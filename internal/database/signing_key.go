@@ -0,0 +1,170 @@
+package database
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"youfun/shipyard/internal/crypto"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/signing"
+
+	"github.com/google/uuid"
+)
+
+// DefaultSigningKeyName is the name of the signing key created on first use.
+const DefaultSigningKeyName = "default"
+
+// --- signing_keys Table Operations ---
+
+// CreateSigningKey generates an ed25519 signing key. The private key is stored encrypted.
+func CreateSigningKey(name string) (*models.SigningKey, error) {
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	encrypted, err := crypto.Encrypt(signing.EncodePrivateKey(priv))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt signing key: %w", err)
+	}
+
+	now := time.Now()
+	key := &models.SigningKey{
+		ID:         uuid.New(),
+		Name:       name,
+		PublicKey:  signing.EncodePublicKey(pub),
+		PrivateKey: encrypted,
+		CreatedAt:  models.NullableTime{Time: &now},
+	}
+	query := `INSERT INTO signing_keys (id, name, public_key, private_key, created_at) VALUES (:id, :name, :public_key, :private_key, :created_at)`
+	if _, err := DB.NamedExec(query, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetSigningKeys retrieves all signing keys, newest first.
+func GetSigningKeys() ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	err := DB.Select(&keys, `SELECT * FROM signing_keys ORDER BY created_at DESC`)
+	return keys, err
+}
+
+// GetActiveSigningKey returns the newest signing key, which signs new artifacts, with its decrypted
+// private key. A key named "default" is created the first time one is needed.
+func GetActiveSigningKey() (*models.SigningKey, ed25519.PrivateKey, error) {
+	var key models.SigningKey
+	err := DB.Get(&key, `SELECT * FROM signing_keys ORDER BY created_at DESC LIMIT 1`)
+	if errors.Is(err, sql.ErrNoRows) {
+		created, createErr := CreateSigningKey(DefaultSigningKeyName)
+		if createErr != nil {
+			return nil, nil, fmt.Errorf("failed to create signing key: %w", createErr)
+		}
+		key, err = *created, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seed, err := crypto.Decrypt(key.PrivateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt signing key '%s': %w", key.Name, err)
+	}
+	priv, err := signing.ParsePrivateKey(seed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &key, priv, nil
+}
+
+// --- app_trusted_keys Table Operations ---
+
+// AddTrustedKey adds a public key an application accepts artifact signatures from.
+func AddTrustedKey(appID uuid.UUID, name, publicKey string) (*models.TrustedKey, error) {
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if _, err := signing.ParsePublicKey(publicKey); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	key := &models.TrustedKey{
+		ID:            uuid.New(),
+		ApplicationID: appID,
+		Name:          name,
+		PublicKey:     publicKey,
+		CreatedAt:     models.NullableTime{Time: &now},
+	}
+	query := `INSERT INTO app_trusted_keys (id, application_id, name, public_key, created_at) VALUES (:id, :application_id, :name, :public_key, :created_at)`
+	if _, err := DB.NamedExec(query, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetTrustedKeysForApp retrieves the public keys configured for an application.
+func GetTrustedKeysForApp(appID uuid.UUID) ([]models.TrustedKey, error) {
+	var keys []models.TrustedKey
+	query := Rebind(`SELECT * FROM app_trusted_keys WHERE application_id = ? ORDER BY created_at`)
+	err := DB.Select(&keys, query, appID)
+	return keys, err
+}
+
+// DeleteTrustedKey removes a public key of an application.
+func DeleteTrustedKey(id, appID uuid.UUID) error {
+	result, err := DB.Exec(Rebind(`DELETE FROM app_trusted_keys WHERE id = ? AND application_id = ?`), id, appID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("trusted key not found")
+	}
+	return nil
+}
+
+// GetTrustedSigningKeys returns the keys artifacts of an application must be signed with: the keys
+// configured for the application, or every signing key of the server if none are.
+func GetTrustedSigningKeys(appID uuid.UUID) ([]signing.TrustedKey, error) {
+	appKeys, err := GetTrustedKeysForApp(appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trusted keys: %w", err)
+	}
+
+	var trusted []signing.TrustedKey
+	if len(appKeys) > 0 {
+		for _, key := range appKeys {
+			pub, err := signing.ParsePublicKey(key.PublicKey)
+			if err != nil {
+				return nil, fmt.Errorf("trusted key '%s': %w", key.Name, err)
+			}
+			trusted = append(trusted, signing.TrustedKey{Name: key.Name, PublicKey: pub})
+		}
+		return trusted, nil
+	}
+
+	serverKeys, err := GetSigningKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing keys: %w", err)
+	}
+	for _, key := range serverKeys {
+		pub, err := signing.ParsePublicKey(key.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing key '%s': %w", key.Name, err)
+		}
+		trusted = append(trusted, signing.TrustedKey{Name: key.Name, PublicKey: pub})
+	}
+	return trusted, nil
+}
+
+// SetDeploymentSigner records who signed the artifact of a deployment, and with which key.
+func SetDeploymentSigner(deploymentID uuid.UUID, signingKey, signedBy string) error {
+	query := Rebind(`UPDATE deployment_history SET signing_key = ?, signed_by = ? WHERE id = ?`)
+	_, err := DB.Exec(query, signingKey, signedBy, deploymentID)
+	return err
+}
@@ -2,7 +2,6 @@ package deploy
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	"fmt"
 	"io"
 	"log"
@@ -10,6 +9,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"youfun/shipyard/internal/compression"
+	"youfun/shipyard/internal/config"
 	"youfun/shipyard/internal/static"
 	"strings"
 	"time"
@@ -20,94 +21,173 @@ var (
 	versionFromMixRegex    = regexp.MustCompile(`version:\s*"(.*?)"`)
 	appNameFromMixRegex    = regexp.MustCompile(`app:\s*:(\w+)`)
 	versionFromPackageJSON = regexp.MustCompile(`"version"\s*:\s*"([^"]+)"`)
+	versionFromPyproject   = regexp.MustCompile(`(?m)^version\s*=\s*["']([^"']+)["']`)
 )
 
-func (d *Deployer) buildRelease() string {
+func (d *Deployer) buildRelease(build config.Build) (string, error) {
+	dockerfilePath, buildArgs, err := d.prepareDockerBuild(build)
+	if err != nil {
+		return "", err
+	}
+	buildOutputDir, err := d.dockerBuild(dockerfilePath, buildArgs)
+	if err != nil {
+		return "", err
+	}
+	log.Println("✅ Docker build version success.")
+	return buildOutputDir, nil
+}
+
+// prepareDockerBuild writes the preset Dockerfile of the runtime unless the project has its own,
+// and returns the Dockerfile and the build arguments of a release build.
+func (d *Deployer) prepareDockerBuild(build config.Build) (string, []string, error) {
 	log.Println("Building using Docker...")
 
 	dockerfilePath := "Dockerfile.shipyard"
 
 	// Check if the agreed Dockerfile exists in project root
-	if _, err := os.Stat(dockerfilePath); os.IsNotExist(err) {
+	if _, err := os.Stat(d.sourcePath(dockerfilePath)); os.IsNotExist(err) {
 		// If not exists, write built-in Dockerfile to current directory based on runtime
 		log.Printf("Did not find '%s' in project root, using built-in preset Dockerfile and outputting to project root", dockerfilePath)
 
 		// Choose Dockerfile based on runtime type
 		var dockerfileContent string
-		if d.Runtime == "elixir" {
+		switch d.Runtime {
+		case "elixir":
 			log.Println("Using pure Elixir Dockerfile (without Phoenix assets)")
 			dockerfileContent = static.DockerfileBuildElixir
-		} else {
+		case "node":
+			log.Println("Using Node.js Dockerfile (production dependencies)")
+			dockerfileContent = static.DockerfileBuildNode
+		case "golang":
+			log.Println("Using Go Dockerfile (static binary bin/server)")
+			dockerfileContent = static.DockerfileBuildGolang
+		case "python":
+			log.Println("Using Python Dockerfile (dependencies vendored in venv/)")
+			dockerfileContent = static.DockerfileBuildPython
+		case config.RuntimeCustom:
+			return "", nil, fmt.Errorf("runtime = \"custom\" has no preset Dockerfile, set [build] command or add %s", dockerfilePath)
+		default:
 			// Default to Phoenix Dockerfile (phoenix runtime or fallback)
 			log.Println("Using Phoenix Dockerfile (with assets build)")
 			dockerfileContent = static.DockerfileBuildPhoenix
 		}
 
-		err := os.WriteFile(dockerfilePath, []byte(dockerfileContent), 0644)
+		err := os.WriteFile(d.sourcePath(dockerfilePath), []byte(dockerfileContent), 0644)
 		if err != nil {
-			log.Fatalf("failed to write preset Dockerfile: %v", err)
+			return "", nil, fmt.Errorf("failed to write preset Dockerfile: %w", err)
 		}
 		// Note: We do not delete it here so user can see and modify it after build
 	} else {
 		log.Printf("Detected '%s' in project root, using this file for build.", dockerfilePath)
 	}
 
+	switch d.Runtime {
+	case "node", "python", config.RuntimeCustom:
+		return dockerfilePath, nil, nil
+	case "golang":
+		target := newGoBuilder(build)
+		return dockerfilePath, []string{"MAIN=" + target.pkg, "GOOS=" + target.goos, "GOARCH=" + target.goarch}, nil
+	}
+
 	// Get app name from mix.exs
 	appName, err := d.getAppNameFromMix()
 	if err != nil {
-		log.Fatalf("failed to get app name from mix.exs: %v", err)
+		return "", nil, fmt.Errorf("failed to get app name from mix.exs: %w", err)
 	}
 	log.Printf("Got app name from mix.exs: %s", appName)
 
 	// For pure Elixir projects, ensure priv directory exists (even if empty)
 	// This is because Docker COPY will fail if the source doesn't exist
 	if d.Runtime == "elixir" {
-		if _, err := os.Stat("priv"); os.IsNotExist(err) {
+		if _, err := os.Stat(d.sourcePath("priv")); os.IsNotExist(err) {
 			log.Println("Creating empty priv directory for Elixir project...")
-			if err := os.MkdirAll("priv", 0755); err != nil {
+			if err := os.MkdirAll(d.sourcePath("priv"), 0755); err != nil {
 				log.Printf("Warning: Failed to create priv directory: %v", err)
 			}
 		}
 	}
 
+	return dockerfilePath, []string{fmt.Sprintf("APP_NAME=%s", appName)}, nil
+}
+
+// dockerBuild runs docker build in the project directory and returns the temp directory holding its output.
+// Note: --output flag requires Docker BuildKit (Docker 18.09+ with DOCKER_BUILDKIT=1 or Docker 23.0+ by default)
+func (d *Deployer) dockerBuild(dockerfilePath string, buildArgs []string) (string, error) {
 	// Create a temp directory to store build artifacts
 	buildOutputDir, err := os.MkdirTemp("", "deployer-build-")
 	if err != nil {
-		log.Fatalf("failed to create temp build directory: %v", err)
+		return "", fmt.Errorf("failed to create temp build directory: %w", err)
 	}
 	log.Printf("Build artifacts will be output to: %s", buildOutputDir)
 
 	// Execute docker build
-	cmd := exec.Command("docker", "build", "--output", fmt.Sprintf("type=local,dest=%s", buildOutputDir), "-f", dockerfilePath, "--build-arg", fmt.Sprintf("APP_NAME=%s", appName), ".")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd := exec.CommandContext(d.context(), "docker", dockerBuildArgs(buildOutputDir, dockerfilePath, buildArgs)...)
+	cmd.Dir = d.sourceDir
+	cmd.Stdout, cmd.Stderr = d.buildStreams()
 	if err := cmd.Run(); err != nil {
-		log.Fatalf("Docker build failed, please check docker status or build file: %v", err)
+		os.RemoveAll(buildOutputDir)
+		if err := d.checkCancelled(); err != nil {
+			log.Println("🛑 Build cancelled, nothing was deployed.")
+			return "", err
+		}
+		return "", fmt.Errorf("docker build failed, please check docker status or build file: %w", err)
 	}
+	return buildOutputDir, nil
+}
 
-	log.Println("✅ Docker build version success.")
-	return buildOutputDir
+// dockerBuildArgs returns the arguments of a docker build of the working directory into outputDir.
+func dockerBuildArgs(outputDir, dockerfilePath string, buildArgs []string) []string {
+	args := []string{"build", "--output", fmt.Sprintf("type=local,dest=%s", outputDir), "-f", dockerfilePath}
+	for _, arg := range buildArgs {
+		args = append(args, "--build-arg", arg)
+	}
+	return append(args, ".")
+}
+
+// sourcePath returns the path of a file of the project to build.
+func (d *Deployer) sourcePath(name string) string {
+	return filepath.Join(d.sourceDir, name)
 }
 
-func (d *Deployer) createTarball(source, prefix string) (string, error) {
-	tarballPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d.tar.gz", prefix, time.Now().Unix()))
+// buildStreams returns where the output of a build goes: the build log when the build runs on
+// shipyard-server for the CLI, otherwise Output and stderr.
+func (d *Deployer) buildStreams() (io.Writer, io.Writer) {
+	if d.buildLog != nil {
+		return d.buildLog, d.buildLog
+	}
+	return Output, os.Stderr
+}
+
+// createTarball packs the source directory into a tarball compressed in the given format, on all cores.
+func (d *Deployer) createTarball(source, prefix, format string) (string, error) {
+	tarballPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d%s", prefix, time.Now().Unix(), compression.Extension(format)))
 	tarballFile, err := os.Create(tarballPath)
 	if err != nil {
 		return "", err
 	}
 	defer tarballFile.Close()
 
-	gzipWriter := gzip.NewWriter(tarballFile)
-	defer gzipWriter.Close()
+	compressWriter, err := compression.NewWriter(tarballFile, format)
+	if err != nil {
+		return "", err
+	}
+	defer compressWriter.Close()
 
-	tarWriter := tar.NewWriter(gzipWriter)
+	tarWriter := tar.NewWriter(compressWriter)
 	defer tarWriter.Close()
 
 	return tarballPath, filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		header, err := tar.FileInfoHeader(info, info.Name())
+		// Symlinks are kept as links, e.g. venv/bin/python to the python3 of the host
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
 		if err != nil {
 			return err
 		}
@@ -117,7 +197,7 @@ func (d *Deployer) createTarball(source, prefix string) (string, error) {
 		if err := tarWriter.WriteHeader(header); err != nil {
 			return err
 		}
-		if !info.IsDir() {
+		if info.Mode().IsRegular() {
 			file, _ := os.Open(path)
 			defer file.Close()
 			_, _ = io.Copy(tarWriter, file)
@@ -127,7 +207,7 @@ func (d *Deployer) createTarball(source, prefix string) (string, error) {
 }
 
 func (d *Deployer) getVersionFromMix() (string, error) {
-	content, err := os.ReadFile("mix.exs")
+	content, err := os.ReadFile(d.sourcePath("mix.exs"))
 	if err != nil {
 		log.Printf("DEBUG: Failed to read mix.exs file: %v", err)
 		return "", fmt.Errorf("failed to read mix.exs: %w", err)
@@ -142,7 +222,7 @@ func (d *Deployer) getVersionFromMix() (string, error) {
 }
 
 func (d *Deployer) getAppNameFromMix() (string, error) {
-	content, err := os.ReadFile("mix.exs")
+	content, err := os.ReadFile(d.sourcePath("mix.exs"))
 	if err != nil {
 		return "", fmt.Errorf("failed to read mix.exs: %w", err)
 	}
@@ -190,7 +270,22 @@ func (d *Deployer) getGitVersion() (string, error) {
 // Requirements:
 // - Docker with BuildKit enabled (Docker 18.09+ with DOCKER_BUILDKIT=1 or Docker 23.0+ by default)
 // - The --output flag requires Docker BuildKit support
-func (d *Deployer) buildStaticRelease() string {
+func (d *Deployer) buildStaticRelease() (string, error) {
+	dockerfilePath, buildArgs, err := d.prepareStaticDockerBuild()
+	if err != nil {
+		return "", err
+	}
+	buildOutputDir, err := d.dockerBuild(dockerfilePath, buildArgs)
+	if err != nil {
+		return "", err
+	}
+	log.Println("✅ Docker multi-stage build completed, single file Linux binary generated.")
+	return buildOutputDir, nil
+}
+
+// prepareStaticDockerBuild writes the preset Dockerfile of static sites unless the project has its own,
+// and returns the Dockerfile and the build arguments of a static site build.
+func (d *Deployer) prepareStaticDockerBuild() (string, []string, error) {
 	log.Println("Using Docker multi-stage build for static site...")
 
 	// Determine which Dockerfile to use based on project type
@@ -198,7 +293,9 @@ func (d *Deployer) buildStaticRelease() string {
 	var dockerfileContent string
 
 	// Check if project has package.json (needs frontend build)
-	if _, err := os.Stat("package.json"); err == nil {
+	_, err := os.Stat(d.sourcePath("package.json"))
+	hasPackageJSON := err == nil
+	if hasPackageJSON {
 		log.Println("Detected package.json, will use Node.js to build frontend then embed into Go binary")
 		dockerfileContent = static.DockerfileStatic
 	} else {
@@ -207,63 +304,44 @@ func (d *Deployer) buildStaticRelease() string {
 	}
 
 	// Check if user has their own Dockerfile.shipyard.static
-	if _, err := os.Stat(dockerfilePath); os.IsNotExist(err) {
+	if _, err := os.Stat(d.sourcePath(dockerfilePath)); os.IsNotExist(err) {
 		log.Printf("Did not find '%s' in project root, using built-in preset Dockerfile and outputting to project root", dockerfilePath)
-		err := os.WriteFile(dockerfilePath, []byte(dockerfileContent), 0644)
+		err := os.WriteFile(d.sourcePath(dockerfilePath), []byte(dockerfileContent), 0644)
 		if err != nil {
-			log.Fatalf("failed to write preset Dockerfile: %v", err)
+			return "", nil, fmt.Errorf("failed to write preset Dockerfile: %w", err)
 		}
 	} else {
 		log.Printf("Detected '%s' in project root, using this file for build.", dockerfilePath)
 	}
 
-	// Create a temporary directory to store build output
-	buildOutputDir, err := os.MkdirTemp("", "deployer-static-build-")
-	if err != nil {
-		log.Fatalf("failed to create temp build directory: %v", err)
-	}
-	log.Printf("Build artifacts will be output to: %s", buildOutputDir)
-
-	// Prepare docker build command
-	// Note: --output flag requires Docker BuildKit (Docker 18.09+ with DOCKER_BUILDKIT=1 or Docker 23.0+ by default)
-	args := []string{"build", "--output", fmt.Sprintf("type=local,dest=%s", buildOutputDir), "-f", dockerfilePath}
-
 	// For simple static (no package.json), pass STATIC_DIR build arg
-	if _, err := os.Stat("package.json"); os.IsNotExist(err) {
-		staticDir := d.findStaticSourceDir()
-		args = append(args, "--build-arg", fmt.Sprintf("STATIC_DIR=%s", staticDir))
-	}
-
-	args = append(args, ".")
-
-	// Execute docker build
-	cmd := exec.Command("docker", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log.Fatalf("Docker build failed, please check Docker status or build file: %v", err)
+	var buildArgs []string
+	if !hasPackageJSON {
+		staticDir, err := d.findStaticSourceDir()
+		if err != nil {
+			return "", nil, err
+		}
+		buildArgs = append(buildArgs, fmt.Sprintf("STATIC_DIR=%s", staticDir))
 	}
 
-	log.Println("✅ Docker multi-stage build completed, single file Linux binary generated.")
-	return buildOutputDir
+	return dockerfilePath, buildArgs, nil
 }
 
 // findStaticSourceDir determines the source directory for static files.
 // Priority: dist/ > build/ > public/ > root (with index.html)
-func (d *Deployer) findStaticSourceDir() string {
+func (d *Deployer) findStaticSourceDir() (string, error) {
 	candidates := []string{"dist", "build", "public"}
 	for _, dir := range candidates {
-		indexPath := filepath.Join(dir, "index.html")
+		indexPath := d.sourcePath(filepath.Join(dir, "index.html"))
 		if _, err := os.Stat(indexPath); err == nil {
-			return dir
+			return dir, nil
 		}
 	}
 	// Check if index.html exists in root
-	if _, err := os.Stat("index.html"); err == nil {
-		return "."
+	if _, err := os.Stat(d.sourcePath("index.html")); err == nil {
+		return ".", nil
 	}
-	log.Fatal("Static file directory not found. Please ensure index.html or dist/build/public directory exists.")
-	return "" // unreachable, but required for Go compiler
+	return "", fmt.Errorf("static file directory not found, please ensure index.html or dist/build/public directory exists")
 }
 
 // copyStaticFiles copies all static files from source to destination directory.
@@ -301,14 +379,87 @@ func (d *Deployer) copyStaticFiles(src, dst string) error {
 // getVersionForStatic returns a version string for static projects.
 // It tries to read from package.json first, then falls back to timestamp-based version.
 func (d *Deployer) getVersionForStatic() (string, error) {
-	// Try to get version from package.json if it exists
-	if content, err := os.ReadFile("package.json"); err == nil {
-		matches := versionFromPackageJSON.FindStringSubmatch(string(content))
-		if len(matches) >= 2 {
-			return matches[1], nil
-		}
+	if version := d.getVersionFromPackageJSON(); version != "" {
+		return version, nil
 	}
 
 	// Fallback to timestamp-based version
 	return time.Now().Format("20060102.150405"), nil
 }
+
+// getVersionForNode returns the version of package.json, else the one git describe gives the project,
+// else a timestamp-based version.
+func (d *Deployer) getVersionForNode() (string, error) {
+	if version := d.getVersionFromPackageJSON(); version != "" {
+		return version, nil
+	}
+	if version := d.getVersionFromGitDescribe(); version != "" {
+		return version, nil
+	}
+	return time.Now().Format("20060102.150405"), nil
+}
+
+// getVersionForGo returns the version git describe gives the project, as Go modules are versioned by
+// their Git tags (go.mod has no version), else a timestamp-based version.
+func (d *Deployer) getVersionForGo() (string, error) {
+	if version := d.getVersionFromGitDescribe(); version != "" {
+		return version, nil
+	}
+	return time.Now().Format("20060102.150405"), nil
+}
+
+// getVersionForPython returns the version of pyproject.toml, else the one git describe gives the project,
+// else a timestamp-based version.
+func (d *Deployer) getVersionForPython() (string, error) {
+	if version := d.getVersionFromPyproject(); version != "" {
+		return version, nil
+	}
+	if version := d.getVersionFromGitDescribe(); version != "" {
+		return version, nil
+	}
+	return time.Now().Format("20060102.150405"), nil
+}
+
+// getVersionFromPyproject returns the version of the pyproject.toml of the project ([project] or
+// [tool.poetry]), or "".
+func (d *Deployer) getVersionFromPyproject() string {
+	content, err := os.ReadFile(d.sourcePath("pyproject.toml"))
+	if err != nil {
+		return ""
+	}
+	matches := versionFromPyproject.FindStringSubmatch(string(content))
+	if len(matches) < 2 {
+		return ""
+	}
+	return matches[1]
+}
+
+// getVersionFromPackageJSON returns the version of the package.json of the project, or "".
+func (d *Deployer) getVersionFromPackageJSON() string {
+	content, err := os.ReadFile(d.sourcePath("package.json"))
+	if err != nil {
+		return ""
+	}
+	matches := versionFromPackageJSON.FindStringSubmatch(string(content))
+	if len(matches) < 2 {
+		return ""
+	}
+	return matches[1]
+}
+
+// getVersionFromGitDescribe returns the newest tag of the project with the commits since, e.g. "1.4.0" or
+// "1.4.0-3-g1a2b3c4", or the abbreviated commit SHA when there is no tag. A leading "v" of the tag is
+// dropped. It returns "" outside a Git repository.
+func (d *Deployer) getVersionFromGitDescribe() string {
+	cmd := exec.Command("git", "describe", "--tags", "--always")
+	cmd.Dir = d.sourceDir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	version := strings.TrimSpace(string(output))
+	if len(version) > 1 && version[0] == 'v' && version[1] >= '0' && version[1] <= '9' {
+		version = version[1:]
+	}
+	return version
+}
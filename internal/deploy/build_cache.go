@@ -0,0 +1,247 @@
+package deploy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/compression"
+	"youfun/shipyard/internal/database"
+	"youfun/shipyard/pkg/types"
+)
+
+// CachedBuild is a release tarball in the local build cache, named <app>-<md5><extension>.
+type CachedBuild struct {
+	AppName     string
+	MD5Hash     string
+	Compression string
+	Path        string
+	Size        int64
+	Artifact    *types.BuildArtifactDTO // nil when the build is no longer in the build history
+}
+
+// PrunePolicy selects the cached builds that 'build prune' deletes.
+type PrunePolicy struct {
+	Keep     int   // Newest builds of each application kept, at least 1
+	KeepDays int   // Builds deployed in the last KeepDays days are kept, 0 to disable
+	MaxSize  int64 // Bytes the cached builds are pruned down to, oldest first, 0 for no cap
+}
+
+// PrunedBuild is a cached build selected by a PrunePolicy.
+type PrunedBuild struct {
+	CachedBuild
+	Reason string
+}
+
+// PruneResult lists the builds pruned from the local build cache and the size of the cached builds
+// that remain.
+type PruneResult struct {
+	Pruned    []PrunedBuild
+	CacheSize int64
+	DryRun    bool
+}
+
+// ListCachedBuilds lists the release tarballs in the local build cache, of all applications when appName is empty.
+func ListCachedBuilds(appName string) ([]CachedBuild, error) {
+	buildCacheDir, err := database.GetBuildCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	entries, err := os.ReadDir(buildCacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var builds []CachedBuild
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		build, ok := parseCacheFileName(entry.Name())
+		if !ok || (appName != "" && build.AppName != appName) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		build.Path = filepath.Join(buildCacheDir, entry.Name())
+		build.Size = info.Size()
+		builds = append(builds, build)
+	}
+	return builds, nil
+}
+
+// parseCacheFileName reads the application, MD5 and compression of a tarball named by artifactCachePath.
+func parseCacheFileName(name string) (CachedBuild, bool) {
+	for _, format := range []string{compression.Gzip, compression.Zstd, compression.None} {
+		base, ok := strings.CutSuffix(name, compression.Extension(format))
+		// <app>-<32 hex digits>
+		if !ok || len(base) < 34 || base[len(base)-33] != '-' {
+			continue
+		}
+		md5Hash := base[len(base)-32:]
+		if _, err := hex.DecodeString(md5Hash); err != nil {
+			continue
+		}
+		return CachedBuild{AppName: base[:len(base)-33], MD5Hash: md5Hash, Compression: format}, true
+	}
+	return CachedBuild{}, false
+}
+
+// PruneBuilds deletes the cached builds of the given applications, all applications of the cache when
+// appName is empty, that the policy does not keep. A pruned build is deleted from the build history and
+// the artifact registry along with its tarball, so no build is left registered without its tarball. A
+// tarball whose build is no longer in the build history is always pruned. With dryRun set, the builds
+// that would be pruned are returned and nothing is deleted.
+func PruneBuilds(apiClient client.APIClient, appName string, policy PrunePolicy, dryRun bool) (*PruneResult, error) {
+	if policy.Keep < 1 {
+		return nil, fmt.Errorf("keep must be at least 1")
+	}
+	builds, err := ListCachedBuilds(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make(map[string][]types.BuildArtifactDTO)
+	for _, build := range builds {
+		artifacts[build.AppName] = nil
+	}
+	for name := range artifacts {
+		list, err := apiClient.ListBuildArtifacts(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list build artifacts of app '%s': %w", name, err)
+		}
+		artifacts[name] = list
+	}
+
+	pruned := selectPrunedBuilds(builds, artifacts, policy, time.Now())
+	result := &PruneResult{DryRun: dryRun}
+	for _, build := range builds {
+		result.CacheSize += build.Size
+	}
+	for _, build := range pruned {
+		if !dryRun {
+			// The build history goes first: a tarball left behind is pruned again, a registered build without
+			// its tarball would make deploys pull or rebuild it
+			if build.Artifact != nil {
+				if err := apiClient.DeleteBuildArtifact(build.AppName, build.MD5Hash); err != nil {
+					log.Printf("⚠️ Failed to delete build %s of app '%s': %v", build.MD5Hash, build.AppName, err)
+					continue
+				}
+			}
+			if err := os.Remove(build.Path); err != nil && !os.IsNotExist(err) {
+				log.Printf("⚠️ Failed to delete cached build %s: %v", build.Path, err)
+				continue
+			}
+		}
+		result.CacheSize -= build.Size
+		result.Pruned = append(result.Pruned, build)
+	}
+	return result, nil
+}
+
+// selectPrunedBuilds applies a prune policy to cached builds, given the build history of their
+// applications (newest first). The newest build of each application and the builds deployed in the last
+// KeepDays days are never pruned, not even to bring the cache under MaxSize.
+func selectPrunedBuilds(builds []CachedBuild, artifacts map[string][]types.BuildArtifactDTO, policy PrunePolicy, now time.Time) []PrunedBuild {
+	type buildKey struct{ app, md5 string }
+	newest := make(map[buildKey]bool) // Kept by policy.Keep
+	pinned := make(map[buildKey]bool) // Never pruned
+	byKey := make(map[buildKey]*types.BuildArtifactDTO)
+	for app, list := range artifacts {
+		for i := range list {
+			key := buildKey{app, list[i].MD5Hash}
+			byKey[key] = &list[i]
+			if i < policy.Keep {
+				newest[key] = true
+			}
+			if i == 0 {
+				pinned[key] = true
+			}
+			deployedAt := list[i].LastDeployedAt
+			if policy.KeepDays > 0 && deployedAt != nil && now.Sub(*deployedAt) < time.Duration(policy.KeepDays)*24*time.Hour {
+				pinned[key] = true
+			}
+		}
+	}
+
+	var pruned []PrunedBuild
+	var kept []CachedBuild
+	var size int64
+	for _, build := range builds {
+		key := buildKey{build.AppName, build.MD5Hash}
+		build.Artifact = byKey[key]
+		switch {
+		case build.Artifact == nil:
+			pruned = append(pruned, PrunedBuild{build, "not in the build history"})
+		case newest[key] || pinned[key]:
+			kept = append(kept, build)
+			size += build.Size
+		default:
+			pruned = append(pruned, PrunedBuild{build, fmt.Sprintf("older than the newest %d", policy.Keep)})
+		}
+	}
+	if policy.MaxSize <= 0 || size <= policy.MaxSize {
+		return pruned
+	}
+
+	// Over the size cap, the oldest builds go first
+	sort.SliceStable(kept, func(i, j int) bool {
+		a, b := kept[i].Artifact.CreatedAt, kept[j].Artifact.CreatedAt
+		return a != nil && (b == nil || a.Before(*b))
+	})
+	for _, build := range kept {
+		if size <= policy.MaxSize {
+			break
+		}
+		if pinned[buildKey{build.AppName, build.MD5Hash}] {
+			continue
+		}
+		pruned = append(pruned, PrunedBuild{build, "build cache over " + FormatBytes(policy.MaxSize)})
+		size -= build.Size
+	}
+	return pruned
+}
+
+// ParseSize parses a size in bytes with an optional binary unit, e.g. "500MB", "5G" or "1.5GB".
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for i, unit := range []string{"K", "M", "G", "T"} {
+		if trimmed, ok := strings.CutSuffix(strings.TrimSuffix(value, "B"), unit); ok {
+			value, multiplier = trimmed, int64(1)<<(10*(i+1))
+			break
+		}
+	}
+	value = strings.TrimSpace(strings.TrimSuffix(value, "B"))
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected e.g. 500MB or 5GB", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// LocateBuild returns the path of the tarball of a build on this machine, pulling it from the registry
+// into the local build cache when it is not there.
+func LocateBuild(apiClient client.APIClient, appName string, artifact *types.BuildArtifactDTO) (string, error) {
+	buildCacheDir, err := database.GetBuildCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	for _, tarballPath := range []string{artifactCachePath(buildCacheDir, appName, artifact.MD5Hash, artifact.Compression), artifact.LocalPath} {
+		if actualMD5, err := calculateMD5(tarballPath); err == nil && actualMD5 == artifact.MD5Hash {
+			return tarballPath, nil
+		}
+	}
+	if !artifact.Stored {
+		return "", fmt.Errorf("build %s is not on this machine nor in the registry", artifact.MD5Hash)
+	}
+	return pullArtifact(apiClient, artifact.ApplicationID, appName, artifact.MD5Hash, artifact.Compression)
+}
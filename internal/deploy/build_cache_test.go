@@ -0,0 +1,106 @@
+package deploy
+
+import (
+	"slices"
+	"testing"
+	"time"
+	"youfun/shipyard/internal/compression"
+	"youfun/shipyard/pkg/types"
+)
+
+func TestParseCacheFileName(t *testing.T) {
+	md5Hash := "0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name   string
+		app    string
+		format string
+		ok     bool
+	}{
+		{"my-app-" + md5Hash + ".tar.gz", "my-app", compression.Gzip, true},
+		{"my_app-" + md5Hash + ".tar.zst", "my_app", compression.Zstd, true},
+		{"web-" + md5Hash + ".tar", "web", compression.None, true},
+		{"-" + md5Hash + ".tar.gz", "", "", false},
+		{"web-0123456789abcdef.tar.gz", "", "", false},
+		{"web-" + md5Hash + ".zip", "", "", false},
+	}
+	for _, tt := range tests {
+		build, ok := parseCacheFileName(tt.name)
+		if ok != tt.ok || build.AppName != tt.app || build.Compression != tt.format || (ok && build.MD5Hash != md5Hash) {
+			t.Errorf("parseCacheFileName(%q) = %+v, %v", tt.name, build, ok)
+		}
+	}
+}
+
+func TestSelectPrunedBuilds(t *testing.T) {
+	now := time.Now()
+	daysAgo := func(days int) *time.Time {
+		t := now.Add(-time.Duration(days) * 24 * time.Hour)
+		return &t
+	}
+	// Newest first, as the build history lists them
+	artifacts := map[string][]types.BuildArtifactDTO{
+		"web": {
+			{Version: "1.4.0", MD5Hash: "web-4", CreatedAt: daysAgo(1)},
+			{Version: "1.3.0", MD5Hash: "web-3", CreatedAt: daysAgo(10)},
+			{Version: "1.2.0", MD5Hash: "web-2", CreatedAt: daysAgo(20), LastDeployedAt: daysAgo(5)},
+			{Version: "1.1.0", MD5Hash: "web-1", CreatedAt: daysAgo(30), LastDeployedAt: daysAgo(25)},
+		},
+		"api": {
+			{Version: "2.0.0", MD5Hash: "api-1", CreatedAt: daysAgo(40)},
+		},
+	}
+	builds := []CachedBuild{
+		{AppName: "web", MD5Hash: "web-4", Size: 100},
+		{AppName: "web", MD5Hash: "web-3", Size: 100},
+		{AppName: "web", MD5Hash: "web-2", Size: 100},
+		{AppName: "web", MD5Hash: "web-1", Size: 100},
+		{AppName: "web", MD5Hash: "web-0", Size: 100}, // pruned from the build history by the registry
+		{AppName: "api", MD5Hash: "api-1", Size: 100},
+	}
+	pruned := func(policy PrunePolicy) []string {
+		var md5s []string
+		for _, build := range selectPrunedBuilds(builds, artifacts, policy, now) {
+			md5s = append(md5s, build.MD5Hash)
+		}
+		return md5s
+	}
+
+	if got := pruned(PrunePolicy{Keep: 10}); !slices.Equal(got, []string{"web-0"}) {
+		t.Errorf("keep 10: pruned %v", got)
+	}
+	if got := pruned(PrunePolicy{Keep: 1}); !slices.Equal(got, []string{"web-3", "web-2", "web-1", "web-0"}) {
+		t.Errorf("keep 1: pruned %v", got)
+	}
+	// 1.2.0 was deployed 5 days ago
+	if got := pruned(PrunePolicy{Keep: 1, KeepDays: 7}); !slices.Equal(got, []string{"web-3", "web-1", "web-0"}) {
+		t.Errorf("keep 1, keep-days 7: pruned %v", got)
+	}
+	// The oldest builds go until 300 bytes are left, but never the newest of an app
+	if got := pruned(PrunePolicy{Keep: 10, MaxSize: 300}); !slices.Equal(got, []string{"web-0", "web-1", "web-2"}) {
+		t.Errorf("max-size 300: pruned %v", got)
+	}
+	if got := pruned(PrunePolicy{Keep: 10, KeepDays: 7, MaxSize: 100}); !slices.Equal(got, []string{"web-0", "web-1", "web-3"}) {
+		t.Errorf("max-size 100, keep-days 7: pruned %v", got)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"1024":   1024,
+		"100B":   100,
+		"500MB":  500 << 20,
+		"5G":     5 << 30,
+		"1.5 GB": 3 << 29,
+		"2kb":    2048,
+	}
+	for s, want := range tests {
+		if got, err := ParseSize(s); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "GB", "-1MB", "5XB"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) should fail", s)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package deploy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"youfun/shipyard/internal/config"
+)
+
+// Builder builds the release of a project. The returned temp directory holds the release in its
+// release/ subdirectory, the layout createTarball and ProcessArtifact expect; the caller removes it.
+type Builder interface {
+	Build(d *Deployer) (string, error)
+}
+
+// newBuilder returns the builder selected by [build] builder, filling in its defaults.
+func newBuilder(build config.Build) (Builder, error) {
+	switch build.Builder {
+	case "", config.BuilderDocker:
+		return dockerBuilder{build: build}, nil
+	case config.BuilderMix:
+		return mixBuilder{}, nil
+	case config.BuilderGo:
+		return newGoBuilder(build), nil
+	case config.BuilderTarball:
+		b := tarballBuilder{dir: build.Dir}
+		if b.dir == "" {
+			b.dir = "."
+		}
+		return b, nil
+	case config.BuilderCommand:
+		b := commandBuilder{command: build.Command, outputDir: build.OutputDir}
+		if b.outputDir == "" {
+			b.outputDir = "."
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unknown [build] builder %q, expected docker, mix, go, tarball or command", build.Builder)
+}
+
+// dockerBuilder builds with docker build and the preset Dockerfile of the runtime, or the project's own.
+type dockerBuilder struct {
+	build config.Build // The preset Go Dockerfile builds main for goos/goarch
+}
+
+func (b dockerBuilder) Build(d *Deployer) (string, error) {
+	if d.Runtime == "static" {
+		return d.buildStaticRelease()
+	}
+	return d.buildRelease(b.build)
+}
+
+// mixBuilder runs mix release on the build machine, which must match the OS and glibc of the target hosts.
+type mixBuilder struct{}
+
+func (mixBuilder) Build(d *Deployer) (string, error) {
+	log.Println("Building with native mix release (MIX_ENV=prod)...")
+	buildOutputDir, err := os.MkdirTemp("", "deployer-build-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp build directory: %w", err)
+	}
+
+	env := []string{"MIX_ENV=prod"}
+	commands := [][]string{
+		{"mix", "deps.get", "--only", "prod"},
+		{"mix", "compile"},
+	}
+	if _, err := os.Stat(d.sourcePath("assets")); err == nil && d.Runtime != "elixir" {
+		commands = append(commands, []string{"mix", "assets.deploy"})
+	}
+	commands = append(commands, []string{"mix", "release", "--overwrite", "--path", filepath.Join(buildOutputDir, "release")})
+
+	for _, args := range commands {
+		if err := d.runBuildCommand(env, args...); err != nil {
+			os.RemoveAll(buildOutputDir)
+			return "", err
+		}
+	}
+
+	log.Println("✅ mix release completed.")
+	return buildOutputDir, nil
+}
+
+// goBuilder cross-compiles the project with go build into release/bin/server, where render_unit.sh starts it.
+type goBuilder struct {
+	goos   string
+	goarch string
+	pkg    string
+}
+
+// newGoBuilder returns the go builder of the [build] settings, for linux/amd64 and the package of the
+// project root unless they say otherwise.
+func newGoBuilder(build config.Build) goBuilder {
+	b := goBuilder{goos: build.GOOS, goarch: build.GOARCH, pkg: build.Main}
+	if b.goos == "" {
+		b.goos = "linux"
+	}
+	if b.goarch == "" {
+		b.goarch = "amd64"
+	}
+	if b.pkg == "" {
+		b.pkg = "."
+	}
+	return b
+}
+
+func (b goBuilder) Build(d *Deployer) (string, error) {
+	log.Printf("Building with native go build (GOOS=%s GOARCH=%s)...", b.goos, b.goarch)
+	buildOutputDir, err := os.MkdirTemp("", "deployer-build-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp build directory: %w", err)
+	}
+
+	env := []string{"GOOS=" + b.goos, "GOARCH=" + b.goarch, "CGO_ENABLED=0"}
+	output := filepath.Join(buildOutputDir, "release", "bin", "server")
+	if err := d.runBuildCommand(env, "go", "build", "-trimpath", "-o", output, b.pkg); err != nil {
+		os.RemoveAll(buildOutputDir)
+		return "", err
+	}
+
+	log.Println("✅ go build completed.")
+	return buildOutputDir, nil
+}
+
+// tarballBuilder packs a directory of the project as it is, e.g. an app built by an earlier CI step.
+type tarballBuilder struct {
+	dir string
+}
+
+func (b tarballBuilder) Build(d *Deployer) (string, error) {
+	src := d.sourcePath(b.dir)
+	if info, err := os.Stat(src); err != nil || !info.IsDir() || (b.dir != "." && !filepath.IsLocal(b.dir)) {
+		return "", fmt.Errorf("[build] dir %q is not a directory of the project", b.dir)
+	}
+	log.Printf("Packing directory %s as the release...", b.dir)
+
+	buildOutputDir, err := os.MkdirTemp("", "deployer-build-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp build directory: %w", err)
+	}
+	if err := copyReleaseDir(src, filepath.Join(buildOutputDir, "release")); err != nil {
+		os.RemoveAll(buildOutputDir)
+		return "", fmt.Errorf("failed to copy %s: %w", b.dir, err)
+	}
+	return buildOutputDir, nil
+}
+
+// commandBuilder runs the build command of the project, then packs its output directory as the release.
+type commandBuilder struct {
+	command   string
+	outputDir string
+}
+
+func (b commandBuilder) Build(d *Deployer) (string, error) {
+	if b.outputDir != "." && !filepath.IsLocal(b.outputDir) {
+		return "", fmt.Errorf("[build] output_dir %q is not a directory of the project", b.outputDir)
+	}
+	log.Printf("Building with %q...", b.command)
+	if err := d.runBuildCommand(nil, "sh", "-c", b.command); err != nil {
+		return "", err
+	}
+	log.Println("✅ Build command completed.")
+	if info, err := os.Stat(d.sourcePath(b.outputDir)); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("[build] output_dir %q was not created by the build command", b.outputDir)
+	}
+	return tarballBuilder{dir: b.outputDir}.Build(d)
+}
+
+// runBuildCommand runs a command of a native build in the project directory, with env added to the environment.
+func (d *Deployer) runBuildCommand(env []string, args ...string) error {
+	cmd := exec.CommandContext(d.context(), args[0], args[1:]...)
+	cmd.Dir = d.sourceDir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout, cmd.Stderr = d.buildStreams()
+	if err := cmd.Run(); err != nil {
+		if err := d.checkCancelled(); err != nil {
+			log.Println("🛑 Build cancelled, nothing was deployed.")
+			return err
+		}
+		return fmt.Errorf("%s failed: %w", args[0]+" "+args[1], err)
+	}
+	return nil
+}
+
+// copyReleaseDir copies a directory without its .git directory. Symlinks to files are copied as files.
+func copyReleaseDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		dstPath := filepath.Join(dst, relPath)
+		if info.IsDir() {
+			return os.MkdirAll(dstPath, info.Mode().Perm()|0700)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(path); err != nil || !target.Mode().IsRegular() {
+				return nil
+			}
+		} else if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(path, dstPath)
+	})
+}
@@ -0,0 +1,215 @@
+package deploy
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/static"
+)
+
+func TestNewBuilderDefaults(t *testing.T) {
+	builder, err := newBuilder(config.Build{Builder: config.BuilderGo})
+	if err != nil {
+		t.Fatalf("newBuilder() failed: %v", err)
+	}
+	if b := builder.(goBuilder); b.goos != "linux" || b.goarch != "amd64" || b.pkg != "." {
+		t.Errorf("unexpected go builder defaults: %+v", b)
+	}
+
+	if _, ok := mustBuilder(t, config.Build{}).(dockerBuilder); !ok {
+		t.Error("expected the docker builder by default")
+	}
+	if b := mustBuilder(t, config.Build{Builder: config.BuilderTarball}).(tarballBuilder); b.dir != "." {
+		t.Errorf("expected the tarball builder to pack the project root, got %q", b.dir)
+	}
+	if _, err := newBuilder(config.Build{Builder: "bazel"}); err == nil {
+		t.Error("expected an unknown builder to fail")
+	}
+}
+
+func mustBuilder(t *testing.T, build config.Build) Builder {
+	t.Helper()
+	builder, err := newBuilder(build)
+	if err != nil {
+		t.Fatalf("newBuilder() failed: %v", err)
+	}
+	return builder
+}
+
+func TestTarballBuilder(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"dist/server.js":      "console.log('hi')",
+		"dist/public/a.css":   "body {}",
+		"dist/.git/HEAD":      "ref: refs/heads/main",
+		"dist/.next/BUILD_ID": "abc",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := &Deployer{sourceDir: dir}
+	buildDir, err := tarballBuilder{dir: "dist"}.Build(d)
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	for _, name := range []string{"server.js", "public/a.css", ".next/BUILD_ID"} {
+		if _, err := os.Stat(filepath.Join(buildDir, "release", name)); err != nil {
+			t.Errorf("expected release/%s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(buildDir, "release", ".git")); !os.IsNotExist(err) {
+		t.Error("expected .git to be left out of the release")
+	}
+
+	if _, err := (tarballBuilder{dir: "../"}).Build(d); err == nil {
+		t.Error("expected a directory outside the project to be refused")
+	}
+}
+
+func TestCommandBuilder(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	d := &Deployer{sourceDir: t.TempDir(), buildLog: os.Stderr}
+	builder := mustBuilder(t, config.Build{Builder: config.BuilderCommand, Command: "mkdir -p out/bin && echo ok > out/bin/app", OutputDir: "out"})
+	buildDir, err := builder.Build(d)
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	defer os.RemoveAll(buildDir)
+	if content, err := os.ReadFile(filepath.Join(buildDir, "release", "bin", "app")); err != nil || string(content) != "ok\n" {
+		t.Errorf("expected the output directory to be the release: %q, %v", content, err)
+	}
+
+	if _, err := (commandBuilder{command: "true", outputDir: "missing"}).Build(d); err == nil {
+		t.Error("expected a missing output directory to fail")
+	}
+	if _, err := (commandBuilder{command: "exit 3", outputDir: "."}).Build(d); err == nil {
+		t.Error("expected a failing build command to fail")
+	}
+
+	// The custom runtime has no preset Dockerfile
+	if _, _, err := (&Deployer{Runtime: config.RuntimeCustom, sourceDir: t.TempDir()}).prepareDockerBuild(config.Build{}); err == nil {
+		t.Error("expected the docker builder of the custom runtime to need Dockerfile.shipyard")
+	}
+}
+
+func TestGoBuilder(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go build in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/hello\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := &Deployer{sourceDir: dir, buildLog: os.Stderr}
+	buildDir, err := goBuilder{goos: "linux", goarch: "amd64", pkg: "."}.Build(d)
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	info, err := os.Stat(filepath.Join(buildDir, "release", "bin", "server"))
+	if err != nil {
+		t.Fatalf("expected release/bin/server: %v", err)
+	}
+	if info.Mode()&0111 == 0 {
+		t.Error("expected release/bin/server to be executable")
+	}
+}
+
+func TestPrepareDockerBuildPresets(t *testing.T) {
+	tests := []struct {
+		runtime    string
+		dockerfile string
+		args       []string
+	}{
+		{"node", static.DockerfileBuildNode, nil},
+		{"golang", static.DockerfileBuildGolang, []string{"MAIN=./cmd/server", "GOOS=linux", "GOARCH=arm64"}},
+		{"python", static.DockerfileBuildPython, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.runtime, func(t *testing.T) {
+			d := &Deployer{Runtime: tt.runtime, sourceDir: t.TempDir()}
+			dockerfilePath, args, err := d.prepareDockerBuild(config.Build{GOARCH: "arm64", Main: "./cmd/server"})
+			if err != nil {
+				t.Fatalf("prepareDockerBuild() failed: %v", err)
+			}
+			content, err := os.ReadFile(d.sourcePath(dockerfilePath))
+			if err != nil || string(content) != tt.dockerfile {
+				t.Errorf("expected the preset %s Dockerfile to be written (err: %v)", tt.runtime, err)
+			}
+			if !slices.Equal(args, tt.args) {
+				t.Errorf("build args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestProjectVersion(t *testing.T) {
+	defer func(cfg config.Config) { config.AppConfig = cfg }(config.AppConfig)
+	config.AppConfig = config.Config{}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name": "web", "version": "2.1.0"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := (&Deployer{Runtime: "node", sourceDir: dir}).projectVersion(); err != nil || version != "2.1.0" {
+		t.Errorf("node: projectVersion() = %q, %v, want the version of package.json", version, err)
+	}
+
+	pyDir := t.TempDir()
+	pyproject := "[build-system]\nrequires = [\"setuptools\"]\n\n[project]\nname = \"api\"\nversion = \"0.3.1\"\n"
+	if err := os.WriteFile(filepath.Join(pyDir, "pyproject.toml"), []byte(pyproject), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := (&Deployer{Runtime: "python", sourceDir: pyDir}).projectVersion(); err != nil || version != "0.3.1" {
+		t.Errorf("python: projectVersion() = %q, %v, want the version of pyproject.toml", version, err)
+	}
+
+	config.AppConfig.Version = "2024.06"
+	if version, _ := (&Deployer{Runtime: "node", sourceDir: dir}).projectVersion(); version != "2024.06" {
+		t.Errorf("expected the version of shipyard.toml to win, got %q", version)
+	}
+	config.AppConfig.Version = ""
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	goDir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"tag", "v1.4.0"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = goDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, output)
+		}
+	}
+	if version, err := (&Deployer{Runtime: "golang", sourceDir: goDir}).projectVersion(); err != nil || version != "1.4.0" {
+		t.Errorf("golang: projectVersion() = %q, %v, want the Git tag without its v", version, err)
+	}
+}
@@ -0,0 +1,228 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"youfun/shipyard/internal/caddy"
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/database"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/pkg/types"
+
+	"github.com/google/uuid"
+)
+
+// canarySteps are the traffic shares a canary goes through when promoted without an explicit weight.
+var canarySteps = []int{10, 25, 50, 100}
+
+// ErrNoCanary is returned when promoting or aborting an instance that has no canary running.
+var ErrNoCanary = errors.New("no canary is running for this instance")
+
+// ErrInvalidCanaryWeight is returned when a canary would not receive more traffic than it already does.
+var ErrInvalidCanaryWeight = errors.New("invalid canary weight")
+
+// nextCanaryWeight returns the step that follows the current share of a canary.
+func nextCanaryWeight(current int) int {
+	for _, step := range canarySteps {
+		if step > current {
+			return step
+		}
+	}
+	return 100
+}
+
+// canaryUpstreams splits the traffic between the stable release and the canary.
+func canaryUpstreams(stablePort, canaryPort, canaryWeight int) []caddy.Upstream {
+	return []caddy.Upstream{
+		{Port: stablePort, Weight: 100 - canaryWeight},
+		{Port: canaryPort, Weight: canaryWeight},
+	}
+}
+
+// startCanary sends a share of the traffic to the new release while the stable release keeps serving the rest,
+// and records the split so it can be promoted or aborted later.
+func (d *Deployer) startCanary(apiClient client.APIClient, stablePort, canaryPort int, releasePath string) error {
+	log.Printf("🐤 Starting canary: %d%% of traffic to new version (:%d), %d%% to stable version (:%d)",
+		d.canaryWeight, canaryPort, 100-d.canaryWeight, stablePort)
+
+	if err := d.caddySvc.UpdateWeightedReverseProxy(d.Domains, canaryUpstreams(stablePort, canaryPort, d.canaryWeight)); err != nil {
+		return fmt.Errorf("failed to update Caddy config: %w", err)
+	}
+
+	err := apiClient.StartCanary(d.DeploymentID, &types.StartCanaryRequest{
+		Port:         canaryPort,
+		Weight:       d.canaryWeight,
+		ReleasePath:  releasePath,
+		GitCommitSHA: d.GitCommitSHA,
+	})
+	if err != nil {
+		// A split nobody knows about could not be promoted or aborted
+		log.Printf("Sending all traffic back to stable version (:%d)...", stablePort)
+		if revertErr := d.caddySvc.UpdateReverseProxyMultiDomain(d.Domains, stablePort); revertErr != nil {
+			log.Printf("⚠️  Warning: Failed to restore Caddy config: %v", revertErr)
+		}
+		return fmt.Errorf("failed to record canary: %w", err)
+	}
+
+	d.enableService(canaryPort)
+	return nil
+}
+
+// PromoteCanary sends more traffic to the canary of an instance: weight percent, or the next step when weight is 0.
+// At 100% the canary becomes the active release and the stable release is stopped and kept as standby.
+func PromoteCanary(instanceID uuid.UUID, weight int) (*models.InstanceCanary, error) {
+	canary, err := database.GetInstanceCanary(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get canary: %w", err)
+	}
+	if canary == nil {
+		return nil, ErrNoCanary
+	}
+
+	if weight == 0 {
+		weight = nextCanaryWeight(canary.CanaryWeight)
+	}
+	if weight <= canary.CanaryWeight || weight > 100 {
+		return nil, fmt.Errorf("%w: the canary receives %d%% of traffic, promote it to between %d and 100", ErrInvalidCanaryWeight, canary.CanaryWeight, canary.CanaryWeight+1)
+	}
+
+	d, err := newInstanceDeployer(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer d.close()
+
+	domains, err := GetDomainsForDeploy(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain list: %w", err)
+	}
+
+	if weight < 100 {
+		log.Printf("🐤 Promoting canary of %s on %s to %d%%", d.AppName, d.HostName, weight)
+		if len(domains) > 0 {
+			if err := d.caddySvc.UpdateWeightedReverseProxy(domains, canaryUpstreams(canary.StablePort, canary.CanaryPort, weight)); err != nil {
+				return nil, fmt.Errorf("failed to update Caddy config: %w", err)
+			}
+		}
+		if err := database.UpdateInstanceCanaryWeight(instanceID, weight); err != nil {
+			return nil, fmt.Errorf("failed to record canary weight: %w", err)
+		}
+		_ = database.AppendDeploymentHistoryOutput(canary.DeploymentID, fmt.Sprintf("Canary promoted to %d%% of traffic\n", weight))
+		canary.CanaryWeight = weight
+		return canary, nil
+	}
+
+	log.Printf("🐤 Promoting canary of %s on %s to all traffic", d.AppName, d.HostName)
+	if err := d.switchTraffic([]int{canary.CanaryPort}, []int{canary.StablePort}, domains); err != nil {
+		return nil, err
+	}
+	// Swaps active/previous ports and marks the stable run as standby
+	if err := database.RecordSuccessfulDeployment(canary.DeploymentID, []int{canary.CanaryPort}, canary.ReleasePath, canary.GitCommitSHA); err != nil {
+		return nil, fmt.Errorf("failed to record promoted canary: %w", err)
+	}
+	if err := database.DeleteInstanceCanary(instanceID); err != nil {
+		log.Printf("⚠️ Failed to remove canary record: %v", err)
+	}
+	_ = database.AppendDeploymentHistoryOutput(canary.DeploymentID, "Canary promoted to all traffic\n")
+	if err := d.restartProcesses(canary.ReleasePath); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+	if err := d.linkCurrentRelease(canary.ReleasePath); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+
+	if summary := drainOldVersion(d.context(), d.caddySvc, canary.StablePort); summary != "" {
+		_ = database.AppendDeploymentHistoryOutput(canary.DeploymentID, summary+"\n")
+	}
+	log.Printf("🛑 Stopping previous version (:%d), files are kept as standby...", canary.StablePort)
+	d.executeRemoteCommand(fmt.Sprintf("systemctl disable %s@%d", d.AppName, canary.StablePort), false)
+	d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d", d.AppName, canary.StablePort), false)
+
+	canary.CanaryWeight = 100
+	return canary, nil
+}
+
+// AbortCanary sends all traffic of an instance back to the stable release and stops the canary.
+func AbortCanary(instanceID uuid.UUID) (*models.InstanceCanary, error) {
+	canary, err := database.GetInstanceCanary(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get canary: %w", err)
+	}
+	if canary == nil {
+		return nil, ErrNoCanary
+	}
+
+	d, err := newInstanceDeployer(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer d.close()
+
+	domains, err := GetDomainsForDeploy(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain list: %w", err)
+	}
+
+	log.Printf("⏪ Aborting canary of %s on %s, sending all traffic back to :%d", d.AppName, d.HostName, canary.StablePort)
+	if len(domains) > 0 {
+		if err := d.caddySvc.UpdateReverseProxyMultiDomain(domains, canary.StablePort); err != nil {
+			return nil, fmt.Errorf("failed to update Caddy config: %w", err)
+		}
+	}
+
+	if err := database.DeleteInstanceCanary(instanceID); err != nil {
+		return nil, fmt.Errorf("failed to remove canary record: %w", err)
+	}
+	_ = database.UpdateDeploymentHistoryStatusOnly(canary.DeploymentID, string(models.DeploymentStatusFailed))
+	_ = database.AppendDeploymentHistoryOutput(canary.DeploymentID, fmt.Sprintf("Canary aborted at %d%% of traffic, all traffic sent back to port %d\n", canary.CanaryWeight, canary.StablePort))
+
+	log.Printf("🛑 Stopping canary version (:%d)...", canary.CanaryPort)
+	d.executeRemoteCommand(fmt.Sprintf("systemctl disable %s@%d || true", d.AppName, canary.CanaryPort), false)
+	d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d || true", d.AppName, canary.CanaryPort), false)
+	d.executeRemoteCommand(fmt.Sprintf("rm -f /var/www/%s/instances/%d || true", d.AppName, canary.CanaryPort), false)
+
+	canary.CanaryWeight = 0
+	return canary, nil
+}
+
+// newInstanceDeployer prepares a deployer that acts on the host of an existing application instance.
+func newInstanceDeployer(instanceID uuid.UUID) (*Deployer, error) {
+	instance, err := database.GetApplicationInstanceByID(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get application instance: %w", err)
+	}
+	app, err := database.GetApplicationByID(instance.ApplicationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get application: %w", err)
+	}
+	host, err := database.GetSSHHostByID(instance.HostID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host: %w", err)
+	}
+
+	d := &Deployer{
+		AppName:     app.Name,
+		HostName:    host.Name,
+		Instance:    instance,
+		Application: app,
+		Host:        host,
+		IsLocalhost: host.Name == "localhost" || host.Addr == "127.0.0.1",
+	}
+	if d.IsLocalhost {
+		d.caddySvc = caddy.NewLocalService()
+		return d, nil
+	}
+	if err := d.connectSSHWithAPIConfig(); err != nil {
+		return nil, err
+	}
+	d.caddySvc = caddy.NewService(d.SSHClient)
+	return d, nil
+}
+
+// close releases the SSH connection of the deployer, if any.
+func (d *Deployer) close() {
+	if d.SSHClient != nil {
+		d.SSHClient.Close()
+	}
+}
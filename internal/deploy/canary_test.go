@@ -0,0 +1,33 @@
+package deploy
+
+import "testing"
+
+func TestNextCanaryWeight(t *testing.T) {
+	tests := []struct {
+		current, want int
+	}{
+		{current: 5, want: 10},
+		{current: 10, want: 25},
+		{current: 30, want: 50},
+		{current: 50, want: 100},
+		{current: 99, want: 100},
+	}
+	for _, tt := range tests {
+		if got := nextCanaryWeight(tt.current); got != tt.want {
+			t.Errorf("nextCanaryWeight(%d) = %d, want %d", tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestCanaryUpstreams(t *testing.T) {
+	upstreams := canaryUpstreams(8001, 8002, 10)
+	if len(upstreams) != 2 {
+		t.Fatalf("expected 2 upstreams, got %v", upstreams)
+	}
+	if upstreams[0].Port != 8001 || upstreams[0].Weight != 90 {
+		t.Errorf("expected stable port 8001 to get 90%%, got %v", upstreams[0])
+	}
+	if upstreams[1].Port != 8002 || upstreams[1].Weight != 10 {
+		t.Errorf("expected canary port 8002 to get 10%%, got %v", upstreams[1])
+	}
+}
@@ -0,0 +1,96 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// cleanupTimeout bounds the commands that undo a cancelled deployment.
+const cleanupTimeout = 30 * time.Second
+
+// deployUndo records what a deployment has created on the host so it can be undone when cancelled.
+type deployUndo struct {
+	releasePath string // Release directory created by this deployment
+	ports       []int  // Ports of the systemd units started by this deployment
+	committed   bool   // Traffic has been switched, the deployment is no longer undone
+}
+
+// context returns the context the deployment runs under.
+func (d *Deployer) context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// cancelled reports whether the deployment has been cancelled.
+func (d *Deployer) cancelled() bool {
+	return d.context().Err() != nil
+}
+
+// checkCancelled returns an error once the deployment has been cancelled.
+// It guards steps that cannot be interrupted halfway, such as API calls and Caddy updates.
+func (d *Deployer) checkCancelled() error {
+	if err := d.context().Err(); err != nil {
+		return fmt.Errorf("deployment cancelled: %w", err)
+	}
+	return nil
+}
+
+// sleep waits for the given duration unless the deployment is cancelled first.
+func (d *Deployer) sleep(duration time.Duration) error {
+	return sleepContext(d.context(), duration)
+}
+
+// sleepContext waits for the given duration unless ctx is done first.
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("deployment cancelled: %w", ctx.Err())
+	}
+}
+
+// commit marks the point after which the deployment is completed even if it gets cancelled,
+// because traffic is already served by the new release.
+func (d *Deployer) commit() {
+	d.undo.committed = true
+	d.ctx = context.WithoutCancel(d.context())
+}
+
+// cleanupCancelled undoes the steps a cancelled deployment has completed:
+// the started unit is stopped, its instance symlink and the release directory are removed.
+func (d *Deployer) cleanupCancelled() {
+	if d.undo.committed {
+		return
+	}
+	if len(d.undo.ports) == 0 && d.undo.releasePath == "" {
+		log.Println("Nothing was created on the host, no cleanup needed.")
+		return
+	}
+
+	// The deployment context is done, the cleanup commands run under their own deadline
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	deployCtx := d.ctx
+	d.ctx = ctx
+	defer func() { d.ctx = deployCtx }()
+
+	log.Println("🧹 Cleaning up cancelled deployment...")
+	for _, port := range d.undo.ports {
+		log.Printf("Stopping new version (:%d)...", port)
+		d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d || true", d.AppName, port), false)
+		d.executeRemoteCommand(fmt.Sprintf("systemctl disable %s@%d || true", d.AppName, port), false)
+		d.executeRemoteCommand(fmt.Sprintf("rm -f /var/www/%s/instances/%d || true", d.AppName, port), false)
+	}
+	if d.undo.releasePath != "" {
+		log.Printf("Removing release directory %s...", d.undo.releasePath)
+		d.executeRemoteCommand(fmt.Sprintf("rm -rf %s || true", shellQuote(d.undo.releasePath)), false)
+	}
+	log.Println("✅ Cancelled deployment cleaned up.")
+}
@@ -0,0 +1,85 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeployerSleepCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d := &Deployer{ctx: ctx}
+
+	if !d.cancelled() {
+		t.Fatal("expected deployment to be cancelled")
+	}
+	if err := d.sleep(time.Minute); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if err := d.checkCancelled(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+}
+
+func TestDeployerCommitIgnoresLaterCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Deployer{ctx: ctx}
+
+	d.commit()
+	cancel()
+
+	if d.cancelled() {
+		t.Error("expected committed deployment to ignore cancellation")
+	}
+	if !d.undo.committed {
+		t.Error("expected deployment to be marked committed")
+	}
+}
+
+func TestDeployerWithoutContext(t *testing.T) {
+	d := &Deployer{}
+	if d.cancelled() {
+		t.Error("deployment without context must not be cancelled")
+	}
+	if err := d.sleep(time.Millisecond); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCleanupCancelledRemovesRelease(t *testing.T) {
+	releasePath := filepath.Join(t.TempDir(), "1.0.0-1700000000")
+	if err := os.MkdirAll(filepath.Join(releasePath, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d := &Deployer{ctx: ctx, AppName: "my_app", IsLocalhost: true}
+	d.undo.releasePath = releasePath
+
+	d.cleanupCancelled()
+
+	if _, err := os.Stat(releasePath); !os.IsNotExist(err) {
+		t.Errorf("expected release directory to be removed, stat err: %v", err)
+	}
+	if !d.cancelled() {
+		t.Error("expected deployment to stay cancelled after cleanup")
+	}
+}
+
+func TestCleanupCancelledSkipsCommittedDeployment(t *testing.T) {
+	releasePath := t.TempDir()
+	d := &Deployer{AppName: "my_app", IsLocalhost: true}
+	d.undo.releasePath = releasePath
+	d.commit()
+
+	d.cleanupCancelled()
+
+	if _, err := os.Stat(releasePath); err != nil {
+		t.Errorf("expected release of committed deployment to be kept: %v", err)
+	}
+}
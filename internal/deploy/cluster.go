@@ -0,0 +1,72 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+	"youfun/shipyard/internal/crypto"
+	"youfun/shipyard/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// ReleaseCookieKey is the secret holding the Erlang distribution cookie shared by all nodes of an app.
+const ReleaseCookieKey = "RELEASE_COOKIE"
+
+// ClusterNodesKey is the variable of the env file listing the nodes of an app, e.g. for a libcluster
+// Epmd strategy: "shop-4001@10.0.0.1,shop-4002@10.0.0.2".
+const ClusterNodesKey = "CLUSTER_NODES"
+
+// clusterRuntime reports whether the releases of a runtime are Erlang nodes, named per instance and
+// clustered with the cookie of the app.
+func clusterRuntime(runtime string) bool {
+	return runtime == "phoenix" || runtime == "elixir"
+}
+
+// nodeHost returns the host part of the node names of the instances on a host, the address Shipyard
+// reaches it at. IPv6 addresses cannot be the host of a node name, the release keeps its own then.
+func nodeHost(addr string) string {
+	if strings.Contains(addr, ":") {
+		return ""
+	}
+	return addr
+}
+
+// NodeName returns the node name of the instance of an app on a port of a host, as the systemd unit sets
+// it in RELEASE_NODE: every instance gets its own, so both colours and scaled instances run side by side.
+func NodeName(appName string, port int, host string) string {
+	return fmt.Sprintf("%s-%d@%s", appName, port, host)
+}
+
+// ClusterNodes returns the node names of the instances of an app serving traffic on all its hosts.
+func ClusterNodes(appName string, peers []database.ClusterPeer) []string {
+	var nodes []string
+	for _, peer := range peers {
+		if host := nodeHost(peer.HostAddr); host != "" {
+			nodes = append(nodes, NodeName(appName, peer.Port, host))
+		}
+	}
+	return nodes
+}
+
+// clusterEnv lists the nodes serving traffic when the deployment starts in CLUSTER_NODES, unless the
+// env of the app sets it. The new instances connect to them, so work can be handed over before the
+// old instances are stopped.
+func clusterEnv(envs map[string]interface{}, nodes []string) {
+	if _, exists := envs[ClusterNodesKey]; exists || len(nodes) == 0 {
+		return
+	}
+	envs[ClusterNodesKey] = strings.Join(nodes, ",")
+}
+
+// ensureReleaseCookie returns the cookie of an application, generating and saving it on first use.
+func ensureReleaseCookie(appID uuid.UUID) (string, error) {
+	cookie, err := crypto.GenerateReleaseCookie()
+	if err != nil {
+		return "", err
+	}
+	cookie, err = database.EnsureSecret(appID, ReleaseCookieKey, cookie)
+	if err != nil {
+		return "", fmt.Errorf("failed to save %s: %w", ReleaseCookieKey, err)
+	}
+	return cookie, nil
+}
@@ -0,0 +1,45 @@
+package deploy
+
+import (
+	"testing"
+	"youfun/shipyard/internal/database"
+)
+
+func TestClusterNodes(t *testing.T) {
+	peers := []database.ClusterPeer{
+		{HostAddr: "10.0.0.1", Port: 4001},
+		{HostAddr: "10.0.0.1", Port: 4002},
+		{HostAddr: "app2.example.com", Port: 4005},
+		{HostAddr: "2001:db8::1", Port: 4001},
+	}
+	nodes := ClusterNodes("shop", peers)
+	want := []string{"shop-4001@10.0.0.1", "shop-4002@10.0.0.1", "shop-4005@app2.example.com"}
+	if len(nodes) != len(want) {
+		t.Fatalf("ClusterNodes() = %v, want %v", nodes, want)
+	}
+	for i := range want {
+		if nodes[i] != want[i] {
+			t.Errorf("node %d = %s, want %s", i, nodes[i], want[i])
+		}
+	}
+}
+
+func TestClusterEnv(t *testing.T) {
+	envs := map[string]interface{}{}
+	clusterEnv(envs, nil)
+	if _, ok := envs[ClusterNodesKey]; ok {
+		t.Errorf("CLUSTER_NODES set without nodes: %v", envs)
+	}
+
+	clusterEnv(envs, []string{"shop-4001@10.0.0.1", "shop-4005@10.0.0.2"})
+	if envs[ClusterNodesKey] != "shop-4001@10.0.0.1,shop-4005@10.0.0.2" {
+		t.Errorf("CLUSTER_NODES = %v", envs[ClusterNodesKey])
+	}
+
+	// Set in the env of the app, e.g. to a fixed list of seed nodes
+	envs = map[string]interface{}{ClusterNodesKey: "seed@10.0.0.9"}
+	clusterEnv(envs, []string{"shop-4001@10.0.0.1"})
+	if envs[ClusterNodesKey] != "seed@10.0.0.9" {
+		t.Errorf("CLUSTER_NODES = %v, want the one of the env", envs[ClusterNodesKey])
+	}
+}
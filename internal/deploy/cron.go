@@ -0,0 +1,392 @@
+package deploy
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/models"
+)
+
+// CronJob is the state of a scheduled job of [[cron]] on a host.
+type CronJob struct {
+	Name       string
+	Schedule   string // systemd calendar event of the timer
+	NextRun    string
+	LastRun    string
+	LastResult string // success, exit-code, timeout, ... of the last run, "" before the first one
+	ExitStatus string
+	Running    bool
+}
+
+// CronRun is a past run of a scheduled job, read from the journal.
+type CronRun struct {
+	Started    time.Time
+	Finished   time.Time // zero while the job runs
+	Result     string    // success, failed or running
+	Reason     string    // why the run failed: exit-code, timeout, signal, ...
+	ExitStatus int
+}
+
+// CronUnit returns the name of the systemd units of a scheduled job, without the .service or .timer suffix.
+func CronUnit(appName, job string) string {
+	return fmt.Sprintf("%s-cron-%s", appName, job)
+}
+
+// currentReleaseDir returns the link to the active release, where scheduled jobs run.
+func currentReleaseDir(appName string) string {
+	return fmt.Sprintf("/var/www/%s/current", appName)
+}
+
+// systemdQuote quotes a word for ExecStart: specifiers and environment variables of systemd are escaped,
+// so the shell gets the word as it is.
+func systemdQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`).Replace(s) + `"`
+}
+
+// cronScript returns the shell command a scheduled job runs in the active release, built like the command of a
+// hook of the same type.
+func cronScript(appName, runtime string, job config.Cron) (string, error) {
+	command := strings.NewReplacer(
+		"{{release_path}}", currentReleaseDir(appName),
+		"{{app_name}}", appName,
+	).Replace(job.Command)
+
+	switch job.Type {
+	case "eval":
+		return fmt.Sprintf("exec %s eval %s", path.Join(currentReleaseDir(appName), "bin", appName), shellQuote(command)), nil
+	case "shell":
+		// Python releases vendor their dependencies in venv/, run the command inside it
+		if runtime == "python" {
+			return `export VIRTUAL_ENV="$PWD/venv" PATH="$PWD/venv/bin:$PATH"; ` + command, nil
+		}
+		return command, nil
+	}
+	return "", fmt.Errorf("unknown cron job type: '%s'", job.Type)
+}
+
+// cronUnits returns the oneshot service and the timer of a scheduled job. The service runs in the active release
+// with the variables of /etc/<app>/env.
+func cronUnits(appName, runtime string, job config.Cron) (service, timer string, err error) {
+	schedule, err := job.OnCalendar()
+	if err != nil {
+		return "", "", err
+	}
+	script, err := cronScript(appName, runtime, job)
+	if err != nil {
+		return "", "", err
+	}
+	timeout := "infinity"
+	if job.Timeout > 0 {
+		timeout = strconv.Itoa(int(job.Timeout.Round(time.Second)/time.Second)) + "s"
+	}
+	unit := CronUnit(appName, job.Name)
+
+	service = fmt.Sprintf(`[Unit]
+Description=%s cron job %s
+After=network.target
+
+[Service]
+Type=oneshot
+User=phoenix
+Group=phoenix
+WorkingDirectory=%s
+EnvironmentFile=/etc/%s/env
+Environment=CRON_JOB=%s
+ExecStart=/bin/sh -c %s
+TimeoutStartSec=%s
+StandardOutput=journal
+StandardError=journal
+SyslogIdentifier=%s
+`, appName, job.Name, currentReleaseDir(appName), appName, job.Name, systemdQuote(script), timeout, unit)
+
+	timer = fmt.Sprintf(`[Unit]
+Description=Schedule of %s cron job %s
+
+[Timer]
+OnCalendar=%s
+Unit=%s.service
+
+[Install]
+WantedBy=timers.target
+`, appName, job.Name, schedule, unit)
+	return service, timer, nil
+}
+
+// cronInstallCommand returns the shell command that checks the schedule of a job, writes its units and
+// (re)starts its timer.
+func cronInstallCommand(appName, runtime string, job config.Cron) (string, error) {
+	service, timer, err := cronUnits(appName, runtime, job)
+	if err != nil {
+		return "", err
+	}
+	schedule, _ := job.OnCalendar()
+	unit := "/etc/systemd/system/" + CronUnit(appName, job.Name)
+	return fmt.Sprintf("systemd-analyze calendar %s >/dev/null && echo '%s' | base64 -d > %s.service && echo '%s' | base64 -d > %s.timer && systemctl daemon-reload && systemctl enable %s.timer && systemctl restart %s.timer",
+		shellQuote(schedule),
+		base64.StdEncoding.EncodeToString([]byte(service)), unit,
+		base64.StdEncoding.EncodeToString([]byte(timer)), unit,
+		path.Base(unit), path.Base(unit)), nil
+}
+
+// cronRemoveCommand returns the shell command that stops the timer of a job and removes its units.
+func cronRemoveCommand(appName, job string) string {
+	unit := CronUnit(appName, job)
+	return fmt.Sprintf("systemctl disable --now %s.timer || true; rm -f /etc/systemd/system/%s.timer /etc/systemd/system/%s.service && systemctl daemon-reload", unit, unit, unit)
+}
+
+// linkCurrentRelease points the link to the active release at a release.
+func (d *Deployer) linkCurrentRelease(releasePath string) error {
+	current := currentReleaseDir(d.AppName)
+	if err := d.executeRemoteCommand(fmt.Sprintf("ln -sfn %s %s && (chown -h phoenix:phoenix %s || true)", shellQuote(releasePath), current, current), false); err != nil {
+		return fmt.Errorf("failed to link the active release: %w", err)
+	}
+	return nil
+}
+
+// hostNames returns the names of hosts.
+func hostNames(hosts []models.SSHHost) []string {
+	names := make([]string, len(hosts))
+	for i, host := range hosts {
+		names[i] = host.Name
+	}
+	return names
+}
+
+// cronJobHost returns the host that runs a scheduled job, so that it runs once and not on every host of the app:
+// its host setting, or else the first of the hosts the app is linked to, sorted by name.
+// It is empty when the hosts of the app are not known, the job then runs on the host being deployed.
+func cronJobHost(job config.Cron, linkedHosts []string) string {
+	if job.Host != "" || len(linkedHosts) == 0 {
+		return job.Host
+	}
+	return linkedHosts[0]
+}
+
+// cronJobsOn splits the jobs of [[cron]] into the jobs hostName runs and the installed jobs to remove from it:
+// jobs removed from shipyard.toml and jobs that run on another host.
+func cronJobsOn(hostName string, linkedHosts []string, jobs []config.Cron, installed []string) (scheduled []config.Cron, removed []string, err error) {
+	runs := make(map[string]bool)
+	for _, job := range jobs {
+		host := cronJobHost(job, linkedHosts)
+		if job.Host != "" && len(linkedHosts) > 0 && !slices.Contains(linkedHosts, job.Host) {
+			return nil, nil, fmt.Errorf("[[cron]] %s runs on host '%s', which the app is not linked to", job.Name, job.Host)
+		}
+		if host == "" || host == hostName {
+			runs[job.Name] = true
+			scheduled = append(scheduled, job)
+		}
+	}
+	for _, name := range installed {
+		if !runs[name] {
+			removed = append(removed, name)
+		}
+	}
+	return scheduled, removed, nil
+}
+
+// deployCron points the scheduled jobs of [[cron]] at the release: the link to the active release moves to it,
+// the units of the jobs this host runs are rendered again, and the timers of jobs removed from shipyard.toml or
+// running on another host are removed.
+func (d *Deployer) deployCron(releasePath string) error {
+	if err := d.linkCurrentRelease(releasePath); err != nil {
+		return err
+	}
+	installed, err := d.installedCronJobs()
+	if err != nil {
+		return err
+	}
+	if len(config.AppConfig.Cron) == 0 && len(installed) == 0 {
+		return nil
+	}
+	if err := config.AppConfig.ValidateCron(); err != nil {
+		return &ConfigError{Err: err}
+	}
+	scheduled, removed, err := cronJobsOn(d.HostName, d.linkedHosts, config.AppConfig.Cron, installed)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	hosts := make(map[string]string)
+	for _, job := range config.AppConfig.Cron {
+		hosts[job.Name] = cronJobHost(job, d.linkedHosts)
+	}
+	for _, job := range scheduled {
+		schedule, _ := job.OnCalendar()
+		log.Printf("⏰ Scheduling cron job %s (%s): %s", job.Name, schedule, job.Command)
+		cmd, err := cronInstallCommand(d.AppName, d.Runtime, job)
+		if err != nil {
+			return err
+		}
+		if err := d.executeRemoteCommand(cmd, true); err != nil {
+			return fmt.Errorf("failed to schedule cron job %s: %w", job.Name, err)
+		}
+	}
+
+	for _, name := range removed {
+		if host, ok := hosts[name]; ok {
+			log.Printf("🧹 Removing cron job %s, it runs on host %s", name, host)
+		} else {
+			log.Printf("🧹 Removing cron job %s, it is no longer in shipyard.toml", name)
+		}
+		if err := d.executeRemoteCommand(cronRemoveCommand(d.AppName, name), true); err != nil {
+			log.Printf("⚠️ Failed to remove cron job %s: %v", name, err)
+		}
+	}
+	log.Println("✅ Cron jobs are scheduled on the new release.")
+	return nil
+}
+
+// installedCronJobs lists the scheduled jobs with a timer on the host.
+func (d *Deployer) installedCronJobs() ([]string, error) {
+	output, err := d.executeRemoteCommandWithOutput(cronTimersCommand(d.AppName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cron jobs: %w", err)
+	}
+	return parseCronTimers(d.AppName, output), nil
+}
+
+// cronTimersCommand returns the shell command listing the timer files of the scheduled jobs of an app.
+func cronTimersCommand(appName string) string {
+	return fmt.Sprintf("cd /etc/systemd/system && ls -1 %s-cron-*.timer 2>/dev/null || true", appName)
+}
+
+// parseCronTimers returns the names of the jobs of the timer files listed by cronTimersCommand.
+func parseCronTimers(appName, output string) []string {
+	var names []string
+	for _, file := range strings.Fields(output) {
+		name, ok := strings.CutPrefix(file, appName+"-cron-")
+		if !ok {
+			continue
+		}
+		if name, ok = strings.CutSuffix(name, ".timer"); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// CronStatusCommand returns the shell command showing the timers and services of the scheduled jobs of an app,
+// parsed by ParseCronJobs.
+func CronStatusCommand(appName string) string {
+	return fmt.Sprintf(`cd /etc/systemd/system && units=$(ls -1 %s-cron-*.timer 2>/dev/null); [ -z "$units" ] || systemctl show -p Id,TimersCalendar,NextElapseUSecRealtime,LastTriggerUSec,Result,ExecMainStatus,ActiveState $units $(echo "$units" | sed 's/\.timer$/.service/')`, appName)
+}
+
+// ParseCronJobs parses the output of CronStatusCommand, sorted by name as systemctl lists them.
+func ParseCronJobs(appName, output string) []CronJob {
+	var jobs []CronJob
+	index := make(map[string]int)
+	job := func(name string) *CronJob {
+		if i, ok := index[name]; ok {
+			return &jobs[i]
+		}
+		index[name] = len(jobs)
+		jobs = append(jobs, CronJob{Name: name})
+		return &jobs[len(jobs)-1]
+	}
+
+	// systemctl show prints the properties of each unit, separated by a blank line
+	for _, block := range strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n\n") {
+		props := make(map[string]string)
+		for _, line := range strings.Split(block, "\n") {
+			if key, value, ok := strings.Cut(line, "="); ok {
+				props[key] = value
+			}
+		}
+		rest, ok := strings.CutPrefix(props["Id"], appName+"-cron-")
+		if !ok {
+			continue
+		}
+		if name, ok := strings.CutSuffix(rest, ".timer"); ok {
+			j := job(name)
+			j.NextRun = props["NextElapseUSecRealtime"]
+			j.LastRun = props["LastTriggerUSec"]
+			// TimersCalendar={ OnCalendar=*-*-* 03:00:00 ; next_elapse=... }
+			if _, calendar, ok := strings.Cut(props["TimersCalendar"], "OnCalendar="); ok {
+				j.Schedule, _, _ = strings.Cut(calendar, " ;")
+			}
+		} else if name, ok := strings.CutSuffix(rest, ".service"); ok {
+			j := job(name)
+			j.Running = props["ActiveState"] == "activating"
+			j.LastResult = props["Result"]
+			j.ExitStatus = props["ExecMainStatus"]
+		}
+	}
+	// A job that never ran only has the initial result of systemd
+	for i := range jobs {
+		if jobs[i].LastRun == "" || jobs[i].LastRun == "n/a" {
+			jobs[i].LastResult, jobs[i].ExitStatus = "", ""
+		}
+	}
+	return jobs
+}
+
+// CronRunCommand returns the shell command that runs a scheduled job now, waits for it and prints its output.
+// It exits with the status of systemctl, non-zero when the job failed.
+func CronRunCommand(appName, job string) string {
+	unit := CronUnit(appName, job) + ".service"
+	return fmt.Sprintf(`systemctl start %s; status=$?; id=$(systemctl show -p InvocationID --value %s); [ -z "$id" ] || journalctl _SYSTEMD_INVOCATION_ID="$id" -o cat --no-pager; exit $status`, unit, unit)
+}
+
+// CronHistoryCommand returns the shell command reading the journal of a scheduled job, parsed by ParseCronHistory.
+func CronHistoryCommand(appName, job string) string {
+	return fmt.Sprintf("journalctl -u %s.service -o json --no-pager -n 5000", CronUnit(appName, job))
+}
+
+// ParseCronHistory reads the runs of a scheduled job from the JSON journal of its service, newest first.
+// systemd records the start and the result of every job of the unit, and the exit status of failed runs.
+func ParseCronHistory(output string) []CronRun {
+	var runs []CronRun
+	var current *CronRun
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		field := func(name string) string {
+			value, _ := entry[name].(string)
+			return value
+		}
+		usec, err := strconv.ParseInt(field("__REALTIME_TIMESTAMP"), 10, 64)
+		if err != nil {
+			continue
+		}
+		at := time.UnixMicro(usec)
+
+		switch {
+		case field("JOB_TYPE") == "start" && field("JOB_RESULT") == "":
+			runs = append(runs, CronRun{Started: at, Result: "running"})
+			current = &runs[len(runs)-1]
+		case current == nil:
+			continue
+		case field("EXIT_STATUS") != "":
+			current.ExitStatus, _ = strconv.Atoi(field("EXIT_STATUS"))
+		case field("UNIT_RESULT") != "":
+			current.Reason = field("UNIT_RESULT")
+		case field("JOB_TYPE") == "start":
+			current.Finished = at
+			current.Result = "success"
+			if field("JOB_RESULT") != "done" {
+				current.Result = "failed"
+				if current.Reason == "" {
+					current.Reason = field("JOB_RESULT")
+				}
+			}
+			current = nil
+		}
+	}
+
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs
+}
@@ -0,0 +1,158 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"youfun/shipyard/internal/config"
+)
+
+func TestCronUnits(t *testing.T) {
+	job := config.Cron{Name: "nightly", Schedule: "0 3 * * *", Type: "eval", Command: `Shop.Tasks.run("100%")`, Timeout: 10 * time.Minute}
+	service, timer, err := cronUnits("shop", "phoenix", job)
+	if err != nil {
+		t.Fatalf("cronUnits() error = %v", err)
+	}
+	for _, want := range []string{
+		"Type=oneshot",
+		"WorkingDirectory=/var/www/shop/current",
+		"EnvironmentFile=/etc/shop/env",
+		"Environment=CRON_JOB=nightly",
+		`ExecStart=/bin/sh -c "exec /var/www/shop/current/bin/shop eval 'Shop.Tasks.run(\"100%%\")'"`,
+		"TimeoutStartSec=600s",
+		"SyslogIdentifier=shop-cron-nightly",
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("service unit = %s\nmissing %s", service, want)
+		}
+	}
+	if !strings.Contains(timer, "OnCalendar=*-*-* 3:0:00\nUnit=shop-cron-nightly.service\n") {
+		t.Errorf("timer unit = %s", timer)
+	}
+
+	job = config.Cron{Name: "cleanup", Schedule: "hourly", Type: "shell", Command: "bin/cleanup $HOME {{app_name}}"}
+	service, _, err = cronUnits("api", "python", job)
+	if err != nil {
+		t.Fatalf("cronUnits() error = %v", err)
+	}
+	for _, want := range []string{
+		`ExecStart=/bin/sh -c "export VIRTUAL_ENV=\"$$PWD/venv\" PATH=\"$$PWD/venv/bin:$$PATH\"; bin/cleanup $$HOME api"`,
+		"TimeoutStartSec=infinity",
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("service unit = %s\nmissing %s", service, want)
+		}
+	}
+}
+
+func TestParseCronTimers(t *testing.T) {
+	names := parseCronTimers("shop", "shop-cron-nightly.timer\nshop-cron-cleanup.timer\nshopify-cron-x.timer\nshop-cron-.timer\n")
+	if strings.Join(names, ",") != "nightly,cleanup" {
+		t.Errorf("parseCronTimers() = %v", names)
+	}
+}
+
+func TestCronJobsOn(t *testing.T) {
+	jobs := []config.Cron{
+		{Name: "nightly", Schedule: "daily", Type: "eval", Command: "Shop.Tasks.nightly()"},
+		{Name: "reports", Schedule: "hourly", Type: "shell", Command: "bin/reports", Host: "web-2"},
+	}
+	linked := []string{"web-1", "web-2"}
+	names := func(jobs []config.Cron) string {
+		var names []string
+		for _, job := range jobs {
+			names = append(names, job.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	// The first host runs the jobs without a host, and drops the jobs it ran before
+	scheduled, removed, err := cronJobsOn("web-1", linked, jobs, []string{"nightly", "reports", "old"})
+	if err != nil {
+		t.Fatalf("cronJobsOn() error = %v", err)
+	}
+	if names(scheduled) != "nightly" || strings.Join(removed, ",") != "reports,old" {
+		t.Errorf("web-1 schedules %v and removes %v", names(scheduled), removed)
+	}
+
+	// A second host removes the timers of the jobs of the first one, so they do not run twice
+	scheduled, removed, err = cronJobsOn("web-2", linked, jobs, []string{"nightly"})
+	if err != nil {
+		t.Fatalf("cronJobsOn() error = %v", err)
+	}
+	if names(scheduled) != "reports" || strings.Join(removed, ",") != "nightly" {
+		t.Errorf("web-2 schedules %v and removes %v", names(scheduled), removed)
+	}
+
+	// Without the hosts of the app, the host being deployed runs the jobs without a host
+	scheduled, _, _ = cronJobsOn("web-1", nil, jobs, nil)
+	if names(scheduled) != "nightly" {
+		t.Errorf("web-1 schedules %v without linked hosts", names(scheduled))
+	}
+
+	if _, _, err := cronJobsOn("web-1", linked, []config.Cron{{Name: "x", Host: "db-1"}}, nil); err == nil {
+		t.Error("expected an error for a host the app is not linked to")
+	}
+}
+
+func TestParseCronJobs(t *testing.T) {
+	output := `Id=shop-cron-cleanup.timer
+TimersCalendar={ OnCalendar=*-*-* *:00:00 ; next_elapse=Fri 2026-10-16 11:00:00 UTC }
+NextElapseUSecRealtime=Fri 2026-10-16 11:00:00 UTC
+LastTriggerUSec=n/a
+
+Id=shop-cron-nightly.timer
+TimersCalendar={ OnCalendar=*-*-* 03:00:00 ; next_elapse=Sat 2026-10-17 03:00:00 UTC }
+NextElapseUSecRealtime=Sat 2026-10-17 03:00:00 UTC
+LastTriggerUSec=Fri 2026-10-16 03:00:00 UTC
+
+Id=shop-cron-cleanup.service
+Result=success
+ExecMainStatus=0
+ActiveState=inactive
+
+Id=shop-cron-nightly.service
+Result=exit-code
+ExecMainStatus=2
+ActiveState=failed
+`
+	jobs := ParseCronJobs("shop", output)
+	want := []CronJob{
+		{Name: "cleanup", Schedule: "*-*-* *:00:00", NextRun: "Fri 2026-10-16 11:00:00 UTC", LastRun: "n/a"},
+		{Name: "nightly", Schedule: "*-*-* 03:00:00", NextRun: "Sat 2026-10-17 03:00:00 UTC", LastRun: "Fri 2026-10-16 03:00:00 UTC", LastResult: "exit-code", ExitStatus: "2"},
+	}
+	if len(jobs) != len(want) {
+		t.Fatalf("ParseCronJobs() = %+v", jobs)
+	}
+	for i := range want {
+		if jobs[i] != want[i] {
+			t.Errorf("job %d = %+v, want %+v", i, jobs[i], want[i])
+		}
+	}
+}
+
+func TestParseCronHistory(t *testing.T) {
+	output := `{"__REALTIME_TIMESTAMP":"1760580000000000","JOB_TYPE":"start","MESSAGE":"Starting shop cron job nightly..."}
+{"__REALTIME_TIMESTAMP":"1760580001000000","MESSAGE":"migrated 12 rows"}
+{"__REALTIME_TIMESTAMP":"1760580042000000","JOB_TYPE":"start","JOB_RESULT":"done","MESSAGE":"Finished shop cron job nightly."}
+{"__REALTIME_TIMESTAMP":"1760666400000000","JOB_TYPE":"start","MESSAGE":"Starting shop cron job nightly..."}
+{"__REALTIME_TIMESTAMP":"1760666405000000","EXIT_CODE":"exited","EXIT_STATUS":"1","MESSAGE":"Main process exited, code=exited, status=1/FAILURE"}
+{"__REALTIME_TIMESTAMP":"1760666405000001","UNIT_RESULT":"exit-code","MESSAGE":"Failed with result 'exit-code'."}
+{"__REALTIME_TIMESTAMP":"1760666405000002","JOB_TYPE":"start","JOB_RESULT":"failed","MESSAGE":"Failed to start shop cron job nightly."}
+{"__REALTIME_TIMESTAMP":"1760752800000000","JOB_TYPE":"start","MESSAGE":"Starting shop cron job nightly..."}
+not json
+`
+	runs := ParseCronHistory(output)
+	if len(runs) != 3 {
+		t.Fatalf("ParseCronHistory() = %+v", runs)
+	}
+	if runs[0].Result != "running" || !runs[0].Finished.IsZero() {
+		t.Errorf("newest run = %+v, want running", runs[0])
+	}
+	if runs[1].Result != "failed" || runs[1].Reason != "exit-code" || runs[1].ExitStatus != 1 {
+		t.Errorf("failed run = %+v", runs[1])
+	}
+	if runs[2].Result != "success" || runs[2].Finished.Sub(runs[2].Started) != 42*time.Second {
+		t.Errorf("successful run = %+v", runs[2])
+	}
+}
@@ -0,0 +1,370 @@
+package deploy
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"youfun/shipyard/internal/compression"
+	"youfun/shipyard/internal/config"
+)
+
+// ManifestEntry is a regular file of a release.
+type ManifestEntry struct {
+	Path   string // Relative to the release directory, e.g. "lib/my_app-1.2.0/ebin/my_app.beam"
+	Size   int64
+	Mode   int64 // Permission bits
+	SHA256 string
+}
+
+// remoteFile is a regular file of the active release on the host.
+type remoteFile struct {
+	mode   int64
+	sha256 string
+}
+
+// deltaPlan splits the files of a new release into the ones the host already has in the active
+// release and the ones that must be uploaded.
+type deltaPlan struct {
+	reuse       []string // Files hard-linked (or copied) from the active release
+	reused      int64    // Bytes not uploaded
+	total       int64    // Bytes of all files of the release
+	uploadFiles int
+}
+
+// uploadRelease uploads the release tarball into releasePath. When the host has an active release,
+// only the files that changed since it are uploaded and the others are hard-linked from it; the
+// release is then checked against the manifest of the tarball. Any failure of the delta upload
+// falls back to uploading the full tarball.
+func (d *Deployer) uploadRelease(tarballPath, releasePath string) error {
+	hostTarball, format, err := d.tarballForHost(tarballPath, d.compression)
+	if err != nil {
+		return err
+	}
+	if hostTarball != tarballPath {
+		defer os.Remove(hostTarball)
+	}
+	tarballPath = hostTarball
+
+	if config.AppConfig.Upload != config.UploadDelta || d.IsLocalhost {
+		return d.uploadTarFile(tarballPath, releasePath, format)
+	}
+
+	activeRelease := d.activeReleaseDir()
+	if activeRelease == "" || path.Clean(activeRelease) == path.Clean(releasePath) {
+		return d.uploadTarFile(tarballPath, releasePath, format)
+	}
+
+	err = d.uploadDelta(tarballPath, format, releasePath, activeRelease)
+	if err == nil {
+		return nil
+	}
+	if d.cancelled() {
+		return err
+	}
+	log.Printf("⚠️  Delta upload failed, uploading the full release: %v", err)
+	if err := d.executeRemoteCommand(fmt.Sprintf("rm -rf %s && mkdir -p %s", shellQuote(releasePath), shellQuote(releasePath)), false); err != nil {
+		return err
+	}
+	return d.uploadTarFile(tarballPath, releasePath, format)
+}
+
+// tarballForHost returns a tarball of the release the host can extract, and its format. When the host lacks
+// the decompressor of the artifact's format, the artifact is recompressed as gzip into a temp file.
+func (d *Deployer) tarballForHost(tarballPath, format string) (string, string, error) {
+	program := compression.Decompressor(format)
+	if program == "" {
+		return tarballPath, compression.Normalize(format), nil
+	}
+	if _, err := d.executeRemoteCommandWithOutput("command -v " + program); err == nil {
+		return tarballPath, format, nil
+	}
+
+	log.Printf("⚠️  %s is not installed on %s, uploading the release as gzip", program, d.HostName)
+	gzipPath, err := compression.Recompress(tarballPath, format, compression.Gzip)
+	if err != nil {
+		return "", "", err
+	}
+	return gzipPath, compression.Gzip, nil
+}
+
+// activeReleaseDir returns the release directory of the version serving traffic, or "" without one.
+func (d *Deployer) activeReleaseDir() string {
+	if d.oldPort <= 0 {
+		return ""
+	}
+	output, err := d.executeRemoteCommandWithOutput(fmt.Sprintf("readlink -f /var/www/%s/instances/%d", d.AppName, d.oldPort))
+	if err != nil || output == "" {
+		return ""
+	}
+	return output
+}
+
+func (d *Deployer) uploadDelta(tarballPath, format, releasePath, activeRelease string) error {
+	manifest, err := ReadTarballManifest(tarballPath, format)
+	if err != nil {
+		return err
+	}
+	remote, err := d.remoteReleaseFiles(activeRelease)
+	if err != nil {
+		return err
+	}
+
+	plan := planDelta(manifest, remote)
+	if len(plan.reuse) == 0 {
+		log.Println("📦 No files of the active release can be reused, uploading the full release")
+		return d.uploadTarFile(tarballPath, releasePath, format)
+	}
+	log.Printf("📦 Delta upload: %d of %d files changed, reusing %s of %s from %s",
+		plan.uploadFiles, len(manifest), FormatBytes(plan.reused), FormatBytes(plan.total), path.Base(activeRelease))
+
+	deltaPath, err := writeDeltaTarball(tarballPath, format, plan.reuse)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(deltaPath)
+
+	if err := d.uploadTarFile(deltaPath, releasePath, format); err != nil {
+		return err
+	}
+
+	// Hard links share the file with the active release; cp is the fallback across file systems
+	// or when the host does not allow linking files of another user
+	linkCmd := fmt.Sprintf(`cd %s && while IFS= read -r f; do mkdir -p "$(dirname %s/"$f")" && { ln -f -- "$f" %s/"$f" 2>/dev/null || cp -p -- "$f" %s/"$f"; } || exit 1; done`,
+		shellQuote(activeRelease), shellQuote(releasePath), shellQuote(releasePath), shellQuote(releasePath))
+	if _, err := d.runRemoteCommandWithInput(linkCmd, strings.NewReader(strings.Join(plan.reuse, "\n")+"\n")); err != nil {
+		return fmt.Errorf("failed to reuse files of the active release: %w", err)
+	}
+
+	// Every file of the new release must match the manifest
+	verifyCmd := fmt.Sprintf("cd %s && sha256sum --quiet --strict -c -", shellQuote(releasePath))
+	if output, err := d.runRemoteCommandWithInput(verifyCmd, strings.NewReader(checksumList(manifest))); err != nil {
+		return fmt.Errorf("release does not match its manifest: %w\n%s", err, output)
+	}
+	log.Printf("✅ Delta upload verified (%d files)", len(manifest))
+	return nil
+}
+
+// remoteReleaseFiles lists the regular files of a release directory on the host with their SHA-256.
+func (d *Deployer) remoteReleaseFiles(releaseDir string) (map[string]remoteFile, error) {
+	output, err := d.executeRemoteCommandWithOutput(fmt.Sprintf(
+		`cd %s && find . -type f -printf 'mode %%m %%P\n' && find . -type f -print0 | xargs -0 -r sha256sum`, shellQuote(releaseDir)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the files of the active release: %w", err)
+	}
+	return parseRemoteFiles(output), nil
+}
+
+// parseRemoteFiles parses the "mode <octal> <path>" lines of find and the "<sha256>  ./<path>" lines of
+// sha256sum. Paths sha256sum escapes (with a backslash or a newline) are left out, so they are uploaded.
+func parseRemoteFiles(output string) map[string]remoteFile {
+	modes := make(map[string]int64)
+	files := make(map[string]remoteFile)
+	for _, line := range strings.Split(output, "\n") {
+		if rest, ok := strings.CutPrefix(line, "mode "); ok {
+			mode, name, found := strings.Cut(rest, " ")
+			if m, err := strconv.ParseInt(mode, 8, 64); found && err == nil {
+				modes[name] = m & 0o777
+			}
+			continue
+		}
+		if strings.HasPrefix(line, `\`) {
+			continue
+		}
+		sum, name, found := strings.Cut(line, "  ")
+		if !found || len(sum) != sha256.Size*2 {
+			continue
+		}
+		files[strings.TrimPrefix(name, "./")] = remoteFile{sha256: sum}
+	}
+	for name, file := range files {
+		mode, ok := modes[name]
+		if !ok {
+			delete(files, name)
+			continue
+		}
+		file.mode = mode
+		files[name] = file
+	}
+	return files
+}
+
+// planDelta selects the files of the manifest the host has with the same content and permissions.
+func planDelta(manifest []ManifestEntry, remote map[string]remoteFile) deltaPlan {
+	var plan deltaPlan
+	for _, entry := range manifest {
+		plan.total += entry.Size
+		file, ok := remote[entry.Path]
+		if ok && file.sha256 == entry.SHA256 && file.mode == entry.Mode && !strings.Contains(entry.Path, "\n") {
+			plan.reuse = append(plan.reuse, entry.Path)
+			plan.reused += entry.Size
+			continue
+		}
+		plan.uploadFiles++
+	}
+	return plan
+}
+
+// ReadTarballManifest lists the regular files of a release tarball compressed in the given format.
+func ReadTarballManifest(tarballPath, format string) ([]ManifestEntry, error) {
+	file, err := os.Open(tarballPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tarball (%s): %w", tarballPath, err)
+	}
+	defer file.Close()
+
+	compressReader, err := compression.NewReader(file, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tarball (%s): %w", tarballPath, err)
+	}
+	defer compressReader.Close()
+
+	var manifest []ManifestEntry
+	tarReader := tar.NewReader(compressReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tarball (%s): %w", tarballPath, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		hash := sha256.New()
+		if _, err := io.Copy(hash, tarReader); err != nil {
+			return nil, fmt.Errorf("failed to read %s from tarball: %w", header.Name, err)
+		}
+		manifest = append(manifest, ManifestEntry{
+			Path:   releaseEntryPath(header.Name),
+			Size:   header.Size,
+			Mode:   header.Mode & 0o777,
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		})
+	}
+	return manifest, nil
+}
+
+// writeDeltaTarball copies the tarball without the regular files in reuse, in the same format. Directories,
+// symlinks and the files that changed are kept.
+func writeDeltaTarball(tarballPath, format string, reuse []string) (string, error) {
+	skip := make(map[string]bool, len(reuse))
+	for _, name := range reuse {
+		skip[name] = true
+	}
+
+	src, err := os.Open(tarballPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open tarball (%s): %w", tarballPath, err)
+	}
+	defer src.Close()
+	compressReader, err := compression.NewReader(src, format)
+	if err != nil {
+		return "", fmt.Errorf("failed to read tarball (%s): %w", tarballPath, err)
+	}
+	defer compressReader.Close()
+
+	delta, err := os.CreateTemp("", "shipyard-delta-*"+compression.Extension(format))
+	if err != nil {
+		return "", fmt.Errorf("failed to create delta tarball: %w", err)
+	}
+	defer delta.Close()
+
+	compressWriter, err := compression.NewWriter(delta, format)
+	if err != nil {
+		os.Remove(delta.Name())
+		return "", err
+	}
+	tarWriter := tar.NewWriter(compressWriter)
+	tarReader := tar.NewReader(compressReader)
+	for {
+		var header *tar.Header
+		header, err = tarReader.Next()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			break
+		}
+		if header.Typeflag == tar.TypeReg && skip[releaseEntryPath(header.Name)] {
+			continue
+		}
+		if err = tarWriter.WriteHeader(header); err != nil {
+			break
+		}
+		if _, err = io.Copy(tarWriter, tarReader); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tarWriter.Close()
+	}
+	if err == nil {
+		err = compressWriter.Close()
+	}
+	if err != nil {
+		os.Remove(delta.Name())
+		return "", fmt.Errorf("failed to write delta tarball: %w", err)
+	}
+	return delta.Name(), nil
+}
+
+// releaseEntryPath returns the path of a tarball entry relative to the release directory.
+func releaseEntryPath(name string) string {
+	return path.Clean(strings.TrimPrefix(name, "./"))
+}
+
+// checksumList formats the manifest for "sha256sum -c". Names with a backslash or a newline are
+// escaped the way sha256sum expects.
+func checksumList(manifest []ManifestEntry) string {
+	var b strings.Builder
+	for _, entry := range manifest {
+		name := entry.Path
+		if strings.ContainsAny(name, "\\\n") {
+			name = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(name)
+			b.WriteString(`\`)
+		}
+		fmt.Fprintf(&b, "%s  %s\n", entry.SHA256, name)
+	}
+	return b.String()
+}
+
+// runRemoteCommandWithInput runs a command on the remote host with input on its stdin and returns its output.
+func (d *Deployer) runRemoteCommandWithInput(command string, input io.Reader) (string, error) {
+	session, err := d.SSHClient.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = input
+	var output []byte
+	err = runSession(d.context(), session, func() (runErr error) {
+		output, runErr = session.CombinedOutput(command)
+		return runErr
+	})
+	return strings.TrimSpace(string(output)), err
+}
+
+// FormatBytes formats a size for logs, e.g. "42.1 MB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
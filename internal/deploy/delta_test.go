@@ -0,0 +1,126 @@
+package deploy
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"youfun/shipyard/internal/compression"
+)
+
+// writeTestTarball writes a release tarball with the given files (mode 0644) and a bin/ directory.
+func writeTestTarball(t *testing.T, format string, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "release"+compression.Extension(format))
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	compressWriter, err := compression.NewWriter(f, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarWriter := tar.NewWriter(compressWriter)
+	if err := tarWriter.WriteHeader(&tar.Header{Name: "./bin/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		content := files[name]
+		if err := tarWriter.WriteHeader(&tar.Header{Name: "./" + name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sha256Of(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDeltaUploadPlan(t *testing.T) {
+	tarball := writeTestTarball(t, compression.Zstd, map[string]string{
+		"bin/server":             "#!/bin/sh\n",
+		"lib/app/ebin/app.beam":  "new beam",
+		"lib/app/ebin/util.beam": "unchanged beam",
+		"releases/1.1.0/vm.args": "-name app",
+	})
+
+	manifest, err := ReadTarballManifest(tarball, compression.Zstd)
+	if err != nil {
+		t.Fatalf("ReadTarballManifest() failed: %v", err)
+	}
+	if len(manifest) != 4 || manifest[0].Path != "bin/server" || manifest[0].SHA256 != sha256Of("#!/bin/sh\n") || manifest[0].Mode != 0644 {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+
+	// The host lists the files of the active release: the mode lines of find, then sha256sum
+	remote := parseRemoteFiles("mode 644 bin/server\n" +
+		"mode 644 lib/app/ebin/app.beam\n" +
+		"mode 644 lib/app/ebin/util.beam\n" +
+		"mode 755 releases/1.1.0/vm.args\n" +
+		sha256Of("#!/bin/sh\n") + "  ./bin/server\n" +
+		sha256Of("old beam") + "  ./lib/app/ebin/app.beam\n" +
+		sha256Of("unchanged beam") + "  ./lib/app/ebin/util.beam\n" +
+		sha256Of("-name app") + "  ./releases/1.1.0/vm.args\n" +
+		`\` + sha256Of("x") + "  ./odd\\nname\n")
+	if len(remote) != 4 {
+		t.Fatalf("expected 4 remote files, got %+v", remote)
+	}
+
+	plan := planDelta(manifest, remote)
+	// app.beam changed, vm.args changed mode
+	if !slices.Equal(plan.reuse, []string{"bin/server", "lib/app/ebin/util.beam"}) {
+		t.Errorf("reuse = %v", plan.reuse)
+	}
+	if plan.uploadFiles != 2 || plan.reused != int64(len("#!/bin/sh\n")+len("unchanged beam")) {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+
+	deltaPath, err := writeDeltaTarball(tarball, compression.Zstd, plan.reuse)
+	if err != nil {
+		t.Fatalf("writeDeltaTarball() failed: %v", err)
+	}
+	defer os.Remove(deltaPath)
+	delta, err := ReadTarballManifest(deltaPath, compression.Zstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uploaded []string
+	for _, entry := range delta {
+		uploaded = append(uploaded, entry.Path)
+	}
+	if !slices.Equal(uploaded, []string{"lib/app/ebin/app.beam", "releases/1.1.0/vm.args"}) {
+		t.Errorf("delta tarball files = %v", uploaded)
+	}
+}
+
+func TestChecksumList(t *testing.T) {
+	manifest := []ManifestEntry{
+		{Path: "bin/server", SHA256: "aa"},
+		{Path: `odd\name`, SHA256: "bb"},
+	}
+	want := "aa  bin/server\n" + `\bb  odd\\name` + "\n"
+	if got := checksumList(manifest); got != want {
+		t.Errorf("checksumList() = %q, want %q", got, want)
+	}
+}
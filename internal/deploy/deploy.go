@@ -1,20 +1,22 @@
 package deploy
 
 import (
-	"bytes"
+	"context"
 	"youfun/shipyard/internal/caddy"
 	"youfun/shipyard/internal/client"
 	"youfun/shipyard/internal/config"
 	"youfun/shipyard/internal/crypto"
 	"youfun/shipyard/internal/database"
 	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/signing"
 	"youfun/shipyard/internal/sshutil"
-	"youfun/shipyard/pkg/types"
 	"fmt"
 	"io"
 	"log"
-	"os"
+	"maps"
+	"slices"
 	"time"
+	"youfun/shipyard/pkg/types"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -30,9 +32,12 @@ type Deployer struct {
 	Host               *models.SSHHost
 	History            *models.DeploymentHistory
 	SSHClient          *ssh.Client
-	LogBuffer          bytes.Buffer
+	LogBuffer          syncBuffer
 	tarballPath        string
 	md5Hash            string
+	compression        string               // Compression format of the artifact at tarballPath
+	signature          artifactSignature    // Verified signature of the artifact at tarballPath
+	trustedKeys        []signing.TrustedKey // Keys artifacts must be signed with, set in API mode
 	Version            string // mix.exs version
 	GitCommitSHA       string // Git commit hash
 	CurrentReleasePath string // The remote path for the current release
@@ -42,28 +47,69 @@ type Deployer struct {
 	IsLocalhost        bool     // Whether it is a local deployment
 	DeploymentID       string   // Friendly ID from API
 	HostKeyCallback    ssh.HostKeyCallback
+	HealthCheck        *config.HealthCheck // Overrides [health_check] from shipyard.toml when set
+	ctx                context.Context     // Cancelled on SIGINT/SIGTERM, nil means not cancellable
+	undo               deployUndo          // What this deployment created, undone when it is cancelled
+	lock               deployLock          // Instance lock held through the API
+	rolloutID          string              // Friendly ID of the rollout this deployment is part of
+	canaryWeight       int                 // Share of the traffic sent to the new version, 0 switches all traffic
+	instanceUID        string              // Friendly ID of the application instance, set in API mode
+	greenPort          int                 // Port the new version runs on
+	oldPort            int                 // Port of the version serving traffic before the deployment
+	greenPorts         []int               // Ports of all instances of the new version, greenPort is the first
+	oldPorts           []int               // Ports of all instances serving traffic before the deployment
+	scale              int                 // Number of instances of the new version to start
+	canaryStarted      bool                // Whether the new version was started as a canary
+	successReported    bool                // Whether the switch to the new version was reported to the server
+	clusterNodes       []string            // Erlang nodes of the app serving traffic on all hosts, set in API mode
+	linkedHosts        []string            // Names of the hosts the app is linked to, sorted, to pick the host of cron jobs
+	events             *EventWriter        // Receives the JSON events of the deployment, nil for none
+
+	resumeSteps     map[string]types.DeploymentStepDTO // Steps recorded by the deployment being resumed
+	unreportedSteps []types.DeploymentStepDTO          // Steps not yet recorded on the server
+
+	sourceDir string    // Directory of the project to build, empty for the working directory
+	buildLog  io.Writer // Receives the output of a build run on shipyard-server for the CLI, nil for Output
+}
+
+// RunOptions are the options of a deployment run through the API.
+type RunOptions struct {
+	UseBuild    string       // Build artifact to reuse (git SHA or MD5 prefix) instead of building
+	WaitForLock bool         // Wait for a running deployment of the instance instead of failing
+	Canary      int          // Percentage of traffic sent to the new version next to the current one, 0 for a full cutover
+	Resume      string       // Deployment ID of a failed deployment to continue from its first incomplete step
+	Events      *EventWriter // Receives the JSON events of the deployment, nil for none
+
+	rollout *rolloutRun // Set when deploying one host of a rollout
 }
 
 // Run executes the deployment process (legacy mode using direct DB).
-func Run(appName, hostName, useBuild string, hostKeyCallback ssh.HostKeyCallback) {
+// Cancelling ctx stops the deployment, undoes what it created on the host and marks it cancelled.
+func Run(ctx context.Context, appName, hostName, useBuild string, hostKeyCallback ssh.HostKeyCallback) (err error) {
 	d := &Deployer{
 		AppName:         appName,
 		HostName:        hostName,
 		useBuild:        useBuild,
 		HostKeyCallback: hostKeyCallback,
+		ctx:             ctx,
 	}
 
 	// Capture all logs during deployment
-	log.SetOutput(io.MultiWriter(os.Stdout, &d.LogBuffer))
+	log.SetOutput(io.MultiWriter(Output, &d.LogBuffer))
 
-	var err error
 	defer func() {
-		if err != nil {
+		if err == nil {
+			return
+		}
+		status := models.DeploymentStatusFailed
+		if d.cancelled() {
+			status = models.DeploymentStatusCancelled
+			log.Println("🛑 Deployment cancelled.")
+		} else {
 			log.Printf("❌ Deployment failed: %v", err)
-			if d.History != nil {
-				_ = database.UpdateDeploymentHistoryStatus(d.History.ID, models.DeploymentStatusFailed, d.LogBuffer.String())
-			}
-			os.Exit(1)
+		}
+		if d.History != nil {
+			_ = database.UpdateDeploymentHistoryStatus(d.History.ID, status, d.LogBuffer.String())
 		}
 	}()
 
@@ -79,50 +125,96 @@ func Run(appName, hostName, useBuild string, hostKeyCallback ssh.HostKeyCallback
 	}
 
 	err = d.execute()
-	if err != nil {
-		return
-	}
+	return
 }
 
 // RunWithAPIClient executes the deployment using the API Client (Client-Server mode).
-func RunWithAPIClient(apiClient client.APIClient, appName, hostName, useBuild string, hostKeyCallback ssh.HostKeyCallback) {
-	// Check if deploying to localhost/server
-	isLocalhost := hostName == "localhost" || hostName == "127.0.0.1" || hostName == "local"
+// Cancelling ctx stops the deployment, undoes what it created on the host and marks it cancelled.
+// Only one deployment runs per instance: the server hands out a lock that is held until the run ends.
+func RunWithAPIClient(ctx context.Context, apiClient client.APIClient, appName, hostName string, opts RunOptions, hostKeyCallback ssh.HostKeyCallback) (err error) {
+	// Losing the instance lock cancels the deployment
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	d := &Deployer{
 		AppName:         appName,
 		HostName:        hostName,
-		useBuild:        useBuild,
+		useBuild:        opts.UseBuild,
 		APIClient:       apiClient,
-		IsLocalhost:     isLocalhost,
+		IsLocalhost:     isLocalhostName(hostName),
 		HostKeyCallback: hostKeyCallback,
+		ctx:             ctx,
+		lock:            deployLock{wait: opts.WaitForLock, lost: cancel},
+		canaryWeight:    opts.Canary,
+		events:          opts.Events,
 	}
 
 	// Capture logs
-	log.SetOutput(io.MultiWriter(os.Stdout, &d.LogBuffer))
+	if opts.rollout != nil {
+		defer opts.rollout.log.attach(&d.LogBuffer)()
+		d.rolloutID = opts.rollout.id
+	} else {
+		log.SetOutput(io.MultiWriter(Output, &d.LogBuffer))
+	}
 
-	var err error
-	// Defer error handling and status update via API
+	// Runs last, the final event follows the status update
 	defer func() {
+		event := Event{Event: EventDeployFinished, Status: string(models.DeploymentStatusSuccess)}
 		if err != nil {
-			log.Printf("❌ Deployment failed: %v", err)
-			// Update status via API if we have a deployment ID
-			if d.DeploymentID != "" {
-				_ = apiClient.UpdateDeploymentStatus(d.DeploymentID, "failed", 0, "", "")
-				_ = apiClient.UploadDeploymentLogs(d.DeploymentID, d.LogBuffer.String())
+			event.Status = string(models.DeploymentStatusFailed)
+			if d.cancelled() {
+				event.Status = string(models.DeploymentStatusCancelled)
 			}
-			os.Exit(1)
+			event.Error = err.Error()
+			event.Class = FailureClass(err)
+		}
+		d.emit(event)
+	}()
+
+	// Runs after the final status update
+	defer d.releaseLock(apiClient)
+
+	// Defer error handling and status update via API
+	defer func() {
+		if err == nil {
+			return
+		}
+		status := models.DeploymentStatusFailed
+		if d.cancelled() {
+			status = models.DeploymentStatusCancelled
+			log.Println("🛑 Deployment cancelled.")
+		} else {
+			log.Printf("❌ Deployment failed: %v", err)
+		}
+		// Update status via API if we have a deployment ID
+		if d.DeploymentID != "" {
+			_ = apiClient.UpdateDeploymentStatus(d.DeploymentID, string(status), nil, "", "")
+			_ = apiClient.UploadDeploymentLogs(d.DeploymentID, d.LogBuffer.String())
 		}
 	}()
 
+	// The resumed deployment decides the host and holds the instance lock from here on
+	if opts.Resume != "" {
+		if err = d.resumeDeployment(apiClient, opts.Resume); err != nil {
+			return
+		}
+	}
+
+	d.emit(Event{Event: EventDeployStarted})
+
 	log.Println("---", "1. [CLI] Fetching remote config", "---")
 	// Fetch config from API
-	conf, err := apiClient.GetDeployConfig(appName, hostName)
+	conf, err := apiClient.GetDeployConfig(appName, d.HostName)
 	if err != nil {
-		return // err set
+		err = &ConfigError{Err: err}
+		return
 	}
 
 	d.Domains = conf.Domains // Store domains for later use
+	if d.trustedKeys, err = trustedKeysFromDTOs(conf.TrustedKeys); err != nil {
+		err = &ConfigError{Err: err}
+		return
+	}
 
 	log.Printf("Config fetched: App=%s, Host=%s", conf.App.Name, conf.Host.Name)
 
@@ -139,17 +231,45 @@ func RunWithAPIClient(apiClient client.APIClient, appName, hostName, useBuild st
 	if err != nil {
 		return
 	}
+	d.instanceUID = conf.Instance.UID
+	d.oldPorts = servingPorts(d.Instance, conf.Instance.ActivePorts)
+	d.clusterNodes = conf.ClusterNodes
+	d.linkedHosts = conf.LinkedHosts
 
 	// Set runtime: prioritize shipyard.toml, otherwise auto-detect
-	config.LoadConfig(d.AppName, config.ConfigPath)
+	if opts.rollout == nil {
+		config.LoadConfig(d.AppName, config.ConfigPath)
+	} else {
+		// Built once for all hosts of the rollout
+		artifact := opts.rollout.artifact
+		d.Version, d.tarballPath, d.md5Hash, d.GitCommitSHA = artifact.version, artifact.tarballPath, artifact.md5Hash, artifact.gitCommitSHA
+		d.compression, d.signature = artifact.compression, artifact.signature
+	}
 	d.Runtime = config.AppConfig.Runtime
 	if d.Runtime == "" {
 		d.Runtime = d.detectRuntime()
 	}
+	if err = config.AppConfig.ValidateRun(); err != nil {
+		err = &ConfigError{Err: err}
+		return
+	}
+	if err = config.AppConfig.ValidateCron(); err != nil {
+		err = &ConfigError{Err: err}
+		return
+	}
+	if err = config.AppConfig.ValidateUpload(); err != nil {
+		err = &ConfigError{Err: err}
+		return
+	}
+	d.scale = instanceCount(config.AppConfig.Scale, d.oldPorts)
+	if d.canaryWeight > 0 && d.scale > 1 {
+		err = &ConfigError{Err: fmt.Errorf("--canary runs a single instance of the new release, it is not supported with %d instances (scale)", d.scale)}
+		return
+	}
 
 	// Display domain info
 	domains := config.AppConfig.Domains
-	log.Printf("App: %s, Host: %s, Domains: %v, runtime=%s", d.Application.Name, d.Host.Name, domains, d.Runtime)
+	log.Printf("App: %s, Host: %s, Domains: %v, runtime=%s, instances=%d", d.Application.Name, d.Host.Name, domains, d.Runtime, d.scale)
 
 	// Check for missing domains and warn user
 	if len(domains) == 0 && len(d.Domains) == 0 {
@@ -163,7 +283,11 @@ func RunWithAPIClient(apiClient client.APIClient, appName, hostName, useBuild st
 	}
 
 	// Check if this is a server-side deployment (localhost = server machine)
-	if isLocalhost {
+	if d.IsLocalhost {
+		if d.canaryWeight > 0 {
+			err = &ConfigError{Err: fmt.Errorf("--canary is not supported for deployments to the server itself")}
+			return
+		}
 		log.Println("---", "2. [Server-Side Deployment Mode] Deploying to server machine", "---")
 		log.Println("📦 This will deploy the application to the server machine itself (not via SSH)")
 		err = d.executeServerSideDeployment(apiClient, conf.Secrets)
@@ -199,6 +323,7 @@ func RunWithAPIClient(apiClient client.APIClient, appName, hostName, useBuild st
 
 	// Execute deployment with API client (use the same execute logic as legacy mode)
 	err = d.executeWithAPIClient(apiClient, conf.Secrets)
+	return
 }
 
 // connectSSHWithAPIConfig establishes an SSH connection using API-provided host config.
@@ -216,58 +341,243 @@ func (d *Deployer) connectSSHWithAPIConfig() error {
 	return nil
 }
 
-// executeWithAPIClient executes the deployment using API-provided secrets
-func (d *Deployer) executeWithAPIClient(apiClient client.APIClient, secrets map[string]string) error {
+// executeWithAPIClient executes the deployment using API-provided secrets.
+// It runs as named steps whose state is recorded on the server, see runSteps.
+func (d *Deployer) executeWithAPIClient(apiClient client.APIClient, secrets map[string]string) (err error) {
 	defer d.SSHClient.Close()
-	var err error
-
-	// --- 3. Process build artifact (New build or reuse) ---
-	if err = d.ProcessArtifact(); err != nil {
-		return err
-	}
+	// Runs before the SSH connection is closed
+	defer func() {
+		if err != nil && d.cancelled() {
+			d.cleanupCancelled()
+		}
+	}()
 
-	log.Println("🚀 Preparing release...")
-	releasePath := fmt.Sprintf("%s/%s-%d", config.GetRemoteReleasesDir(), d.Version, time.Now().Unix())
-	d.CurrentReleasePath = releasePath
-	if err := d.executeRemoteCommand(fmt.Sprintf("mkdir -p %s", releasePath), false); err != nil {
+	d.oldPort = 0
+	if d.Instance.ActivePort.Valid && d.Instance.ActivePort.Int64 > 0 {
+		d.oldPort = int(d.Instance.ActivePort.Int64)
+	}
+
+	steps := []deployStep{
+		{
+			// Build a new artifact or reuse one
+			name:    models.DeployStepArtifact,
+			run:     d.ProcessArtifact,
+			detail:  func() string { return d.md5Hash },
+			restore: d.restoreArtifact,
+		},
+		{
+			name: models.DeployStepUpload,
+			run: func() error {
+				log.Println("🚀 Preparing release...")
+				releasePath := fmt.Sprintf("%s/%s-%d", config.GetRemoteReleasesDir(), d.Version, time.Now().Unix())
+				d.CurrentReleasePath = releasePath
+				d.undo.releasePath = releasePath
+				if err := d.executeRemoteCommand(fmt.Sprintf("mkdir -p %s", releasePath), false); err != nil {
+					return err
+				}
+
+				log.Println("📤 Uploading files...")
+				return d.uploadRelease(d.tarballPath, releasePath)
+			},
+			detail:  func() string { return d.CurrentReleasePath },
+			restore: d.restoreRelease,
+		},
+		{
+			// Set executable permissions for non-static deployments
+			name: models.DeployStepPermissions,
+			run:  func() error { return d.ensurePermissions(d.CurrentReleasePath) },
+		},
+		{
+			name: models.DeployStepPreDeploy,
+			run: func() error {
+				if err := d.runHooks("pre_deploy", config.AppConfig.Hooks.PreDeploy); err != nil {
+					return fmt.Errorf("pre_deploy failed: %w", err)
+				}
+				return nil
+			},
+		},
+		{
+			name: models.DeployStepEnv,
+			run:  func() error { return d.configureEnv(secrets) },
+		},
+		{
+			name: models.DeployStepMigrate,
+			run: func() error {
+				if err := d.runHooks("migrate", config.AppConfig.Hooks.Migrate); err != nil {
+					return fmt.Errorf("migrate failed: %w", err)
+				}
+				return nil
+			},
+		},
+		{
+			name: models.DeployStepStart,
+			run: func() error {
+				runs, err := d.startNewVersions(d.CurrentReleasePath, d.scale)
+				if err != nil {
+					return err
+				}
+				d.greenPorts = runPorts(runs)
+				d.greenPort = d.greenPorts[0]
+				return nil
+			},
+			detail:  func() string { return formatPorts(d.greenPorts) },
+			restore: d.restoreStart,
+		},
+		{
+			name: models.DeployStepHealth,
+			run: func() error {
+				if err := d.sleep(2 * time.Second); err != nil {
+					return err
+				}
+
+				log.Println("💓 Health check...")
+				for _, port := range d.greenPorts {
+					if err := d.performHealthCheck(port); err != nil {
+						if d.cancelled() {
+							return err
+						}
+						// Rollback logic (stop new version)
+						d.stopNewVersions(d.greenPorts)
+						// A resumed deployment starts the new version again
+						d.revertStep(apiClient, models.DeployStepStart, "stopped after the failed health check")
+
+						// Update failed status via API
+						_ = apiClient.UpdateDeploymentStatus(d.DeploymentID, "failed", nil, "", "")
+						return fmt.Errorf("health check failed: %w", err)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: models.DeployStepSwitch,
+			run:  func() error { return d.switchToNewVersion(apiClient) },
+			detail: func() string {
+				if d.canaryStarted {
+					return "canary"
+				}
+				return formatPorts(d.oldPorts)
+			},
+			restore: d.restoreSwitch,
+		},
+		{
+			// Process types besides web follow the release once it serves traffic
+			name: models.DeployStepProcesses,
+			run: func() error {
+				if d.canaryStarted {
+					if len(config.AppConfig.OtherProcesses()) > 0 {
+						log.Println("Processes keep running the stable release until the canary is promoted.")
+					}
+					return nil
+				}
+				return d.deployProcesses(d.CurrentReleasePath, nodeHost(d.Host.Addr))
+			},
+		},
+		{
+			// Scheduled jobs run in the release serving traffic
+			name: models.DeployStepCron,
+			run: func() error {
+				if d.canaryStarted {
+					if len(config.AppConfig.Cron) > 0 {
+						log.Println("Cron jobs keep running on the stable release until the canary is promoted.")
+					}
+					return nil
+				}
+				return d.deployCron(d.CurrentReleasePath)
+			},
+		},
+		{
+			name: models.DeployStepPostDeploy,
+			run: func() error {
+				// The new version already serves traffic, a failing hook does not fail the deployment
+				if err := d.runHooks("post_deploy", config.AppConfig.Hooks.PostDeploy); err != nil {
+					log.Printf("⚠️  Warning: post_deploy failed: %v", err)
+				}
+				return nil
+			},
+		},
+		{
+			name: models.DeployStepCleanup,
+			run: func() error {
+				if d.canaryStarted {
+					// Both versions keep running until the canary is promoted or aborted
+					log.Println("🎉 Canary deployment successful!")
+					log.Printf("   Run 'shipyard-cli canary promote --app %s --host %s' to send it more traffic,", d.AppName, d.HostName)
+					log.Printf("   or 'shipyard-cli canary abort --app %s --host %s' to send all traffic back to :%d.", d.AppName, d.HostName, d.oldPort)
+					return nil
+				}
+				for _, port := range d.oldPorts {
+					if summary := drainOldVersion(d.context(), d.caddySvc, port); summary != "" {
+						_ = apiClient.UploadDeploymentLogs(d.DeploymentID, summary+"\n")
+					}
+					log.Printf("🛑 Stopping old version (:%d)...", port)
+					d.executeRemoteCommand(fmt.Sprintf("systemctl disable %s@%d", d.AppName, port), false)
+					d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d", d.AppName, port), false)
+				}
+
+				log.Println("🎉 Deployment successful!")
+
+				d.pruneReleases(apiClient)
+				return nil
+			},
+		},
+	}
+
+	// --- 3. Process build artifact, before the deployment exists ---
+	if err := d.runSteps(apiClient, steps[:1]); err != nil {
 		return err
 	}
-
-	// Create deployment history via API
-	deployReq := &types.CreateDeploymentRequest{
-		AppName:  d.AppName,
-		HostName: d.HostName,
-		Version:  d.Version,
-	}
-	historyDTO, err := apiClient.CreateDeployment(deployReq)
-	if err != nil {
-		return fmt.Errorf("failed to create deployment record: %w", err)
+	if d.DeploymentID == "" {
+		if err := d.checkCancelled(); err != nil {
+			return err
+		}
+		// Create deployment history via API, this takes the deploy lock of the instance
+		if err := d.createDeployment(apiClient); err != nil {
+			return err
+		}
 	}
-	// Store the friendly ID for API calls
-	d.DeploymentID = historyDTO.ID
 
-	log.Println("📤 Uploading files...")
-	if err := d.uploadTarFile(d.tarballPath, releasePath); err != nil {
+	if err := d.runSteps(apiClient, steps[1:]); err != nil {
 		return err
 	}
 
-	// Set executable permissions for non-static deployments
-	if err := d.ensurePermissions(releasePath); err != nil {
-		return err
+	// Update deployment status via API unless the switch already did, a canary keeps the status set when it started.
+	// A resumed deployment skips the switch, so the success is reported here.
+	if !d.canaryStarted && !d.successReported {
+		if err := apiClient.UpdateDeploymentStatus(d.DeploymentID, "success", d.greenPorts, d.CurrentReleasePath, d.GitCommitSHA); err != nil {
+			// Log quietly
+		}
 	}
-
-	// --- 5b. Execute pre_deploy hooks ---
-	if err := d.runHooks("pre_deploy", config.AppConfig.Hooks.PreDeploy); err != nil {
-		return fmt.Errorf("pre_deploy failed: %w", err)
+	if err := apiClient.UploadDeploymentLogs(d.DeploymentID, d.LogBuffer.String()); err != nil {
+		// Log quietly
 	}
 
-	// --- 5c. Check path-related variables in .env file ---
-	// d.checkAndWarnForPathsInEnvFile(releasePath) // Silence check
+	return nil
+}
 
+// configureEnv writes the environment variables of the new release to the remote server.
+func (d *Deployer) configureEnv(secrets map[string]string) error {
 	log.Println("🔧 Configuring environment...")
 
+	// Releases of BEAM runtimes join the other nodes of the app with the cookie they all share,
+	// generated by the server on first use so that the hosts of a rollout agree on it
+	if _, exists := secrets[ReleaseCookieKey]; clusterRuntime(d.Runtime) && !exists {
+		cookie, err := d.APIClient.EnsureReleaseCookie(d.AppName)
+		if err != nil {
+			return fmt.Errorf("failed to get %s: %w", ReleaseCookieKey, err)
+		}
+		secrets = maps.Clone(secrets)
+		if secrets == nil {
+			secrets = make(map[string]string)
+		}
+		secrets[ReleaseCookieKey] = cookie
+	}
+
 	// 4. Merge all environment variables
 	envs := d.prepareEnvVars(secrets)
+	if clusterRuntime(d.Runtime) {
+		clusterEnv(envs, d.clusterNodes)
+	}
 
 	// For Phoenix apps, ensure SECRET_KEY_BASE exists
 	if d.Runtime == "phoenix" {
@@ -292,81 +602,83 @@ func (d *Deployer) executeWithAPIClient(apiClient client.APIClient, secrets map[
 	if err := d.ensurePathPermissions(envs); err != nil {
 		return fmt.Errorf("failed to ensure path permissions: %w", err)
 	}
+	return nil
+}
 
-	if err := d.runHooks("migrate", config.AppConfig.Hooks.Migrate); err != nil {
-		return fmt.Errorf("migrate failed: %w", err)
-	}
-
-	// 8. Start new version
-	run, err := d.startNewVersion(releasePath)
-	if err != nil {
-		return err
-	}
-	greenPort := run.Port
-
-	time.Sleep(2 * time.Second)
-
-	log.Println("💓 Health check...")
-	if err := d.performHealthCheck(greenPort); err != nil {
-		// Rollback logic (stop new version)
-		instancesDir := fmt.Sprintf("/var/www/%s/instances", d.AppName)
-		d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d || true", d.AppName, greenPort), false)
-		d.executeRemoteCommand(fmt.Sprintf("rm -f %s/%d || true", instancesDir, greenPort), false)
-
-		// Update failed status via API
-		_ = apiClient.UpdateDeploymentStatus(d.DeploymentID, "failed", 0, "", "")
-		return fmt.Errorf("health check failed: %w", err)
+// switchToNewVersion sends the traffic of the domains to the new version,
+// or part of it when deploying a canary.
+func (d *Deployer) switchToNewVersion(apiClient client.APIClient) error {
+	if d.canaryWeight > 0 {
+		if d.oldPort > 0 && len(d.Domains) > 0 {
+			// Both versions keep running until the canary is promoted or aborted
+			if err := d.startCanary(apiClient, d.oldPort, d.greenPort, d.CurrentReleasePath); err != nil {
+				return err
+			}
+			d.commit()
+			d.canaryStarted = true
+			return nil
+		}
+		log.Println("⚠️  Warning: No version is serving traffic through a domain yet, switching all traffic instead of starting a canary")
 	}
-
-	// 10. Switch traffic
-	if err := d.switchTraffic(greenPort, d.Domains); err != nil {
+	if err := d.switchTraffic(d.greenPorts, d.oldPorts, d.Domains); err != nil {
 		return err
 	}
+	d.commit()
 
 	// Update active status (via API if possible, or implicitly done by switch traffic success)
-	_ = apiClient.UpdateDeploymentStatus(d.DeploymentID, "success", greenPort, releasePath, d.GitCommitSHA)
-
-	// --- 10b. Execute post_deploy hooks ---
-	d.runHooks("post_deploy", config.AppConfig.Hooks.PostDeploy)
-
-	oldPort := 0
-	if d.Instance.ActivePort.Valid && d.Instance.ActivePort.Int64 > 0 {
-		oldPort = int(d.Instance.ActivePort.Int64)
-	}
-
-	if oldPort > 0 {
-		log.Printf("🛑 Stopping old version (:%d)...", oldPort)
-		time.Sleep(3 * time.Second)
-		d.executeRemoteCommand(fmt.Sprintf("systemctl disable %s@%d", d.AppName, oldPort), false)
-		d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d", d.AppName, oldPort), false)
+	if err := apiClient.UpdateDeploymentStatus(d.DeploymentID, "success", d.greenPorts, d.CurrentReleasePath, d.GitCommitSHA); err == nil {
+		d.successReported = true
 	}
+	return nil
+}
 
-	log.Println("🎉 Deployment successful!")
-
-	// Update deployment status via API
-	if err := apiClient.UpdateDeploymentStatus(d.DeploymentID, "success", greenPort, releasePath, d.GitCommitSHA); err != nil {
-		// Log quietly
-	}
-	if err := apiClient.UploadDeploymentLogs(d.DeploymentID, d.LogBuffer.String()); err != nil {
-		// Log quietly
+// restoreSwitch picks up how the resumed deployment switched the traffic, the new version is live.
+func (d *Deployer) restoreSwitch(detail string) error {
+	if detail == "canary" {
+		d.canaryStarted = true
+	} else {
+		oldPorts, err := parsePorts(detail)
+		if err != nil {
+			return fmt.Errorf("invalid port of the old version: %q", detail)
+		}
+		d.oldPorts, d.oldPort = oldPorts, 0
+		if len(oldPorts) > 0 {
+			d.oldPort = oldPorts[0]
+		}
 	}
-
+	d.commit()
 	return nil
 }
 
 // execute executes the core logic of deployment
-func (d *Deployer) execute() error {
+func (d *Deployer) execute() (err error) {
 	defer d.SSHClient.Close()
-	var err error
+	var runs []*models.DeploymentInstance
+	// Runs before the SSH connection is closed
+	defer func() {
+		if err != nil && d.cancelled() {
+			d.cleanupCancelled()
+			if !d.undo.committed {
+				st := time.Now()
+				for _, run := range runs {
+					_ = database.UpdateDeploymentInstanceStatus(run.ID, "stopped", &st)
+				}
+			}
+		}
+	}()
 
 	// --- 3. Process build artifact (New build or reuse) ---
 	if err = d.ProcessArtifact(); err != nil {
 		return err
 	}
+	if err := d.checkCancelled(); err != nil {
+		return err
+	}
 
 	log.Println("---", "4. Preparing remote environment", "---")
 	releasePath := fmt.Sprintf("%s/%s-%d", config.GetRemoteReleasesDir(), d.Version, time.Now().Unix())
 	d.CurrentReleasePath = releasePath // Store for hook variable substitution
+	d.undo.releasePath = releasePath
 	if err := d.executeRemoteCommand(fmt.Sprintf("mkdir -p %s", releasePath), false); err != nil {
 		return err
 	}
@@ -375,10 +687,21 @@ func (d *Deployer) execute() error {
 	if err != nil {
 		return fmt.Errorf("failed to create deployment history record: %w", err)
 	}
+	if err := database.SetDeploymentSigner(d.History.ID, d.signature.trustedKey, d.signature.signedBy); err != nil {
+		log.Printf("⚠️ Warning: Failed to record the signer of the artifact: %v", err)
+	}
 
 	log.Println("---", "5. Upload and extract files (streaming)", "---")
+	if d.Instance.ActivePort.Valid && d.Instance.ActivePort.Int64 > 0 {
+		d.oldPort = int(d.Instance.ActivePort.Int64)
+	}
+	activePorts, err := database.GetActiveInstancePorts(d.Instance.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get active instance ports: %w", err)
+	}
+	d.oldPorts = servingPorts(d.Instance, activePorts)
 	// Call new function to complete upload, extract and progress display in one step
-	if err := d.uploadTarFile(d.tarballPath, releasePath); err != nil {
+	if err := d.uploadRelease(d.tarballPath, releasePath); err != nil {
 		// If error occurs, function internal has contained all error info (e.g., "failed to execute remote streaming extraction: ...")
 		// Your "green" environment directory (releasePath) on remote might be incomplete
 		return err
@@ -422,8 +745,24 @@ func (d *Deployer) execute() error {
 		}
 	}
 
+	// 3.5. Releases of BEAM runtimes join the other nodes of the app with the cookie they all share
+	if clusterRuntime(d.Runtime) {
+		if _, exists := secrets[ReleaseCookieKey]; !exists {
+			if secrets[ReleaseCookieKey], err = ensureReleaseCookie(d.Application.ID); err != nil {
+				return err
+			}
+		}
+	}
+
 	// 4. Merge all environment variables
 	envs := d.prepareEnvVars(secrets)
+	if clusterRuntime(d.Runtime) {
+		peers, err := database.GetActivePeersForApp(d.Application.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get the nodes of the app: %w", err)
+		}
+		clusterEnv(envs, ClusterNodes(d.AppName, peers))
+	}
 
 	// 5. Write final environment variables to remote server
 	if err := d.injectEnvVars(envs); err != nil {
@@ -443,28 +782,40 @@ func (d *Deployer) execute() error {
 	}
 
 	// 8. Start new version
-	run, err := d.startNewVersion(releasePath)
+	d.scale = instanceCount(config.AppConfig.Scale, d.oldPorts)
+	started, err := d.startNewVersions(releasePath, d.scale)
 	if err != nil {
 		return err
 	}
 	// In Server mode, immediately record new instance to database
-	if err := database.AddDeploymentInstance(run); err != nil {
-		return fmt.Errorf("failed to record instance run info: %w", err)
+	for _, run := range started {
+		if err := database.AddDeploymentInstance(run); err != nil {
+			d.stopNewVersions(runPorts(started))
+			return fmt.Errorf("failed to record instance run info: %w", err)
+		}
+		runs = append(runs, run)
 	}
-	greenPort := run.Port
+	greenPorts := runPorts(runs)
 
-	time.Sleep(3 * time.Second)
+	if err := d.sleep(3 * time.Second); err != nil {
+		return err
+	}
 
 	log.Println("---", "9. Health check", "---")
-	if err := d.performHealthCheck(greenPort); err != nil {
-		// Rollback logic
-		instancesDir := fmt.Sprintf("/var/www/%s/instances", d.AppName)
-		d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d || true", d.AppName, greenPort), true)
-		d.executeRemoteCommand(fmt.Sprintf("rm -f %s/%d || true", instancesDir, greenPort), false)
+	for _, port := range greenPorts {
+		if err := d.performHealthCheck(port); err != nil {
+			if d.cancelled() {
+				return err
+			}
+			// Rollback logic
+			d.stopNewVersions(greenPorts)
 
-		st := time.Now()
-		_ = database.UpdateDeploymentInstanceStatus(run.ID, "failed", &st)
-		return fmt.Errorf("new version health check failed: %w", err)
+			st := time.Now()
+			for _, run := range runs {
+				_ = database.UpdateDeploymentInstanceStatus(run.ID, "failed", &st)
+			}
+			return fmt.Errorf("new version health check failed: %w", err)
+		}
 	}
 	log.Println("✅ New version health status is good")
 
@@ -476,11 +827,30 @@ func (d *Deployer) execute() error {
 	}
 
 	// Note: we no longer fallback to Application.Domain; rely on domains table or config only.
-	if err := d.switchTraffic(greenPort, domains); err != nil {
+	if err := d.checkCancelled(); err != nil {
+		return err
+	}
+	if err := d.switchTraffic(greenPorts, d.oldPorts, domains); err != nil {
 		return err
 	}
+	d.commit()
 
-	_ = database.UpdateDeploymentInstanceStatus(run.ID, "active", nil)
+	for _, run := range runs {
+		_ = database.UpdateDeploymentInstanceStatus(run.ID, "active", nil)
+	}
+
+	// --- 10a. Run the other process types on the new release ---
+	if err := d.deployProcesses(releasePath, nodeHost(d.Host.Addr)); err != nil {
+		return err
+	}
+	linked, err := database.GetLinkedHostsForApp(d.AppName)
+	if err != nil {
+		return fmt.Errorf("failed to get the hosts of the app: %w", err)
+	}
+	d.linkedHosts = hostNames(linked)
+	if err := d.deployCron(releasePath); err != nil {
+		return err
+	}
 
 	// --- 10b. Execute post_deploy hooks ---
 	if err := d.runHooks("post_deploy", config.AppConfig.Hooks.PostDeploy); err != nil {
@@ -489,15 +859,20 @@ func (d *Deployer) execute() error {
 	}
 
 	// 11. Handle old version
-	if err := d.stopOldVersion(greenPort); err != nil {
+	if err := d.stopOldVersion(greenPorts); err != nil {
 		return err
 	}
 
 	log.Println("---", "12. Clean up stale instances", "---")
-	if err := d.cleanupStaleInstances(greenPort); err != nil {
+	if err := d.cleanupStaleInstances(greenPorts); err != nil {
 		log.Printf("⚠️ Error cleaning up stale instances: %v", err)
 	}
 
+	log.Println("---", "13. Prune old releases", "---")
+	if _, err := PruneReleases(d.Instance.ID, config.AppConfig.KeepReleases, false); err != nil {
+		log.Printf("⚠️ Error pruning old releases: %v", err)
+	}
+
 	log.Println("🎉 Deployment successfully completed!")
 	return database.UpdateDeploymentHistoryStatus(d.History.ID, models.DeploymentStatusSuccess, d.LogBuffer.String())
 }
@@ -525,20 +900,38 @@ func (d *Deployer) ensurePermissions(releasePath string) error {
 	return nil
 }
 
-// switchTraffic updates the Caddy reverse proxy and enables the new service.
-func (d *Deployer) switchTraffic(port int, domains []string) error {
+// switchTraffic updates the Caddy reverse proxy and enables the new services.
+// The replaced ports stay among Caddy's upstreams until drainOldVersion has drained them.
+func (d *Deployer) switchTraffic(ports, replaced []int, domains []string) error {
 	log.Println("🔀 Switching traffic...")
 
+	var draining []int
+	for _, port := range replaced {
+		if !slices.Contains(ports, port) {
+			draining = append(draining, port)
+		}
+	}
+	holdUpstreams(d.caddySvc, draining)
+
 	if len(domains) > 0 {
-		if err := d.caddySvc.UpdateReverseProxyMultiDomain(domains, port); err != nil {
+		// Several instances of the release share the traffic of the domains
+		if err := d.caddySvc.UpdateLoadBalancedReverseProxy(domains, ports); err != nil {
+			releaseUpstreams(d.caddySvc, draining)
 			return fmt.Errorf("failed to update Caddy config: %w", err)
 		}
-		log.Printf("✅ Caddy traffic switched to port %d (Domains: %v)", port, domains)
+		log.Printf("✅ Caddy traffic switched to port %s (Domains: %v)", formatPorts(ports), domains)
 	} else {
 		log.Println("⚠️  Warning: No domain configured, skipping Caddy config")
 	}
 
-	// Enable auto-start for new version
+	for _, port := range ports {
+		d.enableService(port)
+	}
+	return nil
+}
+
+// enableService enables auto-start of the new version.
+func (d *Deployer) enableService(port int) {
 	enableCmd := fmt.Sprintf("systemctl enable %s@%d", d.AppName, port)
 	if err := d.executeRemoteCommand(enableCmd, true); err != nil {
 		log.Printf("⚠️ Warning: Failed to set new version auto-start: %v", err)
@@ -546,8 +939,6 @@ func (d *Deployer) switchTraffic(port int, domains []string) error {
 	} else {
 		log.Printf("✅ Enabled auto-start for new version (Port %d)", port)
 	}
-
-	return nil
 }
 
 // executeServerSideDeployment handles server-side deployment (localhost = server machine)
@@ -560,21 +951,17 @@ func (d *Deployer) executeServerSideDeployment(apiClient client.APIClient, secre
 	if err = d.ProcessArtifact(); err != nil {
 		return err
 	}
+	if err := d.checkCancelled(); err != nil {
+		return err
+	}
 
 	log.Printf("📦 Artifact ready: %s (version: %s)", d.tarballPath, d.Version)
 
 	// --- 4. Create deployment record ---
 	log.Println("---", "4. [CLI] Creating deployment record", "---")
-	deployReq := &types.CreateDeploymentRequest{
-		AppName:  d.AppName,
-		HostName: d.HostName,
-		Version:  d.Version,
-	}
-	historyDTO, err := apiClient.CreateDeployment(deployReq)
-	if err != nil {
-		return fmt.Errorf("failed to create deployment record: %w", err)
+	if err := d.createDeployment(apiClient); err != nil {
+		return err
 	}
-	d.DeploymentID = historyDTO.ID
 	log.Printf("✅ Deployment record created: %s", d.DeploymentID)
 
 	// --- 5. Upload artifact to server ---
@@ -585,10 +972,16 @@ func (d *Deployer) executeServerSideDeployment(apiClient client.APIClient, secre
 	log.Println("✅ Artifact uploaded to server successfully")
 
 	// --- 6. Trigger server-side execution ---
+	if err := d.checkCancelled(); err != nil {
+		return err
+	}
 	log.Println("---", "6. [CLI] Triggering server-side deployment execution", "---")
+	// The server extends the lease for its run, a late heartbeat must not shorten it again
+	d.stopLockHeartbeat()
 	if err := apiClient.ExecuteServerDeployment(d.DeploymentID, d.Version, d.GitCommitSHA, d.md5Hash); err != nil {
 		return fmt.Errorf("failed to trigger server-side deployment: %w", err)
 	}
+	d.handOffLock()
 	log.Println("✅ Server-side deployment triggered successfully")
 	log.Println("📝 Note: Deployment is now running on the server. Check deployment logs for progress.")
 
@@ -1,15 +1,18 @@
 package deploy
 
 import (
+	"youfun/shipyard/internal/config"
 	"youfun/shipyard/internal/database"
 	"youfun/shipyard/internal/models"
 	"fmt"
 	"log"
+	"slices"
 	"time"
 )
 
 // startNewVersion starts the new version of the application on a free port.
 // This encapsulates the logic of directory creation, symlinking, permission fixing, and service starting.
+// The release and the unit are prepared once for all instances by startNewVersions.
 func (d *Deployer) startNewVersion(releasePath string) (*models.DeploymentInstance, error) {
 	log.Println("🌱 Starting new version...")
 	greenPort, err := d.findFreePort()
@@ -17,6 +20,7 @@ func (d *Deployer) startNewVersion(releasePath string) (*models.DeploymentInstan
 		return nil, err
 	}
 	log.Printf("Found free port on remote host: %d", greenPort)
+	d.undo.ports = append(d.undo.ports, greenPort)
 
 	instancesDir := fmt.Sprintf("/var/www/%s/instances", d.AppName)
 	if err := d.executeRemoteCommand(fmt.Sprintf("mkdir -p %s", instancesDir), false); err != nil {
@@ -25,7 +29,7 @@ func (d *Deployer) startNewVersion(releasePath string) (*models.DeploymentInstan
 	if err := d.executeRemoteCommand(fmt.Sprintf("ln -sfn %s %s/%d", releasePath, instancesDir, greenPort), false); err != nil {
 		return nil, err
 	}
-	if err := d.executeRemoteCommand(fmt.Sprintf("(chown -h phoenix:phoenix %s/%d || true) && (chown -R phoenix:phoenix %s || true)", instancesDir, greenPort, releasePath), true); err != nil {
+	if err := d.executeRemoteCommand(fmt.Sprintf("chown -h phoenix:phoenix %s/%d || true", instancesDir, greenPort), true); err != nil {
 		return nil, err
 	}
 	if err := d.executeRemoteCommand(fmt.Sprintf("systemctl start %s@%d", d.AppName, greenPort), true); err != nil {
@@ -49,69 +53,136 @@ func (d *Deployer) startNewVersion(releasePath string) (*models.DeploymentInstan
 	return run, nil
 }
 
-// performHealthCheck performs a health check on the application running on the given port.
+// startNewVersions starts count instances of the new version, each on its own free port, after handing the
+// release to the app user and rendering the unit of the app once for all of them.
+// When one fails to start, the instances started before it are stopped again.
+func (d *Deployer) startNewVersions(releasePath string, count int) ([]*models.DeploymentInstance, error) {
+	if err := d.executeRemoteCommand(fmt.Sprintf("chown -R phoenix:phoenix %s || true", releasePath), true); err != nil {
+		return nil, err
+	}
+	if err := d.renderUnit(); err != nil {
+		return nil, err
+	}
+	runs := make([]*models.DeploymentInstance, 0, count)
+	for i := range count {
+		if count > 1 {
+			log.Printf("Instance %d/%d:", i+1, count)
+		}
+		run, err := d.startNewVersion(releasePath)
+		if err != nil {
+			d.stopNewVersions(runPorts(runs))
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// stopNewVersions stops the instances of the new version on the given ports and removes their instance links.
+func (d *Deployer) stopNewVersions(ports []int) {
+	instancesDir := fmt.Sprintf("/var/www/%s/instances", d.AppName)
+	for _, port := range ports {
+		d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d || true", d.AppName, port), false)
+		d.executeRemoteCommand(fmt.Sprintf("rm -f %s/%d || true", instancesDir, port), false)
+	}
+}
+
+// runPorts returns the ports of deployment instances.
+func runPorts(runs []*models.DeploymentInstance) []int {
+	ports := make([]int, len(runs))
+	for i, run := range runs {
+		ports[i] = run.Port
+	}
+	return ports
+}
+
+// performHealthCheck verifies that the application on the given port is ready to receive traffic.
+// The systemd unit must be active and the HTTP readiness probe configured in [health_check] must pass.
 func (d *Deployer) performHealthCheck(port int) error {
-	log.Printf("Executing service health check (Port: %d)", port)
-	// Use systemctl is-active to check if service started successfully and is running.
-	healthCheckCmd := fmt.Sprintf("systemctl is-active --quiet %s@%d", d.AppName, port)
-
-	for i := 0; i < 10; i++ {
-		if err := d.executeRemoteCommand(healthCheckCmd, false); err == nil {
-			log.Println("✅ Service health check passed (systemd service is active).")
-			return nil
+	hc := config.AppConfig.HealthCheck
+	if d.HealthCheck != nil {
+		hc = *d.HealthCheck
+	}
+	log.Printf("Executing service health check (Port: %d, Path: %s, Retries: %d)", port, hc.Path, hc.Retries)
+
+	unitActiveCmd := fmt.Sprintf("systemctl is-active --quiet %s@%d", d.AppName, port)
+	probe := func(url string, timeout time.Duration) (int, string, error) {
+		// A crashed unit will never answer, report it instead of a connection error
+		if err := d.executeRemoteCommand(unitActiveCmd, false); err != nil {
+			return 0, "", fmt.Errorf("systemd unit %s@%d is not active", d.AppName, port)
 		}
+		return d.probeHTTP(url, timeout)
+	}
 
-		log.Printf("Health check attempt %d/10 failed, retrying in 2 seconds...", i+1)
-		time.Sleep(2 * time.Second)
+	results, err := runHealthProbes(d.context(), port, hc, probe)
+	d.recordHealthChecks(results)
+	if err == nil {
+		log.Println("✅ Service health check passed.")
+		return nil
+	}
+	if d.cancelled() {
+		return err
 	}
 
-	// If loop ends without success, log detailed info and return error
+	// Log detailed info to help diagnose why the release was rejected
 	log.Println("Last health check attempt failed, outputting detailed status of systemd unit:")
 	debugCmd := fmt.Sprintf("systemctl status %s@%d", d.AppName, port)
 	_ = d.executeRemoteCommand(debugCmd, true) // Run once to log output
 
-	return fmt.Errorf("Service failed to enter 'active' state after multiple attempts")
+	return err
 }
 
 // stopOldVersion stops the old version of the application.
 // This replaces the inline logic for stopping old ports.
-func (d *Deployer) stopOldVersion(greenPort int) error {
+func (d *Deployer) stopOldVersion(greenPorts []int) error {
 	oldPort := 0
 	if d.Instance.ActivePort.Valid && d.Instance.ActivePort.Int64 > 0 {
 		oldPort = int(d.Instance.ActivePort.Int64)
 	}
+	greenPort := greenPorts[0]
 
 	if err := database.UpdateInstancePortsForRollback(d.Instance.ID, greenPort, oldPort); err != nil {
 		return fmt.Errorf("failed to update active_port and previous_active_port in database: %w", err)
 	}
 	log.Printf("Database updated: active_port -> %d, previous_active_port -> %d", greenPort, oldPort)
 
-	if oldPort > 0 {
+	if len(d.oldPorts) > 0 {
 		log.Println("--- 11. Handling old version ---")
-		if oldRun, err := database.GetLatestDeploymentInstanceByPort(d.Instance.ID, oldPort); err == nil {
+	}
+	for _, port := range d.oldPorts {
+		if oldRun, err := database.GetLatestDeploymentInstanceByPort(d.Instance.ID, port); err == nil {
 			_ = database.UpdateDeploymentInstanceStatus(oldRun.ID, "standby", nil)
-			log.Printf("Old version (Port %d) marked as 'standby'. Stopping service in 5s to save resources...", oldPort)
-			time.Sleep(5 * time.Second)
-			_ = d.executeRemoteCommand(fmt.Sprintf("systemctl disable %s@%d", d.AppName, oldPort), true)
-			_ = d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d", d.AppName, oldPort), true)
-			log.Printf("✅ Old version (Port %d) service stopped, but files are kept for quick rollback.", oldPort)
+			log.Printf("Old version (Port %d) marked as 'standby'. Stopping service once drained to save resources...", port)
+			if summary := drainOldVersion(d.context(), d.caddySvc, port); summary != "" {
+				_ = database.AppendDeploymentHistoryOutput(d.History.ID, summary+"\n")
+			}
+			_ = d.executeRemoteCommand(fmt.Sprintf("systemctl disable %s@%d", d.AppName, port), true)
+			_ = d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d", d.AppName, port), true)
+			log.Printf("✅ Old version (Port %d) service stopped, but files are kept for quick rollback.", port)
 		}
 	}
 	return nil
 }
 
 // cleanupStaleInstances cleans up stale deployment instances.
-func (d *Deployer) cleanupStaleInstances(greenPort int) error {
+// The instances of the new version and the stopped ones of the old version kept for rollback are not stale.
+func (d *Deployer) cleanupStaleInstances(greenPorts []int) error {
 	oldPort := 0
 	if d.Instance.ActivePort.Valid && d.Instance.ActivePort.Int64 > 0 {
 		oldPort = int(d.Instance.ActivePort.Int64)
 	}
 
-	staleInstances, err := database.GetStaleDeploymentInstances(d.Instance.ID, greenPort, oldPort)
+	candidates, err := database.GetStaleDeploymentInstances(d.Instance.ID, greenPorts[0], oldPort)
 	if err != nil {
 		log.Printf("⚠️ Failed to get stale instance list: %v", err)
 		return nil
 	}
+	var staleInstances []models.DeploymentInstance
+	for _, candidate := range candidates {
+		if !slices.Contains(greenPorts, candidate.Port) && !slices.Contains(d.oldPorts, candidate.Port) {
+			staleInstances = append(staleInstances, candidate)
+		}
+	}
 
 	if len(staleInstances) > 0 {
 		log.Printf("Found %d stale instances, cleaning up...", len(staleInstances))
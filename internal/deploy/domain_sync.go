@@ -2,6 +2,7 @@ package deploy
 
 import (
 	"bufio"
+	"youfun/shipyard/internal/cliutils"
 	"youfun/shipyard/internal/config"
 	"youfun/shipyard/internal/database"
 	"youfun/shipyard/internal/models"
@@ -68,7 +69,7 @@ func SyncDomainsFromConfig(instanceID uuid.UUID, domains []string, primaryDomain
 		}
 	}
 
-	if foundMissing {
+	if foundMissing && !cliutils.IsCI() {
 		log.Println("You can press Q or Ctrl+C to exit now, otherwise deployment will continue in 5s.")
 
 		// Wait 5 seconds, during which pressing Q (Enter) or receiving SIGINT cancels the operation
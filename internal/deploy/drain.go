@@ -0,0 +1,117 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"youfun/shipyard/internal/caddy"
+	"youfun/shipyard/internal/config"
+)
+
+// drainPollInterval is how often Caddy's upstream stats are read while the old version drains.
+const drainPollInterval = time.Second
+
+// drainFallbackWait is how long the old version keeps running when Caddy's upstream stats cannot be read.
+const drainFallbackWait = 3 * time.Second
+
+// errDrainTimeout is returned when requests are still in flight once the drain timeout expires.
+var errDrainTimeout = errors.New("drain timeout expired")
+
+// requestsFunc returns the number of requests Caddy is still proxying to a local port.
+type requestsFunc func(port int) (int, error)
+
+// drainTimeout returns the configured drain timeout.
+// Server-side operations such as rollbacks run without shipyard.toml and use the default.
+func drainTimeout() time.Duration {
+	if config.AppConfig.DrainTimeout > 0 {
+		return config.AppConfig.DrainTimeout
+	}
+	return config.DefaultDrainTimeout
+}
+
+// waitForDrain polls the requests in flight to port until there are none, the timeout expires or ctx is done.
+func waitForDrain(ctx context.Context, port int, timeout, interval time.Duration, requests requestsFunc) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		active, err := requests(port)
+		if err != nil {
+			return err
+		}
+		if active == 0 {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w: %d requests still in flight", errDrainTimeout, active)
+		}
+		if err := sleepContext(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+// holdUpstreams keeps the ports leaving the routes among Caddy's upstreams until drainOldVersion has drained
+// them: Caddy forgets an upstream no route uses, together with the count of its requests in flight.
+// Call it before the routes are updated.
+func holdUpstreams(caddySvc *caddy.Service, ports []int) {
+	if caddySvc == nil {
+		return
+	}
+	for _, port := range ports {
+		if err := caddySvc.HoldUpstream(port); err != nil {
+			log.Printf("⚠️  Warning: %v, its drain cannot count the requests in flight", err)
+		}
+	}
+}
+
+// releaseUpstreams removes the holds of holdUpstreams, for ports that will not be drained after all.
+func releaseUpstreams(caddySvc *caddy.Service, ports []int) {
+	if caddySvc == nil {
+		return
+	}
+	for _, port := range ports {
+		if err := caddySvc.ReleaseUpstream(port); err != nil {
+			log.Printf("⚠️  Warning: %v", err)
+		}
+	}
+}
+
+// drainOldVersion waits for the requests in flight to the old version on port to finish, once Caddy
+// no longer routes new traffic to it, so that stopping the unit does not cut off long requests or websockets.
+// The port must have been held with holdUpstreams before the routes were updated; the hold is released.
+// It returns a summary of the drain to be kept in the deployment record.
+func drainOldVersion(ctx context.Context, caddySvc *caddy.Service, port int) string {
+	return drainPort(ctx, caddySvc, port, drainTimeout(), drainPollInterval, drainFallbackWait)
+}
+
+// drainPort is drainOldVersion with the timing of the drain given.
+func drainPort(ctx context.Context, caddySvc *caddy.Service, port int, timeout, interval, fallbackWait time.Duration) string {
+	if caddySvc == nil {
+		return ""
+	}
+	defer releaseUpstreams(caddySvc, []int{port})
+
+	log.Printf("⏳ Draining old version (:%d), waiting up to %s for in-flight requests...", port, timeout)
+	start := time.Now()
+	err := waitForDrain(ctx, port, timeout, interval, caddySvc.UpstreamRequests)
+	elapsed := time.Since(start).Round(100 * time.Millisecond)
+
+	switch {
+	case err == nil:
+		summary := fmt.Sprintf("Old version (:%d) drained in %s", port, elapsed)
+		log.Printf("✅ %s", summary)
+		return summary
+	case errors.Is(err, errDrainTimeout):
+		summary := fmt.Sprintf("Old version (:%d) not drained after %s (%v), stopping it anyway", port, elapsed, err)
+		log.Printf("⚠️  %s", summary)
+		return summary
+	default:
+		log.Printf("⚠️  Warning: Failed to drain old version (:%d): %v, waiting %s instead", port, err, fallbackWait)
+		_ = sleepContext(ctx, fallbackWait)
+		elapsed = time.Since(start).Round(100 * time.Millisecond)
+		summary := fmt.Sprintf("Old version (:%d) drain stopped after %s: %v", port, elapsed, err)
+		log.Printf("⚠️  %s", summary)
+		return summary
+	}
+}
@@ -0,0 +1,137 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"youfun/shipyard/internal/caddy"
+)
+
+func TestWaitForDrain(t *testing.T) {
+	t.Run("returns once no requests are in flight", func(t *testing.T) {
+		inFlight := []int{3, 1, 0}
+		calls := 0
+		requests := func(port int) (int, error) {
+			if port != 4001 {
+				t.Errorf("expected requests to port 4001, got %d", port)
+			}
+			active := inFlight[calls]
+			calls++
+			return active, nil
+		}
+
+		if err := waitForDrain(context.Background(), 4001, time.Second, time.Millisecond, requests); err != nil {
+			t.Fatalf("expected drain to succeed, got %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 polls, got %d", calls)
+		}
+	})
+
+	t.Run("times out with requests in flight", func(t *testing.T) {
+		requests := func(port int) (int, error) { return 2, nil }
+
+		err := waitForDrain(context.Background(), 4001, 5*time.Millisecond, time.Millisecond, requests)
+		if !errors.Is(err, errDrainTimeout) {
+			t.Fatalf("expected drain timeout, got %v", err)
+		}
+	})
+
+	t.Run("stops when stats cannot be read", func(t *testing.T) {
+		statsErr := errors.New("connection refused")
+		requests := func(port int) (int, error) { return 0, statsErr }
+
+		err := waitForDrain(context.Background(), 4001, time.Second, time.Millisecond, requests)
+		if !errors.Is(err, statsErr) {
+			t.Fatalf("expected stats error, got %v", err)
+		}
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		requests := func(port int) (int, error) {
+			cancel()
+			return 1, nil
+		}
+
+		err := waitForDrain(ctx, 4001, time.Minute, time.Minute, requests)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected cancellation, got %v", err)
+		}
+	})
+}
+
+// fakeCaddyAdmin serves Caddy's upstream stats, one response per poll, and records the drain routes removed.
+type fakeCaddyAdmin struct {
+	mu       sync.Mutex
+	stats    []string
+	polls    int
+	released []string
+}
+
+func (f *fakeCaddyAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/reverse_proxy/upstreams":
+		stats := f.stats[min(f.polls, len(f.stats)-1)]
+		f.polls++
+		fmt.Fprint(w, stats)
+	case strings.HasPrefix(r.URL.Path, "/id/") && r.Method == http.MethodDelete:
+		f.released = append(f.released, strings.Trim(strings.TrimPrefix(r.URL.Path, "/id/"), "/"))
+	case strings.HasPrefix(r.URL.Path, "/id/"):
+		fmt.Fprint(w, "{}")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestDrainPort(t *testing.T) {
+	t.Run("waits for the requests in flight", func(t *testing.T) {
+		admin := &fakeCaddyAdmin{stats: []string{
+			`[{"address":"localhost:4001","num_requests":2}]`,
+			`[{"address":"localhost:4001","num_requests":0}]`,
+		}}
+		server := httptest.NewServer(admin)
+		defer server.Close()
+
+		summary := drainPort(context.Background(), caddy.NewServiceAt(server.URL), 4001, time.Second, time.Millisecond, time.Hour)
+		if !strings.Contains(summary, "Old version (:4001) drained") {
+			t.Errorf("unexpected summary: %s", summary)
+		}
+		if admin.polls != 2 {
+			t.Errorf("expected 2 polls, got %d", admin.polls)
+		}
+		if len(admin.released) != 1 || admin.released[0] != "shipyard-drain-4001" {
+			t.Errorf("expected the hold of :4001 to be released, got %v", admin.released)
+		}
+	})
+
+	t.Run("waits instead when the upstream disappears", func(t *testing.T) {
+		// Requests in flight, then Caddy forgets the upstream: its requests cannot be counted anymore
+		admin := &fakeCaddyAdmin{stats: []string{
+			`[{"address":"localhost:4001","num_requests":2}]`,
+			`[{"address":"localhost:4002","num_requests":0}]`,
+		}}
+		server := httptest.NewServer(admin)
+		defer server.Close()
+
+		start := time.Now()
+		summary := drainPort(context.Background(), caddy.NewServiceAt(server.URL), 4001, time.Second, time.Millisecond, 50*time.Millisecond)
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("expected the fallback wait, drain returned after %s", elapsed)
+		}
+		if !strings.Contains(summary, "drain stopped") || !strings.Contains(summary, caddy.ErrUpstreamNotFound.Error()) {
+			t.Errorf("unexpected summary: %s", summary)
+		}
+		if len(admin.released) != 1 {
+			t.Errorf("expected the hold of :4001 to be released, got %v", admin.released)
+		}
+	})
+}
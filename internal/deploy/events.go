@@ -0,0 +1,139 @@
+package deploy
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/models"
+)
+
+// Output receives the deployment logs and the output of local builds and hooks.
+// The CLI points it to stderr when stdout carries JSON events.
+var Output io.Writer = os.Stdout
+
+// Failure classes of a deployment, reported in events and mapped to exit codes by the CLI in CI mode
+const (
+	FailureConfig  = "config"  // Unknown app or host, invalid flags or configuration
+	FailureAuth    = "auth"    // Missing, invalid or insufficient credentials
+	FailureBuild   = "build"   // The artifact could not be built or found
+	FailureUpload  = "upload"  // The release could not be uploaded to the host
+	FailureHealth  = "health"  // The new version did not start or failed its health check
+	FailureTraffic = "traffic" // Traffic could not be switched to the new version
+	FailureDeploy  = "deploy"  // Any other failure, such as a hook
+)
+
+// stepFailureClasses maps the steps of a deployment to the class of their failures
+var stepFailureClasses = map[string]string{
+	models.DeployStepArtifact:    FailureBuild,
+	models.DeployStepUpload:      FailureUpload,
+	models.DeployStepPermissions: FailureUpload,
+	models.DeployStepStart:       FailureHealth,
+	models.DeployStepHealth:      FailureHealth,
+	models.DeployStepSwitch:      FailureTraffic,
+}
+
+// StepError is the failure of a named step of a deployment.
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string { return e.Err.Error() }
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// ConfigError is a deployment that cannot start because of its configuration, such as an unknown app or host.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string { return e.Err.Error() }
+
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// FailureClass returns the class of the failure of a deployment.
+func FailureClass(err error) string {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden) {
+		return FailureAuth
+	}
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		return FailureConfig
+	}
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		if class, ok := stepFailureClasses[stepErr.Step]; ok {
+			return class
+		}
+	}
+	return FailureDeploy
+}
+
+// Kinds of deployment events
+const (
+	EventDeployStarted   = "deploy_started"
+	EventStepStarted     = "step_started"
+	EventStepFinished    = "step_finished"
+	EventDeployFinished  = "deploy_finished"
+	EventRolloutFinished = "rollout_finished"
+)
+
+// Event is a machine-readable deployment event, written as one JSON object per line.
+type Event struct {
+	Time         time.Time `json:"time"`
+	Event        string    `json:"event"`
+	App          string    `json:"app,omitempty"`
+	Host         string    `json:"host,omitempty"`
+	DeploymentID string    `json:"deployment_id,omitempty"`
+	RolloutID    string    `json:"rollout_id,omitempty"`
+	Version      string    `json:"version,omitempty"`
+	Step         string    `json:"step,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	DurationMs   int64     `json:"duration_ms,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Class        string    `json:"class,omitempty"`
+}
+
+// EventWriter writes deployment events as JSON lines.
+// It is safe for concurrent use by the hosts of a rollout; a nil EventWriter discards events.
+type EventWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewEventWriter returns an EventWriter that writes to w.
+func NewEventWriter(w io.Writer) *EventWriter {
+	return &EventWriter{enc: json.NewEncoder(w)}
+}
+
+// Emit writes an event, stamped with the current time unless it has one.
+func (w *EventWriter) Emit(event Event) {
+	if w == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_ = w.enc.Encode(event)
+}
+
+// emit writes an event about this deployment.
+func (d *Deployer) emit(event Event) {
+	if d.events == nil {
+		return
+	}
+	event.App = d.AppName
+	event.Host = d.HostName
+	event.DeploymentID = d.DeploymentID
+	event.RolloutID = d.rolloutID
+	event.Version = d.Version
+	d.events.Emit(event)
+}
@@ -0,0 +1,72 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/models"
+)
+
+func TestFailureClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unauthorized", &client.APIError{StatusCode: http.StatusUnauthorized, Message: "Invalid or expired token"}, FailureAuth},
+		{"token of another app", fmt.Errorf("failed to fetch config: %w", &client.APIError{StatusCode: http.StatusForbidden}), FailureAuth},
+		{"unknown host", &ConfigError{Err: &client.APIError{StatusCode: http.StatusNotFound, Message: "host not found"}}, FailureConfig},
+		{"build", &StepError{Step: models.DeployStepArtifact, Err: errors.New("docker build failed")}, FailureBuild},
+		{"upload", &StepError{Step: models.DeployStepUpload, Err: errors.New("connection reset")}, FailureUpload},
+		{"health check", fmt.Errorf("rollout stopped at host web-2: %w", &StepError{Step: models.DeployStepHealth, Err: errors.New("health check failed")}), FailureHealth},
+		{"traffic", &StepError{Step: models.DeployStepSwitch, Err: errors.New("caddy unreachable")}, FailureTraffic},
+		{"hook", &StepError{Step: models.DeployStepMigrate, Err: errors.New("migrate failed")}, FailureDeploy},
+		{"other", errors.New("boom"), FailureDeploy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FailureClass(tt.err); got != tt.want {
+				t.Errorf("FailureClass() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunStepsEmitsEvents(t *testing.T) {
+	var out bytes.Buffer
+	d := &Deployer{AppName: "my_app", HostName: "prod", DeploymentID: "dpl_test", Version: "1.0.0", events: NewEventWriter(&out)}
+
+	steps := []deployStep{
+		{name: models.DeployStepUpload, run: func() error { return nil }},
+		{name: models.DeployStepHealth, run: func() error { return errors.New("health check failed") }},
+	}
+	err := d.runSteps(&stepsTestClient{}, steps)
+	if FailureClass(err) != FailureHealth {
+		t.Fatalf("expected a health failure, got %v", err)
+	}
+
+	var events []Event
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d: %s", len(events), out.String())
+	}
+	last := events[3]
+	if last.Event != EventStepFinished || last.Step != models.DeployStepHealth || last.Status != models.StepStatusFailed || last.Error != "health check failed" {
+		t.Errorf("unexpected last event: %+v", last)
+	}
+	if last.App != "my_app" || last.Host != "prod" || last.DeploymentID != "dpl_test" || last.Version != "1.0.0" || last.Time.IsZero() {
+		t.Errorf("expected the event to describe the deployment, got %+v", last)
+	}
+}
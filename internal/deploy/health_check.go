@@ -0,0 +1,182 @@
+package deploy
+
+import (
+	"context"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/database"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/pkg/types"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// healthCheckStatusMarker separates the response body from the status code in curl output.
+const healthCheckStatusMarker = "__SHIPYARD_HTTP_STATUS__"
+
+// probeFunc performs a single HTTP request and returns the status code and response body.
+type probeFunc func(url string, timeout time.Duration) (int, string, error)
+
+// healthCheckURL builds the probe URL for an instance listening on the given port.
+func healthCheckURL(port int, path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return fmt.Sprintf("http://localhost:%d%s", port, path)
+}
+
+// evaluateProbe checks a probe response against the configured expectations.
+func evaluateProbe(hc config.HealthCheck, statusCode int, body string) error {
+	if !hc.AcceptsStatus(statusCode) {
+		if len(hc.ExpectedStatus) > 0 {
+			return fmt.Errorf("unexpected status %d (expected %v)", statusCode, hc.ExpectedStatus)
+		}
+		return fmt.Errorf("unexpected status %d (expected 2xx or 3xx)", statusCode)
+	}
+	if hc.BodyContains != "" && !strings.Contains(body, hc.BodyContains) {
+		return fmt.Errorf("response body does not contain %q", hc.BodyContains)
+	}
+	return nil
+}
+
+// parseCurlProbeOutput splits the output of the curl probe command into status code and body.
+func parseCurlProbeOutput(output string) (int, string, error) {
+	idx := strings.LastIndex(output, healthCheckStatusMarker)
+	if idx == -1 {
+		return 0, "", fmt.Errorf("no HTTP response: %s", strings.TrimSpace(output))
+	}
+	body := strings.TrimSuffix(output[:idx], "\n")
+	code, err := strconv.Atoi(strings.TrimSpace(output[idx+len(healthCheckStatusMarker):]))
+	if err != nil {
+		return 0, body, fmt.Errorf("failed to parse status code: %w", err)
+	}
+	if code == 0 {
+		return 0, body, fmt.Errorf("no HTTP response: %s", strings.TrimSpace(body))
+	}
+	return code, body, nil
+}
+
+// runHealthProbes probes the instance until it passes, the retries are exhausted or ctx is done.
+// Every attempt is logged and returned so the caller can persist why a release was rejected.
+func runHealthProbes(ctx context.Context, port int, hc config.HealthCheck, probe probeFunc) ([]models.HealthCheckResult, error) {
+	url := healthCheckURL(port, hc.Path)
+	var results []models.HealthCheckResult
+	var lastErr error
+
+	if hc.GracePeriod > 0 {
+		log.Printf("Waiting %s grace period before the first health probe...", hc.GracePeriod)
+		if err := sleepContext(ctx, hc.GracePeriod); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 1; attempt <= hc.Retries; attempt++ {
+		start := time.Now()
+		statusCode, body, err := probe(url, hc.Timeout)
+		if err == nil {
+			err = evaluateProbe(hc, statusCode, body)
+		}
+		now := time.Now()
+
+		result := models.HealthCheckResult{
+			Attempt:    attempt,
+			Port:       port,
+			URL:        url,
+			StatusCode: statusCode,
+			Passed:     err == nil,
+			DurationMs: now.Sub(start).Milliseconds(),
+			CreatedAt:  models.NullableTime{Time: &now},
+		}
+
+		if err == nil {
+			result.Message = "ok"
+			results = append(results, result)
+			log.Printf("✅ Health probe %d/%d GET %s -> %d (%dms)", attempt, hc.Retries, url, statusCode, result.DurationMs)
+			return results, nil
+		}
+
+		result.Message = err.Error()
+		results = append(results, result)
+		lastErr = err
+		log.Printf("❌ Health probe %d/%d GET %s failed: %v (%dms)", attempt, hc.Retries, url, err, result.DurationMs)
+
+		if attempt < hc.Retries {
+			if err := sleepContext(ctx, hc.Interval); err != nil {
+				return results, err
+			}
+		}
+	}
+
+	return results, fmt.Errorf("instance on port %d did not become healthy after %d probes: %w", port, hc.Retries, lastErr)
+}
+
+// probeHTTP probes a URL on the target host with curl, over SSH or locally.
+func (d *Deployer) probeHTTP(url string, timeout time.Duration) (int, string, error) {
+	cmd := fmt.Sprintf("curl -sS --max-time %.1f -w '\\n%s%%{http_code}' %s",
+		timeout.Seconds(), healthCheckStatusMarker, shellQuote(url))
+	// curl exits non-zero on connection errors; the status marker is still printed, so parse the output regardless.
+	output, _ := d.executeRemoteCommandWithOutput(cmd)
+	return parseCurlProbeOutput(output)
+}
+
+// probeHTTPLocally probes a URL from the current machine.
+func probeHTTPLocally(url string, timeout time.Duration) (int, string, error) {
+	client := &http.Client{
+		Timeout: timeout,
+		// Report redirects as-is instead of following them to another host
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, "", fmt.Errorf("no HTTP response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+// recordHealthChecks persists the collected probe results, via the API or directly to the database.
+func (d *Deployer) recordHealthChecks(results []models.HealthCheckResult) {
+	if len(results) == 0 {
+		return
+	}
+
+	if d.APIClient != nil {
+		if d.DeploymentID == "" {
+			return
+		}
+		dtos := make([]types.HealthCheckResultDTO, len(results))
+		for i, r := range results {
+			dtos[i] = types.HealthCheckResultDTO{
+				Attempt:    r.Attempt,
+				Port:       r.Port,
+				URL:        r.URL,
+				StatusCode: r.StatusCode,
+				Passed:     r.Passed,
+				Message:    r.Message,
+				DurationMs: r.DurationMs,
+				CreatedAt:  r.CreatedAt.Time,
+			}
+		}
+		if err := d.APIClient.UploadHealthChecks(d.DeploymentID, dtos); err != nil {
+			log.Printf("⚠️ Failed to upload health check results: %v", err)
+		}
+		return
+	}
+
+	if d.History != nil {
+		if err := database.AddDeploymentHealthChecks(d.History.ID, results); err != nil {
+			log.Printf("⚠️ Failed to save health check results: %v", err)
+		}
+	}
+}
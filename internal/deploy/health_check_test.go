@@ -0,0 +1,153 @@
+package deploy
+
+import (
+	"context"
+	"youfun/shipyard/internal/config"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckURL(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "http://localhost:4001/"},
+		{"/healthz", "http://localhost:4001/healthz"},
+		{"ready", "http://localhost:4001/ready"},
+	}
+	for _, tt := range tests {
+		if got := healthCheckURL(4001, tt.path); got != tt.want {
+			t.Errorf("healthCheckURL(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseCurlProbeOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		wantStatus int
+		wantBody   string
+		wantErr    bool
+	}{
+		{
+			name:       "ok response",
+			output:     "{\"status\":\"ok\"}\n" + healthCheckStatusMarker + "200",
+			wantStatus: 200,
+			wantBody:   "{\"status\":\"ok\"}",
+		},
+		{
+			name:       "empty body",
+			output:     "\n" + healthCheckStatusMarker + "204",
+			wantStatus: 204,
+		},
+		{
+			name:    "connection refused",
+			output:  "curl: (7) Failed to connect to localhost port 4001\n\n" + healthCheckStatusMarker + "000",
+			wantErr: true,
+		},
+		{
+			name:    "no marker",
+			output:  "bash: curl: command not found",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body, err := parseCurlProbeOutput(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCurlProbeOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEvaluateProbe(t *testing.T) {
+	hc := config.HealthCheck{ExpectedStatus: []int{200}, BodyContains: "ready"}
+
+	if err := evaluateProbe(hc, 200, "service ready"); err != nil {
+		t.Errorf("expected probe to pass, got %v", err)
+	}
+	if err := evaluateProbe(hc, 503, "service ready"); err == nil {
+		t.Error("expected unexpected status to fail")
+	}
+	if err := evaluateProbe(hc, 200, "starting"); err == nil {
+		t.Error("expected missing body substring to fail")
+	}
+}
+
+func TestRunHealthProbes(t *testing.T) {
+	hc := config.HealthCheck{Path: "/healthz", Interval: time.Millisecond, Timeout: time.Second, Retries: 3}
+
+	t.Run("passes after retries", func(t *testing.T) {
+		calls := 0
+		probe := func(url string, timeout time.Duration) (int, string, error) {
+			calls++
+			if calls < 2 {
+				return 0, "", errors.New("no HTTP response: connection refused")
+			}
+			return 200, "ok", nil
+		}
+
+		results, err := runHealthProbes(context.Background(), 4001, hc, probe)
+		if err != nil {
+			t.Fatalf("expected health check to pass, got %v", err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("expected 2 recorded probes, got %d", len(results))
+		}
+		if results[0].Passed || !results[1].Passed {
+			t.Errorf("unexpected probe results: %+v", results)
+		}
+		if results[1].URL != "http://localhost:4001/healthz" || results[1].StatusCode != 200 {
+			t.Errorf("unexpected probe result: %+v", results[1])
+		}
+	})
+
+	t.Run("fails when retries are exhausted", func(t *testing.T) {
+		probe := func(url string, timeout time.Duration) (int, string, error) {
+			return 500, "internal error", nil
+		}
+
+		results, err := runHealthProbes(context.Background(), 4001, hc, probe)
+		if err == nil {
+			t.Fatal("expected health check to fail")
+		}
+		if len(results) != 3 {
+			t.Fatalf("expected 3 recorded probes, got %d", len(results))
+		}
+		if results[2].Message == "" || results[2].Passed {
+			t.Errorf("expected failed probe to carry a reason, got %+v", results[2])
+		}
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		probe := func(url string, timeout time.Duration) (int, string, error) {
+			cancel()
+			return 503, "starting", nil
+		}
+
+		slow := hc
+		slow.Interval = time.Minute
+		results, err := runHealthProbes(ctx, 4001, slow, probe)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected cancellation error, got %v", err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("expected 1 recorded probe, got %d", len(results))
+		}
+	})
+}
@@ -96,7 +96,12 @@ func (d *Deployer) runHooks(stageName string, hooks []config.Hook) error {
 			envFile := fmt.Sprintf("/etc/%s/env", d.AppName)
 			// Source env file and execute command in sub-shell to ensure variables are passed
 			// Prepend space to command to avoid recording in bash history (relies on HISTCONTROL=ignorespace)
-			commandToExecute = fmt.Sprintf(` cd %s && (set -a; . %s; set +a; exec %s)`, d.CurrentReleasePath, envFile, finalCommand)
+			// Python releases vendor their dependencies in venv/, run the command inside it
+			venv := ""
+			if d.Runtime == "python" {
+				venv = fmt.Sprintf(`export VIRTUAL_ENV="%s" PATH="%s:$PATH"; `, path.Join(d.CurrentReleasePath, "venv"), path.Join(d.CurrentReleasePath, "venv", "bin"))
+			}
+			commandToExecute = fmt.Sprintf(` cd %s && (set -a; . %s; set +a; %sexec %s)`, d.CurrentReleasePath, envFile, venv, finalCommand)
 		default:
 			return fmt.Errorf("unknown hook type: '%s'", hook.Type)
 		}
@@ -0,0 +1,133 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/pkg/types"
+)
+
+const (
+	// lockHeartbeatInterval is how often the deploy lock lease is renewed; the server lease lasts 60s
+	lockHeartbeatInterval = 15 * time.Second
+	// lockWaitInterval is how often a queued deployment retries to take the lock
+	lockWaitInterval = 10 * time.Second
+)
+
+// deployLock tracks the instance lock held by a deployment created through the API.
+type deployLock struct {
+	wait      bool               // Queue behind the current holder instead of failing
+	lost      context.CancelFunc // Cancels the deployment when the lease is lost
+	stop      chan struct{}      // Closed to stop the heartbeat
+	done      chan struct{}      // Closed when the heartbeat has stopped
+	handedOff bool               // The server holds the lock for a server-side deployment
+}
+
+// lockConflict returns the API error of a request rejected because the instance is locked.
+func lockConflict(err error) (*client.APIError, bool) {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// createDeployment creates the deployment record, which takes the deploy lock of the instance.
+// While another deployment holds the lock it fails, or waits for the lock with --wait.
+func (d *Deployer) createDeployment(apiClient client.APIClient) error {
+	deployReq := &types.CreateDeploymentRequest{
+		AppName:   d.AppName,
+		HostName:  d.HostName,
+		Version:   d.Version,
+		RolloutID: d.rolloutID,
+	}
+
+	waiting := ""
+	for {
+		historyDTO, err := apiClient.CreateDeployment(deployReq)
+		if err == nil {
+			// Store the friendly ID for API calls
+			d.DeploymentID = historyDTO.ID
+			d.startLockHeartbeat(apiClient)
+			return nil
+		}
+
+		conflict, ok := lockConflict(err)
+		if !ok {
+			return fmt.Errorf("failed to create deployment record: %w", err)
+		}
+		if !d.lock.wait {
+			return fmt.Errorf("%s (use --wait to queue behind it)", conflict.Message)
+		}
+		if conflict.Message != waiting {
+			log.Printf("⏳ %s, waiting for the lock...", conflict.Message)
+			waiting = conflict.Message
+		}
+		if err := d.sleep(lockWaitInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// startLockHeartbeat renews the lease of the deploy lock until the deployment finishes.
+// If the lease is lost, e.g. because the instance was force-unlocked, the deployment is cancelled.
+func (d *Deployer) startLockHeartbeat(apiClient client.APIClient) {
+	stop, done := make(chan struct{}), make(chan struct{})
+	d.lock.stop, d.lock.done = stop, done
+	deploymentID, lost := d.DeploymentID, d.lock.lost
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(lockHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			err := apiClient.RenewDeploymentLock(deploymentID)
+			if _, ok := lockConflict(err); ok {
+				log.Println("❌ Deploy lock lost, another deployment may have taken over. Stopping...")
+				if lost != nil {
+					lost()
+				}
+				return
+			}
+			if err != nil {
+				log.Printf("⚠️  Warning: Failed to renew deploy lock: %v", err)
+			}
+		}
+	}()
+}
+
+// stopLockHeartbeat stops renewing the deploy lock and waits for a renewal in flight.
+func (d *Deployer) stopLockHeartbeat() {
+	if d.lock.stop != nil {
+		close(d.lock.stop)
+		<-d.lock.done
+		d.lock.stop, d.lock.done = nil, nil
+	}
+}
+
+// handOffLock leaves the deploy lock to the server, which releases it when its run finishes.
+func (d *Deployer) handOffLock() {
+	d.stopLockHeartbeat()
+	d.lock.handedOff = true
+}
+
+// releaseLock releases the deploy lock held by this deployment.
+func (d *Deployer) releaseLock(apiClient client.APIClient) {
+	d.stopLockHeartbeat()
+	if d.DeploymentID == "" || d.lock.handedOff {
+		return
+	}
+	if err := apiClient.ReleaseDeploymentLock(d.DeploymentID); err != nil {
+		log.Printf("⚠️  Warning: Failed to release deploy lock: %v", err)
+	}
+}
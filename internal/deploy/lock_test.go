@@ -0,0 +1,117 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/pkg/types"
+)
+
+// lockTestClient fakes the deployment endpoints that take and release the deploy lock
+type lockTestClient struct {
+	client.APIClient
+	createErrs []error
+	creates    int
+	released   []string
+}
+
+func (c *lockTestClient) CreateDeployment(req *types.CreateDeploymentRequest) (*types.DeploymentHistoryDTO, error) {
+	c.creates++
+	if len(c.createErrs) > 0 {
+		err := c.createErrs[0]
+		c.createErrs = c.createErrs[1:]
+		return nil, err
+	}
+	return &types.DeploymentHistoryDTO{ID: "dpl_test"}, nil
+}
+
+func (c *lockTestClient) RenewDeploymentLock(deploymentID string) error {
+	return nil
+}
+
+func (c *lockTestClient) ReleaseDeploymentLock(deploymentID string) error {
+	c.released = append(c.released, deploymentID)
+	return nil
+}
+
+func lockedError() error {
+	return &client.APIError{StatusCode: http.StatusConflict, Message: "deploy in progress by alice since 2024-05-01 12:30:00"}
+}
+
+func TestCreateDeploymentTakesLock(t *testing.T) {
+	api := &lockTestClient{}
+	d := &Deployer{AppName: "my_app", HostName: "prod"}
+
+	if err := d.createDeployment(api); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.DeploymentID != "dpl_test" {
+		t.Errorf("got deployment ID %q, want dpl_test", d.DeploymentID)
+	}
+
+	d.releaseLock(api)
+	if len(api.released) != 1 || api.released[0] != "dpl_test" {
+		t.Errorf("expected lock of dpl_test to be released, got %v", api.released)
+	}
+}
+
+func TestCreateDeploymentLocked(t *testing.T) {
+	api := &lockTestClient{createErrs: []error{lockedError()}}
+	d := &Deployer{AppName: "my_app", HostName: "prod"}
+
+	err := d.createDeployment(api)
+	if err == nil {
+		t.Fatal("expected error while the instance is locked")
+	}
+	if !strings.Contains(err.Error(), "deploy in progress by alice") || !strings.Contains(err.Error(), "--wait") {
+		t.Errorf("unexpected error message: %v", err)
+	}
+	if api.creates != 1 {
+		t.Errorf("expected a single attempt without --wait, got %d", api.creates)
+	}
+
+	d.releaseLock(api)
+	if len(api.released) != 0 {
+		t.Errorf("expected no release without a deployment, got %v", api.released)
+	}
+}
+
+func TestCreateDeploymentWaitStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	api := &lockTestClient{createErrs: []error{lockedError()}}
+	d := &Deployer{AppName: "my_app", HostName: "prod", ctx: ctx, lock: deployLock{wait: true}}
+
+	if err := d.createDeployment(api); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+}
+
+func TestCreateDeploymentOtherError(t *testing.T) {
+	api := &lockTestClient{createErrs: []error{&client.APIError{StatusCode: http.StatusNotFound, Message: "Host not found"}}}
+	d := &Deployer{AppName: "my_app", HostName: "prod", lock: deployLock{wait: true}}
+
+	if err := d.createDeployment(api); err == nil || !strings.Contains(err.Error(), "failed to create deployment record") {
+		t.Fatalf("expected create error, got %v", err)
+	}
+	if api.creates != 1 {
+		t.Errorf("expected no retry for other errors, got %d attempts", api.creates)
+	}
+}
+
+func TestHandOffLockSkipsRelease(t *testing.T) {
+	api := &lockTestClient{}
+	d := &Deployer{AppName: "my_app", HostName: "prod"}
+	if err := d.createDeployment(api); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	d.handOffLock()
+	d.releaseLock(api)
+	if len(api.released) != 0 {
+		t.Errorf("expected handed-off lock to be kept for the server, got %v", api.released)
+	}
+}
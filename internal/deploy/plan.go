@@ -0,0 +1,457 @@
+package deploy
+
+import (
+	"encoding/json"
+	"youfun/shipyard/internal/caddy"
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/crypto"
+	"youfun/shipyard/internal/models"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// planNewUpstream stands for the new version in planned routes, its port is picked when it starts.
+const planNewUpstream = "localhost:<new port>"
+
+// planNewUpstreams stands for the instances of the new version in planned routes.
+func planNewUpstreams(count int) []string {
+	if count <= 1 {
+		return []string{planNewUpstream}
+	}
+	upstreams := make([]string, count)
+	for i := range upstreams {
+		upstreams[i] = fmt.Sprintf("localhost:<new port %d>", i+1)
+	}
+	return upstreams
+}
+
+// Plan describes what a deployment would do, without changing anything.
+type Plan struct {
+	App         string          `json:"app"`
+	Host        string          `json:"host"`
+	Runtime     string          `json:"runtime"`
+	Canary      int             `json:"canary,omitempty"`
+	Instances   int             `json:"instances"` // Instances of the new version started on the host
+	ReleasePath string          `json:"release_path"`
+	Artifact    PlanArtifact    `json:"artifact"`
+	Hooks       []PlanHook      `json:"hooks"`
+	Env         []PlanEnvChange `json:"env"`
+	Routes      []PlanRoute     `json:"routes"`
+	Stop        []PlanStop      `json:"stop"`
+	Warnings    []string        `json:"warnings,omitempty"`
+}
+
+// PlanArtifact is the build artifact a deployment would reuse or build.
+type PlanArtifact struct {
+	Action       string `json:"action"` // reuse|build
+	Version      string `json:"version"`
+	MD5          string `json:"md5,omitempty"`
+	GitCommitSHA string `json:"git_commit_sha,omitempty"`
+	Path         string `json:"path,omitempty"`
+	Reason       string `json:"reason"`
+	Location     string `json:"location,omitempty"`     // Where a new build runs: local, server or host
+	BuilderHost  string `json:"builder_host,omitempty"` // Builder host of a build on a host
+	Builder      string `json:"builder,omitempty"`      // Builder of a new build: docker, mix, go or tarball
+}
+
+// PlanHook is a hook a deployment would run, with its template variables substituted.
+type PlanHook struct {
+	Stage   string `json:"stage"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Command string `json:"command"`
+}
+
+// PlanEnvChange is the change of one key of the env file of the application. Values are never included.
+type PlanEnvChange struct {
+	Key    string `json:"key"`
+	Action string `json:"action"` // add|change|remove|keep
+}
+
+// PlanRoute is a Caddy route a deployment would add or change.
+type PlanRoute struct {
+	Domain  string   `json:"domain"`
+	Action  string   `json:"action"` // add|change
+	Current []string `json:"current,omitempty"`
+	Target  []string `json:"target"`
+}
+
+// PlanStop is a running version a deployment would stop.
+type PlanStop struct {
+	Port    int    `json:"port"`
+	Version string `json:"version,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// BuildPlan works out what RunWithAPIClient would do for the given app and host, without building,
+// uploading or changing anything on the server or the host. The host is only read over SSH.
+func BuildPlan(apiClient client.APIClient, appName, hostName string, opts RunOptions, hostKeyCallback ssh.HostKeyCallback) (*Plan, error) {
+	d := &Deployer{
+		AppName:         appName,
+		HostName:        hostName,
+		useBuild:        opts.UseBuild,
+		APIClient:       apiClient,
+		IsLocalhost:     hostName == "localhost" || hostName == "127.0.0.1" || hostName == "local",
+		HostKeyCallback: hostKeyCallback,
+		canaryWeight:    opts.Canary,
+	}
+
+	conf, err := apiClient.GetDeployConfig(appName, hostName)
+	if err != nil {
+		return nil, err
+	}
+	d.Domains = conf.Domains
+	if d.Application, err = convertAppDTOToModel(&conf.App); err != nil {
+		return nil, err
+	}
+	if d.trustedKeys, err = trustedKeysFromDTOs(conf.TrustedKeys); err != nil {
+		return nil, err
+	}
+	if d.Host, err = convertHostDTOToModel(&conf.Host); err != nil {
+		return nil, err
+	}
+	if d.Instance, err = convertInstanceDTOToModel(&conf.Instance); err != nil {
+		return nil, err
+	}
+	d.instanceUID = conf.Instance.UID
+	d.clusterNodes = conf.ClusterNodes
+
+	config.LoadConfig(d.AppName, config.ConfigPath)
+	d.Runtime = config.AppConfig.Runtime
+	if d.Runtime == "" {
+		d.Runtime = d.detectRuntime()
+	}
+	// The deployment syncs the domains of shipyard.toml before switching traffic
+	if len(config.AppConfig.Domains) > 0 {
+		d.Domains = config.AppConfig.Domains
+	}
+
+	plan := &Plan{App: d.AppName, Host: d.HostName, Runtime: d.Runtime, Canary: d.canaryWeight}
+	plan.Artifact = d.planArtifact(plan)
+
+	plan.ReleasePath = fmt.Sprintf("%s/%s-%d", config.GetRemoteReleasesDir(), d.Version, time.Now().Unix())
+	d.CurrentReleasePath = plan.ReleasePath
+	plan.Hooks = d.planHooks(plan)
+
+	oldPort := 0
+	if d.Instance.ActivePort.Valid && d.Instance.ActivePort.Int64 > 0 {
+		oldPort = int(d.Instance.ActivePort.Int64)
+	}
+	d.oldPorts = servingPorts(d.Instance, conf.Instance.ActivePorts)
+	d.scale = instanceCount(config.AppConfig.Scale, d.oldPorts)
+	plan.Instances = d.scale
+	if d.canaryWeight > 0 && d.scale > 1 {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("--canary is not supported with %d instances (scale), the deployment would fail", d.scale))
+	}
+	canary := d.canaryWeight > 0 && oldPort > 0 && len(d.Domains) > 0
+	if d.canaryWeight > 0 && !canary {
+		plan.Warnings = append(plan.Warnings, "No version is serving traffic through a domain yet, all traffic would be switched instead of starting a canary")
+	}
+	if len(d.Domains) == 0 {
+		plan.Warnings = append(plan.Warnings, "No domain configured, Caddy would not be configured")
+	}
+
+	if d.IsLocalhost && d.canaryWeight > 0 {
+		plan.Warnings = append(plan.Warnings, "--canary is not supported for deployments to the server itself, the deployment would fail")
+	}
+	if d.IsLocalhost {
+		plan.Warnings = append(plan.Warnings, "Env file and Caddy routes are not compared for deployments to the server itself")
+	} else if err := d.planHost(plan, conf.Secrets, oldPort, canary); err != nil {
+		return nil, err
+	}
+
+	if len(d.oldPorts) > 0 && !canary {
+		releases, err := apiClient.ListReleases(d.instanceUID)
+		if err != nil {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("Failed to list releases: %v", err))
+		}
+		for _, port := range d.oldPorts {
+			stop := PlanStop{Port: port, Reason: fmt.Sprintf("replaced by the new version, stopped once drained (up to %s)", drainTimeout())}
+			for _, release := range releases {
+				if release.Port == port && release.Status == models.ReleaseStatusActive {
+					stop.Version = release.Version
+					break
+				}
+			}
+			plan.Stop = append(plan.Stop, stop)
+		}
+	}
+	return plan, nil
+}
+
+// planArtifact works out which artifact the deployment would reuse, or the version it would build.
+func (d *Deployer) planArtifact(plan *Plan) PlanArtifact {
+	gitVersion, reason := d.reuseArtifact()
+	if d.tarballPath != "" {
+		return PlanArtifact{Action: "reuse", Version: d.Version, MD5: d.md5Hash, GitCommitSHA: d.GitCommitSHA, Path: d.tarballPath, Reason: reason}
+	}
+
+	version, err := d.projectVersion()
+	if err != nil {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("Failed to read the project version: %v", err))
+	}
+	d.Version = version
+	build := config.AppConfig.Build
+	if err := build.Validate(); err != nil {
+		plan.Warnings = append(plan.Warnings, err.Error())
+	}
+	if err := config.AppConfig.ValidateRun(); err != nil {
+		plan.Warnings = append(plan.Warnings, err.Error())
+	}
+	if err := config.AppConfig.ValidateCron(); err != nil {
+		plan.Warnings = append(plan.Warnings, err.Error())
+	}
+	if err := config.AppConfig.ValidateUpload(); err != nil {
+		plan.Warnings = append(plan.Warnings, err.Error())
+	}
+	artifact := PlanArtifact{Action: "build", Version: version, GitCommitSHA: gitVersion, Reason: reason, Location: build.Location, Builder: build.Builder}
+	if build.Location == config.BuildHost {
+		artifact.BuilderHost = build.Host
+	}
+	return artifact
+}
+
+// planHooks lists the configured hooks in the order the deployment runs them.
+func (d *Deployer) planHooks(plan *Plan) []PlanHook {
+	stages := []struct {
+		name  string
+		hooks []config.Hook
+	}{
+		{"pre_deploy", config.AppConfig.Hooks.PreDeploy},
+		{"migrate", config.AppConfig.Hooks.Migrate},
+		{"post_deploy", config.AppConfig.Hooks.PostDeploy},
+	}
+
+	var hooks []PlanHook
+	for _, stage := range stages {
+		for _, hook := range stage.hooks {
+			if hook.Type != "eval" && hook.Type != "shell" {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("Hook '%s' has unknown type '%s', the %s stage would fail", hook.Name, hook.Type, stage.name))
+			}
+			hooks = append(hooks, PlanHook{Stage: stage.name, Name: hook.Name, Type: hook.Type, Command: d.substituteVariables(hook.Command)})
+		}
+	}
+	return hooks
+}
+
+// planHost compares the env file and the Caddy routes on the host with what the deployment would write.
+func (d *Deployer) planHost(plan *Plan, secrets map[string]string, oldPort int, canary bool) error {
+	if err := d.connectSSHWithAPIConfig(); err != nil {
+		return err
+	}
+	defer d.close()
+
+	envs := d.prepareEnvVars(secrets)
+	if clusterRuntime(d.Runtime) {
+		clusterEnv(envs, d.clusterNodes)
+	}
+	target := make(map[string]string, len(envs))
+	for key, value := range envs {
+		target[key] = formatEnvValue(value)
+	}
+	if _, exists := target["SECRET_KEY_BASE"]; d.Runtime == "phoenix" && !exists {
+		// Generated for every deployment that has none
+		secret, err := crypto.GeneratePhoenixSecret()
+		if err != nil {
+			return fmt.Errorf("failed to generate secret: %w", err)
+		}
+		target["SECRET_KEY_BASE"] = secret
+	}
+	if _, exists := target[ReleaseCookieKey]; clusterRuntime(d.Runtime) && !exists {
+		// Generated by the server on the first deployment of the app
+		cookie, err := crypto.GenerateReleaseCookie()
+		if err != nil {
+			return err
+		}
+		target[ReleaseCookieKey] = cookie
+	}
+
+	current, err := d.executeRemoteCommandWithOutput(fmt.Sprintf("cat /etc/%s/env 2>/dev/null || true", d.AppName))
+	if err != nil {
+		return fmt.Errorf("failed to read env file: %w", err)
+	}
+	plan.Env = diffEnv(parseEnvFile(current), target)
+
+	if len(d.Domains) == 0 {
+		return nil
+	}
+	routes, err := caddy.NewService(d.SSHClient).RouteUpstreams()
+	if err != nil {
+		return err
+	}
+	upstreams := planNewUpstreams(d.scale)
+	if canary {
+		upstreams = []string{
+			caddy.Upstream{Port: oldPort, Weight: 100 - d.canaryWeight}.String(),
+			fmt.Sprintf("%s (%d%%)", planNewUpstream, d.canaryWeight),
+		}
+	}
+	plan.Routes = planRoutes(d.Domains, routes, upstreams)
+	return nil
+}
+
+// parseEnvFile reads the KEY=VALUE lines of an env file.
+func parseEnvFile(content string) map[string]string {
+	envs := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		envs[strings.TrimSpace(key)] = value
+	}
+	return envs
+}
+
+// diffEnv compares the current env file with the one the deployment would write, key by key.
+// The env file is rewritten as a whole, so keys missing from target are removed.
+func diffEnv(current, target map[string]string) []PlanEnvChange {
+	keys := make([]string, 0, len(current)+len(target))
+	for key := range target {
+		keys = append(keys, key)
+	}
+	for key := range current {
+		if _, ok := target[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := make([]PlanEnvChange, 0, len(keys))
+	for _, key := range keys {
+		currentValue, inCurrent := current[key]
+		targetValue, inTarget := target[key]
+		action := "keep"
+		switch {
+		case !inCurrent:
+			action = "add"
+		case !inTarget:
+			action = "remove"
+		case currentValue != targetValue:
+			action = "change"
+		}
+		changes = append(changes, PlanEnvChange{Key: key, Action: action})
+	}
+	return changes
+}
+
+// planRoutes compares the upstreams of the domains in Caddy with the ones the deployment would set.
+func planRoutes(domains []string, current map[string][]string, target []string) []PlanRoute {
+	routes := make([]PlanRoute, 0, len(domains))
+	for _, domain := range domains {
+		route := PlanRoute{Domain: domain, Action: "add", Target: target}
+		if upstreams, ok := current[domain]; ok {
+			route.Action = "change"
+			route.Current = upstreams
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// WriteText writes the plan in a form meant to be read by people, e.g. in change tickets.
+func (p *Plan) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Deployment plan for app '%s' on host '%s' (runtime: %s)\n", p.App, p.Host, p.Runtime)
+	if p.Canary > 0 {
+		fmt.Fprintf(&b, "Canary: %d%% of the traffic to the new version\n", p.Canary)
+	}
+	if p.Instances > 1 {
+		fmt.Fprintf(&b, "Instances: %d, load balanced by Caddy\n", p.Instances)
+	}
+
+	fmt.Fprintf(&b, "\nArtifact:\n")
+	a := p.Artifact
+	if a.Action == "reuse" {
+		fmt.Fprintf(&b, "  reuse %s (MD5: %s, Git: %s)\n", a.Version, a.MD5, a.GitCommitSHA)
+	} else {
+		fmt.Fprintf(&b, "  build %s (Git: %s) with %s%s\n", a.Version, a.GitCommitSHA, a.Builder, buildLocationText(a.Location, a.BuilderHost))
+	}
+	fmt.Fprintf(&b, "  reason: %s\n", a.Reason)
+	fmt.Fprintf(&b, "  release: %s\n", p.ReleasePath)
+
+	fmt.Fprintf(&b, "\nHooks:\n")
+	if len(p.Hooks) == 0 {
+		fmt.Fprintf(&b, "  none\n")
+	}
+	for _, hook := range p.Hooks {
+		fmt.Fprintf(&b, "  [%s] %s (%s): %s\n", hook.Stage, hook.Name, hook.Type, hook.Command)
+	}
+
+	fmt.Fprintf(&b, "\nEnv (/etc/%s/env, values hidden):\n", p.App)
+	switch {
+	case p.Env == nil:
+		fmt.Fprintf(&b, "  not compared\n")
+	case len(p.Env) == 0:
+		fmt.Fprintf(&b, "  none\n")
+	}
+	envSigns := map[string]string{"add": "+", "change": "~", "remove": "-", "keep": " "}
+	for _, change := range p.Env {
+		fmt.Fprintf(&b, "  %s %s (%s)\n", envSigns[change.Action], change.Key, change.Action)
+	}
+
+	fmt.Fprintf(&b, "\nCaddy routes:\n")
+	if len(p.Routes) == 0 {
+		fmt.Fprintf(&b, "  none\n")
+	}
+	for _, route := range p.Routes {
+		if route.Action == "add" {
+			fmt.Fprintf(&b, "  + %s -> %s\n", route.Domain, strings.Join(route.Target, ", "))
+			continue
+		}
+		fmt.Fprintf(&b, "  ~ %s: %s -> %s\n", route.Domain, strings.Join(route.Current, ", "), strings.Join(route.Target, ", "))
+	}
+
+	fmt.Fprintf(&b, "\nStopped versions:\n")
+	if len(p.Stop) == 0 {
+		fmt.Fprintf(&b, "  none\n")
+	}
+	for _, stop := range p.Stop {
+		version := stop.Version
+		if version == "" {
+			version = "unknown version"
+		}
+		fmt.Fprintf(&b, "  :%d (%s), %s\n", stop.Port, version, stop.Reason)
+	}
+
+	if len(p.Warnings) > 0 {
+		fmt.Fprintf(&b, "\nWarnings:\n")
+		for _, warning := range p.Warnings {
+			fmt.Fprintf(&b, "  ⚠️  %s\n", warning)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// buildLocationText describes where a new build runs, empty for a local build.
+func buildLocationText(location, builderHost string) string {
+	switch location {
+	case config.BuildServer:
+		return " on the server"
+	case config.BuildHost:
+		return fmt.Sprintf(" on builder host %s", builderHost)
+	}
+	return ""
+}
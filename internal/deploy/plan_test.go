@@ -0,0 +1,92 @@
+package deploy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	content := "# generated\nDATABASE_URL=postgres://user:pass@db/app?sslmode=disable\n\nPHX_HOST=example.com\ninvalid line\n"
+
+	got := parseEnvFile(content)
+	want := map[string]string{
+		"DATABASE_URL": "postgres://user:pass@db/app?sslmode=disable",
+		"PHX_HOST":     "example.com",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseEnvFile() = %v, want %v", got, want)
+	}
+}
+
+func TestDiffEnv(t *testing.T) {
+	current := map[string]string{"API_KEY": "old", "PHX_HOST": "example.com", "LEGACY": "1"}
+	target := map[string]string{"API_KEY": "new", "PHX_HOST": "example.com", "POOL_SIZE": "10"}
+
+	got := diffEnv(current, target)
+	want := []PlanEnvChange{
+		{Key: "API_KEY", Action: "change"},
+		{Key: "LEGACY", Action: "remove"},
+		{Key: "PHX_HOST", Action: "keep"},
+		{Key: "POOL_SIZE", Action: "add"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffEnv() = %v, want %v", got, want)
+	}
+}
+
+func TestPlanRoutes(t *testing.T) {
+	current := map[string][]string{"example.com": {"localhost:10001"}}
+	target := []string{planNewUpstream}
+
+	got := planRoutes([]string{"example.com", "www.example.com"}, current, target)
+	want := []PlanRoute{
+		{Domain: "example.com", Action: "change", Current: []string{"localhost:10001"}, Target: target},
+		{Domain: "www.example.com", Action: "add", Target: target},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("planRoutes() = %v, want %v", got, want)
+	}
+}
+
+func TestPlanNewUpstreams(t *testing.T) {
+	if got := planNewUpstreams(1); !reflect.DeepEqual(got, []string{planNewUpstream}) {
+		t.Errorf("planNewUpstreams(1) = %v", got)
+	}
+	want := []string{"localhost:<new port 1>", "localhost:<new port 2>", "localhost:<new port 3>"}
+	if got := planNewUpstreams(3); !reflect.DeepEqual(got, want) {
+		t.Errorf("planNewUpstreams(3) = %v, want %v", got, want)
+	}
+}
+
+func TestPlanWriteText(t *testing.T) {
+	plan := &Plan{
+		App:         "myapp",
+		Host:        "prod",
+		Runtime:     "phoenix",
+		ReleasePath: "/var/www/myapp/releases/1.2.0-1714550000",
+		Artifact:    PlanArtifact{Action: "reuse", Version: "1.2.0", MD5: "abc123", GitCommitSHA: "9f8e7d6", Reason: "already built from commit 9f8e7d6"},
+		Hooks:       []PlanHook{{Stage: "migrate", Name: "migrate", Type: "eval", Command: "MyApp.Release.migrate"}},
+		Env:         []PlanEnvChange{{Key: "API_KEY", Action: "change"}, {Key: "LEGACY", Action: "remove"}},
+		Routes:      []PlanRoute{{Domain: "example.com", Action: "change", Current: []string{"localhost:10001"}, Target: []string{planNewUpstream}}},
+		Stop:        []PlanStop{{Port: 10001, Version: "1.1.0", Reason: "replaced by the new version"}},
+	}
+
+	var b strings.Builder
+	if err := plan.WriteText(&b); err != nil {
+		t.Fatalf("WriteText() failed: %v", err)
+	}
+	out := b.String()
+	for _, line := range []string{
+		"reuse 1.2.0 (MD5: abc123, Git: 9f8e7d6)",
+		"[migrate] migrate (eval): MyApp.Release.migrate",
+		"~ API_KEY (change)",
+		"- LEGACY (remove)",
+		"~ example.com: localhost:10001 -> localhost:<new port>",
+		":10001 (1.1.0), replaced by the new version",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected plan to contain %q, got:\n%s", line, out)
+		}
+	}
+}
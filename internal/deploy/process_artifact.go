@@ -1,9 +1,14 @@
 package deploy
 
 import (
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/compression"
+	"youfun/shipyard/internal/config"
 	"youfun/shipyard/internal/database"
 	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/signing"
 	"youfun/shipyard/pkg/types"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -16,6 +21,27 @@ import (
 // ProcessArtifact handles the build artifact lifecycle: validation, reuse, or creation.
 // It populates the Deployer's metadata fields (Version, GitCommitSHA, tarballPath, md5Hash).
 func (d *Deployer) ProcessArtifact() error {
+	// Already built for this run, e.g. once for all hosts of a rollout
+	if d.tarballPath != "" {
+		log.Printf("✅ Using build artifact %s (Version: %s, MD5: %s)", d.tarballPath, d.Version, d.md5Hash)
+		return nil
+	}
+
+	// 1-2. Try to reuse an existing build
+	gitVersion, _ := d.reuseArtifact()
+	if d.tarballPath != "" {
+		return nil
+	}
+
+	// 3. Perform a new build if no artifact has been reused
+	log.Println("--- Performing new build ---")
+	return d.performNewBuild(gitVersion)
+}
+
+// reuseArtifact looks for an existing artifact to deploy: the build requested with --use-build,
+// then the build of the current Git commit. On success the Deployer's metadata fields are set.
+// It returns the Git version of the workspace and why the artifact was picked or a new build is needed.
+func (d *Deployer) reuseArtifact() (gitVersion string, reason string) {
 	// 1. Try to reuse explicitly requested build (MD5 or Version)
 	if d.useBuild != "" {
 		log.Printf("--- Attempting to reuse build artifact: %s ---", d.useBuild)
@@ -23,7 +49,7 @@ func (d *Deployer) ProcessArtifact() error {
 			// Logic matches original: if not found, log and continue.
 			// The logging inside findAndReuseArtifact handles the user feedback.
 		} else if d.tarballPath != "" {
-			return nil
+			return d.GitCommitSHA, fmt.Sprintf("requested with --use-build %s", d.useBuild)
 		}
 	}
 
@@ -36,29 +62,49 @@ func (d *Deployer) ProcessArtifact() error {
 
 	d.GitCommitSHA = gitVersion
 
-	if d.tarballPath == "" && gitVersion != "unknown" && !strings.HasSuffix(gitVersion, "-dirty") {
+	switch {
+	case gitVersion == "unknown":
+		reason = "no Git version of the workspace"
+	case strings.HasSuffix(gitVersion, "-dirty"):
+		log.Printf("Git version: %s (uncommitted changes in workspace)", gitVersion)
+		reason = "uncommitted changes in the workspace"
+	default:
 		log.Printf("Git version: %s (clean workspace)", gitVersion)
-		if err := d.findAndReuseArtifact(gitVersion, false); err != nil {
-			// Just log
-		} else if d.tarballPath != "" {
-			return nil
+		if err := d.findAndReuseArtifact(gitVersion, false); err == nil && d.tarballPath != "" {
+			return gitVersion, fmt.Sprintf("already built from commit %s", gitVersion)
 		}
-	} else if strings.HasSuffix(gitVersion, "-dirty") {
-		log.Printf("Git version: %s (uncommitted changes in workspace)", gitVersion)
+		reason = fmt.Sprintf("no build of commit %s", gitVersion)
 	}
-
-	// 3. Perform a new build if no artifact has been reused
-	if d.tarballPath == "" {
-		log.Println("--- Performing new build ---")
-		return d.performNewBuild(gitVersion)
+	if d.useBuild != "" {
+		reason = fmt.Sprintf("no build matches --use-build %s, %s", d.useBuild, reason)
 	}
+	return gitVersion, reason
+}
 
-	return nil
+// projectVersion reads the version of the project to build: the version of shipyard.toml when set,
+// otherwise the one of the project files of the runtime.
+func (d *Deployer) projectVersion() (string, error) {
+	if config.AppConfig.Version != "" {
+		return config.AppConfig.Version, nil
+	}
+	switch d.Runtime {
+	case "static":
+		return d.getVersionForStatic()
+	case "node":
+		return d.getVersionForNode()
+	case "golang", config.RuntimeCustom:
+		return d.getVersionForGo()
+	case "python":
+		return d.getVersionForPython()
+	}
+	return d.getVersionFromMix()
 }
 
 // findAndReuseArtifact attempts to find and validate an existing artifact by identifier (MD5, Version or GitSHA).
 func (d *Deployer) findAndReuseArtifact(query string, isExplicit bool) error {
-	var version, tarballPath, md5Hash, gitSha string
+	var version, tarballPath, md5Hash, gitSha, format string
+	var stored bool
+	var sig artifactSignature
 
 	if d.APIClient != nil {
 		// API Mode
@@ -68,6 +114,9 @@ func (d *Deployer) findAndReuseArtifact(query string, isExplicit bool) error {
 			tarballPath = artifact.LocalPath
 			md5Hash = artifact.MD5Hash
 			gitSha = artifact.GitCommitSHA
+			stored = artifact.Stored
+			format = artifact.Compression
+			sig = signatureOfDTO(artifact)
 		} else if artErr != nil {
 			// Log checking error if needed, but we essentially proceed to not found
 			// log.Printf("Debug: API artifact check error: %v", artErr)
@@ -84,6 +133,8 @@ func (d *Deployer) findAndReuseArtifact(query string, isExplicit bool) error {
 				tarballPath = buildArtifact.LocalPath
 				md5Hash = buildArtifact.MD5Hash
 				gitSha = buildArtifact.GitCommitSHA
+				format = buildArtifact.Compression
+				sig = signatureOfModel(buildArtifact)
 			}
 		} else {
 			// Implicit by Git SHA
@@ -93,22 +144,45 @@ func (d *Deployer) findAndReuseArtifact(query string, isExplicit bool) error {
 				tarballPath = buildArtifact.LocalPath
 				md5Hash = buildArtifact.MD5Hash
 				gitSha = buildArtifact.GitCommitSHA
+				format = buildArtifact.Compression
+				sig = signatureOfModel(buildArtifact)
 			}
 		}
 	}
 
-	if tarballPath != "" {
+	if md5Hash != "" {
+		// Builds made on another machine or on the server are pulled from the artifact registry
+		if actualMD5, err := calculateMD5(tarballPath); (err != nil || actualMD5 != md5Hash) && stored && d.APIClient != nil {
+			if cachedPath, fetchErr := d.fetchServerArtifact(md5Hash, format); fetchErr == nil {
+				tarballPath = cachedPath
+			} else {
+				log.Printf("⚠️ Failed to pull build artifact %s from the registry: %v", md5Hash, fetchErr)
+			}
+		}
+
 		// Validate Local File
 		actualMD5, md5Err := calculateMD5(tarballPath)
 		if md5Err == nil && actualMD5 == md5Hash {
+			if err := d.verifyArtifact(tarballPath, sig); err != nil {
+				log.Printf("⚠️ Build artifact %s cannot be deployed: %v", md5Hash, err)
+				if errors.Is(err, signing.ErrUnsigned) && d.APIClient != nil {
+					log.Printf("    Builds made before artifacts were signed get a signature with 'shipyard-cli build push %s'.", md5Hash)
+				}
+				return fmt.Errorf("artifact signature verification failed: %w", err)
+			}
 			log.Printf("✅ Found and reusing build artifact (Version: %s, MD5: %s, Git: %s)", version, md5Hash, gitSha)
 			d.Version = version
 			d.tarballPath = tarballPath
 			d.md5Hash = md5Hash
+			d.compression = compression.Normalize(format)
 			d.GitCommitSHA = gitSha
 			return nil
 		}
-		log.Printf("⚠️ Cached build artifact '%s' is corrupted or MD5 mismatch.", tarballPath)
+		if d.APIClient != nil && !stored {
+			log.Printf("⚠️ Build artifact %s was built on another machine and is not in the registry.", md5Hash)
+		} else {
+			log.Printf("⚠️ Cached build artifact '%s' is corrupted or MD5 mismatch.", tarballPath)
+		}
 	} else {
 		if isExplicit {
 			log.Printf("⚠️ No matching build artifact found for '%s'", query)
@@ -123,28 +197,35 @@ func (d *Deployer) performNewBuild(gitVersion string) error {
 	var err error
 
 	// Determine version
-	if d.Runtime == "static" {
-		version, err = d.getVersionForStatic()
-	} else {
-		version, err = d.getVersionFromMix()
-	}
+	version, err = d.projectVersion()
 	if err != nil {
 		return err
 	}
 	log.Printf("Got project version: %s", version)
 
+	build := config.AppConfig.Build
+	if err := build.Validate(); err != nil {
+		return &ConfigError{Err: err}
+	}
+	if build.Location != config.BuildLocal {
+		return d.performRemoteBuild(build, version, gitVersion)
+	}
+
 	// Build
-	var buildDir string
-	if d.Runtime == "static" {
-		buildDir = d.buildStaticRelease()
-	} else {
-		buildDir = d.buildRelease()
+	builder, err := newBuilder(build)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+	buildDir, err := builder.Build(d)
+	if err != nil {
+		return err
 	}
 	// Note: buildDir is a temp dir that contains the release structure
 	defer os.RemoveAll(buildDir)
 
 	// Create Tarball
-	tempTarballPath, err := d.createTarball(filepath.Join(buildDir, "release"), d.AppName)
+	format := compression.Normalize(build.Compression)
+	tempTarballPath, err := d.createTarball(filepath.Join(buildDir, "release"), d.AppName, format)
 	if err != nil {
 		return err
 	}
@@ -161,13 +242,13 @@ func (d *Deployer) performNewBuild(gitVersion string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get cache directory: %w", err)
 	}
-	cachedTarballPath := path.Join(buildCacheDir, fmt.Sprintf("%s-%s.tar.gz", d.AppName, md5Hash))
+	cachedTarballPath := artifactCachePath(buildCacheDir, d.AppName, md5Hash, format)
 
 	if err := moveFile(tempTarballPath, cachedTarballPath); err != nil {
 		return fmt.Errorf("failed to write cache: %w", err)
 	}
 
-	// Register Metadata
+	// Register Metadata, the artifact is signed on its way into the registry
 	if d.APIClient != nil {
 		artifactDTO := &types.BuildArtifactDTO{
 			ApplicationID: d.Application.ID.String(),
@@ -175,10 +256,17 @@ func (d *Deployer) performNewBuild(gitVersion string) error {
 			GitCommitSHA:  gitVersion,
 			MD5Hash:       md5Hash,
 			LocalPath:     cachedTarballPath,
+			Compression:   format,
 		}
-		if err := d.APIClient.RegisterArtifact(artifactDTO); err != nil {
-			log.Printf("⚠️ Warning: Failed to register build artifact to API: %v", err)
+		log.Println("⬆️  Pushing build artifact to the registry...")
+		pushed, err := d.APIClient.PushArtifact(artifactDTO, cachedTarballPath, config.AppConfig.Build.KeepArtifacts)
+		if err != nil {
+			return fmt.Errorf("failed to push build artifact to the registry: %w", err)
 		}
+		if err := d.verifyArtifact(cachedTarballPath, signatureOfDTO(pushed)); err != nil {
+			return fmt.Errorf("artifact signature verification failed: %w", err)
+		}
+		log.Printf("✅ Build artifact pushed to the registry (Version: %s, Git: %s, MD5: %s)", version, gitVersion, md5Hash)
 	} else {
 		// DB Mode
 		now := time.Now()
@@ -188,20 +276,147 @@ func (d *Deployer) performNewBuild(gitVersion string) error {
 			GitCommitSHA:  gitVersion,
 			MD5Hash:       md5Hash,
 			LocalPath:     cachedTarballPath,
+			Compression:   format,
 			CreatedAt:     models.NullableTime{Time: &now},
 		}
+		if err := signArtifact(artifact, cachedTarballPath); err != nil {
+			return err
+		}
 		if err := database.AddBuildArtifact(artifact); err != nil {
 			log.Printf("⚠️ Warning: Failed to save build artifact metadata: %v", err)
 		} else {
 			log.Printf("✅ Build artifact cached (Version: %s, Git: %s, MD5: %s)", version, gitVersion, md5Hash)
 		}
+		if err := d.verifyArtifact(cachedTarballPath, signatureOfModel(artifact)); err != nil {
+			return fmt.Errorf("artifact signature verification failed: %w", err)
+		}
 	}
 
 	// Update Deployer State
 	d.Version = version
 	d.tarballPath = cachedTarballPath
 	d.md5Hash = md5Hash
+	d.compression = format
 	// d.GitCommitSHA is typically updated to match the build env, which is passed in.
 
 	return nil
 }
+
+// performRemoteBuild sends the sources of the project to shipyard-server, which builds them itself or on
+// the builder host and pushes the artifact to its registry. The build output streams back; the release
+// tarball is then pulled into the local cache for the upload.
+func (d *Deployer) performRemoteBuild(build config.Build, version, gitVersion string) error {
+	if d.APIClient == nil {
+		return &ConfigError{Err: fmt.Errorf("[build] location = %q needs shipyard-server, deploy through the API", build.Location)}
+	}
+
+	log.Println("📦 Packing sources (honouring .gitignore and .dockerignore)...")
+	sourcePath, err := packSource(".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sourcePath)
+
+	if build.Location == config.BuildHost {
+		log.Printf("🔨 Building on builder host %s through the server...", build.Host)
+	} else {
+		log.Println("🔨 Building on the server...")
+	}
+	artifact, err := d.APIClient.BuildOnServer(&types.ServerBuildRequest{
+		AppName:      d.AppName,
+		Runtime:      d.Runtime,
+		Version:      version,
+		GitCommitSHA: gitVersion,
+		Location:     build.Location,
+		Host:         build.Host,
+		Builder:      build.Builder,
+		GOOS:         build.GOOS,
+		GOARCH:       build.GOARCH,
+		Main:         build.Main,
+		Dir:          build.Dir,
+		Compression:  build.Compression,
+		Command:      build.Command,
+		OutputDir:    build.OutputDir,
+
+		KeepArtifacts: build.KeepArtifacts,
+	}, sourcePath, Output)
+	if err != nil {
+		if cancelErr := d.checkCancelled(); cancelErr != nil {
+			log.Println("🛑 Build cancelled, nothing was deployed.")
+			return cancelErr
+		}
+		return fmt.Errorf("remote build failed: %w", err)
+	}
+
+	cachedTarballPath, err := d.fetchServerArtifact(artifact.MD5Hash, artifact.Compression)
+	if err != nil {
+		return err
+	}
+	if err := d.verifyArtifact(cachedTarballPath, signatureOfDTO(artifact)); err != nil {
+		return fmt.Errorf("artifact signature verification failed: %w", err)
+	}
+	log.Printf("✅ Build artifact pushed to the registry (Version: %s, Git: %s, MD5: %s)", version, gitVersion, artifact.MD5Hash)
+
+	d.Version = version
+	d.tarballPath = cachedTarballPath
+	d.md5Hash = artifact.MD5Hash
+	d.compression = compression.Normalize(artifact.Compression)
+	return nil
+}
+
+// fetchServerArtifact pulls an artifact from the registry of shipyard-server into the local build cache.
+func (d *Deployer) fetchServerArtifact(md5Hash, format string) (string, error) {
+	return pullArtifact(d.APIClient, d.Application.ID.String(), d.AppName, md5Hash, format)
+}
+
+// artifactCachePath returns the path of an artifact in the local build cache, named after its format.
+func artifactCachePath(buildCacheDir, appName, md5Hash, format string) string {
+	return path.Join(buildCacheDir, fmt.Sprintf("%s-%s%s", appName, md5Hash, compression.Extension(format)))
+}
+
+// pullArtifact pulls an artifact of an application from the registry into the local build cache, unless
+// it is already there, and returns its path.
+func pullArtifact(apiClient client.APIClient, appID, appName, md5Hash, format string) (string, error) {
+	buildCacheDir, err := database.GetBuildCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	cachedTarballPath := artifactCachePath(buildCacheDir, appName, md5Hash, format)
+	if actualMD5, err := calculateMD5(cachedTarballPath); err == nil && actualMD5 == md5Hash {
+		return cachedTarballPath, nil
+	}
+
+	log.Printf("⬇️  Pulling build artifact %s from the registry...", md5Hash)
+	if err := apiClient.DownloadArtifact(appID, md5Hash, cachedTarballPath); err != nil {
+		return "", fmt.Errorf("failed to download build artifact: %w", err)
+	}
+	if actualMD5, err := calculateMD5(cachedTarballPath); err != nil || actualMD5 != md5Hash {
+		os.Remove(cachedTarballPath)
+		return "", fmt.Errorf("downloaded build artifact %s does not match its MD5", md5Hash)
+	}
+	return cachedTarballPath, nil
+}
+
+// PullBuild pulls a build from the artifact registry into the local build cache and returns its path.
+func PullBuild(apiClient client.APIClient, appName string, artifact *types.BuildArtifactDTO) (string, error) {
+	if !artifact.Stored {
+		return "", fmt.Errorf("build %s is not in the registry, push it from the machine that built it", artifact.MD5Hash)
+	}
+	return pullArtifact(apiClient, artifact.ApplicationID, appName, artifact.MD5Hash, artifact.Compression)
+}
+
+// PushBuild pushes a build made on this machine to the artifact registry, which signs it, e.g. one registered
+// before the registry existed. The tarball is looked up at its registered path, then in the local build cache.
+func PushBuild(apiClient client.APIClient, appName string, artifact *types.BuildArtifactDTO, keep int) (*types.BuildArtifactDTO, error) {
+	buildCacheDir, err := database.GetBuildCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	candidates := []string{artifact.LocalPath, artifactCachePath(buildCacheDir, appName, artifact.MD5Hash, artifact.Compression)}
+	for _, tarballPath := range candidates {
+		if actualMD5, err := calculateMD5(tarballPath); err == nil && actualMD5 == artifact.MD5Hash {
+			return apiClient.PushArtifact(artifact, tarballPath, keep)
+		}
+	}
+	return nil, fmt.Errorf("build %s is not on this machine", artifact.MD5Hash)
+}
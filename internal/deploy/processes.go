@@ -0,0 +1,166 @@
+package deploy
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"youfun/shipyard/internal/config"
+)
+
+// ProcessInstance is a systemd unit instance of a process type besides web.
+type ProcessInstance struct {
+	Process string
+	Index   int
+	State   string // ACTIVE/SUB state of the unit, e.g. "active/running"
+}
+
+// processesDir returns the directory with the release link of each process type besides web.
+func processesDir(appName string) string {
+	return fmt.Sprintf("/var/www/%s/processes", appName)
+}
+
+// stopProcessUnitsCommand returns the shell command that disables and stops the instances of a process
+// type above count; a count of 0 stops all of them.
+func stopProcessUnitsCommand(appName, process string, count int) string {
+	unit := config.ProcessUnit(appName, process)
+	return fmt.Sprintf(`for u in $(systemctl list-units --all --plain --no-legend %s | awk '{print $1}'); do i=${u#%s}; i=${i%%.service}; if [ "$i" -gt %d ] 2>/dev/null; then systemctl disable --now "$u" || true; fi; done`,
+		shellQuote(unit+"*.service"), unit, count)
+}
+
+// processCommand returns the shell command that links the release for a process type and (re)starts
+// instances 1..count of its unit, stopping the instances above count.
+func processCommand(appName, process string, count int, releasePath string) string {
+	link := processesDir(appName) + "/" + process
+	unit := config.ProcessUnit(appName, process)
+	return fmt.Sprintf(`mkdir -p %s && ln -sfn %s %s && (chown -h phoenix:phoenix %s || true) && %s && for i in $(seq 1 %d); do systemctl enable %s"$i" && systemctl restart %s"$i" || exit 1; done`,
+		processesDir(appName), shellQuote(releasePath), link, link, stopProcessUnitsCommand(appName, process, count), count, unit, unit)
+}
+
+// removeProcessCommand returns the shell command that stops all instances of a process type and removes
+// its unit and release link.
+func removeProcessCommand(appName, process string) string {
+	return fmt.Sprintf("%s; rm -f %s/%s /etc/systemd/system/%s.service && systemctl daemon-reload",
+		stopProcessUnitsCommand(appName, process, 0), processesDir(appName), process, config.ProcessUnit(appName, process))
+}
+
+// deployProcesses runs the process types of [processes] besides web on the release: their units are
+// rendered with the start command of shipyard.toml and restarted, and process types removed from
+// shipyard.toml are stopped. Unlike web, they take no part in the port switch. The Erlang nodes of the
+// processes are named on nodeHost, as the web instances.
+func (d *Deployer) deployProcesses(releasePath, nodeHost string) error {
+	processes := config.AppConfig.OtherProcesses()
+	deployed, err := d.deployedProcesses()
+	if err != nil {
+		return err
+	}
+	if len(processes) == 0 && len(deployed) == 0 {
+		return nil
+	}
+
+	for _, name := range processes {
+		process := config.AppConfig.Processes[name]
+		log.Printf("⚙️ Rendering systemd unit %s.service with start command: %s", config.ProcessUnit(d.AppName, name), process.Command)
+		if err := d.executeRemoteCommand(renderUnitCommand(d.AppName, d.Runtime, name, process.Command, "phoenix", nodeHost), true); err != nil {
+			return fmt.Errorf("failed to render systemd unit of process %s: %w", name, err)
+		}
+		log.Printf("🔁 Running %d %s process(es) on %s", process.Count, name, releasePath)
+		if err := d.executeRemoteCommand(processCommand(d.AppName, name, process.Count, releasePath), true); err != nil {
+			return fmt.Errorf("failed to start process %s: %w", name, err)
+		}
+	}
+
+	for _, name := range deployed {
+		if _, ok := config.AppConfig.Processes[name]; ok {
+			continue
+		}
+		log.Printf("🧹 Removing process %s, it is no longer in shipyard.toml", name)
+		if err := d.executeRemoteCommand(removeProcessCommand(d.AppName, name), true); err != nil {
+			log.Printf("⚠️ Failed to remove process %s: %v", name, err)
+		}
+	}
+	log.Println("✅ Processes are running the new release.")
+	return nil
+}
+
+// restartProcesses moves the deployed process types besides web to another release and restarts their
+// running instances. Rollbacks and promoted canaries use it, as they do not read shipyard.toml.
+func (d *Deployer) restartProcesses(releasePath string) error {
+	deployed, err := d.deployedProcesses()
+	if err != nil || len(deployed) == 0 {
+		return err
+	}
+	for _, name := range deployed {
+		link := processesDir(d.AppName) + "/" + name
+		unit := config.ProcessUnit(d.AppName, name)
+		log.Printf("🔁 Restarting process %s on %s", name, releasePath)
+		cmd := fmt.Sprintf(`ln -sfn %s %s && (chown -h phoenix:phoenix %s || true) && for u in $(systemctl list-units --plain --no-legend --state=active %s | awk '{print $1}'); do systemctl restart "$u" || exit 1; done`,
+			shellQuote(releasePath), link, link, shellQuote(unit+"*.service"))
+		if err := d.executeRemoteCommand(cmd, true); err != nil {
+			return fmt.Errorf("failed to restart process %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// deployedProcesses lists the process types besides web with a release link on the host.
+func (d *Deployer) deployedProcesses() ([]string, error) {
+	output, err := d.executeRemoteCommandWithOutput(fmt.Sprintf("ls -1 %s 2>/dev/null || true", processesDir(d.AppName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	var names []string
+	for _, name := range strings.Fields(output) {
+		if name != config.ProcessWeb {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// ListProcessInstancesCommand returns the shell command listing the unit instances of the given process
+// types besides web, parsed by ParseProcessInstances.
+func ListProcessInstancesCommand(appName string, processes []string) string {
+	patterns := make([]string, 0, len(processes))
+	for _, name := range processes {
+		patterns = append(patterns, shellQuote(config.ProcessUnit(appName, name)+"*.service"))
+	}
+	return "systemctl list-units --all --plain --no-legend " + strings.Join(patterns, " ")
+}
+
+// ParseProcessInstances parses the output of ListProcessInstancesCommand, sorted by process type and index.
+func ParseProcessInstances(appName, output string) []ProcessInstance {
+	var instances []ProcessInstance
+	for _, line := range strings.Split(output, "\n") {
+		// UNIT LOAD ACTIVE SUB DESCRIPTION
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		rest, ok := strings.CutPrefix(fields[0], appName+"-")
+		if !ok {
+			continue
+		}
+		rest, ok = strings.CutSuffix(rest, ".service")
+		if !ok {
+			continue
+		}
+		process, index, ok := strings.Cut(rest, "@")
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			continue
+		}
+		instances = append(instances, ProcessInstance{Process: process, Index: i, State: fields[2] + "/" + fields[3]})
+	}
+	sort.Slice(instances, func(a, b int) bool {
+		if instances[a].Process != instances[b].Process {
+			return instances[a].Process < instances[b].Process
+		}
+		return instances[a].Index < instances[b].Index
+	})
+	return instances
+}
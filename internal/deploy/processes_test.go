@@ -0,0 +1,48 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProcessCommand(t *testing.T) {
+	cmd := processCommand("shop", "worker", 2, "/var/www/shop/releases/1.2.0-1700000000")
+	for _, want := range []string{
+		"ln -sfn '/var/www/shop/releases/1.2.0-1700000000' /var/www/shop/processes/worker",
+		`systemctl list-units --all --plain --no-legend 'shop-worker@*.service'`,
+		`if [ "$i" -gt 2 ]`,
+		`for i in $(seq 1 2); do systemctl enable shop-worker@"$i" && systemctl restart shop-worker@"$i" || exit 1; done`,
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("processCommand() = %s\nmissing %s", cmd, want)
+		}
+	}
+
+	cmd = removeProcessCommand("shop", "scheduler")
+	if !strings.Contains(cmd, `if [ "$i" -gt 0 ]`) || !strings.Contains(cmd, "rm -f /var/www/shop/processes/scheduler /etc/systemd/system/shop-scheduler@.service") {
+		t.Errorf("removeProcessCommand() = %s", cmd)
+	}
+}
+
+func TestParseProcessInstances(t *testing.T) {
+	output := "shop-worker@2.service    loaded active   running Shop worker 2\n" +
+		"shop-worker@1.service    loaded active   running Shop worker 1\n" +
+		"shop-scheduler@1.service loaded failed   failed  Shop scheduler 1\n" +
+		"shop@4001.service        loaded active   running Shop instance 4001\n" +
+		"shop-worker@x.service    loaded inactive dead    Shop worker x\n"
+
+	instances := ParseProcessInstances("shop", output)
+	want := []ProcessInstance{
+		{Process: "scheduler", Index: 1, State: "failed/failed"},
+		{Process: "worker", Index: 1, State: "active/running"},
+		{Process: "worker", Index: 2, State: "active/running"},
+	}
+	if len(instances) != len(want) {
+		t.Fatalf("ParseProcessInstances() = %+v", instances)
+	}
+	for i := range want {
+		if instances[i] != want[i] {
+			t.Errorf("instance %d = %+v, want %+v", i, instances[i], want[i])
+		}
+	}
+}
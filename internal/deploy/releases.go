@@ -0,0 +1,268 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/database"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/pkg/types"
+
+	"github.com/google/uuid"
+)
+
+// releaseRunsLimit bounds the runs read to work out the releases of an instance.
+const releaseRunsLimit = 1000
+
+// ErrReleaseNotFound is returned when a release is not known for an instance.
+var ErrReleaseNotFound = errors.New("release not found")
+
+// Release is a release directory of an application instance and the state of the runs started from it.
+type Release struct {
+	Path         string
+	Version      string
+	GitCommitSHA string
+	Status       string
+	Pinned       bool
+	Port         int // Port of the newest run started from the release
+	StartedAt    models.NullableTime
+}
+
+// Name returns the directory name of the release, which identifies it on the host.
+func (r Release) Name() string {
+	return path.Base(r.Path)
+}
+
+// protected reports whether release retention must keep the release regardless of its age.
+func (r Release) protected() bool {
+	switch r.Status {
+	case models.ReleaseStatusActive, models.ReleaseStatusStandby, models.ReleaseStatusCanary:
+		return true
+	}
+	return r.Pinned
+}
+
+// groupReleases groups the runs of an instance (newest first) by release directory, newest release first.
+// The canary release has no run until it is promoted, so it is listed on its own.
+func groupReleases(runs []models.DeploymentInstance, activePort, previousPort int, canary *models.InstanceCanary, pinned map[string]bool) []Release {
+	activeRelease := activeReleasePath(runs, activePort)
+	standbyRelease := activeReleasePath(runs, previousPort)
+
+	var releases []Release
+	index := make(map[string]int)
+	if canary != nil {
+		releases = append(releases, Release{
+			Path:         canary.ReleasePath,
+			Version:      path.Base(canary.ReleasePath),
+			GitCommitSHA: canary.GitCommitSHA,
+			Status:       models.ReleaseStatusCanary,
+			Pinned:       pinned[canary.ReleasePath],
+			Port:         canary.CanaryPort,
+			StartedAt:    canary.StartedAt,
+		})
+		index[canary.ReleasePath] = 0
+	}
+
+	for _, run := range runs {
+		if run.ReleasePath == "" {
+			continue
+		}
+		if i, ok := index[run.ReleasePath]; ok {
+			// Older run of a release already listed: it only matters if the release ever ran fine
+			if releases[i].Status == models.ReleaseStatusFailed && run.Status != "failed" {
+				releases[i].Status = models.ReleaseStatusRetained
+			}
+			continue
+		}
+
+		release := Release{
+			Path:         run.ReleasePath,
+			Version:      run.Version,
+			GitCommitSHA: run.GitCommitSHA,
+			Pinned:       pinned[run.ReleasePath],
+			Port:         run.Port,
+			StartedAt:    run.StartedAt,
+		}
+		switch {
+		case run.ReleasePath == activeRelease:
+			release.Status = models.ReleaseStatusActive
+		case run.ReleasePath == standbyRelease:
+			release.Status = models.ReleaseStatusStandby
+		case run.Status == models.ReleaseStatusPruned:
+			release.Status = models.ReleaseStatusPruned
+		case run.Status == "failed":
+			release.Status = models.ReleaseStatusFailed
+		default:
+			release.Status = models.ReleaseStatusRetained
+		}
+		index[run.ReleasePath] = len(releases)
+		releases = append(releases, release)
+	}
+	return releases
+}
+
+// selectPrunableReleases returns the releases deleted by release retention: failed releases and the
+// retained releases beyond the newest keep. Active, standby, canary and pinned releases, and releases
+// still linked from the instances directory on the host, are never selected.
+func selectPrunableReleases(releases []Release, keep int, linked map[string]bool) []Release {
+	var prunable []Release
+	kept := 0
+	for _, release := range releases {
+		switch {
+		case release.Status == models.ReleaseStatusPruned:
+		case release.protected() || linked[path.Clean(release.Path)]:
+		case release.Status == models.ReleaseStatusFailed:
+			prunable = append(prunable, release)
+		case kept < keep:
+			kept++
+		default:
+			prunable = append(prunable, release)
+		}
+	}
+	return prunable
+}
+
+// ListReleases returns the releases of an application instance, newest first.
+func ListReleases(instanceID uuid.UUID) ([]Release, error) {
+	instance, err := database.GetApplicationInstanceByID(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get application instance: %w", err)
+	}
+	runs, err := database.GetDeploymentHistoryForInstance(instanceID, releaseRunsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment instances: %w", err)
+	}
+	canary, err := database.GetInstanceCanary(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get canary: %w", err)
+	}
+	pinned, err := database.GetPinnedReleases(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pinned releases: %w", err)
+	}
+
+	activePort, previousPort := 0, 0
+	if instance.ActivePort.Valid {
+		activePort = int(instance.ActivePort.Int64)
+	}
+	if instance.PreviousActivePort.Valid {
+		previousPort = int(instance.PreviousActivePort.Int64)
+	}
+	return groupReleases(runs, activePort, previousPort, canary, pinned), nil
+}
+
+// PinRelease protects a release of an application instance from release retention, or lifts the protection.
+// The release is identified by its directory name.
+func PinRelease(instanceID uuid.UUID, name string, pin bool) (*Release, error) {
+	releases, err := ListReleases(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range releases {
+		release := &releases[i]
+		if release.Name() != name {
+			continue
+		}
+		if pin && release.Status == models.ReleaseStatusPruned {
+			return nil, fmt.Errorf("%w: release %s has been pruned", ErrReleaseNotFound, name)
+		}
+		if pin {
+			err = database.PinRelease(instanceID, release.Path)
+		} else {
+			err = database.UnpinRelease(instanceID, release.Path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to update release pin: %w", err)
+		}
+		release.Pinned = pin
+		return release, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrReleaseNotFound, name)
+}
+
+// PruneReleases deletes the old releases of an application instance from its host and marks their runs pruned.
+// Besides the active, standby, canary and pinned releases, the newest keep releases are kept.
+// With dryRun set, the releases that would be deleted are returned and nothing is changed.
+func PruneReleases(instanceID uuid.UUID, keep int, dryRun bool) ([]Release, error) {
+	if keep < 0 {
+		return nil, fmt.Errorf("keep must not be negative")
+	}
+	releases, err := ListReleases(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := newInstanceDeployer(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer d.close()
+
+	// A release linked from the instances directory may still be started by systemd
+	linked, err := d.linkedReleases()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instance links: %w", err)
+	}
+
+	prunable := selectPrunableReleases(releases, keep, linked)
+	if dryRun || len(prunable) == 0 {
+		return prunable, nil
+	}
+
+	releasesDir := fmt.Sprintf("/var/www/%s/releases/", d.AppName)
+	var pruned []Release
+	for _, release := range prunable {
+		if !strings.HasPrefix(path.Clean(release.Path), releasesDir) {
+			log.Printf("⚠️  Skipping release %s outside of %s", release.Path, releasesDir)
+			continue
+		}
+		if err := d.executeRemoteCommand(fmt.Sprintf("rm -rf %s", path.Clean(release.Path)), false); err != nil {
+			log.Printf("⚠️  Failed to delete release %s: %v", release.Path, err)
+			continue
+		}
+		if err := database.MarkReleasePruned(instanceID, release.Path); err != nil {
+			log.Printf("⚠️  Failed to mark release %s as pruned: %v", release.Path, err)
+		}
+		release.Status = models.ReleaseStatusPruned
+		pruned = append(pruned, release)
+	}
+	log.Printf("🧹 Pruned %d releases of %s on %s", len(pruned), d.AppName, d.HostName)
+	return pruned, nil
+}
+
+// linkedReleases returns the release directories linked from the instances directory of the application.
+func (d *Deployer) linkedReleases() (map[string]bool, error) {
+	output, err := d.executeRemoteCommandWithOutput(fmt.Sprintf(
+		`for link in /var/www/%s/instances/*; do if [ -L "$link" ]; then readlink "$link"; fi; done`, d.AppName))
+	if err != nil {
+		return nil, err
+	}
+	linked := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			linked[path.Clean(line)] = true
+		}
+	}
+	return linked, nil
+}
+
+// pruneReleases applies release retention after a successful deployment through the API.
+// A failure is only reported, the deployment itself has succeeded.
+func (d *Deployer) pruneReleases(apiClient client.APIClient) {
+	if d.instanceUID == "" {
+		return
+	}
+	keep := config.AppConfig.KeepReleases
+	result, err := apiClient.PruneReleases(d.instanceUID, &types.PruneReleasesRequest{Keep: &keep})
+	if err != nil {
+		log.Printf("⚠️  Warning: Failed to prune old releases: %v", err)
+		return
+	}
+	for _, release := range result.Pruned {
+		log.Printf("🧹 Pruned release %s", release.Name)
+	}
+}
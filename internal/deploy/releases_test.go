@@ -0,0 +1,77 @@
+package deploy
+
+import (
+	"youfun/shipyard/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestGroupReleases(t *testing.T) {
+	// Newest first, as returned by GetDeploymentHistoryForInstance
+	runs := []models.DeploymentInstance{
+		{Version: "v5", ReleasePath: "/releases/v5", Port: 4005, Status: "running"},
+		{Version: "v4", ReleasePath: "/releases/v4", Port: 4004, Status: "failed"},
+		{Version: "v2", ReleasePath: "/releases/v2", Port: 4006, Status: "standby"}, // Rolled back to v2 on a fresh port
+		{Version: "v3", ReleasePath: "/releases/v3", Port: 4003, Status: "stopped"},
+		{Version: "v2", ReleasePath: "/releases/v2", Port: 4002, Status: "stopped"},
+		{Version: "v1", ReleasePath: "/releases/v1", Port: 4001, Status: "pruned"},
+	}
+	canary := &models.InstanceCanary{ReleasePath: "/releases/v6-1", CanaryPort: 4007}
+	pinned := map[string]bool{"/releases/v3": true}
+
+	releases := groupReleases(runs, 4005, 4006, canary, pinned)
+
+	var got []string
+	for _, r := range releases {
+		state := r.Name() + ":" + r.Status
+		if r.Pinned {
+			state += ":pinned"
+		}
+		got = append(got, state)
+	}
+	want := []string{"v6-1:canary", "v5:active", "v4:failed", "v2:standby", "v3:retained:pinned", "v1:pruned"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupReleases() = %v, want %v", got, want)
+	}
+	if releases[3].Port != 4006 {
+		t.Errorf("expected release v2 to report its newest port 4006, got %d", releases[3].Port)
+	}
+}
+
+func TestSelectPrunableReleases(t *testing.T) {
+	releases := []Release{
+		{Path: "/releases/v9", Status: models.ReleaseStatusActive},
+		{Path: "/releases/v8", Status: models.ReleaseStatusStandby},
+		{Path: "/releases/v7", Status: models.ReleaseStatusFailed},
+		{Path: "/releases/v6", Status: models.ReleaseStatusRetained},
+		{Path: "/releases/v5", Status: models.ReleaseStatusRetained, Pinned: true},
+		{Path: "/releases/v4", Status: models.ReleaseStatusRetained},
+		{Path: "/releases/v3", Status: models.ReleaseStatusRetained},
+		{Path: "/releases/v2", Status: models.ReleaseStatusRetained},
+		{Path: "/releases/v1", Status: models.ReleaseStatusPruned},
+	}
+
+	tests := []struct {
+		name   string
+		keep   int
+		linked map[string]bool
+		want   []string
+	}{
+		{name: "keeps newest", keep: 2, want: []string{"v7", "v3", "v2"}},
+		{name: "keeps none", keep: 0, want: []string{"v7", "v6", "v4", "v3", "v2"}},
+		{name: "keeps all", keep: 10, want: []string{"v7"}},
+		{name: "skips linked releases", keep: 1, linked: map[string]bool{"/releases/v3": true, "/releases/v7": true}, want: []string{"v4", "v2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range selectPrunableReleases(releases, tt.keep, tt.linked) {
+				got = append(got, r.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectPrunableReleases() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
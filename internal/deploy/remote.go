@@ -2,6 +2,7 @@ package deploy
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -10,6 +11,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"youfun/shipyard/internal/compression"
+	"youfun/shipyard/internal/config"
 	"youfun/shipyard/internal/depsinstall"
 	"youfun/shipyard/internal/models"
 	"youfun/shipyard/internal/sshutil"
@@ -24,13 +28,6 @@ import (
 
 // InitializeHost prepares a remote host for deployments.
 func InitializeHost(host *models.SSHHost, appName, runtime, startCmd, user string, hostKeyCallback ssh.HostKeyCallback) error {
-	// Use embedded script
-	scriptBytes := []byte(static.InitRuntimeScript)
-
-	// Convert CRLF to LF for Unix-like systems
-	scriptBytes = bytes.ReplaceAll(scriptBytes, []byte("\r\n"), []byte("\n"))
-	b64 := base64.StdEncoding.EncodeToString(scriptBytes)
-
 	// Connect to remote and execute
 	sshConfig, err := sshutil.NewClientConfig(host, hostKeyCallback)
 	if err != nil {
@@ -54,10 +51,13 @@ func InitializeHost(host *models.SSHHost, appName, runtime, startCmd, user strin
 	}
 	defer sess.Close()
 
-	remoteCmd := fmt.Sprintf("bash -c \"mkdir -p /tmp; echo '%s' | base64 -d > /tmp/init_runtime.sh; chmod +x /tmp/init_runtime.sh; APP=%s USER=%s RUNTIME=%s START_CMD=\\\"%s\\\" bash /tmp/init_runtime.sh\"", b64, appName, user, runtime, strings.ReplaceAll(startCmd, "\"", "\\\""))
+	remoteCmd := initRuntimeCommand(appName, runtime, config.ProcessWeb, startCmd, user, nodeHost(host.Addr))
 	log.Printf("🚀 Executing remote initialization: %s@%s runtime=%s user=%s app=%s", host.User, host.Addr, runtime, user, appName)
 	out, err := sess.CombinedOutput(remoteCmd)
-	fmt.Print(string(out))
+	// Through log like the rest of the deployment, stdout carries only the events of deploy --json
+	if len(out) > 0 {
+		log.Println(strings.TrimSpace(string(out)))
+	}
 	if err != nil {
 		return fmt.Errorf("remote initialization failed: %w", err)
 	}
@@ -66,6 +66,52 @@ func InitializeHost(host *models.SSHHost, appName, runtime, startCmd, user strin
 	return nil
 }
 
+// initRuntimeCommand returns the shell command running the embedded init_runtime.sh, which prepares the
+// user, the directories and the env file of the app, followed by render_unit.sh, see renderUnitCommand.
+func initRuntimeCommand(appName, runtime, process, startCmd, user, nodeHost string) string {
+	return runtimeScriptCommand(static.InitRuntimeScript+"\n"+static.RenderUnitScript, appName, runtime, process, startCmd, user, nodeHost)
+}
+
+// renderUnitCommand returns the shell command running the embedded render_unit.sh, which (re)writes the
+// systemd template unit of a process type with the given start command and leaves the rest of the host alone.
+// Instances of phoenix and elixir releases are named as Erlang nodes on nodeHost, unless it is empty.
+func renderUnitCommand(appName, runtime, process, startCmd, user, nodeHost string) string {
+	return runtimeScriptCommand(static.RenderUnitScript, appName, runtime, process, startCmd, user, nodeHost)
+}
+
+// runtimeScriptCommand returns the shell command running script with the variables of the app.
+func runtimeScriptCommand(script, appName, runtime, process, startCmd, user, nodeHost string) string {
+	// Convert CRLF to LF for Unix-like systems
+	scriptBytes := bytes.ReplaceAll([]byte(script), []byte("\r\n"), []byte("\n"))
+	b64 := base64.StdEncoding.EncodeToString(scriptBytes)
+	return fmt.Sprintf("script=$(mktemp) && echo '%s' | base64 -d > \"$script\" && APP=%s USER=%s RUNTIME=%s PROCESS=%s START_CMD=%s NODE_HOST=%s bash \"$script\"; status=$?; rm -f \"$script\"; exit $status",
+		b64, shellQuote(appName), shellQuote(user), shellQuote(runtime), shellQuote(process), shellQuote(startCmd), shellQuote(nodeHost))
+}
+
+// renderUnit writes the systemd unit of the app again with the start command of [run] command or [processes] web,
+// so a changed start command applies to the instances of this deployment without initializing the host again.
+// It runs once per deployment, before its instances are started.
+// Units of phoenix and elixir releases are always rendered, hosts initialized before they named the Erlang
+// nodes of the instances get the node names this way.
+func (d *Deployer) renderUnit() error {
+	if err := config.AppConfig.ValidateRun(); err != nil {
+		return &ConfigError{Err: err}
+	}
+	startCmd := config.AppConfig.StartCommand()
+	if startCmd == "" && !clusterRuntime(d.Runtime) {
+		return nil
+	}
+	if startCmd != "" {
+		log.Printf("⚙️ Rendering systemd unit %s.service with start command: %s", config.ProcessUnit(d.AppName, config.ProcessWeb), startCmd)
+	} else {
+		log.Printf("⚙️ Rendering systemd unit %s.service with the node names of the instances", config.ProcessUnit(d.AppName, config.ProcessWeb))
+	}
+	if err := d.executeRemoteCommand(renderUnitCommand(d.AppName, d.Runtime, config.ProcessWeb, startCmd, "phoenix", nodeHost(d.Host.Addr)), true); err != nil {
+		return fmt.Errorf("failed to render systemd unit: %w", err)
+	}
+	return nil
+}
+
 // installCaddyIfNeeded checks if Caddy is installed on the remote host and installs it if not.
 func installCaddyIfNeeded(client *ssh.Client) error {
 	return depsinstall.CheckAndInstallCaddyRemote(client, true)
@@ -74,14 +120,16 @@ func installCaddyIfNeeded(client *ssh.Client) error {
 /**
  * @brief Upload and untar a tarball via streaming (piping) with progress bar
  *
- * Streams a local tar.gz file to the remote host and pipes it into 'tar -xzf -'
- * so upload and extraction happen concurrently. Shows a progress bar during upload.
+ * Streams a local tarball to the remote host and pipes it into 'tar -xf -' (through
+ * the decompressor of its format) so upload and extraction happen concurrently.
+ * Shows a progress bar during upload.
  *
- * @param localTarballPath  local .tar.gz file path (e.g., "./build/my_app.tar.gz")
+ * @param localTarballPath  local tarball path (e.g., "./build/my_app.tar.gz")
  * @param remoteReleasePath remote directory to extract into (e.g., "/opt/app/releases/20251108")
+ * @param format            compression format of the tarball (gzip, zstd or none)
  * @return error            non-nil on failure, nil on success
  */
-func (d *Deployer) uploadTarFile(localTarballPath string, remoteReleasePath string) error {
+func (d *Deployer) uploadTarFile(localTarballPath string, remoteReleasePath string, format string) error {
 
 	// 1. Open local tarball
 	localFile, err := os.Open(localTarballPath)
@@ -115,13 +163,13 @@ func (d *Deployer) uploadTarFile(localTarballPath string, remoteReleasePath stri
 	session.Stdin = barReader
 
 	// 6. Prepare remote command
-	remoteCmd := fmt.Sprintf("mkdir -p %s && tar -xzf - -C %s",
-		remoteReleasePath,
+	remoteCmd := fmt.Sprintf("mkdir -p %s && %s",
 		remoteReleasePath,
+		compression.ExtractCommand(format, remoteReleasePath),
 	)
 
 	// 7. Run remote command (session.Run pumps session.Stdin)
-	if err := session.Run(remoteCmd); err != nil {
+	if err := runSession(d.context(), session, func() error { return session.Run(remoteCmd) }); err != nil {
 		bar.Finish()
 		return fmt.Errorf("remote streaming untar failed: %w", err)
 	}
@@ -148,7 +196,11 @@ func (d *Deployer) executeRemoteCommand(command string, logOutput bool) error {
 
 	// log.Printf("🚀 Executing remote command")
 	// log.Printf("🚀 Executing remote command: %s", command)
-	output, err := session.CombinedOutput(command)
+	var output []byte
+	err = runSession(d.context(), session, func() (runErr error) {
+		output, runErr = session.CombinedOutput(command)
+		return runErr
+	})
 
 	if logOutput && len(output) > 0 {
 		log.Println(strings.TrimSpace(string(output)))
@@ -161,6 +213,27 @@ func (d *Deployer) executeRemoteCommand(command string, logOutput bool) error {
 	return nil
 }
 
+// runSession runs a command on an SSH session. When ctx is done first, the remote command
+// is interrupted and the session closed, so a cancelled deployment does not wait for it.
+func runSession(ctx context.Context, session *ssh.Session, run func() error) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("deployment cancelled: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- run() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGINT)
+		_ = session.Close()
+		<-done
+		return fmt.Errorf("deployment cancelled: %w", ctx.Err())
+	}
+}
+
 // executeRemoteCommandWithOutput executes a command on the remote host and returns the output.
 func (d *Deployer) executeRemoteCommandWithOutput(command string) (string, error) {
 	if d.IsLocalhost {
@@ -174,7 +247,11 @@ func (d *Deployer) executeRemoteCommandWithOutput(command string) (string, error
 	defer session.Close()
 
 	// log.Printf("🚀 Executing remote command (capture output): %s", command)
-	output, err := session.CombinedOutput(command)
+	var output []byte
+	err = runSession(d.context(), session, func() (runErr error) {
+		output, runErr = session.CombinedOutput(command)
+		return runErr
+	})
 	if err != nil {
 		return string(output), fmt.Errorf("command execution failed: %w", err)
 	}
@@ -185,7 +262,7 @@ func (d *Deployer) executeRemoteCommandWithOutput(command string) (string, error
 func (d *Deployer) executeLocalCommand(command string, logOutput bool) error {
 	// log.Printf("🚀 Executing local command: %s", command)
 
-	cmd := exec.Command("bash", "-c", command)
+	cmd := exec.CommandContext(d.context(), "bash", "-c", command)
 	output, err := cmd.CombinedOutput()
 
 	if logOutput && len(output) > 0 {
@@ -203,7 +280,7 @@ func (d *Deployer) executeLocalCommand(command string, logOutput bool) error {
 func (d *Deployer) executeLocalCommandWithOutput(command string) (string, error) {
 	log.Printf("🚀 Executing local command (capture output): %s", command)
 
-	cmd := exec.Command("bash", "-c", command)
+	cmd := exec.CommandContext(d.context(), "bash", "-c", command)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return string(output), fmt.Errorf("command execution failed: %w", err)
@@ -308,6 +385,9 @@ func (d *Deployer) copyFileLocally(localPath, targetDir string) error {
 func (d *Deployer) findFreePort() (int, error) {
 	for range 100 { // try up to 100 times
 		port := rand.Intn(10000) + 10000 // 10000-19999 range
+		if slices.Contains(d.undo.ports, port) {
+			continue // Started by this deployment, it may not listen yet
+		}
 		output, err := d.executeRemoteCommandWithOutput(fmt.Sprintf("ss -lntu | grep :%d", port))
 		// We expect an error if grep finds nothing, so we check the output.
 		if err != nil && !strings.Contains(output, ":"+fmt.Sprint(port)) {
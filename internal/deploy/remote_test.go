@@ -0,0 +1,76 @@
+package deploy
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"youfun/shipyard/internal/static"
+)
+
+func TestInitRuntimeCommand(t *testing.T) {
+	if _, err := exec.LookPath("base64"); err != nil {
+		t.Skip("base64 not available")
+	}
+	defer func(script string) { static.InitRuntimeScript = script }(static.InitRuntimeScript)
+	defer func(script string) { static.RenderUnitScript = script }(static.RenderUnitScript)
+	static.InitRuntimeScript = "printf 'init|'\r\n"
+	static.RenderUnitScript = "printf '%s|%s|%s|%s|%s|%s' \"$APP\" \"$USER\" \"$RUNTIME\" \"$PROCESS\" \"$NODE_HOST\" \"$START_CMD\"\r\n"
+
+	// The start command reaches the script verbatim
+	startCmd := `./bin/app serve --port "$PORT" --name 'my app'`
+	output, err := exec.Command("sh", "-c", renderUnitCommand("web", "custom", "worker", startCmd, "phoenix", "10.0.0.1")).CombinedOutput()
+	if err != nil {
+		t.Fatalf("render command failed: %v: %s", err, output)
+	}
+	if want := "web|phoenix|custom|worker|10.0.0.1|" + startCmd; string(output) != want {
+		t.Errorf("script got %q, want %q", output, want)
+	}
+
+	// Initializing a host renders the unit after preparing the host
+	output, err = exec.Command("sh", "-c", initRuntimeCommand("web", "custom", "worker", startCmd, "phoenix", "10.0.0.1")).CombinedOutput()
+	if err != nil {
+		t.Fatalf("init command failed: %v: %s", err, output)
+	}
+	if want := "init|web|phoenix|custom|worker|10.0.0.1|" + startCmd; string(output) != want {
+		t.Errorf("script got %q, want %q", output, want)
+	}
+
+	static.InitRuntimeScript = "exit 3"
+	err = exec.Command("sh", "-c", initRuntimeCommand("web", "custom", "web", "x", "phoenix", "")).Run()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+		t.Errorf("expected the exit status of the script, got %v", err)
+	}
+}
+
+func TestRenderUnitType(t *testing.T) {
+	for _, tool := range []string{"bash", "base64", "sed"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+	// The unit is written to UNIT_DIR, nothing else on the host is changed but the units systemd loads
+	stubs := t.TempDir()
+	if err := os.WriteFile(filepath.Join(stubs, "systemctl"), []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	unitDir := t.TempDir()
+
+	// bin/app start of a release runs in the foreground, systemd must not wait for it to fork
+	cmd := exec.Command("sh", "-c", renderUnitCommand("app", "phoenix", "web", "bin/app start", "phoenix", ""))
+	cmd.Env = append(os.Environ(), "PATH="+stubs+string(os.PathListSeparator)+os.Getenv("PATH"), "UNIT_DIR="+unitDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("render command failed: %v: %s", err, output)
+	}
+	unit, err := os.ReadFile(filepath.Join(unitDir, "app@.service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(unit), "\nType=simple\n") {
+		t.Errorf("expected Type=simple, got:\n%s", unit)
+	}
+	if !strings.Contains(string(unit), "exec bin/app start;") {
+		t.Errorf("expected the start command in ExecStart, got:\n%s", unit)
+	}
+}
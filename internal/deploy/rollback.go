@@ -0,0 +1,274 @@
+package deploy
+
+import (
+	"youfun/shipyard/internal/caddy"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/database"
+	"youfun/shipyard/internal/models"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RollbackOptions selects the release to roll back to.
+// With neither DeploymentID nor Version set, the standby release is used.
+type RollbackOptions struct {
+	DeploymentID uuid.UUID           // Roll back to the release of this deployment
+	Version      string              // Roll back to the newest retained release with this version
+	HealthCheck  *config.HealthCheck // Readiness probe for the restarted release, defaults apply when nil
+}
+
+// RollbackResult describes a completed rollback.
+type RollbackResult struct {
+	DeploymentID uuid.UUID
+	Version      string
+	ReleasePath  string
+	Port         int
+	PreviousPort int
+}
+
+// selectRollbackTarget picks the retained release to roll back to from the instance's runs (newest first).
+// The release currently serving traffic is never selected.
+func selectRollbackTarget(runs []models.DeploymentInstance, activePort, previousPort int, version string) (*models.DeploymentInstance, error) {
+	activeRelease := activeReleasePath(runs, activePort)
+
+	var candidates []models.DeploymentInstance
+	for _, run := range runs {
+		if run.ReleasePath == "" || run.ReleasePath == activeRelease {
+			continue
+		}
+		if run.Status == "failed" || run.Status == "pruned" {
+			continue
+		}
+		candidates = append(candidates, run)
+	}
+
+	if version != "" {
+		for i := range candidates {
+			if candidates[i].Version == version {
+				return &candidates[i], nil
+			}
+		}
+		return nil, fmt.Errorf("no retained release found for version %s", version)
+	}
+
+	// Prefer the standby kept by the last deployment
+	if previousPort > 0 {
+		for i := range candidates {
+			if candidates[i].Port == previousPort {
+				return &candidates[i], nil
+			}
+		}
+	}
+	if len(candidates) > 0 {
+		return &candidates[0], nil
+	}
+	return nil, fmt.Errorf("no standby or retained release available to roll back to")
+}
+
+// activeReleasePath returns the release path of the newest run on the active port.
+func activeReleasePath(runs []models.DeploymentInstance, activePort int) string {
+	if activePort <= 0 {
+		return ""
+	}
+	for _, run := range runs {
+		if run.Port == activePort {
+			return run.ReleasePath
+		}
+	}
+	return ""
+}
+
+// Rollback restarts a retained release of an application instance on a fresh port, health-checks it
+// and switches traffic back to it. The release being replaced is stopped and kept as the new standby.
+func Rollback(instanceID uuid.UUID, opts RollbackOptions) (*RollbackResult, error) {
+	instance, err := database.GetApplicationInstanceByID(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get application instance: %w", err)
+	}
+	app, err := database.GetApplicationByID(instance.ApplicationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get application: %w", err)
+	}
+	host, err := database.GetSSHHostByID(instance.HostID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host: %w", err)
+	}
+
+	activePort := 0
+	if instance.ActivePort.Valid {
+		activePort = int(instance.ActivePort.Int64)
+	}
+	previousPort := 0
+	if instance.PreviousActivePort.Valid {
+		previousPort = int(instance.PreviousActivePort.Int64)
+	}
+
+	runs, err := database.GetDeploymentHistoryForInstance(instance.ID, 50)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment instances: %w", err)
+	}
+	activePorts, err := database.GetActiveInstancePorts(instance.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active instance ports: %w", err)
+	}
+
+	var target *models.DeploymentInstance
+	if opts.DeploymentID != uuid.Nil {
+		target, err = rollbackTargetForDeployment(instance.ID, opts.DeploymentID, runs)
+	} else {
+		target, err = selectRollbackTarget(runs, activePort, previousPort, opts.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if target.ReleasePath == activeReleasePath(runs, activePort) {
+		return nil, fmt.Errorf("release %s is already serving traffic", target.ReleasePath)
+	}
+
+	hc := config.HealthCheck{}
+	if opts.HealthCheck != nil {
+		hc = *opts.HealthCheck
+	}
+	hc.ApplyDefaults()
+
+	d := &Deployer{
+		AppName:            app.Name,
+		HostName:           host.Name,
+		Instance:           instance,
+		Application:        app,
+		Host:               host,
+		Version:            target.Version,
+		GitCommitSHA:       target.GitCommitSHA,
+		CurrentReleasePath: target.ReleasePath,
+		IsLocalhost:        host.Name == "localhost" || host.Addr == "127.0.0.1",
+		HealthCheck:        &hc,
+	}
+
+	log.Printf("⏪ Rolling back %s on %s to %s (%s)", app.Name, host.Name, target.Version, target.ReleasePath)
+
+	if d.IsLocalhost {
+		d.caddySvc = caddy.NewLocalService()
+	} else {
+		if err := d.connectSSHWithAPIConfig(); err != nil {
+			return nil, err
+		}
+		defer d.SSHClient.Close()
+		d.caddySvc = caddy.NewService(d.SSHClient)
+	}
+
+	if err := d.executeRemoteCommand(fmt.Sprintf("test -d %s", target.ReleasePath), false); err != nil {
+		return nil, fmt.Errorf("release %s no longer exists on host %s", target.ReleasePath, host.Name)
+	}
+
+	d.History, err = database.CreateRollbackHistory(instance.ID, target.Version, target.ReleasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create deployment history record: %w", err)
+	}
+
+	d.oldPorts = servingPorts(instance, activePorts)
+	result, err := d.executeRollback(target, activePort)
+	if err != nil {
+		log.Printf("❌ Rollback failed: %v", err)
+		_ = database.UpdateDeploymentHistoryStatusOnly(d.History.ID, string(models.DeploymentStatusFailed))
+		_ = database.AppendDeploymentHistoryOutput(d.History.ID, fmt.Sprintf("Rollback to %s failed: %v\n", target.ReleasePath, err))
+		return nil, err
+	}
+
+	_ = database.AppendDeploymentHistoryOutput(d.History.ID, fmt.Sprintf("Rolled back to %s (%s): port %d -> %d\n", target.Version, target.ReleasePath, activePort, result.Port))
+	log.Println("🎉 Rollback successful!")
+	return result, nil
+}
+
+// rollbackTargetForDeployment resolves the release deployed by a specific deployment of the instance.
+func rollbackTargetForDeployment(instanceID, deploymentID uuid.UUID, runs []models.DeploymentInstance) (*models.DeploymentInstance, error) {
+	history, err := database.GetDeploymentHistoryByID(deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("deployment not found: %w", err)
+	}
+	if history.InstanceID != instanceID {
+		return nil, fmt.Errorf("deployment %s does not belong to this instance", deploymentID)
+	}
+	if history.ReleasePath == "" {
+		return nil, fmt.Errorf("deployment %s has no release to roll back to", deploymentID)
+	}
+
+	for i := range runs {
+		if runs[i].ReleasePath == history.ReleasePath {
+			return &runs[i], nil
+		}
+	}
+	return &models.DeploymentInstance{
+		ApplicationInstanceID: instanceID,
+		Version:               history.Version,
+		ReleasePath:           history.ReleasePath,
+		Port:                  history.Port,
+	}, nil
+}
+
+// executeRollback starts the target release on as many instances as serve traffic, checks them
+// and switches traffic to them.
+func (d *Deployer) executeRollback(target *models.DeploymentInstance, activePort int) (*RollbackResult, error) {
+	runs, err := d.startNewVersions(target.ReleasePath, instanceCount(0, d.oldPorts))
+	if err != nil {
+		return nil, err
+	}
+	ports := runPorts(runs)
+	port := ports[0]
+
+	time.Sleep(2 * time.Second)
+
+	log.Println("💓 Health check...")
+	for _, p := range ports {
+		if err := d.performHealthCheck(p); err != nil {
+			d.stopNewVersions(ports)
+			return nil, fmt.Errorf("health check failed: %w", err)
+		}
+	}
+
+	domains, err := GetDomainsForDeploy(d.Instance.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain list: %w", err)
+	}
+	if err := d.switchTraffic(ports, d.oldPorts, domains); err != nil {
+		return nil, err
+	}
+
+	// Swaps active/previous ports and marks the replaced runs as standby
+	if err := database.RecordSuccessfulDeployment(d.History.ID, ports, target.ReleasePath, target.GitCommitSHA); err != nil {
+		return nil, fmt.Errorf("failed to record rollback: %w", err)
+	}
+	if err := d.restartProcesses(target.ReleasePath); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+	if err := d.linkCurrentRelease(target.ReleasePath); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+
+	// The release now runs on a fresh port, retire the run it was restarted from
+	if target.ID != uuid.Nil && !slices.Contains(d.oldPorts, target.Port) && !slices.Contains(ports, target.Port) {
+		st := time.Now()
+		_ = database.UpdateDeploymentInstanceStatus(target.ID, "stopped", &st)
+		d.executeRemoteCommand(fmt.Sprintf("rm -f /var/www/%s/instances/%d || true", d.AppName, target.Port), false)
+	}
+
+	for _, oldPort := range d.oldPorts {
+		if summary := drainOldVersion(d.context(), d.caddySvc, oldPort); summary != "" {
+			_ = database.AppendDeploymentHistoryOutput(d.History.ID, summary+"\n")
+		}
+		log.Printf("🛑 Stopping rolled back version (:%d), files are kept as standby...", oldPort)
+		d.executeRemoteCommand(fmt.Sprintf("systemctl disable %s@%d", d.AppName, oldPort), false)
+		d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d", d.AppName, oldPort), false)
+	}
+
+	return &RollbackResult{
+		DeploymentID: d.History.ID,
+		Version:      target.Version,
+		ReleasePath:  target.ReleasePath,
+		Port:         port,
+		PreviousPort: activePort,
+	}, nil
+}
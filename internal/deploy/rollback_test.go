@@ -0,0 +1,60 @@
+package deploy
+
+import (
+	"youfun/shipyard/internal/models"
+	"testing"
+)
+
+func TestSelectRollbackTarget(t *testing.T) {
+	// Newest first, as returned by GetDeploymentHistoryForInstance
+	runs := []models.DeploymentInstance{
+		{Version: "v4", ReleasePath: "/releases/v4", Port: 4004, Status: "failed"},
+		{Version: "v3", ReleasePath: "/releases/v3", Port: 4003, Status: "active"},
+		{Version: "v2", ReleasePath: "/releases/v2", Port: 4002, Status: "standby"},
+		{Version: "v1", ReleasePath: "/releases/v1", Port: 4001, Status: "stopped"},
+		{Version: "v0", ReleasePath: "/releases/v0", Port: 4000, Status: "pruned"},
+	}
+
+	tests := []struct {
+		name         string
+		previousPort int
+		version      string
+		wantPath     string
+		wantErr      bool
+	}{
+		{name: "standby by previous port", previousPort: 4002, wantPath: "/releases/v2"},
+		{name: "no previous port uses newest retained", previousPort: 0, wantPath: "/releases/v2"},
+		{name: "unknown previous port uses newest retained", previousPort: 4999, wantPath: "/releases/v2"},
+		{name: "explicit version", previousPort: 4002, version: "v1", wantPath: "/releases/v1"},
+		{name: "active version rejected", version: "v3", wantErr: true},
+		{name: "failed version rejected", version: "v4", wantErr: true},
+		{name: "pruned version rejected", version: "v0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectRollbackTarget(runs, 4003, tt.previousPort, tt.version)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", got.ReleasePath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.ReleasePath != tt.wantPath {
+				t.Errorf("got %s, want %s", got.ReleasePath, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestSelectRollbackTarget_NothingRetained(t *testing.T) {
+	runs := []models.DeploymentInstance{
+		{Version: "v1", ReleasePath: "/releases/v1", Port: 4001, Status: "active"},
+	}
+	if _, err := selectRollbackTarget(runs, 4001, 0, ""); err == nil {
+		t.Fatal("expected error when only the active release is retained")
+	}
+}
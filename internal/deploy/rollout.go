@@ -0,0 +1,283 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/pkg/types"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// RolloutOptions are the options of a rolling deployment to several hosts.
+type RolloutOptions struct {
+	RunOptions
+	Parallel int // Number of hosts deployed at the same time, 1 deploys them in sequence
+}
+
+// RolloutResult is the outcome of a rollout on one host.
+type RolloutResult struct {
+	Host    string
+	Err     error
+	Skipped bool // Not deployed because the rollout stopped before reaching the host
+}
+
+// rolloutRun is shared by the deployments of one rollout.
+type rolloutRun struct {
+	id       string // Friendly ID of the rollout record
+	artifact builtArtifact
+	log      *rolloutLog
+}
+
+// builtArtifact is the artifact built once and deployed to every host of a rollout.
+type builtArtifact struct {
+	version      string
+	tarballPath  string
+	md5Hash      string
+	compression  string
+	gitCommitSHA string
+	signature    artifactSignature
+}
+
+// syncBuffer is a bytes.Buffer that can be written by the logger while being read.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// rolloutLog writes log output to stdout and to the log buffer of every deployment of the rollout
+// that is running. The standard logger is global, so deployments running in parallel share their logs.
+type rolloutLog struct {
+	mu      sync.Mutex
+	out     io.Writer
+	buffers map[*syncBuffer]struct{}
+}
+
+func newRolloutLog(out io.Writer) *rolloutLog {
+	return &rolloutLog{out: out, buffers: make(map[*syncBuffer]struct{})}
+}
+
+func (l *rolloutLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for buf := range l.buffers {
+		buf.Write(p)
+	}
+	return l.out.Write(p)
+}
+
+// attach captures the rollout log in buf until the returned function is called.
+func (l *rolloutLog) attach(buf *syncBuffer) func() {
+	l.mu.Lock()
+	l.buffers[buf] = struct{}{}
+	l.mu.Unlock()
+	return func() {
+		l.mu.Lock()
+		delete(l.buffers, buf)
+		l.mu.Unlock()
+	}
+}
+
+// RunRollout deploys an application to several hosts with a rolling strategy.
+// The artifact is built once, then the hosts are deployed in order, parallel at a time.
+// The rollout stops at the first host that fails; hosts not started yet are skipped.
+// All deployments are grouped under one rollout record.
+func RunRollout(ctx context.Context, apiClient client.APIClient, appName string, hosts []string, opts RolloutOptions, hostKeyCallback ssh.HostKeyCallback) ([]RolloutResult, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts to deploy to")
+	}
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	if parallel > len(hosts) {
+		parallel = len(hosts)
+	}
+
+	run := &rolloutRun{log: newRolloutLog(Output)}
+	log.SetOutput(run.log)
+
+	log.Printf("--- 🚀 Rolling deployment of %s to %d hosts (%d at a time): %s ---", appName, len(hosts), parallel, strings.Join(hosts, ", "))
+
+	// Loaded once, the deployments of the rollout share it
+	config.LoadConfig(appName, config.ConfigPath)
+
+	log.Println("---", "1. [CLI] Building artifact for all hosts", "---")
+	artifact, err := buildRolloutArtifact(ctx, apiClient, appName, hosts[0], opts.UseBuild)
+	if err != nil {
+		err = &StepError{Step: models.DeployStepArtifact, Err: fmt.Errorf("failed to build artifact: %w", err)}
+		opts.Events.Emit(Event{Event: EventRolloutFinished, App: appName, Status: models.RolloutStatusFailed, Error: err.Error(), Class: FailureClass(err)})
+		return nil, err
+	}
+	run.artifact = *artifact
+
+	rollout, err := apiClient.CreateRollout(&types.CreateRolloutRequest{
+		AppName:  appName,
+		Version:  artifact.version,
+		Hosts:    hosts,
+		Parallel: parallel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rollout record: %w", err)
+	}
+	run.id = rollout.ID
+	log.Printf("✅ Rollout record created: %s", run.id)
+
+	results := runRolloutHosts(ctx, hosts, parallel, func(host string) error {
+		runOpts := opts.RunOptions
+		runOpts.rollout = run
+		return RunWithAPIClient(ctx, apiClient, appName, host, runOpts, hostKeyCallback)
+	})
+
+	status := models.RolloutStatusSuccess
+	var failed *RolloutResult
+	skipped := false
+	for i := range results {
+		if results[i].Err != nil && failed == nil {
+			failed = &results[i]
+		}
+		skipped = skipped || results[i].Skipped
+	}
+	switch {
+	case ctx.Err() != nil && (failed != nil || skipped):
+		status = models.RolloutStatusCancelled
+	case failed != nil:
+		status = models.RolloutStatusFailed
+	}
+	if err := apiClient.UpdateRolloutStatus(run.id, status); err != nil {
+		log.Printf("⚠️  Warning: Failed to update rollout status: %v", err)
+	}
+
+	logRolloutSummary(run.id, results)
+	switch {
+	case failed != nil:
+		err = fmt.Errorf("rollout stopped at host %s: %w", failed.Host, failed.Err)
+	case skipped:
+		err = fmt.Errorf("rollout cancelled: %w", ctx.Err())
+	}
+	event := Event{Event: EventRolloutFinished, App: appName, RolloutID: run.id, Version: artifact.version, Status: status}
+	if err != nil {
+		event.Error = err.Error()
+		event.Class = FailureClass(err)
+	}
+	opts.Events.Emit(event)
+	return results, err
+}
+
+// runRolloutHosts deploys the hosts in order, parallel at a time.
+// Once a deployment fails no further host is started; deployments already running finish.
+func runRolloutHosts(ctx context.Context, hosts []string, parallel int, deployHost func(host string) error) []RolloutResult {
+	results := make([]RolloutResult, len(hosts))
+	slots := make(chan struct{}, parallel)
+	var (
+		mu      sync.Mutex
+		stopped bool
+		wg      sync.WaitGroup
+	)
+
+	for i, host := range hosts {
+		slots <- struct{}{}
+		mu.Lock()
+		stop := stopped || ctx.Err() != nil
+		mu.Unlock()
+		if stop {
+			<-slots
+			results[i] = RolloutResult{Host: host, Skipped: true}
+			continue
+		}
+
+		log.Printf("--- [%d/%d] Deploying to %s ---", i+1, len(hosts), host)
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			err := deployHost(host)
+			mu.Lock()
+			results[i] = RolloutResult{Host: host, Err: err}
+			if err != nil {
+				stopped = true
+			}
+			mu.Unlock()
+		}(i, host)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// buildRolloutArtifact builds (or reuses) the artifact deployed to every host of a rollout.
+func buildRolloutArtifact(ctx context.Context, apiClient client.APIClient, appName, hostName, useBuild string) (*builtArtifact, error) {
+	conf, err := apiClient.GetDeployConfig(appName, hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Deployer{
+		AppName:   appName,
+		HostName:  hostName,
+		useBuild:  useBuild,
+		APIClient: apiClient,
+		ctx:       ctx,
+	}
+	if d.Application, err = convertAppDTOToModel(&conf.App); err != nil {
+		return nil, err
+	}
+	if d.trustedKeys, err = trustedKeysFromDTOs(conf.TrustedKeys); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	d.Runtime = config.AppConfig.Runtime
+	if d.Runtime == "" {
+		d.Runtime = d.detectRuntime()
+	}
+
+	if err := d.ProcessArtifact(); err != nil {
+		return nil, err
+	}
+	if err := d.checkCancelled(); err != nil {
+		return nil, err
+	}
+	log.Printf("📦 Artifact ready: %s (version: %s)", d.tarballPath, d.Version)
+
+	return &builtArtifact{
+		version:      d.Version,
+		tarballPath:  d.tarballPath,
+		md5Hash:      d.md5Hash,
+		compression:  d.compression,
+		gitCommitSHA: d.GitCommitSHA,
+		signature:    d.signature,
+	}, nil
+}
+
+// logRolloutSummary reports the outcome of every host of a rollout.
+func logRolloutSummary(rolloutID string, results []RolloutResult) {
+	log.Printf("--- Rollout %s summary ---", rolloutID)
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			log.Printf("⏭️  %s: skipped", r.Host)
+		case r.Err != nil:
+			log.Printf("❌ %s: %v", r.Host, r.Err)
+		default:
+			log.Printf("✅ %s: deployed", r.Host)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunRolloutHostsStopsAtFirstFailure(t *testing.T) {
+	hosts := []string{"web-1", "web-2", "web-3", "web-4"}
+	var deployed []string
+
+	results := runRolloutHosts(context.Background(), hosts, 1, func(host string) error {
+		deployed = append(deployed, host)
+		if host == "web-2" {
+			return errors.New("health check failed")
+		}
+		return nil
+	})
+
+	if strings.Join(deployed, ",") != "web-1,web-2" {
+		t.Errorf("expected rollout to stop after web-2, deployed %v", deployed)
+	}
+	if results[0].Err != nil || results[0].Skipped {
+		t.Errorf("expected web-1 to be deployed, got %+v", results[0])
+	}
+	if results[1].Err == nil {
+		t.Errorf("expected web-2 to report its failure, got %+v", results[1])
+	}
+	for _, r := range results[2:] {
+		if !r.Skipped {
+			t.Errorf("expected %s to be skipped, got %+v", r.Host, r)
+		}
+	}
+}
+
+func TestRunRolloutHostsParallel(t *testing.T) {
+	hosts := []string{"web-1", "web-2", "web-3", "web-4", "web-5"}
+	var (
+		mu          sync.Mutex
+		running     int
+		maxRunning  int
+		release     = make(chan struct{})
+		started     = make(chan struct{}, len(hosts))
+		resultsChan = make(chan []RolloutResult)
+	)
+
+	go func() {
+		resultsChan <- runRolloutHosts(context.Background(), hosts, 2, func(host string) error {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			started <- struct{}{}
+			<-release
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+	}()
+
+	// Let the hosts through one at a time once two are running
+	for i := 0; i < len(hosts); i++ {
+		<-started
+		if i >= 1 {
+			release <- struct{}{}
+		}
+	}
+	release <- struct{}{}
+	results := <-resultsChan
+
+	if maxRunning != 2 {
+		t.Errorf("expected 2 hosts to be deployed at the same time, got %d", maxRunning)
+	}
+	for _, r := range results {
+		if r.Err != nil || r.Skipped {
+			t.Errorf("expected %s to be deployed, got %+v", r.Host, r)
+		}
+	}
+}
+
+func TestRunRolloutHostsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	hosts := []string{"web-1", "web-2"}
+
+	results := runRolloutHosts(ctx, hosts, 1, func(host string) error {
+		cancel()
+		return nil
+	})
+
+	if results[0].Skipped || !results[1].Skipped {
+		t.Errorf("expected hosts after cancellation to be skipped, got %+v", results)
+	}
+}
+
+func TestRolloutLogCapturesAttachedBuffers(t *testing.T) {
+	var out bytes.Buffer
+	l := newRolloutLog(&out)
+	var first, second syncBuffer
+
+	detachFirst := l.attach(&first)
+	l.Write([]byte("building\n"))
+	detachSecond := l.attach(&second)
+	l.Write([]byte("deploying\n"))
+	detachFirst()
+	l.Write([]byte("done\n"))
+	detachSecond()
+
+	if out.String() != "building\ndeploying\ndone\n" {
+		t.Errorf("unexpected stdout: %q", out.String())
+	}
+	if first.String() != "building\ndeploying\n" {
+		t.Errorf("unexpected first buffer: %q", first.String())
+	}
+	if second.String() != "deploying\ndone\n" {
+		t.Errorf("unexpected second buffer: %q", second.String())
+	}
+}
@@ -0,0 +1,203 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/database"
+	"youfun/shipyard/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotDeployed is returned when scaling an instance that has no release serving traffic.
+var ErrNotDeployed = errors.New("no release is serving traffic on this instance, deploy first")
+
+// ErrInvalidScale is returned for an instance count out of range.
+var ErrInvalidScale = errors.New("invalid instance count")
+
+// ScaleResult describes the instances of the active release after scaling.
+type ScaleResult struct {
+	Version     string
+	ReleasePath string
+	Ports       []int // Ports serving traffic, the active port first
+	Started     []int // Ports of the instances started
+	Stopped     []int // Ports of the instances stopped
+}
+
+// servingPorts returns the ports of the instances of the active release that serve traffic.
+// activePorts are the ports of the runs marked active; the active port of the application instance
+// is authoritative, a list that does not contain it is from before instances were scaled.
+func servingPorts(instance *models.ApplicationInstance, activePorts []int) []int {
+	if instance == nil || !instance.ActivePort.Valid || instance.ActivePort.Int64 <= 0 {
+		return nil
+	}
+	activePort := int(instance.ActivePort.Int64)
+	if !slices.Contains(activePorts, activePort) {
+		return []int{activePort}
+	}
+	// The active port first, it is the one scaling down keeps last
+	ports := []int{activePort}
+	for _, port := range activePorts {
+		if port != activePort {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// instanceCount returns how many instances of a release to run on a host: scale from shipyard.toml when set,
+// otherwise as many as serve traffic now, so that a count set with 'shipyard-cli scale' survives deployments.
+func instanceCount(scale int, serving []int) int {
+	if scale > 0 {
+		return scale
+	}
+	if len(serving) > 0 {
+		return len(serving)
+	}
+	return 1
+}
+
+// Scale runs count instances of the active release of an application instance behind Caddy.
+// Added instances are started on free ports and health-checked before they receive traffic;
+// removed ones are taken out of the routes first and stopped once drained. The active port is kept.
+func Scale(instanceID uuid.UUID, count int, hc *config.HealthCheck) (*ScaleResult, error) {
+	if count < 1 || count > config.MaxScale {
+		return nil, fmt.Errorf("%w: %d, scale to between 1 and %d", ErrInvalidScale, count, config.MaxScale)
+	}
+
+	d, err := newInstanceDeployer(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer d.close()
+
+	activePorts, err := database.GetActiveInstancePorts(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active instance ports: %w", err)
+	}
+	ports := servingPorts(d.Instance, activePorts)
+	if len(ports) == 0 {
+		return nil, ErrNotDeployed
+	}
+	runs, err := database.GetDeploymentHistoryForInstance(instanceID, 50)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment instances: %w", err)
+	}
+	releasePath := activeReleasePath(runs, ports[0])
+	if releasePath == "" {
+		return nil, fmt.Errorf("no release found for the active port %d", ports[0])
+	}
+	for _, run := range runs {
+		if run.Port == ports[0] {
+			d.Version, d.GitCommitSHA = run.Version, run.GitCommitSHA
+			break
+		}
+	}
+	d.CurrentReleasePath = releasePath
+
+	domains, err := GetDomainsForDeploy(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain list: %w", err)
+	}
+
+	result := &ScaleResult{Version: d.Version, ReleasePath: releasePath}
+	switch {
+	case count > len(ports):
+		check := config.HealthCheck{}
+		if hc != nil {
+			check = *hc
+		}
+		check.ApplyDefaults()
+		d.HealthCheck = &check
+
+		log.Printf("📈 Scaling %s on %s from %d to %d instances of %s", d.AppName, d.HostName, len(ports), count, releasePath)
+		started, err := d.scaleUp(releasePath, count-len(ports), slices.Clone(ports), domains)
+		if err != nil {
+			return nil, err
+		}
+		result.Started = started
+		ports = append(ports, started...)
+	case count < len(ports):
+		log.Printf("📉 Scaling %s on %s from %d to %d instances", d.AppName, d.HostName, len(ports), count)
+		if err := d.scaleDown(ports[:count], ports[count:], domains); err != nil {
+			return nil, err
+		}
+		result.Stopped = ports[count:]
+		ports = ports[:count]
+	default:
+		log.Printf("%s on %s already runs %d instance(s)", d.AppName, d.HostName, count)
+	}
+	result.Ports = ports
+	return result, nil
+}
+
+// scaleUp starts count more instances of the release, checks them and adds them to the routes
+// next to the instances serving traffic. It returns the ports of the started instances.
+func (d *Deployer) scaleUp(releasePath string, count int, serving []int, domains []string) ([]int, error) {
+	runs, err := d.startNewVersions(releasePath, count)
+	if err != nil {
+		return nil, err
+	}
+	started := runPorts(runs)
+
+	time.Sleep(2 * time.Second)
+
+	log.Println("💓 Health check...")
+	for _, port := range started {
+		if err := d.performHealthCheck(port); err != nil {
+			d.stopNewVersions(started)
+			return nil, fmt.Errorf("health check failed: %w", err)
+		}
+	}
+
+	for _, run := range runs {
+		if err := database.AddDeploymentInstance(run); err != nil {
+			d.stopNewVersions(started)
+			return nil, fmt.Errorf("failed to record instance run info: %w", err)
+		}
+	}
+	if len(domains) > 0 {
+		if err := d.caddySvc.UpdateLoadBalancedReverseProxy(domains, append(serving, started...)); err != nil {
+			d.stopNewVersions(started)
+			st := time.Now()
+			for _, run := range runs {
+				_ = database.UpdateDeploymentInstanceStatus(run.ID, "stopped", &st)
+			}
+			return nil, fmt.Errorf("failed to update Caddy config: %w", err)
+		}
+	}
+	for _, run := range runs {
+		d.enableService(run.Port)
+		_ = database.UpdateDeploymentInstanceStatus(run.ID, "active", nil)
+	}
+	log.Printf("✅ Instances on port %s serve traffic", formatPorts(started))
+	return started, nil
+}
+
+// scaleDown takes the instances on the ports to remove out of the routes, then stops them once drained.
+func (d *Deployer) scaleDown(keep, remove []int, domains []string) error {
+	holdUpstreams(d.caddySvc, remove)
+	if len(domains) > 0 {
+		if err := d.caddySvc.UpdateLoadBalancedReverseProxy(domains, keep); err != nil {
+			releaseUpstreams(d.caddySvc, remove)
+			return fmt.Errorf("failed to update Caddy config: %w", err)
+		}
+	}
+
+	for _, port := range remove {
+		drainOldVersion(d.context(), d.caddySvc, port)
+		log.Printf("🛑 Stopping instance (:%d)...", port)
+		d.executeRemoteCommand(fmt.Sprintf("systemctl disable %s@%d || true", d.AppName, port), false)
+		d.executeRemoteCommand(fmt.Sprintf("systemctl stop %s@%d || true", d.AppName, port), false)
+		d.executeRemoteCommand(fmt.Sprintf("rm -f /var/www/%s/instances/%d || true", d.AppName, port), false)
+		if run, err := database.GetLatestDeploymentInstanceByPort(d.Instance.ID, port); err == nil {
+			st := time.Now()
+			_ = database.UpdateDeploymentInstanceStatus(run.ID, "stopped", &st)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package deploy
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"youfun/shipyard/internal/models"
+)
+
+func TestServingPorts(t *testing.T) {
+	instance := func(port int64) *models.ApplicationInstance {
+		return &models.ApplicationInstance{ActivePort: sql.NullInt64{Int64: port, Valid: port > 0}}
+	}
+	tests := []struct {
+		name        string
+		instance    *models.ApplicationInstance
+		activePorts []int
+		want        []int
+	}{
+		{"not deployed", instance(0), []int{4001}, nil},
+		{"single instance", instance(4001), []int{4001}, []int{4001}},
+		{"active port first", instance(4002), []int{4001, 4002, 4003}, []int{4002, 4001, 4003}},
+		{"runs from before scaling", instance(4002), []int{4001}, []int{4002}},
+		{"no runs", instance(4001), nil, []int{4001}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := servingPorts(tt.instance, tt.activePorts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("servingPorts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstanceCount(t *testing.T) {
+	if got := instanceCount(3, []int{4001}); got != 3 {
+		t.Errorf("instanceCount(scale 3) = %d, want 3", got)
+	}
+	if got := instanceCount(0, []int{4001, 4002}); got != 2 {
+		t.Errorf("instanceCount(serving 2) = %d, want 2", got)
+	}
+	if got := instanceCount(0, nil); got != 1 {
+		t.Errorf("instanceCount(first deploy) = %d, want 1", got)
+	}
+}
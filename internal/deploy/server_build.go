@@ -0,0 +1,188 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"youfun/shipyard/internal/compression"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/sshutil"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ServerBuildOptions describes a build run by shipyard-server for the CLI ([build] location = "server" or "host").
+type ServerBuildOptions struct {
+	AppName     string
+	Runtime     string
+	SourcePath  string          // tar.gz of the project sources uploaded by the CLI
+	Build       config.Build    // [build] settings of the project
+	BuilderHost *models.SSHHost // Host to build on over SSH, nil builds on the server
+	Output      io.Writer       // Receives the build output, streamed back to the CLI
+}
+
+// BuildOnServer builds a release from the uploaded sources of a project, on the server or on a builder host.
+// It returns the path and MD5 of the release tarball, compressed as [build] compression says, a temp file the
+// caller pushes to the registry and removes.
+func BuildOnServer(ctx context.Context, opts ServerBuildOptions) (tarballPath, md5Hash string, err error) {
+	// Only docker builds, the native builders would run the code of the project in shipyard-server itself
+	if err := opts.Build.Validate(); err != nil {
+		return "", "", err
+	}
+	sourceDir, err := os.MkdirTemp("", "shipyard-source-")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create source directory: %w", err)
+	}
+	defer os.RemoveAll(sourceDir)
+
+	if err := extractTarball(opts.SourcePath, sourceDir, compression.Gzip); err != nil {
+		return "", "", fmt.Errorf("failed to extract sources: %w", err)
+	}
+
+	d := &Deployer{
+		AppName:   opts.AppName,
+		Runtime:   opts.Runtime,
+		ctx:       ctx,
+		sourceDir: sourceDir,
+		buildLog:  opts.Output,
+	}
+
+	var buildDir string
+	if opts.BuilderHost == nil {
+		fmt.Fprintf(opts.Output, "🔨 Building %s on the server...\n", opts.AppName)
+		builder, err := newBuilder(opts.Build)
+		if err != nil {
+			return "", "", err
+		}
+		buildDir, err = builder.Build(d)
+		if err != nil {
+			return "", "", err
+		}
+	} else {
+		fmt.Fprintf(opts.Output, "🔨 Building %s on builder host %s...\n", opts.AppName, opts.BuilderHost.Name)
+		buildDir, err = d.dockerBuildOnHost(opts.BuilderHost, opts.Build)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	defer os.RemoveAll(buildDir)
+
+	tarballPath, err = d.createTarball(filepath.Join(buildDir, "release"), d.AppName, opts.Build.Compression)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to pack release: %w", err)
+	}
+
+	md5Hash, err = calculateMD5(tarballPath)
+	if err != nil {
+		os.Remove(tarballPath)
+		return "", "", fmt.Errorf("MD5 calculation failed: %w", err)
+	}
+
+	fmt.Fprintf(opts.Output, "✅ Build completed (MD5: %s)\n", md5Hash)
+	return tarballPath, md5Hash, nil
+}
+
+// dockerBuildOnHost runs docker build on a builder host over SSH and fetches its output
+// into a temp directory, laid out as a local docker build would leave it.
+func (d *Deployer) dockerBuildOnHost(host *models.SSHHost, build config.Build) (string, error) {
+	var dockerfilePath string
+	var buildArgs []string
+	var err error
+	if d.Runtime == "static" {
+		dockerfilePath, buildArgs, err = d.prepareStaticDockerBuild()
+	} else {
+		dockerfilePath, buildArgs, err = d.prepareDockerBuild(build)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sshConfig, err := sshutil.NewClientConfig(host, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create SSH config: %w", err)
+	}
+	d.SSHClient, err = ssh.Dial("tcp", fmt.Sprintf("%s:%d", host.Addr, host.Port), sshConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to builder host %s: %w", host.Name, err)
+	}
+	defer d.SSHClient.Close()
+
+	remoteDir := fmt.Sprintf("/tmp/shipyard-build-%s-%d", d.AppName, time.Now().UnixNano())
+	defer d.executeRemoteCommand(fmt.Sprintf("rm -rf %s", shellQuote(remoteDir)), false)
+
+	// 1. Send the sources, including the preset Dockerfile
+	sourceTarball, err := d.createTarball(d.sourceDir, d.AppName+"-source", compression.Gzip)
+	if err != nil {
+		return "", fmt.Errorf("failed to pack sources: %w", err)
+	}
+	defer os.Remove(sourceTarball)
+	if err := d.uploadTarFile(sourceTarball, remoteDir+"/src", compression.Gzip); err != nil {
+		return "", fmt.Errorf("failed to send sources to builder host: %w", err)
+	}
+
+	// 2. Build
+	args := dockerBuildArgs(remoteDir+"/out", dockerfilePath, buildArgs)
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
+	stdout, stderr := d.buildStreams()
+	session, err := d.SSHClient.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+	session.Stdout, session.Stderr = stdout, stderr
+	buildCmd := fmt.Sprintf("cd %s && docker %s", shellQuote(remoteDir+"/src"), strings.Join(args, " "))
+	if err := runSession(d.context(), session, func() error { return session.Run(buildCmd) }); err != nil {
+		if err := d.checkCancelled(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("docker build failed on builder host %s: %w", host.Name, err)
+	}
+
+	// 3. Fetch the release
+	buildOutputDir, err := os.MkdirTemp("", "deployer-build-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp build directory: %w", err)
+	}
+	if err := d.fetchRemoteRelease(remoteDir+"/out/release", filepath.Join(buildOutputDir, "release")); err != nil {
+		os.RemoveAll(buildOutputDir)
+		return "", err
+	}
+	return buildOutputDir, nil
+}
+
+// fetchRemoteRelease copies a release directory of the builder host into a local directory.
+func (d *Deployer) fetchRemoteRelease(remoteDir, localDir string) error {
+	tarball, err := os.CreateTemp("", "shipyard-release-*.tar.gz")
+	if err != nil {
+		return fmt.Errorf("failed to create release archive: %w", err)
+	}
+	defer os.Remove(tarball.Name())
+	defer tarball.Close()
+
+	session, err := d.SSHClient.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+	session.Stdout = tarball
+	_, session.Stderr = d.buildStreams()
+	fetchCmd := fmt.Sprintf("tar -czf - -C %s .", shellQuote(remoteDir))
+	if err := runSession(d.context(), session, func() error { return session.Run(fetchCmd) }); err != nil {
+		return fmt.Errorf("failed to fetch release from builder host: %w", err)
+	}
+
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		return fmt.Errorf("failed to create release directory: %w", err)
+	}
+	if err := extractTarball(tarball.Name(), localDir, compression.Gzip); err != nil {
+		return fmt.Errorf("failed to extract release: %w", err)
+	}
+	return nil
+}
@@ -2,24 +2,29 @@ package deploy
 
 import (
 	"archive/tar"
-	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"youfun/shipyard/internal/caddy"
+	"youfun/shipyard/internal/compression"
 	"youfun/shipyard/internal/config"
 	"youfun/shipyard/internal/database"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/signing"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 // ExecuteServerSideDeployment executes deployment on the server itself (no SSH)
-// This is called by the API handler when localhost deployment is triggered
-func ExecuteServerSideDeployment(deploymentIDStr, appName, version string) error {
+// This is called by the API handler when localhost deployment is triggered.
+// The uploaded artifact must be the registered build md5Hash, signed by a key the application trusts.
+func ExecuteServerSideDeployment(deploymentIDStr, appName, version, md5Hash string) error {
 	log.Printf("🚀 [Server] Starting server-side deployment for %s (deployment: %s, version: %s)", appName, deploymentIDStr, version)
 
 	// Parse deployment ID
@@ -59,6 +64,16 @@ func ExecuteServerSideDeployment(deploymentIDStr, appName, version string) error
 
 	log.Printf("📦 [Server] Artifact found: %s", artifactPath)
 
+	// Nothing is extracted from an artifact before its signature is verified
+	artifact, keyName, err := verifyServerArtifact(app.ID, artifactPath, md5Hash)
+	if err != nil {
+		return fmt.Errorf("refusing to deploy artifact: %w", err)
+	}
+	log.Printf("🔏 [Server] Artifact signature verified (key: %s, signed for: %s)", keyName, artifact.SignedBy)
+	if err := database.SetDeploymentSigner(deploymentID, keyName, artifact.SignedBy); err != nil {
+		log.Printf("⚠️  Warning: Failed to record the signer of the artifact: %v", err)
+	}
+
 	// Load app config
 	config.LoadConfig(appName, config.ConfigPath)
 
@@ -71,7 +86,7 @@ func ExecuteServerSideDeployment(deploymentIDStr, appName, version string) error
 
 	// Extract artifact to release path
 	log.Printf("📤 [Server] Extracting artifact to %s", releasePath)
-	if err := extractTarGz(artifactPath, releasePath); err != nil {
+	if err := extractTarball(artifactPath, releasePath, artifact.Compression); err != nil {
 		return fmt.Errorf("failed to extract artifact: %w", err)
 	}
 
@@ -96,29 +111,100 @@ func ExecuteServerSideDeployment(deploymentIDStr, appName, version string) error
 		log.Printf("⚠️  Warning: Failed to get secrets: %v", err)
 		secrets = make(map[string]string)
 	}
+	// Releases of BEAM runtimes join the other nodes of the app with the cookie they all share
+	host, err := database.GetHostByID(instance.HostID)
+	if err != nil {
+		return fmt.Errorf("failed to get host: %w", err)
+	}
+	var clusterHost string
+	if clusterRuntime(config.AppConfig.Runtime) {
+		clusterHost = nodeHost(host.Addr)
+		if _, exists := secrets[ReleaseCookieKey]; !exists {
+			if secrets[ReleaseCookieKey], err = ensureReleaseCookie(app.ID); err != nil {
+				return err
+			}
+		}
+		if _, exists := secrets[ClusterNodesKey]; !exists {
+			peers, err := database.GetActivePeersForApp(app.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get the nodes of the app: %w", err)
+			}
+			if nodes := ClusterNodes(app.Name, peers); len(nodes) > 0 {
+				secrets[ClusterNodesKey] = strings.Join(nodes, ",")
+			}
+		}
+	}
 	if err := injectEnvVarsLocally(releasePath, secrets); err != nil {
 		return fmt.Errorf("failed to inject environment variables: %w", err)
 	}
 
-	// Start new version
-	log.Printf("🌱 [Server] Starting new version")
-	port, err := findFreePortLocally()
+	// The instances serving traffic now, replaced by as many of the new version unless scale is set
+	activePorts, err := database.GetActiveInstancePorts(instance.ID)
 	if err != nil {
-		return fmt.Errorf("failed to find free port: %w", err)
+		return fmt.Errorf("failed to get active instance ports: %w", err)
 	}
-	log.Printf("Found free port: %d", port)
+	oldPorts := servingPorts(instance, activePorts)
+	count := instanceCount(config.AppConfig.Scale, oldPorts)
 
-	if err := startLocalInstance(app.Name, port, releasePath); err != nil {
-		return fmt.Errorf("failed to start new version: %w", err)
+	// The start command of shipyard.toml and the node names apply from this deployment on, as with SSH deployments
+	if startCmd := config.AppConfig.StartCommand(); startCmd != "" || clusterHost != "" {
+		log.Printf("⚙️ [Server] Rendering systemd unit with start command: %s", startCmd)
+		if output, err := exec.Command("sh", "-c", renderUnitCommand(app.Name, config.AppConfig.Runtime, config.ProcessWeb, startCmd, "phoenix", clusterHost)).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to render systemd unit: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	// Start new version
+	log.Printf("🌱 [Server] Starting new version (%d instance(s))", count)
+	var ports []int
+	stopNew := func() {
+		for _, port := range ports {
+			_ = stopLocalInstance(app.Name, port)
+			_ = exec.Command("systemctl", "disable", fmt.Sprintf("%s@%d", app.Name, port)).Run()
+			_ = os.Remove(filepath.Join(fmt.Sprintf("/var/www/%s/instances", app.Name), fmt.Sprintf("%d", port)))
+		}
+	}
+	for range count {
+		port, err := findFreePortLocally()
+		if err != nil {
+			stopNew()
+			return fmt.Errorf("failed to find free port: %w", err)
+		}
+		log.Printf("Found free port: %d", port)
+		if err := startLocalInstance(app.Name, port, releasePath); err != nil {
+			stopNew()
+			return fmt.Errorf("failed to start new version: %w", err)
+		}
+		ports = append(ports, port)
+	}
+
+	// Health check before switching traffic
+	log.Printf("💓 [Server] Health check")
+	for _, port := range ports {
+		unitName := fmt.Sprintf("%s@%d", app.Name, port)
+		probe := func(url string, timeout time.Duration) (int, string, error) {
+			if err := exec.Command("systemctl", "is-active", "--quiet", unitName).Run(); err != nil {
+				return 0, "", fmt.Errorf("systemd unit %s is not active", unitName)
+			}
+			return probeHTTPLocally(url, timeout)
+		}
+		results, err := runHealthProbes(context.Background(), port, config.AppConfig.HealthCheck, probe)
+		if recErr := database.AddDeploymentHealthChecks(deploymentID, results); recErr != nil {
+			log.Printf("⚠️  Warning: Failed to save health check results: %v", recErr)
+		}
+		if err != nil {
+			stopNew()
+			return fmt.Errorf("health check failed: %w", err)
+		}
 	}
 
 	// Update deployment history status
-	if err := database.RecordSuccessfulDeployment(deploymentID, port, releasePath, ""); err != nil {
+	if err := database.RecordSuccessfulDeployment(deploymentID, ports, releasePath, ""); err != nil {
 		return fmt.Errorf("failed to update deployment status: %w", err)
 	}
 
 	// Switch traffic via Caddy
-	log.Printf("🔄 [Server] Switching traffic to port %d", port)
+	log.Printf("🔄 [Server] Switching traffic to port %s", formatPorts(ports))
 	caddySvc := caddy.NewLocalService()
 	
 	// Get domains from config
@@ -127,44 +213,95 @@ func ExecuteServerSideDeployment(deploymentIDStr, appName, version string) error
 		log.Println("⚠️  Warning: No domains configured in shipyard.toml")
 	}
 
+	// The old instances stay among Caddy's upstreams until drained
+	holdUpstreams(caddySvc, oldPorts)
 	if len(domains) > 0 {
-		if err := caddySvc.UpdateReverseProxyMultiDomain(domains, port); err != nil {
+		if err := caddySvc.UpdateLoadBalancedReverseProxy(domains, ports); err != nil {
 			log.Printf("⚠️  Warning: Failed to update Caddy routes: %v", err)
 		} else {
-			log.Printf("✅ [Server] Updated Caddy routes for %d domains to port %d", len(domains), port)
+			log.Printf("✅ [Server] Updated Caddy routes for %d domains to port %s", len(domains), formatPorts(ports))
 		}
 	} else {
 		log.Println("⚠️  Warning: No domains configured, skipping traffic switching")
 	}
 
+	if err := deployServerSideProcesses(app.Name, config.AppConfig.Runtime, host.Name, releasePath, clusterHost); err != nil {
+		return err
+	}
+
 	// Handle old version cleanup
-	if instance.ActivePort.Valid && instance.ActivePort.Int64 > 0 {
-		oldPort := int(instance.ActivePort.Int64)
+	for _, oldPort := range oldPorts {
+		if summary := drainOldVersion(context.Background(), caddySvc, oldPort); summary != "" {
+			_ = database.AppendDeploymentHistoryOutput(deploymentID, summary+"\n")
+		}
 		log.Printf("🔄 [Server] Stopping old version on port %d", oldPort)
 		if err := stopLocalInstance(app.Name, oldPort); err != nil {
 			log.Printf("⚠️  Warning: Failed to stop old version: %v", err)
 		}
 	}
 
+	if pruned, err := PruneReleases(instance.ID, config.AppConfig.KeepReleases, false); err != nil {
+		log.Printf("⚠️  Warning: Failed to prune old releases: %v", err)
+	} else if len(pruned) > 0 {
+		_ = database.AppendDeploymentHistoryOutput(deploymentID, fmt.Sprintf("Pruned %d old releases\n", len(pruned)))
+	}
+
 	log.Printf("✅ [Server] Server-side deployment completed successfully")
 	return nil
 }
 
-// extractTarGz extracts a tar.gz file to the target directory
-func extractTarGz(tarPath, targetDir string) error {
+// deployServerSideProcesses runs the other process types of [processes] and the jobs of [[cron]] on the
+// new release, as with SSH deployments to hostName. The Erlang nodes of the processes are named on nodeHost.
+func deployServerSideProcesses(appName, runtime, hostName, releasePath, nodeHost string) error {
+	local := &Deployer{AppName: appName, HostName: hostName, Runtime: runtime, IsLocalhost: true}
+	if err := local.deployProcesses(releasePath, nodeHost); err != nil {
+		return err
+	}
+	linked, err := database.GetLinkedHostsForApp(appName)
+	if err != nil {
+		return fmt.Errorf("failed to get the hosts of the app: %w", err)
+	}
+	local.linkedHosts = hostNames(linked)
+	return local.deployCron(releasePath)
+}
+
+// verifyServerArtifact checks that an uploaded artifact is the registered build md5Hash of an application
+// and that its signature verifies against the keys the application trusts. It returns the registered
+// artifact and the name of the key that verifies it.
+func verifyServerArtifact(appID uuid.UUID, artifactPath, md5Hash string) (*models.BuildArtifact, string, error) {
+	if md5Hash == "" {
+		return nil, "", fmt.Errorf("%w: no build MD5 given for the uploaded artifact", signing.ErrUnsigned)
+	}
+	artifact, err := database.GetBuildArtifactByMD5(appID, md5Hash)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", signing.ErrUnsigned, err)
+	}
+	trusted, err := database.GetTrustedSigningKeys(appID)
+	if err != nil {
+		return nil, "", err
+	}
+	keyName, err := signing.VerifyFile(trusted, appID.String(), artifactPath, artifact.SHA256, artifact.Signature)
+	if err != nil {
+		return nil, "", err
+	}
+	return artifact, keyName, nil
+}
+
+// extractTarball extracts a tarball compressed in the given format to the target directory
+func extractTarball(tarPath, targetDir, format string) error {
 	file, err := os.Open(tarPath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	gzr, err := gzip.NewReader(file)
+	cr, err := compression.NewReader(file, format)
 	if err != nil {
 		return err
 	}
-	defer gzr.Close()
+	defer cr.Close()
 
-	tr := tar.NewReader(gzr)
+	tr := tar.NewReader(cr)
 
 	for {
 		header, err := tr.Next()
@@ -176,6 +313,14 @@ func extractTarGz(tarPath, targetDir string) error {
 		}
 
 		target := filepath.Join(targetDir, header.Name)
+		// Uploaded sources are extracted too, refuse entries outside the target directory
+		if rel, err := filepath.Rel(targetDir, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in archive: %s", header.Name)
+		}
+		// Links are extracted as they are, nothing may be written through one
+		if linked, err := throughSymlink(targetDir, target); err != nil || linked {
+			return fmt.Errorf("invalid path in archive (through a symlink): %s", header.Name)
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
@@ -195,12 +340,45 @@ func extractTarGz(tarPath, targetDir string) error {
 				return err
 			}
 			f.Close()
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// throughSymlink reports whether target, or a directory between dir and target, is a symlink.
+func throughSymlink(dir, target string) (bool, error) {
+	rel, err := filepath.Rel(dir, target)
+	if err != nil {
+		return false, err
+	}
+	current := dir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part == "." {
+			continue
+		}
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // setExecutablePermissions sets execute permissions for binaries
 func setExecutablePermissions(releasePath, appName string) error {
 	// For Phoenix/Elixir apps
@@ -229,7 +407,7 @@ func executeLocalHooks(releasePath, hookName string, hooks []config.Hook) error
 		log.Printf("🪝 Executing %s hook [%d/%d]: %s", hookName, i+1, len(hooks), cmdStr)
 		cmd := exec.Command("bash", "-c", cmdStr)
 		cmd.Dir = releasePath
-		cmd.Stdout = os.Stdout
+		cmd.Stdout = Output
 		cmd.Stderr = os.Stderr
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("hook command failed: %w", err)
@@ -0,0 +1,88 @@
+package deploy
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"youfun/shipyard/internal/compression"
+	"youfun/shipyard/internal/config"
+	"youfun/shipyard/internal/static"
+)
+
+func TestReleaseTarballSymlinks(t *testing.T) {
+	// A vendored virtualenv links to the python3 of the host
+	source := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(source, "venv", "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "main.py"), []byte("app = None\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/usr/bin/python3", filepath.Join(source, "venv", "bin", "python3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("python3", filepath.Join(source, "venv", "bin", "python")); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball, err := (&Deployer{}).createTarball(source, "test-release", compression.Gzip)
+	if err != nil {
+		t.Fatalf("createTarball() failed: %v", err)
+	}
+	defer os.Remove(tarball)
+
+	target := t.TempDir()
+	if err := extractTarball(tarball, target, compression.Gzip); err != nil {
+		t.Fatalf("extractTarball() failed: %v", err)
+	}
+	for name, want := range map[string]string{"venv/bin/python3": "/usr/bin/python3", "venv/bin/python": "python3"} {
+		if link, err := os.Readlink(filepath.Join(target, name)); err != nil || link != want {
+			t.Errorf("%s links to %q (%v), want %q", name, link, err, want)
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(target, "main.py")); err != nil || string(content) != "app = None\n" {
+		t.Errorf("unexpected main.py: %q, %v", content, err)
+	}
+}
+
+func TestExtractTarballRefusesWritesThroughSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	tarball := writeTestTarball(t, compression.Gzip, map[string]string{"bin/server": "#!/bin/sh\n"})
+
+	target := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(target, "bin")); err != nil {
+		t.Fatal(err)
+	}
+	if err := extractTarball(tarball, target, compression.Gzip); err == nil {
+		t.Error("expected a file written through a symlink to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "server")); !os.IsNotExist(err) {
+		t.Error("expected nothing to be written outside the target directory")
+	}
+}
+
+func TestDeployServerSideProcesses(t *testing.T) {
+	if _, err := exec.LookPath("base64"); err != nil {
+		t.Skip("base64 not available")
+	}
+	defer func(cfg config.Config) { config.AppConfig = cfg }(config.AppConfig)
+	config.AppConfig = config.Config{Processes: map[string]config.Process{
+		"web":    {Command: "bin/shop start"},
+		"worker": {Command: "bin/shop eval Shop.Worker.run", Count: 2},
+	}}
+	// The rendered unit records what it was given and fails, so no process is started
+	defer func(script string) { static.RenderUnitScript = script }(static.RenderUnitScript)
+	rendered := filepath.Join(t.TempDir(), "rendered")
+	static.RenderUnitScript = "printf '%s|%s' \"$PROCESS\" \"$NODE_HOST\" > '" + rendered + "'; exit 3"
+
+	// Server-side deployments run without an SSH host, the node host comes from the instance
+	err := deployServerSideProcesses("shipyard_test_app", "phoenix", "web-1", t.TempDir(), "10.0.0.1")
+	if err == nil || !strings.Contains(err.Error(), "failed to render systemd unit of process worker") {
+		t.Fatalf("expected the failing unit to stop the deployment, got %v", err)
+	}
+	if got, _ := os.ReadFile(rendered); string(got) != "worker|10.0.0.1" {
+		t.Errorf("unit rendered with %q, want worker|10.0.0.1", got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"youfun/shipyard/internal/caddy"
+	"youfun/shipyard/internal/cliutils"
 	"youfun/shipyard/internal/config"
 	"youfun/shipyard/internal/database"
 	"youfun/shipyard/internal/models"
@@ -48,6 +49,15 @@ func (d *Deployer) setup() error {
 	if d.Runtime == "" {
 		d.Runtime = d.detectRuntime()
 	}
+	if err := config.AppConfig.ValidateRun(); err != nil {
+		return &ConfigError{Err: err}
+	}
+	if err := config.AppConfig.ValidateCron(); err != nil {
+		return &ConfigError{Err: err}
+	}
+	if err := config.AppConfig.ValidateUpload(); err != nil {
+		return &ConfigError{Err: err}
+	}
 
 	// Display domain information
 	domains := config.AppConfig.Domains
@@ -98,7 +108,10 @@ func (d *Deployer) handleInstanceLoadingError(err error) error {
 			return fmt.Errorf("Application '%s' is not registered in the database. Please run 'shipyard init' to sync it from '%s' to the database", d.AppName, config.ConfigPath)
 		}
 	} else if errors.Is(err, database.ErrInstanceNotFound) {
-		fmt.Printf("Application '%s' is not linked to host '%s'. Link it now? (y/n): ", d.AppName, d.HostName)
+		if cliutils.IsCI() {
+			return fmt.Errorf("Application '%s' is not linked to host '%s'. Link it with 'shipyard-cli launch' before deploying in CI mode", d.AppName, d.HostName)
+		}
+		fmt.Fprintf(Output, "Application '%s' is not linked to host '%s'. Link it now? (y/n): ", d.AppName, d.HostName)
 		reader := bufio.NewReader(os.Stdin)
 		input, _ := reader.ReadString('\n')
 		input = strings.ToLower(strings.TrimSpace(input))
@@ -130,14 +143,17 @@ func (d *Deployer) handleInstanceLoadingError(err error) error {
 
 // initializeHostInteractively prompts the user to initialize an uninitialized host.
 func (d *Deployer) initializeHostInteractively() error {
-	fmt.Printf("Host '%s' is not initialized. Initialize it now? (y/n): ", d.HostName)
+	if cliutils.IsCI() {
+		return fmt.Errorf("Host '%s' is not initialized. Initialize it before deploying in CI mode", d.HostName)
+	}
+	fmt.Fprintf(Output, "Host '%s' is not initialized. Initialize it now? (y/n): ", d.HostName)
 	reader := bufio.NewReader(os.Stdin)
 	input, _ := reader.ReadString('\n')
 	input = strings.ToLower(strings.TrimSpace(input))
 
 	if input == "y" || input == "yes" {
 		log.Printf("Initializing host '%s'...", d.HostName)
-		if err := InitializeHost(d.Host, d.Application.Name, d.detectRuntime(), "", "phoenix", d.HostKeyCallback); err != nil {
+		if err := InitializeHost(d.Host, d.Application.Name, d.Runtime, config.AppConfig.StartCommand(), "phoenix", d.HostKeyCallback); err != nil {
 			return fmt.Errorf("automatic initialization failed: %w", err)
 		}
 		// Refresh host info to get the initialization timestamp
@@ -170,6 +186,12 @@ func (d *Deployer) detectRuntime() string {
 	if _, err := os.Stat("go.mod"); err == nil {
 		return "golang"
 	}
+	if _, err := os.Stat("pyproject.toml"); err == nil {
+		return "python"
+	}
+	if _, err := os.Stat("requirements.txt"); err == nil {
+		return "python"
+	}
 	// Check for static HTML files (index.html or a dist/build directory with index.html)
 	if isStaticProject() {
 		return "static"
@@ -0,0 +1,81 @@
+package deploy
+
+import (
+	"fmt"
+	"log"
+	"os/user"
+	"youfun/shipyard/internal/database"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/internal/signing"
+	"youfun/shipyard/pkg/types"
+)
+
+// artifactSignature is the signature shipyard-server made for the artifact of a deployment.
+type artifactSignature struct {
+	sha256     string
+	value      string
+	signedBy   string // User or application token the artifact was signed for
+	trustedKey string // Name of the trusted key that verified the signature, set once verified
+}
+
+func signatureOfDTO(artifact *types.BuildArtifactDTO) artifactSignature {
+	return artifactSignature{sha256: artifact.SHA256, value: artifact.Signature, signedBy: artifact.SignedBy}
+}
+
+func signatureOfModel(artifact *models.BuildArtifact) artifactSignature {
+	return artifactSignature{sha256: artifact.SHA256, value: artifact.Signature, signedBy: artifact.SignedBy}
+}
+
+// trustedKeysFromDTOs parses the trusted keys sent with the deploy config.
+func trustedKeysFromDTOs(dtos []types.TrustedKeyDTO) ([]signing.TrustedKey, error) {
+	keys := make([]signing.TrustedKey, 0, len(dtos))
+	for _, dto := range dtos {
+		pub, err := signing.ParsePublicKey(dto.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("trusted key '%s': %w", dto.Name, err)
+		}
+		keys = append(keys, signing.TrustedKey{Name: dto.Name, PublicKey: pub})
+	}
+	return keys, nil
+}
+
+// verifyArtifact checks that a tarball is the artifact shipyard-server signed, with a key the application
+// trusts. On success the signature becomes the one of the deployment.
+func (d *Deployer) verifyArtifact(tarballPath string, sig artifactSignature) error {
+	keys := d.trustedKeys
+	if d.APIClient == nil {
+		var err error
+		if keys, err = database.GetTrustedSigningKeys(d.Application.ID); err != nil {
+			return err
+		}
+	}
+
+	keyName, err := signing.VerifyFile(keys, d.Application.ID.String(), tarballPath, sig.sha256, sig.value)
+	if err != nil {
+		return err
+	}
+	sig.trustedKey = keyName
+	d.signature = sig
+	log.Printf("🔏 Artifact signature verified (key: %s, signed for: %s)", keyName, sig.signedBy)
+	return nil
+}
+
+// signArtifact signs an artifact built without shipyard-server (legacy mode) with the active signing key
+// of the database, which is the key the server would have used.
+func signArtifact(artifact *models.BuildArtifact, tarballPath string) error {
+	key, privateKey, err := database.GetActiveSigningKey()
+	if err != nil {
+		return fmt.Errorf("failed to get signing key: %w", err)
+	}
+	sum, err := signing.FileSHA256(tarballPath)
+	if err != nil {
+		return err
+	}
+	artifact.SHA256 = sum
+	artifact.Signature = signing.Sign(privateKey, artifact.ApplicationID.String(), sum)
+	artifact.SigningKey = key.Name
+	if u, err := user.Current(); err == nil {
+		artifact.SignedBy = "local:" + u.Username
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one pattern of a .gitignore or .dockerignore file.
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool // "!pattern" includes again what an earlier rule excluded
+	dirOnly bool // "pattern/" only matches directories
+}
+
+// ignoreRules decides which files of the project are left out of the sources sent to a remote build.
+type ignoreRules []ignoreRule
+
+// loadIgnoreRules reads the .gitignore and .dockerignore files at the root of the project.
+// Patterns of .dockerignore are relative to the root, as Docker reads them.
+func loadIgnoreRules(dir string) (ignoreRules, error) {
+	var rules ignoreRules
+	for _, file := range []struct {
+		name     string
+		anchored bool
+	}{{".gitignore", false}, {".dockerignore", true}} {
+		f, err := os.Open(filepath.Join(dir, file.name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.name, err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(scanner.Text(), file.anchored); ok {
+				rules = append(rules, rule)
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.name, err)
+		}
+	}
+	return rules, nil
+}
+
+// parseIgnoreRule parses a line of an ignore file. Blank lines and comments yield no rule.
+// A pattern without a slash matches at any depth unless anchored is set.
+func parseIgnoreRule(line string, anchored bool) (ignoreRule, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.HasPrefix(line, "/") || strings.Contains(line, "/") {
+		anchored = true
+	}
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+	pattern, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.pattern = pattern
+	return rule, true
+}
+
+// globToRegexp translates a glob with "**" support into a regular expression.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				b.WriteString("(.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			if end := strings.IndexByte(glob[i:], ']'); end > 0 {
+				b.WriteString(glob[i : i+end+1])
+				i += end
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// ignored reports whether a file of the project, given by its slash-separated path, is left out.
+// The last matching rule wins.
+func (rules ignoreRules) ignored(path string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.pattern.MatchString(path) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// packSource packs the project in dir into a tar.gz for a build on shipyard-server or a builder host.
+// The .git directory and everything matched by .gitignore or .dockerignore are left out.
+func packSource(dir string) (string, error) {
+	rules, err := loadIgnoreRules(dir)
+	if err != nil {
+		return "", err
+	}
+
+	tarball, err := os.CreateTemp("", "shipyard-source-*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("failed to create source archive: %w", err)
+	}
+	defer tarball.Close()
+
+	gzipWriter := gzip.NewWriter(tarball)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPath == ".git" || rules.ignored(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Sockets, devices and symlinks are not part of a build context
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = relPath
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err == nil {
+		err = tarWriter.Close()
+	}
+	if err == nil {
+		err = gzipWriter.Close()
+	}
+	if err != nil {
+		os.Remove(tarball.Name())
+		return "", fmt.Errorf("failed to pack sources: %w", err)
+	}
+	return tarball.Name(), nil
+}
@@ -0,0 +1,107 @@
+package deploy
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	var rules ignoreRules
+	for _, line := range []string{"# build output", "_build/", "*.log", "!keep.log", "/secret.env", "assets/**/*.map", ""} {
+		if rule, ok := parseIgnoreRule(line, false); ok {
+			rules = append(rules, rule)
+		}
+	}
+	dockerRule, _ := parseIgnoreRule("tmp", true)
+	rules = append(rules, dockerRule)
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"_build", true, true},
+		{"apps/web/_build", true, true},
+		{"_build", false, false}, // "_build/" only matches directories
+		{"debug.log", false, true},
+		{"logs/debug.log", false, true},
+		{"keep.log", false, false},
+		{"secret.env", false, true},
+		{"config/secret.env", false, false}, // anchored to the root
+		{"assets/js/app.js.map", false, true},
+		{"assets/app.js.map", false, true},
+		{"assets/app.js", false, false},
+		{"tmp", true, true},
+		{"lib/tmp", true, false}, // .dockerignore patterns are relative to the root
+		{"mix.exs", false, false},
+	}
+	for _, tt := range tests {
+		if got := rules.ignored(tt.path, tt.isDir); got != tt.ignored {
+			t.Errorf("ignored(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.ignored)
+		}
+	}
+}
+
+func TestPackSource(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore":            "deps/\nnode_modules\n",
+		".dockerignore":         "test\n",
+		".git/HEAD":             "ref: refs/heads/main\n",
+		"mix.exs":               "defmodule MyApp.MixProject do\nend\n",
+		"lib/my_app.ex":         "defmodule MyApp do\nend\n",
+		"deps/phoenix/mix.exs":  "",
+		"assets/node_modules/x": "",
+		"test/my_app_test.exs":  "",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tarball, err := packSource(dir)
+	if err != nil {
+		t.Fatalf("packSource() failed: %v", err)
+	}
+	defer os.Remove(tarball)
+
+	f, err := os.Open(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packed []string
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			packed = append(packed, header.Name)
+		}
+	}
+	slices.Sort(packed)
+
+	want := []string{".dockerignore", ".gitignore", "lib/my_app.ex", "mix.exs"}
+	if !slices.Equal(packed, want) {
+		t.Errorf("packed %v, want %v", packed, want)
+	}
+}
@@ -164,7 +164,10 @@ func TestFindStaticSourceDir(t *testing.T) {
 
 			// Test
 			d := &Deployer{}
-			result := d.findStaticSourceDir()
+			result, err := d.findStaticSourceDir()
+			if err != nil {
+				t.Fatalf("findStaticSourceDir() failed: %v", err)
+			}
 			if result != tt.expected {
 				t.Errorf("findStaticSourceDir() = %v, want %v", result, tt.expected)
 			}
@@ -329,7 +332,10 @@ func TestBuildStaticRelease(t *testing.T) {
 
 	// Test buildStaticRelease
 	d := &Deployer{}
-	buildDir := d.buildStaticRelease()
+	buildDir, err := d.buildStaticRelease()
+	if err != nil {
+		t.Fatalf("buildStaticRelease() failed: %v", err)
+	}
 	defer os.RemoveAll(buildDir)
 
 	// Verify that the release directory was created with correct structure
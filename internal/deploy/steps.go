@@ -0,0 +1,172 @@
+package deploy
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/pkg/types"
+)
+
+// deployStep is a named step of a deployment through the API.
+// Its status and timing are recorded on the server so that a failed deployment can be resumed.
+type deployStep struct {
+	name    string
+	run     func() error
+	detail  func() string             // What later steps need from this step, recorded when it succeeds
+	restore func(detail string) error // Restores the state of the completed step when resuming after it
+}
+
+// runSteps runs the steps of a deployment in order and records their outcome.
+// When resuming, the steps completed by the resumed deployment are restored instead of run again,
+// up to the first incomplete one; every step after it runs again.
+func (d *Deployer) runSteps(apiClient client.APIClient, steps []deployStep) error {
+	for _, step := range steps {
+		if recorded, ok := d.resumeSteps[step.name]; ok && recorded.Status == models.StepStatusSuccess {
+			if step.restore != nil {
+				if err := step.restore(recorded.Detail); err != nil {
+					return &StepError{Step: step.name, Err: fmt.Errorf("cannot resume after step %s: %w", step.name, err)}
+				}
+			}
+			log.Printf("⏭️  Step %s already completed, skipping", step.name)
+			continue
+		}
+		d.resumeSteps = nil
+
+		if err := d.checkCancelled(); err != nil {
+			return err
+		}
+
+		position := slices.Index(models.DeploySteps, step.name) + 1
+		log.Println("---", fmt.Sprintf("Step %d/%d: %s", position, len(models.DeploySteps), step.name), "---")
+		start := time.Now()
+		record := types.DeploymentStepDTO{Name: step.name, Position: position, Status: models.StepStatusRunning, StartedAt: &start}
+		d.reportStep(apiClient, record)
+		d.emit(Event{Event: EventStepStarted, Step: step.name})
+
+		err := step.run()
+		finished := time.Now()
+		record.FinishedAt = &finished
+		record.DurationMs = finished.Sub(start).Milliseconds()
+		if err != nil {
+			record.Status = models.StepStatusFailed
+			record.Error = err.Error()
+		} else {
+			record.Status = models.StepStatusSuccess
+			if step.detail != nil {
+				record.Detail = step.detail()
+			}
+		}
+		d.reportStep(apiClient, record)
+		d.emit(Event{Event: EventStepFinished, Step: step.name, Status: record.Status, DurationMs: record.DurationMs, Error: record.Error})
+		if err != nil {
+			return &StepError{Step: step.name, Err: err}
+		}
+	}
+	return nil
+}
+
+// reportStep records the state of a step on the server. The artifact is built before the
+// deployment record exists, so its step is held back until the record has been created.
+func (d *Deployer) reportStep(apiClient client.APIClient, step types.DeploymentStepDTO) {
+	d.unreportedSteps = append(d.unreportedSteps, step)
+	if d.DeploymentID == "" {
+		return
+	}
+	for i := range d.unreportedSteps {
+		if err := apiClient.UpdateDeploymentStep(d.DeploymentID, &d.unreportedSteps[i]); err != nil {
+			log.Printf("⚠️  Warning: Failed to record step %s: %v", d.unreportedSteps[i].Name, err)
+		}
+	}
+	d.unreportedSteps = nil
+}
+
+// revertStep records that a completed step has been undone, so a resumed deployment runs it again.
+func (d *Deployer) revertStep(apiClient client.APIClient, name, reason string) {
+	now := time.Now()
+	d.reportStep(apiClient, types.DeploymentStepDTO{
+		Name:       name,
+		Position:   slices.Index(models.DeploySteps, name) + 1,
+		Status:     models.StepStatusReverted,
+		Error:      reason,
+		FinishedAt: &now,
+	})
+}
+
+// resumeDeployment takes up a failed deployment again through the API, which hands out the instance lock.
+// The host and the steps to skip are taken from the resumed deployment.
+func (d *Deployer) resumeDeployment(apiClient client.APIClient, deploymentID string) error {
+	resumed, err := apiClient.ResumeDeployment(deploymentID)
+	if err != nil {
+		return fmt.Errorf("failed to resume deployment %s: %w", deploymentID, err)
+	}
+	d.DeploymentID = resumed.DeploymentID
+	d.startLockHeartbeat(apiClient)
+
+	if resumed.AppName != d.AppName {
+		return &ConfigError{Err: fmt.Errorf("deployment %s belongs to app '%s', not '%s'", deploymentID, resumed.AppName, d.AppName)}
+	}
+	if d.HostName != "" && d.HostName != resumed.HostName {
+		return &ConfigError{Err: fmt.Errorf("deployment %s ran on host '%s', not '%s'", deploymentID, resumed.HostName, d.HostName)}
+	}
+	d.HostName = resumed.HostName
+	d.IsLocalhost = isLocalhostName(d.HostName)
+	if d.IsLocalhost {
+		return &ConfigError{Err: fmt.Errorf("--resume is not supported for deployments to the server itself")}
+	}
+
+	d.resumeSteps = make(map[string]types.DeploymentStepDTO, len(resumed.Steps))
+	var completed []string
+	for _, step := range resumed.Steps {
+		d.resumeSteps[step.Name] = step
+		if step.Status == models.StepStatusSuccess {
+			completed = append(completed, step.Name)
+		}
+	}
+	log.Printf("⏯️  Resuming deployment %s of version %s on %s (completed steps: %s)", deploymentID, resumed.Version, d.HostName, strings.Join(completed, ", "))
+	return nil
+}
+
+// restoreArtifact picks up the artifact built for the resumed deployment again.
+func (d *Deployer) restoreArtifact(md5Hash string) error {
+	if md5Hash == "" {
+		return fmt.Errorf("no build artifact was recorded")
+	}
+	if err := d.findAndReuseArtifact(md5Hash, true); err != nil {
+		return fmt.Errorf("build artifact %s is no longer available, start a new deployment", md5Hash)
+	}
+	return nil
+}
+
+// restoreRelease checks that the release directory uploaded by the resumed deployment is still on the host.
+func (d *Deployer) restoreRelease(releasePath string) error {
+	if releasePath == "" {
+		return fmt.Errorf("no release directory was recorded")
+	}
+	if err := d.executeRemoteCommand(fmt.Sprintf("test -d %s", shellQuote(releasePath)), false); err != nil {
+		return fmt.Errorf("release directory %s no longer exists on the host, start a new deployment", releasePath)
+	}
+	d.CurrentReleasePath = releasePath
+	d.undo.releasePath = releasePath
+	return nil
+}
+
+// restoreStart picks up the ports the resumed deployment started the new version on.
+func (d *Deployer) restoreStart(detail string) error {
+	ports, err := parsePorts(detail)
+	if err != nil || len(ports) == 0 {
+		return fmt.Errorf("invalid port of the new version: %q", detail)
+	}
+	d.greenPorts = ports
+	d.greenPort = ports[0]
+	d.undo.ports = ports
+	return nil
+}
+
+// isLocalhostName reports whether a host name designates the server machine itself.
+func isLocalhostName(hostName string) bool {
+	return hostName == "localhost" || hostName == "127.0.0.1" || hostName == "local"
+}
@@ -0,0 +1,142 @@
+package deploy
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"youfun/shipyard/internal/client"
+	"youfun/shipyard/internal/models"
+	"youfun/shipyard/pkg/types"
+)
+
+// stepsTestClient records the steps reported through the API
+type stepsTestClient struct {
+	client.APIClient
+	reported []types.DeploymentStepDTO
+}
+
+func (c *stepsTestClient) UpdateDeploymentStep(deploymentID string, step *types.DeploymentStepDTO) error {
+	c.reported = append(c.reported, *step)
+	return nil
+}
+
+func (c *stepsTestClient) statuses() []string {
+	var statuses []string
+	for _, step := range c.reported {
+		statuses = append(statuses, step.Name+":"+step.Status)
+	}
+	return statuses
+}
+
+func TestRunStepsReportsOutcome(t *testing.T) {
+	api := &stepsTestClient{}
+	d := &Deployer{AppName: "my_app", DeploymentID: "dpl_test"}
+
+	var ran []string
+	steps := []deployStep{
+		{name: models.DeployStepUpload, run: func() error { ran = append(ran, "upload"); return nil }, detail: func() string { return "/var/www/my_app/releases/1.0.0-1" }},
+		{name: models.DeployStepMigrate, run: func() error { ran = append(ran, "migrate"); return errors.New("boom") }},
+		{name: models.DeployStepStart, run: func() error { ran = append(ran, "start"); return nil }},
+	}
+
+	if err := d.runSteps(api, steps); err == nil || err.Error() != "boom" {
+		t.Fatalf("expected the migrate error, got %v", err)
+	}
+	if !slices.Equal(ran, []string{"upload", "migrate"}) {
+		t.Errorf("ran %v, expected to stop at the failing step", ran)
+	}
+	want := []string{"upload:running", "upload:success", "migrate:running", "migrate:failed"}
+	if !slices.Equal(api.statuses(), want) {
+		t.Errorf("reported %v, want %v", api.statuses(), want)
+	}
+
+	upload, migrate := api.reported[1], api.reported[3]
+	if upload.Position != 2 || upload.Detail != "/var/www/my_app/releases/1.0.0-1" || upload.FinishedAt == nil {
+		t.Errorf("unexpected upload step: %+v", upload)
+	}
+	if migrate.Position != 6 || migrate.Error != "boom" {
+		t.Errorf("unexpected migrate step: %+v", migrate)
+	}
+}
+
+func TestRunStepsHoldsBackStepsUntilDeploymentExists(t *testing.T) {
+	api := &stepsTestClient{}
+	d := &Deployer{AppName: "my_app"}
+
+	artifact := []deployStep{{name: models.DeployStepArtifact, run: func() error { return nil }}}
+	if err := d.runSteps(api, artifact); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(api.reported) != 0 {
+		t.Fatalf("expected no steps to be reported without a deployment, got %v", api.statuses())
+	}
+
+	d.DeploymentID = "dpl_test"
+	upload := []deployStep{{name: models.DeployStepUpload, run: func() error { return nil }}}
+	if err := d.runSteps(api, upload); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"artifact:running", "artifact:success", "upload:running", "upload:success"}
+	if !slices.Equal(api.statuses(), want) {
+		t.Errorf("reported %v, want %v", api.statuses(), want)
+	}
+}
+
+func TestRunStepsResumesAfterCompletedSteps(t *testing.T) {
+	api := &stepsTestClient{}
+	d := &Deployer{
+		AppName:      "my_app",
+		DeploymentID: "dpl_test",
+		resumeSteps: map[string]types.DeploymentStepDTO{
+			models.DeployStepUpload:  {Name: models.DeployStepUpload, Status: models.StepStatusSuccess, Detail: "/releases/1.0.0-1"},
+			models.DeployStepEnv:     {Name: models.DeployStepEnv, Status: models.StepStatusFailed},
+			models.DeployStepMigrate: {Name: models.DeployStepMigrate, Status: models.StepStatusSuccess},
+		},
+	}
+
+	var ran []string
+	var restored string
+	run := func(name string) func() error {
+		return func() error { ran = append(ran, name); return nil }
+	}
+	steps := []deployStep{
+		{name: models.DeployStepUpload, run: run("upload"), restore: func(detail string) error { restored = detail; return nil }},
+		{name: models.DeployStepEnv, run: run("env")},
+		{name: models.DeployStepMigrate, run: run("migrate")},
+	}
+
+	if err := d.runSteps(api, steps); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restored != "/releases/1.0.0-1" {
+		t.Errorf("expected the upload step to be restored, got %q", restored)
+	}
+	// Steps after the first incomplete one run again, even when they completed before
+	if !slices.Equal(ran, []string{"env", "migrate"}) {
+		t.Errorf("ran %v, want [env migrate]", ran)
+	}
+}
+
+func TestRunStepsFailsWhenRestoreFails(t *testing.T) {
+	api := &stepsTestClient{}
+	d := &Deployer{
+		AppName:      "my_app",
+		DeploymentID: "dpl_test",
+		resumeSteps: map[string]types.DeploymentStepDTO{
+			models.DeployStepArtifact: {Name: models.DeployStepArtifact, Status: models.StepStatusSuccess},
+		},
+	}
+
+	ran := false
+	steps := []deployStep{{
+		name:    models.DeployStepArtifact,
+		run:     func() error { ran = true; return nil },
+		restore: d.restoreArtifact,
+	}}
+	if err := d.runSteps(api, steps); err == nil {
+		t.Fatal("expected resuming without a recorded artifact to fail")
+	}
+	if ran {
+		t.Error("expected the completed step not to run again")
+	}
+}
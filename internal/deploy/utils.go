@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // calculateMD5 calculates the MD5 checksum of a file.
@@ -56,3 +58,37 @@ func moveFile(src, dst string) error {
 	}
 	return os.Remove(src)
 }
+
+// shellQuote wraps a string in single quotes so it is passed to the remote shell verbatim.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// formatPorts records the ports of the instances of a release in the detail of a deployment step,
+// e.g. "4001,4002", or "0" for none.
+func formatPorts(ports []int) string {
+	if len(ports) == 0 {
+		return "0"
+	}
+	parts := make([]string, len(ports))
+	for i, port := range ports {
+		parts[i] = strconv.Itoa(port)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parsePorts parses the ports recorded by formatPorts, a single port as recorded by earlier versions included.
+func parsePorts(detail string) ([]int, error) {
+	if detail == "0" {
+		return nil, nil
+	}
+	var ports []int
+	for _, part := range strings.Split(detail, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || port <= 0 {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
@@ -139,3 +139,25 @@ func TestMoveFile_SourceNotFound(t *testing.T) {
 		t.Error("moveFile() expected error for non-existent source, got nil")
 	}
 }
+
+func TestFormatParsePorts(t *testing.T) {
+	if got := formatPorts(nil); got != "0" {
+		t.Errorf("formatPorts(nil) = %q, want \"0\"", got)
+	}
+	detail := formatPorts([]int{4001, 4002})
+	if detail != "4001,4002" {
+		t.Errorf("formatPorts() = %q", detail)
+	}
+	ports, err := parsePorts(detail)
+	if err != nil || len(ports) != 2 || ports[0] != 4001 || ports[1] != 4002 {
+		t.Errorf("parsePorts(%q) = %v, %v", detail, ports, err)
+	}
+	if ports, err := parsePorts("0"); err != nil || ports != nil {
+		t.Errorf("parsePorts(\"0\") = %v, %v", ports, err)
+	}
+	for _, detail := range []string{"", "4001,x", "-1"} {
+		if _, err := parsePorts(detail); err == nil {
+			t.Errorf("parsePorts(%q) expected error", detail)
+		}
+	}
+}
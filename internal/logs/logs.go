@@ -39,6 +39,23 @@ func connectSSH(host *models.SSHHost, hostKeyCallback ssh.HostKeyCallback) (*ssh
 //
 // Returns: Log content string or error
 func FetchJournalLogs(host *models.SSHHost, appName string, port int, lines int, follow bool, hostKeyCallback ssh.HostKeyCallback) (string, error) {
+	// Use <app-name>@<port> format for systemd template unit
+	unitName := fmt.Sprintf("%s@%d", appName, port)
+	return fetchUnitLogs(host, unitName, lines, follow, hostKeyCallback)
+}
+
+// FetchProcessLogs returns the logs of all instances of a process type besides web, whose units are
+// <app-name>-<process>@<index>.
+func FetchProcessLogs(host *models.SSHHost, appName, process string, lines int, hostKeyCallback ssh.HostKeyCallback) (string, error) {
+	return fetchUnitLogs(host, ProcessUnitPattern(appName, process), lines, false, hostKeyCallback)
+}
+
+// ProcessUnitPattern returns the journalctl unit pattern matching all instances of a process type besides web.
+func ProcessUnitPattern(appName, process string) string {
+	return fmt.Sprintf("'%s-%s@*'", appName, process)
+}
+
+func fetchUnitLogs(host *models.SSHHost, unitName string, lines int, follow bool, hostKeyCallback ssh.HostKeyCallback) (string, error) {
 	// Establish SSH connection
 	client, err := connectSSH(host, hostKeyCallback)
 	if err != nil {
@@ -47,8 +64,6 @@ func FetchJournalLogs(host *models.SSHHost, appName string, port int, lines int,
 	defer client.Close()
 
 	// Build journalctl command
-	// Use <app-name>@<port> format for systemd template unit
-	unitName := fmt.Sprintf("%s@%d", appName, port)
 	cmd := fmt.Sprintf("journalctl -u %s -n %d --no-pager -o cat", unitName, lines)
 
 	if follow {
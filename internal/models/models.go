@@ -134,9 +134,17 @@ type ApplicationInstance struct {
 type DeploymentStatus string
 
 const (
-	DeploymentStatusPending DeploymentStatus = "pending"
-	DeploymentStatusSuccess DeploymentStatus = "success"
-	DeploymentStatusFailed  DeploymentStatus = "failed"
+	DeploymentStatusPending   DeploymentStatus = "pending"
+	DeploymentStatusSuccess   DeploymentStatus = "success"
+	DeploymentStatusFailed    DeploymentStatus = "failed"
+	DeploymentStatusCancelled DeploymentStatus = "cancelled"
+	DeploymentStatusCanary    DeploymentStatus = "canary" // Serving a share of the traffic next to the stable release
+)
+
+// Deployment kinds recorded in deployment_history
+const (
+	DeploymentKindDeploy   = "deploy"
+	DeploymentKindRollback = "rollback"
 )
 
 // DeploymentHistory stores history record of a deployment
@@ -147,12 +155,152 @@ type DeploymentHistory struct {
 	ReleasePath string           `db:"release_path"`
 	Status      DeploymentStatus `db:"status"`
 	LogOutput   string           `db:"log_output"`
-	Port        int              `db:"port"` // Added field
+	Port        int              `db:"port"`        // Added field
+	Kind        string           `db:"kind"`        // deploy|rollback
+	RolloutID   uuid.NullUUID    `db:"rollout_id"`  // Set when the deployment is part of a multi-host rollout
+	SigningKey  string           `db:"signing_key"` // Key that signed the deployed artifact
+	SignedBy    string           `db:"signed_by"`   // User or token the artifact was signed for
 	DeployedAt  NullableTime     `db:"deployed_at"`
 	CreatedAt   NullableTime     `db:"created_at"`
 	UpdatedAt   NullableTime     `db:"updated_at"`
 }
 
+// HealthCheckResult stores the outcome of a single readiness probe made during a deployment
+type HealthCheckResult struct {
+	ID           uuid.UUID    `db:"id"`
+	DeploymentID uuid.UUID    `db:"deployment_id"`
+	Attempt      int          `db:"attempt"`
+	Port         int          `db:"port"`
+	URL          string       `db:"url"`
+	StatusCode   int          `db:"status_code"` // 0 if no HTTP response was received
+	Passed       bool         `db:"passed"`
+	Message      string       `db:"message"`
+	DurationMs   int64        `db:"duration_ms"`
+	CreatedAt    NullableTime `db:"created_at"`
+}
+
+// Steps of a deployment through the API, in the order they run
+const (
+	DeployStepArtifact    = "artifact"
+	DeployStepUpload      = "upload"
+	DeployStepPermissions = "permissions"
+	DeployStepPreDeploy   = "pre_deploy"
+	DeployStepEnv         = "env"
+	DeployStepMigrate     = "migrate"
+	DeployStepStart       = "start"
+	DeployStepHealth      = "health"
+	DeployStepSwitch      = "switch"
+	DeployStepProcesses   = "processes"
+	DeployStepCron        = "cron"
+	DeployStepPostDeploy  = "post_deploy"
+	DeployStepCleanup     = "cleanup"
+)
+
+// DeploySteps lists the steps of a deployment through the API in the order they run
+var DeploySteps = []string{
+	DeployStepArtifact, DeployStepUpload, DeployStepPermissions, DeployStepPreDeploy, DeployStepEnv, DeployStepMigrate,
+	DeployStepStart, DeployStepHealth, DeployStepSwitch, DeployStepProcesses, DeployStepCron, DeployStepPostDeploy, DeployStepCleanup,
+}
+
+// Deployment step statuses recorded in deployment_steps
+const (
+	StepStatusRunning  = "running"
+	StepStatusSuccess  = "success"
+	StepStatusFailed   = "failed"
+	StepStatusReverted = "reverted" // Completed, then undone after a later step failed
+)
+
+// DeploymentStep records the outcome of one step of a deployment, so a failed deployment can be resumed
+type DeploymentStep struct {
+	DeploymentID uuid.UUID    `db:"deployment_id"`
+	Name         string       `db:"name"`
+	Position     int          `db:"position"`
+	Status       string       `db:"status"`
+	Detail       string       `db:"detail"` // What the step produced that later steps need, e.g. the release path
+	Error        string       `db:"error"`
+	StartedAt    NullableTime `db:"started_at"`
+	FinishedAt   NullableTime `db:"finished_at"`
+	DurationMs   int64        `db:"duration_ms"`
+}
+
+// Lock kinds recorded in instance_locks
+const (
+	LockKindDeploy   = "deploy"   // Held by a running deployment, expires unless renewed by heartbeats
+	LockKindRollback = "rollback" // Held by a running rollback
+	LockKindCanary   = "canary"   // Held while a canary is promoted or aborted
+	LockKindScale    = "scale"    // Held while instances are added or removed
+	LockKindManual   = "manual"   // Set with 'shipyard-cli lock', held until unlocked
+)
+
+// InstanceLock is a lease on an application instance that serializes deployments to it
+type InstanceLock struct {
+	ID           uuid.UUID     `db:"id"` // Identifies this holding of the lock, a lock taken over after its lease expired gets a new one
+	InstanceID   uuid.UUID     `db:"instance_id"`
+	Kind         string        `db:"kind"`
+	Owner        string        `db:"owner"`
+	Reason       string        `db:"reason"`
+	DeploymentID uuid.NullUUID `db:"deployment_id"`
+	AcquiredAt   NullableTime  `db:"acquired_at"`
+	ExpiresAt    NullableTime  `db:"expires_at"` // nil for manual locks, which never expire
+}
+
+// Expired reports whether the lease of the lock has run out at the given time.
+func (l *InstanceLock) Expired(now time.Time) bool {
+	return l.ExpiresAt.Time != nil && !l.ExpiresAt.Time.After(now)
+}
+
+// Rollout statuses recorded in rollouts
+const (
+	RolloutStatusRunning   = "running"
+	RolloutStatusSuccess   = "success"
+	RolloutStatusFailed    = "failed"    // Stopped at the first host that failed
+	RolloutStatusCancelled = "cancelled" // Interrupted from the CLI
+)
+
+// Rollout groups the deployments of one release to several hosts of an application
+type Rollout struct {
+	ID            uuid.UUID    `db:"id"`
+	ApplicationID uuid.UUID    `db:"application_id"`
+	Version       string       `db:"version"`
+	Hosts         string       `db:"hosts"` // Comma-separated host names in rollout order
+	Parallel      int          `db:"parallel"`
+	Status        string       `db:"status"`
+	CreatedBy     string       `db:"created_by"`
+	CreatedAt     NullableTime `db:"created_at"`
+	FinishedAt    NullableTime `db:"finished_at"`
+}
+
+// Canary statuses reported by the API
+const (
+	CanaryStatusRunning  = "running"
+	CanaryStatusPromoted = "promoted" // Serving all traffic, the previous release is kept as standby
+	CanaryStatusAborted  = "aborted"  // Stopped, all traffic is back on the stable release
+)
+
+// Release states reported by the API, derived from the runs started from a release directory
+const (
+	ReleaseStatusActive   = "active"   // Serving traffic
+	ReleaseStatusStandby  = "standby"  // Replaced by the last deployment, kept for a quick rollback
+	ReleaseStatusCanary   = "canary"   // Receiving a share of the traffic
+	ReleaseStatusRetained = "retained" // Kept on the host, available for rollback
+	ReleaseStatusFailed   = "failed"   // Never served traffic successfully
+	ReleaseStatusPruned   = "pruned"   // Deleted from the host by release retention
+)
+
+// InstanceCanary is a release of an application instance that receives a share of the traffic
+// next to the stable release, until it is promoted or aborted
+type InstanceCanary struct {
+	InstanceID   uuid.UUID    `db:"instance_id"`
+	DeploymentID uuid.UUID    `db:"deployment_id"`
+	StablePort   int          `db:"stable_port"`
+	CanaryPort   int          `db:"canary_port"`
+	CanaryWeight int          `db:"canary_weight"` // Percentage of requests sent to the canary
+	ReleasePath  string       `db:"release_path"`
+	GitCommitSHA string       `db:"git_commit_sha"`
+	StartedAt    NullableTime `db:"started_at"`
+	UpdatedAt    NullableTime `db:"updated_at"`
+}
+
 // Secret stores an encrypted sensitive variable
 type Secret struct {
 	ID            uuid.UUID    `db:"id"`
@@ -171,6 +319,30 @@ type BuildArtifact struct {
 	Version       string       `db:"version"`
 	MD5Hash       string       `db:"md5_hash"`
 	LocalPath     string       `db:"local_path"`
+	Stored        bool         `db:"stored"`      // Kept in the artifact registry of shipyard-server
+	SHA256        string       `db:"sha256"`      // Digest covered by the signature
+	Signature     string       `db:"signature"`   // ed25519 signature, see package signing
+	SigningKey    string       `db:"signing_key"` // Name of the server key that signed the artifact
+	SignedBy      string       `db:"signed_by"`   // User or application token that pushed or built the artifact
+	Compression   string       `db:"compression"` // gzip, zstd or none, see package compression
+	CreatedAt     NullableTime `db:"created_at"`
+}
+
+// SigningKey is an ed25519 key of shipyard-server that signs build artifacts
+type SigningKey struct {
+	ID         uuid.UUID    `db:"id"`
+	Name       string       `db:"name"`
+	PublicKey  string       `db:"public_key"`  // ed25519:<base64>
+	PrivateKey string       `db:"private_key"` // Encrypted seed
+	CreatedAt  NullableTime `db:"created_at"`
+}
+
+// TrustedKey is a public key an application accepts artifact signatures from
+type TrustedKey struct {
+	ID            uuid.UUID    `db:"id"`
+	ApplicationID uuid.UUID    `db:"application_id"`
+	Name          string       `db:"name"`
+	PublicKey     string       `db:"public_key"` // ed25519:<base64>
 	CreatedAt     NullableTime `db:"created_at"`
 }
 
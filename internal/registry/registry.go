@@ -0,0 +1,115 @@
+// Package registry keeps build artifacts in shipyard-server, content-addressed by their MD5,
+// so that any teammate or CI job can pull a build instead of relying on the CLI cache that made it.
+package registry
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"youfun/shipyard/internal/database"
+)
+
+// ErrNotFound is returned by a Store for a key it does not hold.
+var ErrNotFound = errors.New("artifact not found in the registry")
+
+// ErrChecksumMismatch is returned by Push for content that does not match the MD5 it is pushed under.
+var ErrChecksumMismatch = errors.New("artifact content does not match its MD5")
+
+// Store is the storage backend of the registry.
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any previous content.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens the content stored under key, or returns ErrNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content stored under key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Key is the content address of an artifact tarball in a Store.
+func Key(md5Hash string) string {
+	return path.Join(md5Hash[:2], md5Hash+".tar.gz")
+}
+
+// ValidMD5 reports whether s is a full hex encoded MD5, the only form accepted as a content address.
+func ValidMD5(s string) bool {
+	if len(s) != 2*md5.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Push stores an artifact tarball read from r under its MD5, after checking the content matches it.
+// It returns the hex encoded SHA-256 of the content, the digest artifact signatures cover.
+func Push(ctx context.Context, store Store, md5Hash string, r io.Reader) (string, error) {
+	if !ValidMD5(md5Hash) {
+		return "", fmt.Errorf("invalid MD5 %q", md5Hash)
+	}
+
+	// Spool the tarball first, nothing reaches the store before its MD5 is checked
+	tmp, err := os.CreateTemp("", "shipyard-artifact-*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash, sum := md5.New(), sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash, sum), r)
+	if err != nil {
+		return "", fmt.Errorf("failed to receive artifact: %w", err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != md5Hash {
+		return "", fmt.Errorf("%w: got %s, expected %s", ErrChecksumMismatch, actual, md5Hash)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if err := store.Put(ctx, Key(md5Hash), tmp, size); err != nil {
+		return "", fmt.Errorf("failed to store artifact: %w", err)
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultStore Store
+	defaultErr   error
+)
+
+// Default returns the store configured by the environment of shipyard-server, opened once.
+func Default() (Store, error) {
+	defaultOnce.Do(func() {
+		defaultStore, defaultErr = Open()
+	})
+	return defaultStore, defaultErr
+}
+
+// Open opens the store selected by ARTIFACT_STORE:
+//   - "fs" (default): files under ARTIFACT_DIR, ~/.shipyard/artifacts unless set
+//   - "s3": the bucket S3_BUCKET of an S3-compatible service such as MinIO, see NewS3StoreFromEnv
+func Open() (Store, error) {
+	switch kind := os.Getenv("ARTIFACT_STORE"); kind {
+	case "", "fs":
+		dir := os.Getenv("ARTIFACT_DIR")
+		if dir == "" {
+			var err error
+			if dir, err = database.GetArtifactDir(); err != nil {
+				return nil, err
+			}
+		}
+		return NewFSStore(dir)
+	case "s3":
+		return NewS3StoreFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown ARTIFACT_STORE %q, expected fs or s3", kind)
+	}
+}
@@ -0,0 +1,121 @@
+package registry
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func md5Hex(data string) string {
+	sum := md5.Sum([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	content := "release tarball"
+	md5Hash := md5Hex(content)
+
+	if _, err := Push(ctx, store, md5Hash, strings.NewReader("tampered")); err == nil {
+		t.Fatal("expected content that does not match its MD5 to be refused")
+	}
+	if _, err := store.Get(ctx, Key(md5Hash)); err != ErrNotFound {
+		t.Fatalf("expected nothing to be stored after a refused push, got %v", err)
+	}
+
+	sum, err := Push(ctx, store, md5Hash, strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+	if want := sha256.Sum256([]byte(content)); sum != hex.EncodeToString(want[:]) {
+		t.Errorf("Push() SHA-256 = %s, want %x", sum, want)
+	}
+	r, err := store.Get(ctx, Key(md5Hash))
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	got, _ := io.ReadAll(r)
+	r.Close()
+	if string(got) != content {
+		t.Errorf("Get() = %q, want %q", got, content)
+	}
+
+	if err := store.Delete(ctx, Key(md5Hash)); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := store.Get(ctx, Key(md5Hash)); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound after Delete(), got %v", err)
+	}
+	if err := store.Delete(ctx, Key(md5Hash)); err != nil {
+		t.Errorf("expected deleting a missing key to succeed, got %v", err)
+	}
+}
+
+func TestFSStore(t *testing.T) {
+	store, err := NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+}
+
+func TestS3Store(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/artifacts/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	testStore(t, &S3Store{
+		Endpoint:        server.URL,
+		Bucket:          "artifacts",
+		Region:          "us-east-1",
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio-secret",
+	})
+}
+
+func TestValidMD5(t *testing.T) {
+	for s, want := range map[string]bool{
+		md5Hex("x"):                      true,
+		"abc123":                         false,
+		"../../../../etc/passwd00000000": false,
+		strings.Repeat("z", 32):          false,
+	} {
+		if got := ValidMD5(s); got != want {
+			t.Errorf("ValidMD5(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
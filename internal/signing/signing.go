@@ -0,0 +1,128 @@
+// Package signing signs build artifacts with the ed25519 keys of shipyard-server and verifies them
+// against the public keys an application trusts, so that only artifacts made by the server's build
+// pipeline are deployed.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// publicKeyPrefix marks the algorithm of an encoded public key, e.g. "ed25519:7Yh0...".
+const publicKeyPrefix = "ed25519:"
+
+// ErrUnsigned is returned by Verify for an artifact without a signature.
+var ErrUnsigned = errors.New("artifact is not signed")
+
+// ErrUntrusted is returned by Verify for a signature no trusted key verifies.
+var ErrUntrusted = errors.New("artifact signature is not from a trusted key")
+
+// TrustedKey is a public key an application accepts artifact signatures from.
+type TrustedKey struct {
+	Name      string
+	PublicKey ed25519.PublicKey
+}
+
+// message is what gets signed: the SHA-256 of the tarball, bound to its application so that a
+// signed artifact of one application cannot be deployed as another.
+func message(appID, sha256Hex string) []byte {
+	return []byte("shipyard-artifact/v1\n" + appID + "\n" + sha256Hex)
+}
+
+// Sign signs the artifact of an application with the given SHA-256 and returns the base64 encoded signature.
+func Sign(key ed25519.PrivateKey, appID, sha256Hex string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, message(appID, sha256Hex)))
+}
+
+// Verify checks the signature of an artifact against the trusted keys and returns the name of the key
+// that verifies it.
+func Verify(keys []TrustedKey, appID, sha256Hex, signature string) (string, error) {
+	if signature == "" || sha256Hex == "" {
+		return "", ErrUnsigned
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "", fmt.Errorf("invalid artifact signature: %w", err)
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key.PublicKey, message(appID, sha256Hex), sig) {
+			return key.Name, nil
+		}
+	}
+	return "", ErrUntrusted
+}
+
+// VerifyFile checks that the tarball at path has the signed SHA-256 and that the signature verifies
+// against the trusted keys. It returns the name of the key that verifies it.
+func VerifyFile(keys []TrustedKey, appID, path, sha256Hex, signature string) (string, error) {
+	if signature == "" || sha256Hex == "" {
+		return "", ErrUnsigned
+	}
+	actual, err := FileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+	if actual != sha256Hex {
+		return "", fmt.Errorf("artifact does not match its signed SHA-256: got %s, expected %s", actual, sha256Hex)
+	}
+	return Verify(keys, appID, sha256Hex, signature)
+}
+
+// FileSHA256 returns the hex encoded SHA-256 of a file.
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to calculate SHA-256 for file %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// EncodePublicKey encodes a public key as "ed25519:<base64>", the form keys are configured in.
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return publicKeyPrefix + base64.StdEncoding.EncodeToString(key)
+}
+
+// ParsePublicKey parses a public key encoded by EncodePublicKey.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(s, publicKeyPrefix) {
+		return nil, fmt.Errorf("public key must start with %q", publicKeyPrefix)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, publicKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: expected %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// EncodePrivateKey encodes the seed of a private key as base64, the form it is stored in (encrypted).
+func EncodePrivateKey(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Seed())
+}
+
+// ParsePrivateKey parses a private key encoded by EncodePrivateKey.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid private key: expected %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
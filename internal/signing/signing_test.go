@@ -0,0 +1,84 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "release.tar.gz")
+	if err := os.WriteFile(path, []byte("release tarball"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := FileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := Sign(priv, "app-1", sum)
+	trusted := []TrustedKey{{Name: "other", PublicKey: otherPub}, {Name: "ci", PublicKey: pub}}
+
+	name, err := VerifyFile(trusted, "app-1", path, sum, sig)
+	if err != nil {
+		t.Fatalf("VerifyFile() failed: %v", err)
+	}
+	if name != "ci" {
+		t.Errorf("VerifyFile() key = %q, want ci", name)
+	}
+
+	if _, err := VerifyFile(trusted, "app-1", path, "", ""); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("expected ErrUnsigned for an unsigned artifact, got %v", err)
+	}
+	if _, err := Verify(trusted[:1], "app-1", sum, sig); !errors.Is(err, ErrUntrusted) {
+		t.Errorf("expected ErrUntrusted without the signing key, got %v", err)
+	}
+	if _, err := Verify(trusted, "app-2", sum, sig); !errors.Is(err, ErrUntrusted) {
+		t.Errorf("expected the signature of another application to be refused, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyFile(trusted, "app-1", path, sum, sig); err == nil {
+		t.Error("expected a tarball that does not match its signed SHA-256 to be refused")
+	}
+}
+
+func TestEncodeKeys(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsedPub, err := ParsePublicKey(EncodePublicKey(pub))
+	if err != nil {
+		t.Fatalf("ParsePublicKey() failed: %v", err)
+	}
+	if !parsedPub.Equal(pub) {
+		t.Error("public key does not round-trip")
+	}
+	parsedPriv, err := ParsePrivateKey(EncodePrivateKey(priv))
+	if err != nil {
+		t.Fatalf("ParsePrivateKey() failed: %v", err)
+	}
+	if !parsedPriv.Equal(priv) {
+		t.Error("private key does not round-trip")
+	}
+
+	for _, s := range []string{"", "rsa:AAAA", "ed25519:not-base64!", "ed25519:AAAA"} {
+		if _, err := ParsePublicKey(s); err == nil {
+			t.Errorf("expected ParsePublicKey(%q) to fail", s)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Writes the systemd template unit of a process type of an app, on every deployment.
+# Unlike init_runtime.sh it leaves the user, the directories and the env file of the app alone.
+APP="${APP:-chat_room}"
+USER="${USER:-phoenix}"
+RUNTIME="${RUNTIME:-phoenix}" # phoenix|elixir|node|golang|python|static|custom
+START_CMD="${START_CMD:-}" # Optional: Override start command ([run] command), required by custom
+PROCESS="${PROCESS:-web}" # Process type of [processes]; only web serves HTTP on the port of its instance
+NODE_HOST="${NODE_HOST:-}" # Optional: host of the Erlang node names of phoenix/elixir instances
+UNIT_DIR="${UNIT_DIR:-/etc/systemd/system}" # Directory of the systemd template units
+
+# Erlang distribution: long names for an address or FQDN, short names for a plain host name
+case "$NODE_HOST" in
+  *.*) DISTRIBUTION=name ;;
+  *) DISTRIBUTION=sname ;;
+esac
+CLUSTERED=$([ -n "$NODE_HOST" ] && { [ "$RUNTIME" = phoenix ] || [ "$RUNTIME" = elixir ]; } && echo 1 || echo 0)
+
+# START_CMD as it goes into ExecStart: quoted for sh -lc '...', $ escaped for systemd, \ escaped for sed
+UNIT_CMD=$(printf '%s' "$START_CMD" | sed -e "s/'/'\\\\''/g" -e 's/\$/$$/g' -e 's/\\/\\\\/g')
+HAS_START_CMD=$([ -n "$START_CMD" ] && echo 1 || echo 0)
+
+# Other process types (worker, scheduler, ...) get their own template unit, instance = index (1..count).
+# Their instances run the release linked at /var/www/$APP/processes/$PROCESS and listen on no port.
+if [ "$PROCESS" != web ]; then
+  if [ "$HAS_START_CMD" != 1 ]; then
+    echo "PROCESS=$PROCESS needs START_CMD ([processes] $PROCESS)" >&2; exit 1;
+  fi
+  UNIT_PATH="$UNIT_DIR/$APP-$PROCESS@.service"
+  cat > "$UNIT_PATH" <<'UNIT'
+[Unit]
+Description=%APP% %PROCESS% %i
+After=network.target
+PartOf=%APP%.target
+
+[Service]
+User=%USER%
+Group=%USER%
+WorkingDirectory=/var/www/%APP%/processes/%PROCESS%
+EnvironmentFile=/etc/%APP%/env
+Environment=PROCESS=%PROCESS%
+Environment=PROCESS_INDEX=%i
+Restart=always
+RestartSec=5s
+Type=simple
+LimitNOFILE=65536
+StandardOutput=journal
+StandardError=journal
+SyslogIdentifier=%APP%-%PROCESS%-%i
+
+# ExecStart/ExecStop will be replaced below based on RUNTIME
+
+[Install]
+WantedBy=multi-user.target
+UNIT
+  PRELUDE=""
+  if [ "$RUNTIME" = python ]; then
+    PRELUDE="export VIRTUAL_ENV=\"\$\$PWD/venv\" PATH=\"\$\$PWD/venv/bin:\$\$PATH\"; "
+  fi
+  sed -i "/# ExecStart\/ExecStop/a ExecStart=/bin/sh -lc '${PRELUDE}exec $UNIT_CMD'" "$UNIT_PATH"
+  if [ "$CLUSTERED" = 1 ]; then
+    sed -i "/^Environment=PROCESS_INDEX=%i/a Environment=RELEASE_DISTRIBUTION=$DISTRIBUTION\nEnvironment=RELEASE_NODE=%APP%-%PROCESS%-%i@$NODE_HOST" "$UNIT_PATH"
+  fi
+  sed -i "s/%APP%/$APP/g; s/%USER%/$USER/g; s/%PROCESS%/$PROCESS/g" "$UNIT_PATH"
+  systemctl daemon-reload
+  echo "Initialized runtime=$RUNTIME process=$PROCESS unit=$UNIT_PATH"
+  exit 0
+fi
+
+# 1) Generate systemd template unit
+UNIT_PATH="$UNIT_DIR/$APP@.service"
+cat > "$UNIT_PATH" <<'UNIT'
+[Unit]
+Description=%APP% instance %i
+After=network.target
+PartOf=%APP%.target
+
+[Service]
+User=%USER%
+Group=%USER%
+WorkingDirectory=/var/www/%APP%/instances/%i
+EnvironmentFile=/etc/%APP%/env
+Environment=PORT=%i
+Environment=PHX_SERVER=true
+Restart=always
+RestartSec=5s
+Type=simple
+LimitNOFILE=65536
+StandardOutput=journal
+StandardError=journal
+SyslogIdentifier=%APP%-%i
+
+# ExecStart/ExecStop will be replaced below based on RUNTIME
+
+[Install]
+WantedBy=multi-user.target
+UNIT
+
+# 2) Inject ExecStart/ExecStop based on runtime
+case "$RUNTIME" in
+  phoenix)
+    # Phoenix releases have a 'foreground' command that runs in foreground
+    sed -i "/# ExecStart\/ExecStop/a ExecStart=/bin/sh -lc 'if [ $HAS_START_CMD = 1 ]; then exec $UNIT_CMD; elif [ -x ./bin/server ]; then exec ./bin/server; else exec ./bin/$APP foreground; fi'\nExecStop=/var/www/$APP/instances/%i/bin/$APP stop" "$UNIT_PATH"
+    ;;
+  elixir)
+    # Plain Elixir releases use 'start' which runs in foreground when called directly
+    sed -i "/# ExecStart\/ExecStop/a ExecStart=/bin/sh -lc 'if [ $HAS_START_CMD = 1 ]; then exec $UNIT_CMD; elif [ -x ./bin/server ]; then exec ./bin/server; else exec ./bin/$APP start; fi'\nExecStop=/var/www/$APP/instances/%i/bin/$APP stop" "$UNIT_PATH"
+    ;;
+  node)
+    sed -i "/# ExecStart\/ExecStop/a ExecStart=/bin/sh -lc 'if [ $HAS_START_CMD = 1 ]; then exec $UNIT_CMD; elif command -v pnpm >/dev/null 2>&1 && [ -f package.json ]; then exec pnpm start; elif command -v yarn >/dev/null 2>&1 && [ -f package.json ]; then exec yarn start; elif command -v npm >/dev/null 2>&1 && [ -f package.json ]; then exec npm start -- --port=\$\$PORT; elif [ -f server.js ]; then exec node server.js; else echo \"No start command found (set START_CMD or provide scripts.start/server.js)\"; exit 1; fi" "$UNIT_PATH"
+    ;;
+  golang)
+    sed -i "/# ExecStart\/ExecStop/a ExecStart=/bin/sh -lc 'if [ $HAS_START_CMD = 1 ]; then exec $UNIT_CMD; elif [ -x ./bin/server ]; then exec ./bin/server -port \$\$PORT; elif [ -x ./bin/$APP ]; then exec ./bin/$APP -port \$\$PORT; elif [ -x ./$APP ]; then exec ./$APP -port \$\$PORT; else echo \"No Go binary found (set START_CMD or provide ./bin/server|./bin/$APP|./$APP)\"; exit 1; fi" "$UNIT_PATH"
+    ;;
+  python)
+    # The release vendors its dependencies in venv/ (Dockerfile.build.python), which comes first in PATH
+    # APP_MODULE (env file) names the app, e.g. main:app for uvicorn or mysite.wsgi:application for gunicorn
+    # Shell variables are escaped as $$ for systemd, %i is the port of the instance
+    sed -i "/# ExecStart\/ExecStop/a ExecStart=/bin/sh -lc 'export VIRTUAL_ENV=\"\$\$PWD/venv\" PATH=\"\$\$PWD/venv/bin:\$\$PATH\"; if [ $HAS_START_CMD = 1 ]; then exec $UNIT_CMD; elif [ -x ./venv/bin/uvicorn ]; then exec python -m uvicorn \"\$\${APP_MODULE:-main:app}\" --host 0.0.0.0 --port %i; elif [ -x ./venv/bin/gunicorn ]; then exec python -m gunicorn --bind 0.0.0.0:%i \"\$\${APP_MODULE:-wsgi:application}\"; else echo \"No ASGI/WSGI server found in venv (set START_CMD or add uvicorn or gunicorn to the dependencies)\"; exit 1; fi'" "$UNIT_PATH"
+    ;;
+  static)
+    # Static sites use a single Go-compiled binary server
+    # Multi-stage Docker build has embedded static files into the Go binary
+    # VPS needs no Node.js, Go, or Docker, just run the binary
+    sed -i "/# ExecStart\/ExecStop/a ExecStart=/bin/sh -lc 'if [ $HAS_START_CMD = 1 ]; then exec $UNIT_CMD; elif [ -x ./server ]; then exec ./server; else echo \"No static server binary found (expected ./server)\"; exit 1; fi" "$UNIT_PATH"
+    ;;
+  custom)
+    # The start command of [run] command in shipyard.toml, rendered again on every deployment
+    if [ "$HAS_START_CMD" != 1 ]; then
+      echo "RUNTIME=custom needs START_CMD ([run] command)" >&2; exit 1;
+    fi
+    sed -i "/# ExecStart\/ExecStop/a ExecStart=/bin/sh -lc 'exec $UNIT_CMD'" "$UNIT_PATH"
+    ;;
+  *)
+    echo "Unsupported RUNTIME: $RUNTIME" >&2; exit 1;
+    ;;
+ esac
+
+# Every instance is its own Erlang node, <app>-<port>@<host>, so both colours and scaled instances can
+# run side by side and join one cluster with RELEASE_COOKIE; the env file overrides both variables
+if [ "$CLUSTERED" = 1 ]; then
+  sed -i "/^Environment=PHX_SERVER=true/a Environment=RELEASE_DISTRIBUTION=$DISTRIBUTION\nEnvironment=RELEASE_NODE=%APP%-%i@$NODE_HOST" "$UNIT_PATH"
+fi
+
+# 3) Replace template variables
+sed -i "s/%APP%/$APP/g" "$UNIT_PATH"
+sed -i "s/%USER%/$USER/g" "$UNIT_PATH"
+
+# The unit stays Type=simple: START_CMD comes from shipyard.toml and must run in the foreground,
+# like the 'start' command of a release does
+
+# 4) Apply configuration
+systemctl daemon-reload
+# Optional: systemctl enable $APP@.service (for instances use systemctl enable $APP@PORT)
+
+echo "Initialized runtime=$RUNTIME unit=$UNIT_PATH"
@@ -8,12 +8,34 @@ var DockerfileBuildPhoenix string
 //go:embed Dockerfile.build.elixir
 var DockerfileBuildElixir string
 
+// DockerfileBuildNode builds a Node.js app with its production dependencies.
+//
+//go:embed Dockerfile.build.node
+var DockerfileBuildNode string
+
+// DockerfileBuildGolang builds a Go app into a single static binary, bin/server.
+//
+//go:embed Dockerfile.build.golang
+var DockerfileBuildGolang string
+
+// DockerfileBuildPython builds a Python app with its dependencies vendored in a virtualenv, venv/.
+//
+//go:embed Dockerfile.build.python
+var DockerfileBuildPython string
+
 //go:embed install_caddy_github.sh
 var InstallCaddyScript string
 
+// InitRuntimeScript prepares the user, the directories and the env file of an app on a host.
+//
 //go:embed init_runtime.sh
 var InitRuntimeScript string
 
+// RenderUnitScript writes the systemd template unit of a process type of an app.
+//
+//go:embed render_unit.sh
+var RenderUnitScript string
+
 // DockerfileStatic is a multi-stage Dockerfile for static sites that require frontend build.
 // Uses Node.js to build frontend, and Go to compile into a single binary.
 //
@@ -40,6 +40,7 @@ type ApplicationInstanceDTO struct {
 	Status             string `json:"status,omitempty"`
 	ActivePort         int64  `json:"active_port,omitempty"`
 	PreviousActivePort int64  `json:"previous_active_port,omitempty"`
+	ActivePorts        []int  `json:"active_ports,omitempty"` // Ports of all instances serving traffic, ActivePort among them
 }
 
 // DeploymentHistoryDTO represents deployment history for API transfer
@@ -52,6 +53,8 @@ type DeploymentHistoryDTO struct {
 	Status      string     `json:"status"`
 	LogOutput   string     `json:"log_output,omitempty"`
 	HostName    string     `json:"host_name,omitempty"`
+	SigningKey  string     `json:"signing_key,omitempty"` // Key that signed the deployed artifact
+	SignedBy    string     `json:"signed_by,omitempty"`   // User or token the artifact was signed for
 	DeployedAt  *time.Time `json:"deployed_at,omitempty"`
 	CreatedAt   *time.Time `json:"created_at,omitempty"`
 	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
@@ -64,13 +67,48 @@ type SecretDTO struct {
 
 // BuildArtifactDTO represents build artifact metadata for API transfer
 type BuildArtifactDTO struct {
-	ID            string     `json:"id,omitempty"`
-	ApplicationID string     `json:"application_id,omitempty"`
-	GitCommitSHA  string     `json:"git_commit_sha,omitempty"`
-	Version       string     `json:"version"`
-	MD5Hash       string     `json:"md5_hash"`
-	LocalPath     string     `json:"local_path,omitempty"`
-	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	ID             string     `json:"id,omitempty"`
+	ApplicationID  string     `json:"application_id,omitempty"`
+	GitCommitSHA   string     `json:"git_commit_sha,omitempty"`
+	Version        string     `json:"version"`
+	MD5Hash        string     `json:"md5_hash"`
+	LocalPath      string     `json:"local_path,omitempty"`
+	Stored         bool       `json:"stored,omitempty"` // Kept in the artifact registry of shipyard-server
+	SHA256         string     `json:"sha256,omitempty"`
+	Signature      string     `json:"signature,omitempty"`   // ed25519 signature made by shipyard-server
+	SigningKey     string     `json:"signing_key,omitempty"` // Name of the server key that signed the artifact
+	SignedBy       string     `json:"signed_by,omitempty"`   // User or application token the artifact was signed for
+	Compression    string     `json:"compression,omitempty"` // gzip (default), zstd or none
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+	LastDeployedAt *time.Time `json:"last_deployed_at,omitempty"` // Last successful deployment of the build
+}
+
+// ServerBuildRequest asks shipyard-server to build uploaded sources, itself or on a builder host.
+type ServerBuildRequest struct {
+	AppName      string `json:"app"`
+	Runtime      string `json:"runtime,omitempty"`
+	Version      string `json:"version"`
+	GitCommitSHA string `json:"git_commit_sha,omitempty"`
+	Location     string `json:"location"`       // server|host
+	Host         string `json:"host,omitempty"` // Builder host for location "host"
+	Builder      string `json:"builder,omitempty"`
+	GOOS         string `json:"goos,omitempty"`
+	GOARCH       string `json:"goarch,omitempty"`
+	Main         string `json:"main,omitempty"`
+	Dir          string `json:"dir,omitempty"`
+	Compression  string `json:"compression,omitempty"`
+	Command      string `json:"command,omitempty"`    // Build command of the command builder
+	OutputDir    string `json:"output_dir,omitempty"` // Directory packed by the command builder
+
+	KeepArtifacts int `json:"keep_artifacts,omitempty"` // Builds of the app kept in the registry, server default if 0
+}
+
+// ServerBuildMessage is one line of the NDJSON stream answering a build on shipyard-server:
+// build output as it comes, then the registered artifact or the error that ended the build.
+type ServerBuildMessage struct {
+	Output   string            `json:"output,omitempty"`
+	Artifact *BuildArtifactDTO `json:"artifact,omitempty"`
+	Error    string            `json:"error,omitempty"`
 }
 
 // DeployConfigResponse is the aggregated config returned by the server for CLI deployment
@@ -81,19 +119,32 @@ type DeployConfigResponse struct {
 	Instance     ApplicationInstanceDTO `json:"instance"`
 	Secrets      map[string]string      `json:"secrets,omitempty"`
 	Domains      []string               `json:"domains,omitempty"`
+	TrustedKeys  []TrustedKeyDTO        `json:"trusted_keys,omitempty"`  // Keys artifacts must be signed with
+	ClusterNodes []string               `json:"cluster_nodes,omitempty"` // Erlang nodes of the app serving traffic on all hosts
+	LinkedHosts  []string               `json:"linked_hosts,omitempty"`  // Names of the hosts the app is linked to, sorted
+}
+
+// TrustedKeyDTO is a public key an application accepts artifact signatures from
+type TrustedKeyDTO struct {
+	UID       string `json:"uid,omitempty"`
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"` // ed25519:<base64>
+	CreatedAt string `json:"created_at,omitempty"`
 }
 
 // CreateDeploymentRequest is the request to create a new deployment
 type CreateDeploymentRequest struct {
-	AppName  string `json:"app_name"`
-	HostName string `json:"host_name"`
-	Version  string `json:"version,omitempty"`
+	AppName   string `json:"app_name"`
+	HostName  string `json:"host_name"`
+	Version   string `json:"version,omitempty"`
+	RolloutID string `json:"rollout_id,omitempty"` // Groups the deployment under a multi-host rollout
 }
 
 // UpdateDeploymentStatusRequest is the request to update deployment status
 type UpdateDeploymentStatusRequest struct {
 	Status       string `json:"status"`
 	Port         int    `json:"port,omitempty"`
+	Ports        []int  `json:"ports,omitempty"` // All ports of the release when it runs several instances, Port is the first
 	ReleasePath  string `json:"release_path,omitempty"`
 	GitCommitSHA string `json:"git_commit_sha,omitempty"`
 }
@@ -103,6 +154,176 @@ type UploadDeploymentLogsRequest struct {
 	Logs string `json:"logs"`
 }
 
+// HealthCheckResultDTO represents a single readiness probe result for API transfer
+type HealthCheckResultDTO struct {
+	Attempt    int        `json:"attempt"`
+	Port       int        `json:"port"`
+	URL        string     `json:"url"`
+	StatusCode int        `json:"status_code"`
+	Passed     bool       `json:"passed"`
+	Message    string     `json:"message,omitempty"`
+	DurationMs int64      `json:"duration_ms"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+}
+
+// DeploymentStepDTO represents the state of one step of a deployment for API transfer
+type DeploymentStepDTO struct {
+	Name       string     `json:"name"`
+	Position   int        `json:"position"`
+	Status     string     `json:"status"`
+	Detail     string     `json:"detail,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	DurationMs int64      `json:"duration_ms"`
+}
+
+// ResumeDeploymentResponse is returned when a failed deployment is taken up again
+type ResumeDeploymentResponse struct {
+	DeploymentID string              `json:"deployment_id"`
+	AppName      string              `json:"app_name"`
+	HostName     string              `json:"host_name"`
+	Version      string              `json:"version"`
+	Steps        []DeploymentStepDTO `json:"steps"`
+}
+
+// UploadHealthChecksRequest is the request to upload readiness probe results of a deployment
+type UploadHealthChecksRequest struct {
+	Results []HealthCheckResultDTO `json:"results"`
+}
+
+// HealthCheckConfigDTO carries the [health_check] settings of shipyard.toml for server-side operations
+type HealthCheckConfigDTO struct {
+	Path           string `json:"path,omitempty"`
+	ExpectedStatus []int  `json:"expected_status,omitempty"`
+	BodyContains   string `json:"body_contains,omitempty"`
+	IntervalMs     int64  `json:"interval_ms,omitempty"`
+	TimeoutMs      int64  `json:"timeout_ms,omitempty"`
+	GracePeriodMs  int64  `json:"grace_period_ms,omitempty"`
+	Retries        int    `json:"retries,omitempty"`
+}
+
+// RollbackRequest is the request to roll an application instance back to a retained release
+type RollbackRequest struct {
+	To          string                `json:"to,omitempty"` // deployment ID or version, empty for the standby release
+	HealthCheck *HealthCheckConfigDTO `json:"health_check,omitempty"`
+}
+
+// RollbackResponse describes a completed rollback
+type RollbackResponse struct {
+	DeploymentID string `json:"deployment_id"`
+	Version      string `json:"version"`
+	ReleasePath  string `json:"release_path"`
+	Port         int    `json:"port"`
+	PreviousPort int    `json:"previous_port"`
+}
+
+// ScaleRequest is the request to run a number of instances of the active release of an application instance
+type ScaleRequest struct {
+	Count       int                   `json:"count" binding:"required"`
+	HealthCheck *HealthCheckConfigDTO `json:"health_check,omitempty"`
+}
+
+// ScaleResponse describes the instances of an application instance after scaling
+type ScaleResponse struct {
+	Version     string `json:"version"`
+	ReleasePath string `json:"release_path"`
+	Ports       []int  `json:"ports"`             // Ports serving traffic, the active port first
+	Started     []int  `json:"started,omitempty"` // Ports of the instances started
+	Stopped     []int  `json:"stopped,omitempty"` // Ports of the instances stopped
+}
+
+// InstanceLockDTO describes who holds the deploy lock of an application instance
+type InstanceLockDTO struct {
+	Kind         string     `json:"kind"` // deploy, rollback or manual
+	Owner        string     `json:"owner"`
+	Reason       string     `json:"reason,omitempty"`
+	DeploymentID string     `json:"deployment_id,omitempty"`
+	AcquiredAt   *time.Time `json:"acquired_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"` // Unset for manual locks
+}
+
+// LockInstanceRequest is the request to freeze deployments to an application instance
+type LockInstanceRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// CanaryDTO describes how the traffic of an application instance is split between
+// the stable release and a canary release
+type CanaryDTO struct {
+	DeploymentID string     `json:"deployment_id"`
+	Status       string     `json:"status"` // running, promoted or aborted
+	StablePort   int        `json:"stable_port"`
+	StableWeight int        `json:"stable_weight"`
+	CanaryPort   int        `json:"canary_port"`
+	CanaryWeight int        `json:"canary_weight"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+}
+
+// StartCanaryRequest records that a deployment serves a share of the traffic next to the stable release
+type StartCanaryRequest struct {
+	Port         int    `json:"port" binding:"required"`
+	Weight       int    `json:"weight" binding:"required"` // Percentage of requests sent to the canary
+	ReleasePath  string `json:"release_path" binding:"required"`
+	GitCommitSHA string `json:"git_commit_sha,omitempty"`
+}
+
+// PromoteCanaryRequest is the request to send more traffic to a canary
+type PromoteCanaryRequest struct {
+	Weight int `json:"weight,omitempty"` // New share of the canary, the next step when unset; 100 completes the release
+}
+
+// ReleaseDTO is a release directory of an application instance on its host
+type ReleaseDTO struct {
+	Name         string     `json:"name"` // Directory name, e.g. 1.2.0-1714550000
+	Version      string     `json:"version"`
+	Path         string     `json:"path"`
+	Status       string     `json:"status"` // active, standby, canary, retained, failed or pruned
+	Pinned       bool       `json:"pinned"`
+	Port         int        `json:"port"` // Port of the last run started from the release
+	GitCommitSHA string     `json:"git_commit_sha,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+}
+
+// PruneReleasesRequest is the request to delete old releases of an application instance from its host
+type PruneReleasesRequest struct {
+	Keep   *int `json:"keep,omitempty"` // Releases kept besides the active, standby and pinned ones, default 3
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// PruneReleasesResponse lists the releases deleted by a prune
+type PruneReleasesResponse struct {
+	Pruned []ReleaseDTO `json:"pruned"`
+	DryRun bool         `json:"dry_run"`
+}
+
+// CreateRolloutRequest is the request to start a rollout of an application to several hosts
+type CreateRolloutRequest struct {
+	AppName  string   `json:"app_name" binding:"required"`
+	Version  string   `json:"version,omitempty"`
+	Hosts    []string `json:"hosts" binding:"required"`
+	Parallel int      `json:"parallel,omitempty"`
+}
+
+// UpdateRolloutStatusRequest is the request to record the final status of a rollout
+type UpdateRolloutStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+// RolloutDTO describes a rollout and the deployments it is made of
+type RolloutDTO struct {
+	ID          string                 `json:"id"`
+	Version     string                 `json:"version,omitempty"`
+	Hosts       []string               `json:"hosts"`
+	Parallel    int                    `json:"parallel"`
+	Status      string                 `json:"status"`
+	CreatedBy   string                 `json:"created_by,omitempty"`
+	CreatedAt   *time.Time             `json:"created_at,omitempty"`
+	FinishedAt  *time.Time             `json:"finished_at,omitempty"`
+	Deployments []DeploymentHistoryDTO `json:"deployments,omitempty"`
+}
+
 // LinkAppRequest is the request to link an application to a host
 type LinkAppRequest struct {
 	AppName  string `json:"app_name"`
@@ -48,13 +48,21 @@ export function DeploymentsTab(props: { deployments: DeploymentHistory[]; isLoad
               <For each={props.deployments}>
                 {(deployment) => (
                   <tr class="hover">
-                    <td>{deployment.version}</td>
+                    <td>
+                      {deployment.version}
+                      <Show when={deployment.rollout_uid}>
+                        <span class="badge badge-outline badge-sm ml-2" title={deployment.rollout_uid}>
+                          {deployment.rollout_uid}
+                        </span>
+                      </Show>
+                    </td>
                     <td>
                       <span classList={{
                         'badge': true,
                         'badge-success': deployment.status === 'success',
                         'badge-error': deployment.status === 'failed',
                         'badge-warning': deployment.status === 'pending',
+                        'badge-ghost': deployment.status === 'cancelled',
                       }}>
                         {deployment.status}
                       </span>
@@ -116,7 +116,14 @@ export function OverviewTab(props: { app: Application | undefined }): JSX.Elemen
                           {instance.status}
                         </span>
                       </td>
-                      <td>{instance.active_port}</td>
+                      <td>
+                        {instance.active_port}
+                        <Show when={instance.canary}>
+                          <span class="badge badge-outline badge-sm ml-2" title={instance.canary?.deployment_id}>
+                            {`${instance.canary?.canary_port} @ ${instance.canary?.canary_weight}%`}
+                          </span>
+                        </Show>
+                      </td>
                       <td class="flex gap-2">
                         <Show when={instance.status !== 'running' && instance.status !== 'linked'}>
                           <button
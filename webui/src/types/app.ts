@@ -32,6 +32,7 @@ export interface DeploymentHistory {
   port: number
   created_at: string
   output?: string
+  rollout_uid?: string
 }
 
 export interface ApplicationInstance {
@@ -41,6 +42,16 @@ export interface ApplicationInstance {
   host_addr: string
   status: string
   active_port: number
+  canary?: InstanceCanary
+}
+
+export interface InstanceCanary {
+  deployment_id: string
+  status: string
+  stable_port: number
+  stable_weight: number
+  canary_port: number
+  canary_weight: number
 }
 
 export interface Secret {